@@ -0,0 +1,72 @@
+// Package auth guarda la clave con la que se firman y validan los JWT del
+// servicio. El login firma con SigningKey y el middleware valida con la misma
+// clave, asi las dos puntas no pueden quedar desincronizadas.
+package auth
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// LegacySecret es la clave que el servicio tenia fija en el codigo. Se sigue
+// usando si no se configura JWT_SECRET para no invalidar los tokens emitidos.
+const LegacySecret = "bitsion"
+
+// ErrNoSigningKey indica que no hay una clave para firmar tokens.
+var ErrNoSigningKey = errors.New("jwt signing key not loaded")
+
+var (
+	mu       sync.RWMutex
+	key      = []byte(LegacySecret)
+	legacy   = true
+	required bool
+)
+
+// LoadSigningKey reemplaza la clave de firma. Un secreto vacio deja la clave
+// anterior y devuelve false, para que quien llama avise que sigue con la
+// clave por defecto.
+func LoadSigningKey(secret string) bool {
+	if secret == "" {
+		return false
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	key = []byte(secret)
+	legacy = false
+	return true
+}
+
+// SigningKey devuelve la clave actual para firmar o validar un JWT.
+func SigningKey() []byte {
+	mu.RLock()
+	defer mu.RUnlock()
+	return key
+}
+
+// UsingLegacySecret informa si se sigue firmando con LegacySecret.
+func UsingLegacySecret() bool {
+	mu.RLock()
+	defer mu.RUnlock()
+	return legacy
+}
+
+// RequireConfiguredKey hace que Check falle mientras se firme con
+// LegacySecret. Se usa fuera de desarrollo, donde la clave del codigo no es
+// un secreto.
+func RequireConfiguredKey(require bool) {
+	mu.Lock()
+	defer mu.Unlock()
+	required = require
+}
+
+// Check falla si no hay clave de firma, o si solo esta LegacySecret y se
+// pidio una configurada; lo usa el chequeo de readiness.
+func Check(context.Context) error {
+	mu.RLock()
+	defer mu.RUnlock()
+	if len(key) == 0 || (required && legacy) {
+		return ErrNoSigningKey
+	}
+	return nil
+}
@@ -0,0 +1,42 @@
+package auth
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadSigningKey(t *testing.T) {
+	defer func() { key, legacy = []byte(LegacySecret), true }()
+
+	assert.True(t, UsingLegacySecret())
+	assert.Equal(t, []byte(LegacySecret), SigningKey())
+
+	assert.False(t, LoadSigningKey(""))
+	assert.True(t, UsingLegacySecret())
+
+	assert.True(t, LoadSigningKey("otra-clave"))
+	assert.False(t, UsingLegacySecret())
+	assert.Equal(t, []byte("otra-clave"), SigningKey())
+	assert.NoError(t, Check(context.Background()))
+}
+
+func TestCheck_RequiresConfiguredKey(t *testing.T) {
+	defer func() { key, legacy, required = []byte(LegacySecret), true, false }()
+
+	assert.NoError(t, Check(context.Background()))
+
+	RequireConfiguredKey(true)
+	assert.ErrorIs(t, Check(context.Background()), ErrNoSigningKey)
+
+	LoadSigningKey("otra-clave")
+	assert.NoError(t, Check(context.Background()))
+}
+
+func TestCheck_FailsWithoutKey(t *testing.T) {
+	defer func() { key, legacy = []byte(LegacySecret), true }()
+	key = nil
+
+	assert.ErrorIs(t, Check(context.Background()), ErrNoSigningKey)
+}
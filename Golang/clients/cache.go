@@ -0,0 +1,258 @@
+package clientUsers
+
+import (
+	"Golang/logging"
+	Model "Golang/model"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+const (
+	CacheNone   = "none"
+	CacheMemory = "memory"
+	CacheRedis  = "redis"
+
+	defaultCacheTTL  = time.Minute
+	defaultCacheSize = 1024
+)
+
+// Cache es el almacenamiento clave/valor que usa Cached. Un error de la
+// cache nunca es fatal: Cached lo registra y sigue contra el repositorio.
+type Cache interface {
+	Get(key string) ([]byte, bool, error)
+	Set(key string, value []byte, ttl time.Duration) error
+	Delete(keys ...string) error
+}
+
+// CacheConfig selecciona la cache que va delante del repositorio.
+type CacheConfig struct {
+	// Driver es "none" (o vacio), "memory" o "redis".
+	Driver string
+	TTL    time.Duration
+	// Size es la cantidad maxima de entradas de la cache en memoria.
+	Size int
+	// Addr, Password, DB y TLS solo aplican a Redis.
+	Addr     string
+	Password string
+	DB       int
+	// TLS cifra la conexion con Redis; nil la deja en texto plano.
+	TLS *tls.Config
+}
+
+// NewCache construye la cache indicada por config.Driver. Devuelve nil si
+// la cache esta desactivada.
+func NewCache(config CacheConfig) (Cache, error) {
+	switch strings.ToLower(strings.TrimSpace(config.Driver)) {
+	case "", CacheNone:
+		return nil, nil
+	case CacheMemory, "lru":
+		size := config.Size
+		if size <= 0 {
+			size = defaultCacheSize
+		}
+		return NewLRU(size), nil
+	case CacheRedis:
+		return NewRedis(config)
+	default:
+		return nil, fmt.Errorf("unknown cache driver %q", config.Driver)
+	}
+}
+
+// CacheStats cuenta los aciertos y fallos de GetUserById. Errors son las
+// operaciones contra la cache que fallaron y se resolvieron en la base.
+type CacheStats struct {
+	Hits   uint64
+	Misses uint64
+	Errors uint64
+}
+
+// Cached es un Repository que guarda en cache las lecturas por id y las
+// invalida en cada escritura. El resto de los metodos pasan directo al
+// repositorio envuelto.
+//
+// Las lecturas concurrentes de un mismo id que no estan en cache se resuelven
+// con una sola consulta. Una lectura que empezo antes de una escritura no
+// guarda su resultado, asi que esta instancia nunca vuelve a servir un valor
+// reemplazado; otras instancias que comparten Redis pueden verlo como mucho
+// hasta que venza el TTL.
+//
+// Las entradas no llevan el hash de la contraseña, asi que un acierto
+// devuelve Password vacio.
+type Cached struct {
+	Repository
+	cache Cache
+	ttl   time.Duration
+
+	group singleflight.Group
+	// fill se toma en lectura para guardar en cache y en escritura para
+	// invalidar, de modo que epoch no cambia mientras se guarda un valor.
+	fill  sync.RWMutex
+	epoch atomic.Uint64
+
+	hits   atomic.Uint64
+	misses atomic.Uint64
+	errors atomic.Uint64
+}
+
+// NewCached envuelve next con cache. Si ttl es cero se usa un minuto.
+func NewCached(next Repository, cache Cache, ttl time.Duration) *Cached {
+	if ttl <= 0 {
+		ttl = defaultCacheTTL
+	}
+	return &Cached{
+		Repository: next,
+		cache:      cache,
+		ttl:        ttl,
+	}
+}
+
+// Stats devuelve los contadores acumulados desde que se creo la cache.
+func (repository *Cached) Stats() CacheStats {
+	return CacheStats{
+		Hits:   repository.hits.Load(),
+		Misses: repository.misses.Load(),
+		Errors: repository.errors.Load(),
+	}
+}
+
+func (repository *Cached) GetUserById(ctx context.Context, Id int) (Model.User, error) {
+	key := userCacheKey(Id)
+	if user, ok := repository.lookup(ctx, key); ok {
+		repository.hits.Add(1)
+		return user, nil
+	}
+	repository.misses.Add(1)
+
+	// La epoca forma parte de la clave: quien llega despues de una escritura
+	// no se suma a una consulta que empezo antes.
+	epoch := repository.epoch.Load()
+	flight := key + "@" + strconv.FormatUint(epoch, 10)
+	value, err, _ := repository.group.Do(flight, func() (interface{}, error) {
+		// La consulta compartida no se corta si se cancela el pedido que la
+		// inicio: otros pueden estar esperando su resultado.
+		user, err := repository.Repository.GetUserById(context.WithoutCancel(ctx), Id)
+		if err != nil {
+			return user, err
+		}
+		repository.store(ctx, key, user, epoch)
+		return user, nil
+	})
+
+	return value.(Model.User), err
+}
+
+func (repository *Cached) InsertUser(ctx context.Context, user Model.User) (Model.User, error) {
+	created, err := repository.Repository.InsertUser(ctx, user)
+	if err == nil {
+		repository.invalidate(ctx, created.Id)
+	}
+	return created, err
+}
+
+func (repository *Cached) InsertUsers(ctx context.Context, users []Model.User) ([]Model.User, error) {
+	created, err := repository.Repository.InsertUsers(ctx, users)
+	for _, user := range created {
+		repository.invalidate(ctx, user.Id)
+	}
+	return created, err
+}
+
+func (repository *Cached) InsertSealed(ctx context.Context, users []Model.User, seal func(*Model.User) error) ([]Model.User, error) {
+	created, err := repository.Repository.InsertSealed(ctx, users, seal)
+	for _, user := range created {
+		repository.invalidate(ctx, user.Id)
+	}
+	return created, err
+}
+
+// UpdateUser invalida aun si la escritura falla: un ErrPreconditionFailed
+// indica que lo que habia en cache ya estaba viejo.
+func (repository *Cached) UpdateUser(ctx context.Context, User Model.User) (Model.User, error) {
+	updated, err := repository.Repository.UpdateUser(ctx, User)
+	repository.invalidate(ctx, User.Id)
+	return updated, err
+}
+
+func (repository *Cached) PatchUser(ctx context.Context, Id int, version int, fields map[string]interface{}) (Model.User, error) {
+	patched, err := repository.Repository.PatchUser(ctx, Id, version, fields)
+	repository.invalidate(ctx, Id)
+	return patched, err
+}
+
+func (repository *Cached) RewriteColumns(ctx context.Context, Id int, version int, fields map[string]interface{}) error {
+	err := repository.Repository.RewriteColumns(ctx, Id, version, fields)
+	repository.invalidate(ctx, Id)
+	return err
+}
+
+func (repository *Cached) DeleteUser(ctx context.Context, Id int) error {
+	err := repository.Repository.DeleteUser(ctx, Id)
+	repository.invalidate(ctx, Id)
+	return err
+}
+
+func (repository *Cached) lookup(ctx context.Context, key string) (Model.User, bool) {
+	data, found, err := repository.cache.Get(key)
+	if err != nil {
+		repository.errors.Add(1)
+		logging.FromContext(ctx).WithError(err).Warn("cache get failed")
+		return Model.User{}, false
+	}
+	if !found {
+		return Model.User{}, false
+	}
+
+	var user Model.User
+	if err := json.Unmarshal(data, &user); err != nil {
+		repository.errors.Add(1)
+		logging.FromContext(ctx).WithError(err).WithField("key", key).Warn("discarding unreadable cache entry")
+		return Model.User{}, false
+	}
+	return user, true
+}
+
+func (repository *Cached) store(ctx context.Context, key string, user Model.User, epoch uint64) {
+	user.Password = ""
+	data, err := json.Marshal(user)
+	if err != nil {
+		repository.errors.Add(1)
+		logging.FromContext(ctx).WithError(err).Warn("cache encode failed")
+		return
+	}
+
+	repository.fill.RLock()
+	defer repository.fill.RUnlock()
+	if repository.epoch.Load() != epoch {
+		return
+	}
+	if err := repository.cache.Set(key, data, repository.ttl); err != nil {
+		repository.errors.Add(1)
+		logging.FromContext(ctx).WithError(err).Warn("cache set failed")
+	}
+}
+
+func (repository *Cached) invalidate(ctx context.Context, Id int) {
+	repository.fill.Lock()
+	defer repository.fill.Unlock()
+
+	repository.epoch.Add(1)
+	if err := repository.cache.Delete(userCacheKey(Id)); err != nil {
+		repository.errors.Add(1)
+		logging.FromContext(ctx).WithError(err).WithField("user_id", Id).Warn("cache invalidation failed, entry may be stale until its TTL")
+	}
+}
+
+// userCacheKey lleva version de formato para poder cambiar Model.User sin
+// leer entradas viejas de Redis.
+func userCacheKey(Id int) string {
+	return "users:v1:id:" + strconv.Itoa(Id)
+}
@@ -0,0 +1,363 @@
+package clientUsers_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"math/big"
+	"net"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	clientUsers "Golang/clients"
+	Domain "Golang/domain"
+	Model "Golang/model"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// gatedRepository cuenta las lecturas por id y, si gate no es nil, las
+// frena hasta que se cierre.
+type gatedRepository struct {
+	clientUsers.Repository
+	reads   atomic.Int32
+	started chan struct{}
+	gate    chan struct{}
+}
+
+func (repository *gatedRepository) GetUserById(ctx context.Context, Id int) (Model.User, error) {
+	repository.reads.Add(1)
+	if repository.gate != nil {
+		repository.started <- struct{}{}
+		<-repository.gate
+	}
+	return repository.Repository.GetUserById(ctx, Id)
+}
+
+func TestCached_SecondReadIsAHit(t *testing.T) {
+	backend := &gatedRepository{Repository: clientUsers.NewMemory()}
+	repo := clientUsers.NewCached(backend, clientUsers.NewLRU(16), time.Minute)
+
+	created, err := repo.InsertUser(context.Background(), Model.User{Nombre: "ana"})
+	require.NoError(t, err)
+
+	for i := 0; i < 3; i++ {
+		got, err := repo.GetUserById(context.Background(), created.Id)
+		require.NoError(t, err)
+		assert.Equal(t, "ana", got.Nombre)
+	}
+
+	assert.Equal(t, int32(1), backend.reads.Load())
+	assert.Equal(t, clientUsers.CacheStats{Hits: 2, Misses: 1}, repo.Stats())
+}
+
+func TestCached_NotFoundIsNotCached(t *testing.T) {
+	backend := &gatedRepository{Repository: clientUsers.NewMemory()}
+	repo := clientUsers.NewCached(backend, clientUsers.NewLRU(16), time.Minute)
+
+	_, err := repo.GetUserById(context.Background(), 1)
+	assert.ErrorIs(t, err, Domain.ErrNotFound)
+
+	created, err := repo.InsertUser(context.Background(), Model.User{Nombre: "ana"})
+	require.NoError(t, err)
+	got, err := repo.GetUserById(context.Background(), created.Id)
+	require.NoError(t, err)
+	assert.Equal(t, "ana", got.Nombre)
+}
+
+func TestCached_WritesInvalidate(t *testing.T) {
+	repo := clientUsers.NewCached(clientUsers.NewMemory(), clientUsers.NewLRU(16), time.Minute)
+	ctx := context.Background()
+
+	created, err := repo.InsertUser(ctx, Model.User{Nombre: "ana"})
+	require.NoError(t, err)
+	_, err = repo.GetUserById(ctx, created.Id)
+	require.NoError(t, err)
+
+	created.Nombre = "ana maria"
+	_, err = repo.UpdateUser(ctx, created)
+	require.NoError(t, err)
+	got, err := repo.GetUserById(ctx, created.Id)
+	require.NoError(t, err)
+	assert.Equal(t, "ana maria", got.Nombre)
+	assert.Equal(t, 2, got.Version)
+
+	_, err = repo.PatchUser(ctx, created.Id, got.Version, map[string]interface{}{"estado": true})
+	require.NoError(t, err)
+	got, err = repo.GetUserById(ctx, created.Id)
+	require.NoError(t, err)
+	assert.True(t, got.Estado)
+	assert.Equal(t, 3, got.Version)
+}
+
+func TestCached_ConcurrentMissesShareOneQuery(t *testing.T) {
+	memory := clientUsers.NewMemory()
+	created, err := memory.InsertUser(context.Background(), Model.User{Nombre: "ana"})
+	require.NoError(t, err)
+
+	backend := &gatedRepository{
+		Repository: memory,
+		started:    make(chan struct{}, 1),
+		gate:       make(chan struct{}),
+	}
+	repo := clientUsers.NewCached(backend, clientUsers.NewLRU(16), time.Minute)
+
+	const readers = 20
+	var wg sync.WaitGroup
+	for i := 0; i < readers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			got, err := repo.GetUserById(context.Background(), created.Id)
+			assert.NoError(t, err)
+			assert.Equal(t, "ana", got.Nombre)
+		}()
+	}
+
+	<-backend.started
+	require.Eventually(t, func() bool {
+		return repo.Stats().Misses == readers
+	}, time.Second, time.Millisecond)
+	// Los lectores ya contaron el fallo; se les da un momento para sumarse a
+	// la consulta en curso antes de liberarla.
+	time.Sleep(20 * time.Millisecond)
+	close(backend.gate)
+	wg.Wait()
+
+	assert.Equal(t, int32(1), backend.reads.Load())
+}
+
+func TestCached_ReadStartedBeforeWriteIsNotStored(t *testing.T) {
+	memory := clientUsers.NewMemory()
+	created, err := memory.InsertUser(context.Background(), Model.User{Nombre: "ana"})
+	require.NoError(t, err)
+
+	backend := &gatedRepository{
+		Repository: memory,
+		started:    make(chan struct{}, 1),
+		gate:       make(chan struct{}),
+	}
+	repo := clientUsers.NewCached(backend, clientUsers.NewLRU(16), time.Minute)
+
+	done := make(chan Model.User)
+	go func() {
+		got, _ := repo.GetUserById(context.Background(), created.Id)
+		done <- got
+	}()
+	<-backend.started
+
+	// La lectura frenada ya tiene la version 1; la escritura pasa a la 2.
+	created.Nombre = "ana maria"
+	_, err = repo.UpdateUser(context.Background(), created)
+	require.NoError(t, err)
+
+	close(backend.gate)
+	<-done
+
+	got, err := repo.GetUserById(context.Background(), created.Id)
+	require.NoError(t, err)
+	assert.Equal(t, "ana maria", got.Nombre)
+}
+
+func TestCached_CacheDownFallsBackToRepository(t *testing.T) {
+	server := miniredis.RunT(t)
+	cache, err := clientUsers.NewRedis(clientUsers.CacheConfig{Addr: server.Addr()})
+	require.NoError(t, err)
+	repo := clientUsers.NewCached(clientUsers.NewMemory(), cache, time.Minute)
+
+	created, err := repo.InsertUser(context.Background(), Model.User{Nombre: "ana"})
+	require.NoError(t, err)
+
+	server.Close()
+
+	got, err := repo.GetUserById(context.Background(), created.Id)
+	require.NoError(t, err)
+	assert.Equal(t, "ana", got.Nombre)
+	assert.NotZero(t, repo.Stats().Errors)
+}
+
+func TestCached_RedisStoresAndDeletes(t *testing.T) {
+	server := miniredis.RunT(t)
+	cache, err := clientUsers.NewRedis(clientUsers.CacheConfig{Addr: server.Addr()})
+	require.NoError(t, err)
+	repo := clientUsers.NewCached(clientUsers.NewMemory(), cache, time.Minute)
+
+	created, err := repo.InsertUser(context.Background(), Model.User{Nombre: "ana", Password: "hash-secreto"})
+	require.NoError(t, err)
+	_, err = repo.GetUserById(context.Background(), created.Id)
+	require.NoError(t, err)
+	assert.True(t, server.Exists("users:v1:id:1"))
+	cached, err := server.Get("users:v1:id:1")
+	require.NoError(t, err)
+	assert.NotContains(t, cached, "hash-secreto")
+	got, err := repo.GetUserById(context.Background(), created.Id)
+	require.NoError(t, err)
+	assert.Empty(t, got.Password)
+
+	_, err = repo.UpdateUser(context.Background(), created)
+	require.NoError(t, err)
+	assert.False(t, server.Exists("users:v1:id:1"))
+}
+
+func TestLRU_EvictsLeastRecentlyUsed(t *testing.T) {
+	cache := clientUsers.NewLRU(2)
+
+	require.NoError(t, cache.Set("a", []byte("1"), time.Minute))
+	require.NoError(t, cache.Set("b", []byte("2"), time.Minute))
+	_, found, _ := cache.Get("a")
+	require.True(t, found)
+	require.NoError(t, cache.Set("c", []byte("3"), time.Minute))
+
+	_, found, _ = cache.Get("b")
+	assert.False(t, found, "b era la menos usada")
+	value, found, _ := cache.Get("a")
+	assert.True(t, found)
+	assert.Equal(t, []byte("1"), value)
+	assert.Equal(t, 2, cache.Len())
+}
+
+func TestLRU_ExpiresEntries(t *testing.T) {
+	cache := clientUsers.NewLRU(2)
+	clock := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	cache.SetClock(func() time.Time { return clock })
+
+	require.NoError(t, cache.Set("a", []byte("1"), time.Minute))
+
+	clock = clock.Add(59 * time.Second)
+	_, found, _ := cache.Get("a")
+	assert.True(t, found)
+
+	clock = clock.Add(time.Second)
+	_, found, _ = cache.Get("a")
+	assert.False(t, found)
+	assert.Equal(t, 0, cache.Len())
+}
+
+func TestRedis_GetSetDelete(t *testing.T) {
+	server := miniredis.RunT(t)
+	cache, err := clientUsers.NewRedis(clientUsers.CacheConfig{Addr: server.Addr()})
+	require.NoError(t, err)
+	defer cache.Close()
+
+	_, found, err := cache.Get("k")
+	require.NoError(t, err)
+	assert.False(t, found)
+
+	// Los valores son binarios: deben sobrevivir saltos de linea.
+	require.NoError(t, cache.Set("k", []byte("a\r\nb"), time.Minute))
+	value, found, err := cache.Get("k")
+	require.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, []byte("a\r\nb"), value)
+
+	require.NoError(t, cache.Delete("k", "otra"))
+	_, found, err = cache.Get("k")
+	require.NoError(t, err)
+	assert.False(t, found)
+}
+
+func TestRedis_TTL(t *testing.T) {
+	server := miniredis.RunT(t)
+	cache, err := clientUsers.NewRedis(clientUsers.CacheConfig{Addr: server.Addr()})
+	require.NoError(t, err)
+	defer cache.Close()
+
+	require.NoError(t, cache.Set("k", []byte("v"), 10*time.Millisecond))
+	assert.True(t, server.Exists("k"))
+	server.FastForward(10 * time.Millisecond)
+	assert.False(t, server.Exists("k"))
+}
+
+func TestRedis_Auth(t *testing.T) {
+	server := miniredis.RunT(t)
+	server.RequireAuth("secreto")
+
+	_, err := clientUsers.NewRedis(clientUsers.CacheConfig{Addr: server.Addr(), Password: "otro"})
+	assert.Error(t, err)
+
+	cache, err := clientUsers.NewRedis(clientUsers.CacheConfig{Addr: server.Addr(), Password: "secreto", DB: 2})
+	require.NoError(t, err)
+	defer cache.Close()
+	require.NoError(t, cache.Set("k", []byte("v"), time.Minute))
+	assert.True(t, server.DB(2).Exists("k"))
+}
+
+func TestRedis_TLS(t *testing.T) {
+	cert, roots := selfSigned(t)
+	server := miniredis.NewMiniRedis()
+	require.NoError(t, server.StartTLS(&tls.Config{Certificates: []tls.Certificate{cert}}))
+	t.Cleanup(server.Close)
+
+	_, err := clientUsers.NewRedis(clientUsers.CacheConfig{Addr: server.Addr()})
+	assert.Error(t, err, "the server only speaks TLS")
+
+	_, err = clientUsers.NewRedis(clientUsers.CacheConfig{Addr: server.Addr(), TLS: &tls.Config{}})
+	assert.Error(t, err, "the self-signed certificate must be verified")
+
+	cache, err := clientUsers.NewRedis(clientUsers.CacheConfig{Addr: server.Addr(), TLS: &tls.Config{RootCAs: roots}})
+	require.NoError(t, err)
+	defer cache.Close()
+	require.NoError(t, cache.Set("k", []byte("v"), time.Minute))
+	assert.True(t, server.Exists("k"))
+}
+
+func TestRedis_PingRespectsContext(t *testing.T) {
+	server := miniredis.RunT(t)
+	cache, err := clientUsers.NewRedis(clientUsers.CacheConfig{Addr: server.Addr()})
+	require.NoError(t, err)
+	defer cache.Close()
+
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.ErrorIs(t, cache.Ping(canceled), context.Canceled)
+
+	// Con el servidor trabado el PING no responde hasta que vence ctx.
+	server.Lock()
+	defer server.Unlock()
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	started := time.Now()
+	assert.Error(t, cache.Ping(ctx))
+	assert.Less(t, time.Since(started), 500*time.Millisecond)
+}
+
+// selfSigned devuelve un certificado para 127.0.0.1 y el pool que lo
+// valida.
+func selfSigned(t *testing.T) (tls.Certificate, *x509.CertPool) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	leaf, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	roots := x509.NewCertPool()
+	roots.AddCert(leaf)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}, roots
+}
+
+func TestNewCache_Drivers(t *testing.T) {
+	cache, err := clientUsers.NewCache(clientUsers.CacheConfig{})
+	assert.NoError(t, err)
+	assert.Nil(t, cache)
+
+	cache, err = clientUsers.NewCache(clientUsers.CacheConfig{Driver: "memory", Size: 4})
+	assert.NoError(t, err)
+	assert.IsType(t, &clientUsers.LRU{}, cache)
+
+	_, err = clientUsers.NewCache(clientUsers.CacheConfig{Driver: "memcached"})
+	assert.Error(t, err)
+}
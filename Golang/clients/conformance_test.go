@@ -0,0 +1,111 @@
+package clientUsers_test
+
+import (
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	clientUsers "Golang/clients"
+	"Golang/clients/repotest"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+func TestConformance_Memory(t *testing.T) {
+	repotest.Run(t, func(t *testing.T) clientUsers.Repository {
+		return clientUsers.NewMemory()
+	})
+}
+
+func TestConformance_SQLite(t *testing.T) {
+	repotest.Run(t, func(t *testing.T) clientUsers.Repository {
+		repo, err := clientUsers.NewSQLite(clientUsers.Config{})
+		if err != nil {
+			t.Fatalf("failed to open sqlite in memory: %v", err)
+		}
+		t.Cleanup(func() { repo.Close() })
+		return repo
+	})
+}
+
+func TestConformance_Instrumented(t *testing.T) {
+	repotest.Run(t, func(t *testing.T) clientUsers.Repository {
+		return clientUsers.NewInstrumented(clientUsers.NewMemory())
+	})
+}
+
+func TestConformance_CachedLRU(t *testing.T) {
+	repotest.Run(t, func(t *testing.T) clientUsers.Repository {
+		return clientUsers.NewCached(clientUsers.NewMemory(), clientUsers.NewLRU(16), time.Minute)
+	})
+}
+
+func TestConformance_CachedRedis(t *testing.T) {
+	repotest.Run(t, func(t *testing.T) clientUsers.Repository {
+		server := miniredis.RunT(t)
+		cache, err := clientUsers.NewRedis(clientUsers.CacheConfig{Addr: server.Addr()})
+		if err != nil {
+			t.Fatalf("failed to connect to fake redis: %v", err)
+		}
+		t.Cleanup(func() { cache.Close() })
+		return clientUsers.NewCached(clientUsers.NewMemory(), cache, time.Minute)
+	})
+}
+
+func TestConformance_Encrypted(t *testing.T) {
+	repotest.Run(t, func(t *testing.T) clientUsers.Repository {
+		return newEncrypted(t, clientUsers.NewMemory(), "k1:"+testKey(1))
+	})
+}
+
+func TestConformance_EncryptedSQLite(t *testing.T) {
+	repotest.Run(t, func(t *testing.T) clientUsers.Repository {
+		repo, err := clientUsers.NewSQLite(clientUsers.Config{})
+		if err != nil {
+			t.Fatalf("failed to open sqlite in memory: %v", err)
+		}
+		t.Cleanup(func() { repo.Close() })
+		return newEncrypted(t, repo, "k1:"+testKey(1))
+	})
+}
+
+// Los backends de red solo se prueban si hay una base disponible, por
+// ejemplo TEST_MYSQL_HOST=localhost TEST_MYSQL_USER=root ... go test ./clients
+func TestConformance_MySQL(t *testing.T) {
+	runAgainstServer(t, clientUsers.DriverMySQL, "TEST_MYSQL_")
+}
+
+func TestConformance_Postgres(t *testing.T) {
+	runAgainstServer(t, clientUsers.DriverPostgres, "TEST_POSTGRES_")
+}
+
+func runAgainstServer(t *testing.T, driver string, prefix string) {
+	host := os.Getenv(prefix + "HOST")
+	if host == "" {
+		t.Skipf("%sHOST not set", prefix)
+	}
+	port, _ := strconv.Atoi(os.Getenv(prefix + "PORT"))
+	config := clientUsers.Config{
+		Driver: driver,
+		Host:   host,
+		Port:   port,
+		User:   os.Getenv(prefix + "USER"),
+		Pass:   os.Getenv(prefix + "PASS"),
+		Name:   os.Getenv(prefix + "NAME"),
+		TLS:    os.Getenv(prefix + "TLS"),
+	}
+
+	repotest.Run(t, func(t *testing.T) clientUsers.Repository {
+		repo, err := clientUsers.NewRepository(config)
+		if err != nil {
+			t.Fatalf("failed to connect to %s: %v", driver, err)
+		}
+		sql := repo.(clientUsers.SQL)
+		t.Cleanup(func() { sql.Close() })
+		if err := clientUsers.Truncate(sql); err != nil {
+			t.Fatalf("failed to truncate users: %v", err)
+		}
+		return sql
+	})
+}
@@ -0,0 +1,205 @@
+package clientUsers
+
+import (
+	Domain "Golang/domain"
+	"Golang/logging"
+	Model "Golang/model"
+	"context"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// ConsentStore guarda los textos de consentimiento y los registros de cada
+// usuario. Va aparte de Repository: son otras tablas y no pasan por el cache
+// ni por el cifrado de los usuarios.
+type ConsentStore interface {
+	// PublishConsentVersion publica text como la version siguiente a la
+	// vigente.
+	PublishConsentVersion(ctx context.Context, text string) (Model.ConsentVersion, error)
+	// GetConsentVersion devuelve la version vigente, o ErrNotFound si
+	// todavia no se publico ninguna.
+	GetConsentVersion(ctx context.Context) (Model.ConsentVersion, error)
+	InsertConsent(ctx context.Context, consent Model.Consent) (Model.Consent, error)
+	// GetConsent devuelve el ultimo registro del usuario, o ErrNotFound si
+	// no tiene ninguno.
+	GetConsent(ctx context.Context, userId int) (Model.Consent, error)
+	// ConsentedUsers devuelve, ordenados, los usuarios cuyo ultimo registro
+	// es un otorgamiento de version.
+	ConsentedUsers(ctx context.Context, version int) ([]int, error)
+	// AnonymizeConsents borra la IP de los registros anteriores a before y
+	// devuelve cuantos fueron. El registro queda como prueba de que se
+	// otorgo o retiro el consentimiento. Con dryRun solo los cuenta.
+	AnonymizeConsents(ctx context.Context, before time.Time, dryRun bool) (int, error)
+}
+
+// PublishConsentVersion calcula el numero de version dentro de una
+// transaccion; si dos publicaciones compiten, el indice unico de version
+// rechaza la segunda con ErrConflict.
+func (repository SQL) PublishConsentVersion(ctx context.Context, text string) (_ Model.ConsentVersion, err error) {
+	tx := repository.with(ctx).Begin()
+	if tx.Error != nil {
+		return Model.ConsentVersion{}, classify(tx.Error, "error publishing consent")
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	var latest struct{ Version int }
+	if err := tx.Model(&Model.ConsentVersion{}).Select("COALESCE(MAX(version), 0) AS version").Scan(&latest).Error; err != nil {
+		return Model.ConsentVersion{}, classify(err, "error publishing consent")
+	}
+	version := Model.ConsentVersion{Version: latest.Version + 1, Text: text, PublishedAt: now()}
+	if err := tx.Create(&version).Error; err != nil {
+		logQueryError(ctx, err, "Error al publicar el consentimiento")
+		return Model.ConsentVersion{}, classify(err, "error publishing consent")
+	}
+	if err := tx.Commit().Error; err != nil {
+		return Model.ConsentVersion{}, classify(err, "error publishing consent")
+	}
+
+	logging.FromContext(ctx).WithField("version", version.Version).Info("consent version published")
+	return version, nil
+}
+
+func (repository SQL) GetConsentVersion(ctx context.Context) (Model.ConsentVersion, error) {
+	var version Model.ConsentVersion
+	result := repository.with(ctx).Order("version DESC").First(&version)
+	if result.Error != nil {
+		logQueryError(ctx, result.Error, "Error al buscar el consentimiento vigente")
+		return version, classify(result.Error, "error finding consent version")
+	}
+	return version, nil
+}
+
+func (repository SQL) InsertConsent(ctx context.Context, consent Model.Consent) (Model.Consent, error) {
+	result := repository.with(ctx).Create(&consent)
+	if result.Error != nil {
+		logQueryError(ctx, result.Error, "Error al registrar el consentimiento")
+		return consent, classify(result.Error, "error recording consent")
+	}
+	return consent, nil
+}
+
+func (repository SQL) GetConsent(ctx context.Context, userId int) (Model.Consent, error) {
+	var consent Model.Consent
+	result := repository.with(ctx).Where("user_id = ?", userId).Order("id DESC").First(&consent)
+	if result.Error != nil {
+		logQueryError(ctx, result.Error, "Error al buscar el consentimiento")
+		return consent, classify(result.Error, "error finding consent")
+	}
+	return consent, nil
+}
+
+func (repository SQL) ConsentedUsers(ctx context.Context, version int) ([]int, error) {
+	users := make([]int, 0)
+	result := repository.with(ctx).Model(&Model.Consent{}).
+		Where("granted = ? AND version = ? AND id = (SELECT MAX(latest.id) FROM consents latest WHERE latest.user_id = consents.user_id)", true, version).
+		Order("user_id").
+		Pluck("user_id", &users)
+	if result.Error != nil {
+		logQueryError(ctx, result.Error, "Error al buscar los consentimientos")
+		return nil, classify(result.Error, "error listing consents")
+	}
+	return users, nil
+}
+
+func (repository SQL) AnonymizeConsents(ctx context.Context, before time.Time, dryRun bool) (int, error) {
+	query := repository.with(ctx).Model(&Model.Consent{}).Where("created_at < ? AND ip <> ?", before, "")
+	if dryRun {
+		count := 0
+		if err := query.Count(&count).Error; err != nil {
+			return 0, classify(err, "error counting consents")
+		}
+		return count, nil
+	}
+	result := query.UpdateColumn("ip", "")
+	if result.Error != nil {
+		logQueryError(ctx, result.Error, "Error al anonimizar los consentimientos")
+		return 0, classify(result.Error, "error anonymizing consents")
+	}
+	return int(result.RowsAffected), nil
+}
+
+func (repository *Memory) PublishConsentVersion(ctx context.Context, text string) (Model.ConsentVersion, error) {
+	repository.mu.Lock()
+	defer repository.mu.Unlock()
+
+	version := Model.ConsentVersion{
+		Id:          len(repository.consentVersions) + 1,
+		Version:     len(repository.consentVersions) + 1,
+		Text:        text,
+		PublishedAt: now(),
+	}
+	repository.consentVersions = append(repository.consentVersions, version)
+	return version, nil
+}
+
+func (repository *Memory) GetConsentVersion(ctx context.Context) (Model.ConsentVersion, error) {
+	repository.mu.RLock()
+	defer repository.mu.RUnlock()
+
+	if len(repository.consentVersions) == 0 {
+		return Model.ConsentVersion{}, fmt.Errorf("error finding consent version: %w", Domain.ErrNotFound)
+	}
+	return repository.consentVersions[len(repository.consentVersions)-1], nil
+}
+
+func (repository *Memory) InsertConsent(ctx context.Context, consent Model.Consent) (Model.Consent, error) {
+	repository.mu.Lock()
+	defer repository.mu.Unlock()
+
+	consent.Id = len(repository.consents) + 1
+	consent.CreatedAt = now()
+	repository.consents = append(repository.consents, consent)
+	return consent, nil
+}
+
+func (repository *Memory) GetConsent(ctx context.Context, userId int) (Model.Consent, error) {
+	repository.mu.RLock()
+	defer repository.mu.RUnlock()
+
+	for i := len(repository.consents) - 1; i >= 0; i-- {
+		if repository.consents[i].UserId == userId {
+			return repository.consents[i], nil
+		}
+	}
+	return Model.Consent{}, fmt.Errorf("error finding consent of user %d: %w", userId, Domain.ErrNotFound)
+}
+
+func (repository *Memory) ConsentedUsers(ctx context.Context, version int) ([]int, error) {
+	repository.mu.RLock()
+	defer repository.mu.RUnlock()
+
+	latest := make(map[int]Model.Consent)
+	for _, consent := range repository.consents {
+		latest[consent.UserId] = consent
+	}
+	users := make([]int, 0, len(latest))
+	for userId, consent := range latest {
+		if consent.Granted && consent.Version == version {
+			users = append(users, userId)
+		}
+	}
+	sort.Ints(users)
+	return users, nil
+}
+
+func (repository *Memory) AnonymizeConsents(ctx context.Context, before time.Time, dryRun bool) (int, error) {
+	repository.mu.Lock()
+	defer repository.mu.Unlock()
+
+	anonymized := 0
+	for i, consent := range repository.consents {
+		if consent.IP == "" || !consent.CreatedAt.Before(before) {
+			continue
+		}
+		anonymized++
+		if !dryRun {
+			repository.consents[i].IP = ""
+		}
+	}
+	return anonymized, nil
+}
@@ -0,0 +1,94 @@
+package clientUsers_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	clientUsers "Golang/clients"
+	Domain "Golang/domain"
+	Model "Golang/model"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConsentStore(t *testing.T) {
+	stores := map[string]func(t *testing.T) clientUsers.ConsentStore{
+		"memory": func(t *testing.T) clientUsers.ConsentStore { return clientUsers.NewMemory() },
+		"sqlite": func(t *testing.T) clientUsers.ConsentStore {
+			repo, err := clientUsers.NewSQLite(clientUsers.Config{})
+			require.NoError(t, err)
+			t.Cleanup(func() { repo.Close() })
+			return repo
+		},
+	}
+	for name, newStore := range stores {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			store := newStore(t)
+
+			_, err := store.GetConsentVersion(ctx)
+			assert.ErrorIs(t, err, Domain.ErrNotFound)
+			_, err = store.GetConsent(ctx, 1)
+			assert.ErrorIs(t, err, Domain.ErrNotFound)
+
+			first, err := store.PublishConsentVersion(ctx, "texto 1")
+			require.NoError(t, err)
+			second, err := store.PublishConsentVersion(ctx, "texto 2")
+			require.NoError(t, err)
+			assert.Equal(t, 1, first.Version)
+			assert.Equal(t, 2, second.Version)
+			current, err := store.GetConsentVersion(ctx)
+			require.NoError(t, err)
+			assert.Equal(t, "texto 2", current.Text)
+			assert.False(t, current.PublishedAt.IsZero())
+
+			for _, consent := range []Model.Consent{
+				{UserId: 1, Version: 1, Granted: true, IP: "10.0.0.1"},
+				{UserId: 2, Version: 1, Granted: true},
+				{UserId: 3, Version: 2, Granted: true},
+				{UserId: 2, Version: 1, Granted: false},
+				{UserId: 1, Version: 2, Granted: true, IP: "10.0.0.2"},
+			} {
+				_, err := store.InsertConsent(ctx, consent)
+				require.NoError(t, err)
+			}
+
+			latest, err := store.GetConsent(ctx, 1)
+			require.NoError(t, err)
+			assert.Equal(t, 2, latest.Version)
+			assert.Equal(t, "10.0.0.2", latest.IP)
+			assert.False(t, latest.CreatedAt.IsZero())
+			latest, err = store.GetConsent(ctx, 2)
+			require.NoError(t, err)
+			assert.False(t, latest.Granted)
+
+			users, err := store.ConsentedUsers(ctx, 2)
+			require.NoError(t, err)
+			assert.Equal(t, []int{1, 3}, users)
+			users, err = store.ConsentedUsers(ctx, 1)
+			require.NoError(t, err)
+			assert.Empty(t, users)
+
+			// Vencidos los registros se borra la IP y quedan el resto de los datos.
+			anonymized, err := store.AnonymizeConsents(ctx, time.Now().Add(-time.Hour), false)
+			require.NoError(t, err)
+			assert.Zero(t, anonymized)
+			anonymized, err = store.AnonymizeConsents(ctx, time.Now().Add(time.Hour), true)
+			require.NoError(t, err)
+			assert.Equal(t, 2, anonymized)
+			anonymized, err = store.AnonymizeConsents(ctx, time.Now().Add(time.Hour), false)
+			require.NoError(t, err)
+			assert.Equal(t, 2, anonymized)
+			latest, err = store.GetConsent(ctx, 1)
+			require.NoError(t, err)
+			assert.Empty(t, latest.IP)
+			assert.True(t, latest.Granted)
+			assert.Equal(t, 2, latest.Version)
+			anonymized, err = store.AnonymizeConsents(ctx, time.Now().Add(time.Hour), true)
+			require.NoError(t, err)
+			assert.Zero(t, anonymized)
+		})
+	}
+}
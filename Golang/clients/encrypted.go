@@ -0,0 +1,561 @@
+package clientUsers
+
+import (
+	Domain "Golang/domain"
+	"Golang/logging"
+	Model "Golang/model"
+	"context"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// encryptionVersion encabeza los textos cifrados y las claves envueltas, para
+// poder cambiar el formato sin ambiguedad.
+const encryptionVersion = "v1"
+
+// rowBoundVersion encabeza los textos cifrados cuyo dato asociado es la
+// columna y el id de la fila. Los "v1" solo usan la columna: se siguen
+// leyendo y Reencrypt los reescribe.
+const rowBoundVersion = "v2"
+
+// blindIndexLength es la cantidad de caracteres hex del indice ciego que se
+// guardan (128 bits).
+const blindIndexLength = 32
+
+// sensitiveColumns son las columnas que se guardan cifradas.
+var sensitiveColumns = []string{"atributos", "enfermedades", "diabetico"}
+
+// EncryptionConfig activa el cifrado en reposo de los datos medicos. Sin
+// MasterKeys el cifrado esta apagado.
+type EncryptionConfig struct {
+	// MasterKeys son las claves maestras, "id:base64" separadas por comas.
+	MasterKeys string
+	// ActiveKey es el id de la clave que envuelve las claves de datos nuevas.
+	// Vacio usa la primera de MasterKeys.
+	ActiveKey string
+	// IndexKey es la clave del indice ciego, en base64. Es independiente de
+	// las maestras para que rotarlas no obligue a recalcular los indices.
+	IndexKey string
+}
+
+// NewEncryption construye el repositorio cifrado sobre next con claves de un
+// LocalKMS. Devuelve nil si el cifrado esta desactivado.
+func NewEncryption(next Repository, config EncryptionConfig) (*Encrypted, error) {
+	if strings.TrimSpace(config.MasterKeys) == "" {
+		return nil, nil
+	}
+	kms, err := NewLocalKMS(config.MasterKeys, config.ActiveKey)
+	if err != nil {
+		return nil, err
+	}
+	indexKey, err := decodeKey(config.IndexKey)
+	if err != nil {
+		return nil, fmt.Errorf("index key: %w", err)
+	}
+	return NewEncrypted(next, kms, indexKey), nil
+}
+
+// ReencryptStats resume una pasada de Reencrypt. Skipped son las filas que
+// cambiaron o se borraron durante la pasada; Failed las que no se pudieron
+// descifrar, por ejemplo porque su clave maestra ya no esta configurada.
+type ReencryptStats struct {
+	Scanned     int
+	Reencrypted int
+	Skipped     int
+	Failed      int
+}
+
+// Encrypted es un Repository que guarda Atributos, Enfermedades y Diabetico
+// cifrados. Cada fila tiene su propia clave de datos (AES-256-GCM), envuelta
+// con la clave maestra activa del KeyManager y guardada en DataKey. Las
+// lecturas descifran y devuelven los usuarios como si la base estuviera en
+// texto plano; las filas viejas sin DataKey se leen tal cual y Reencrypt las
+// cifra.
+//
+// Cada texto cifrado usa como dato asociado la columna y el id de la fila,
+// asi no se descifra si se lo copia (con o sin DataKey) a otra columna o a
+// otra fila. En las altas el id recien se conoce al insertar: las columnas
+// se sellan con id 0 y se vuelven a sellar con el id asignado dentro de la
+// misma alta (ver Repository.InsertSealed).
+//
+// Diabetico se puede filtrar con ScanUsers gracias a un indice ciego (HMAC
+// del valor). Como es un booleano el indice oculta cual es cual pero no
+// cuantas filas hay de cada uno.
+//
+// Va por encima de Cached, asi la cache (que puede ser Redis) solo guarda
+// texto cifrado. Reenvia cada metodo en forma explicita en lugar de embeber
+// el repositorio: un metodo nuevo de Repository no compila hasta decidir si
+// tiene que descifrar.
+type Encrypted struct {
+	next     Repository
+	keys     KeyManager
+	indexKey []byte
+}
+
+func NewEncrypted(next Repository, keys KeyManager, indexKey []byte) *Encrypted {
+	return &Encrypted{next: next, keys: keys, indexKey: indexKey}
+}
+
+func (repository *Encrypted) InsertUser(ctx context.Context, user Model.User) (Model.User, error) {
+	created, err := repository.InsertSealed(ctx, []Model.User{user}, nil)
+	if err != nil {
+		return user, err
+	}
+	return created[0], nil
+}
+
+func (repository *Encrypted) InsertUsers(ctx context.Context, users []Model.User) ([]Model.User, error) {
+	return repository.InsertSealed(ctx, users, nil)
+}
+
+// InsertSealed cifra users y los ata a su id con next.InsertSealed; seal,
+// si no es nil, recibe cada fila ya cifrada.
+func (repository *Encrypted) InsertSealed(ctx context.Context, users []Model.User, seal func(*Model.User) error) ([]Model.User, error) {
+	sealed := make([]Model.User, len(users))
+	for i, user := range users {
+		if err := repository.sealUser(ctx, &user); err != nil {
+			return nil, err
+		}
+		sealed[i] = user
+	}
+	created, err := repository.next.InsertSealed(ctx, sealed, func(row *Model.User) error {
+		if err := repository.bindToRow(ctx, row); err != nil {
+			return err
+		}
+		if seal != nil {
+			return seal(row)
+		}
+		return nil
+	})
+	if err != nil {
+		return created, err
+	}
+	return repository.openAll(ctx, created)
+}
+
+func (repository *Encrypted) GetUserById(ctx context.Context, Id int) (Model.User, error) {
+	user, err := repository.next.GetUserById(ctx, Id)
+	if err != nil {
+		return user, err
+	}
+	return repository.openUser(ctx, user)
+}
+
+func (repository *Encrypted) UpdateUser(ctx context.Context, User Model.User) (Model.User, error) {
+	if err := repository.sealUser(ctx, &User); err != nil {
+		return Model.User{}, err
+	}
+	updated, err := repository.next.UpdateUser(ctx, User)
+	if err != nil {
+		return updated, err
+	}
+	return repository.openUser(ctx, updated)
+}
+
+// PatchUser que toca una columna sensible vuelve a cifrar las tres con una
+// clave de datos nueva, partiendo de la fila actual. La condicion de version
+// de next garantiza que esa fila no cambio en el medio.
+func (repository *Encrypted) PatchUser(ctx context.Context, Id int, version int, fields map[string]interface{}) (Model.User, error) {
+	if !touchesSensitive(fields) {
+		user, err := repository.next.PatchUser(ctx, Id, version, fields)
+		if err != nil {
+			return user, err
+		}
+		return repository.openUser(ctx, user)
+	}
+
+	current, err := repository.GetUserById(ctx, Id)
+	if err != nil {
+		return Model.User{}, err
+	}
+	if current.Version != version {
+		return Model.User{}, fmt.Errorf("error patching user %d: %w", Id, Domain.ErrPreconditionFailed)
+	}
+	sensitive := make(map[string]interface{}, len(sensitiveColumns))
+	for _, column := range sensitiveColumns {
+		if value, ok := fields[column]; ok {
+			sensitive[column] = value
+		}
+	}
+	if err := applyFields(&current, sensitive); err != nil {
+		return Model.User{}, err
+	}
+	if err := repository.sealUser(ctx, &current); err != nil {
+		return Model.User{}, err
+	}
+
+	values := sealedFields(current)
+	for column, value := range fields {
+		if _, ok := sensitive[column]; !ok {
+			values[column] = value
+		}
+	}
+	user, err := repository.next.PatchUser(ctx, Id, version, values)
+	if err != nil {
+		return user, err
+	}
+	return repository.openUser(ctx, user)
+}
+
+func (repository *Encrypted) GetUserByName(ctx context.Context, Usuario Model.User) (Model.User, error) {
+	user, err := repository.next.GetUserByName(ctx, Usuario)
+	if err != nil {
+		return user, err
+	}
+	return repository.openUser(ctx, user)
+}
+
+func (repository *Encrypted) GetAllUsers(ctx context.Context) ([]Model.User, error) {
+	users, err := repository.next.GetAllUsers(ctx)
+	if err != nil {
+		return users, err
+	}
+	return repository.openAll(ctx, users)
+}
+
+func (repository *Encrypted) GetUsersStamp(ctx context.Context) (Model.UsersStamp, error) {
+	return repository.next.GetUsersStamp(ctx)
+}
+
+func (repository *Encrypted) ExistingNames(ctx context.Context, nombres []string) ([]string, error) {
+	return repository.next.ExistingNames(ctx, nombres)
+}
+
+// RewriteColumns escribe fields tal cual: son columnas de la fila guardada,
+// ya cifradas.
+func (repository *Encrypted) RewriteColumns(ctx context.Context, Id int, version int, fields map[string]interface{}) error {
+	return repository.next.RewriteColumns(ctx, Id, version, fields)
+}
+
+func (repository *Encrypted) DeleteUser(ctx context.Context, Id int) error {
+	return repository.next.DeleteUser(ctx, Id)
+}
+
+// ScanUsers traduce el filtro por Diabetico al indice ciego.
+func (repository *Encrypted) ScanUsers(ctx context.Context, filter Model.UserFilter, fn func(Model.User) error) error {
+	if filter.Diabetico != nil {
+		filter.DiabeticoIndex = repository.blindIndex("diabetico", strconv.FormatBool(*filter.Diabetico))
+	}
+	return repository.next.ScanUsers(ctx, filter, func(user Model.User) error {
+		user, err := repository.openUser(ctx, user)
+		if err != nil {
+			return err
+		}
+		return fn(user)
+	})
+}
+
+// Reencrypt recorre la tabla y vuelve a cifrar las filas en texto plano, las
+// envueltas con una clave maestra que ya no es la activa, las que no tienen
+// indice ciego y las cifradas sin el id de la fila ("v1"). Cada fila se
+// escribe con RewriteColumns: los datos no cambian, asi que tampoco la
+// version ni UpdatedAt, y los clientes no reciben un 412 por el re-cifrado.
+// Las filas que cambian en el medio se saltean y las toma la pasada
+// siguiente.
+func (repository *Encrypted) Reencrypt(ctx context.Context) (ReencryptStats, error) {
+	var stats ReencryptStats
+	active := repository.keys.ActiveKeyId()
+	err := repository.next.ScanUsers(ctx, Model.UserFilter{}, func(raw Model.User) error {
+		stats.Scanned++
+		if keyId, _, _ := parseDataKey(raw.DataKey); keyId == active && raw.DiabeticoIndex != "" && rowBound(raw) {
+			return nil
+		}
+
+		user, err := repository.openUser(ctx, raw)
+		if err == nil {
+			err = repository.sealUser(ctx, &user)
+		}
+		if err != nil {
+			stats.Failed++
+			logging.FromContext(ctx).WithError(err).WithField("user_id", raw.Id).Warn("could not re-encrypt user")
+			return nil
+		}
+
+		err = repository.next.RewriteColumns(ctx, raw.Id, raw.Version, sealedFields(user))
+		switch {
+		case errors.Is(err, Domain.ErrPreconditionFailed), errors.Is(err, Domain.ErrNotFound):
+			stats.Skipped++
+		case err != nil:
+			return err
+		default:
+			stats.Reencrypted++
+		}
+		return nil
+	})
+	return stats, err
+}
+
+// StartReencryption corre Reencrypt al arrancar y despues cada interval, en
+// segundo plano. La funcion devuelta la detiene y espera la pasada en curso
+// hasta que venza su ctx; sirve para server.OnShutdown.
+func (repository *Encrypted) StartReencryption(interval time.Duration) func(context.Context) error {
+	base, cancel := context.WithCancel(context.Background())
+	var running sync.WaitGroup
+	running.Add(1)
+	go func() {
+		defer running.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			repository.reencryptPass(base)
+			select {
+			case <-base.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	return func(ctx context.Context) error {
+		cancel()
+		done := make(chan struct{})
+		go func() {
+			running.Wait()
+			close(done)
+		}()
+		select {
+		case <-done:
+			return nil
+		case <-ctx.Done():
+			return fmt.Errorf("re-encryption did not stop: %w", ctx.Err())
+		}
+	}
+}
+
+func (repository *Encrypted) reencryptPass(ctx context.Context) {
+	stats, err := repository.Reencrypt(ctx)
+	entry := logging.FromContext(ctx).
+		WithField("scanned", stats.Scanned).
+		WithField("reencrypted", stats.Reencrypted).
+		WithField("skipped", stats.Skipped).
+		WithField("failed", stats.Failed)
+	switch {
+	case err != nil && ctx.Err() == nil:
+		entry.WithError(err).Error("re-encryption failed")
+	case stats.Reencrypted > 0 || stats.Failed > 0:
+		entry.Info("users re-encrypted")
+	default:
+		entry.Debug("users re-encrypted")
+	}
+}
+
+// sealUser cifra las columnas sensibles de user con una clave de datos nueva,
+// atadas a user.Id, y deja vacias las de texto plano.
+func (repository *Encrypted) sealUser(ctx context.Context, user *Model.User) error {
+	dataKey := make([]byte, keySize)
+	if _, err := rand.Read(dataKey); err != nil {
+		return fmt.Errorf("generating data key: %w", err)
+	}
+	keyId, wrapped, err := repository.keys.WrapKey(ctx, dataKey)
+	if err != nil {
+		return fmt.Errorf("wrapping data key: %w", err)
+	}
+	aead, err := newAEAD(dataKey)
+	if err != nil {
+		return err
+	}
+
+	diabetico := strconv.FormatBool(user.Diabetico)
+	for _, field := range []struct {
+		column string
+		value  string
+		target *string
+	}{
+		{"atributos", user.Atributos, &user.AtributosEnc},
+		{"enfermedades", user.Enfermedades, &user.EnfermedadesEnc},
+		{"diabetico", diabetico, &user.DiabeticoEnc},
+	} {
+		sealed, err := sealField(aead, field.column, user.Id, field.value)
+		if err != nil {
+			return err
+		}
+		*field.target = sealed
+	}
+	user.DiabeticoIndex = repository.blindIndex("diabetico", diabetico)
+	user.DataKey = strings.Join([]string{encryptionVersion, keyId, base64.StdEncoding.EncodeToString(wrapped)}, ":")
+	user.Atributos, user.Enfermedades, user.Diabetico = "", "", false
+	return nil
+}
+
+// bindToRow vuelve a sellar las columnas que sealUser cifro con id 0 usando
+// el id que le asigno el alta, con la misma clave de datos.
+func (repository *Encrypted) bindToRow(ctx context.Context, user *Model.User) error {
+	aead, err := repository.dataAEAD(ctx, user.DataKey)
+	if err != nil {
+		return fmt.Errorf("error encrypting user %d: %w", user.Id, err)
+	}
+	for _, field := range []struct {
+		column string
+		target *string
+	}{
+		{"atributos", &user.AtributosEnc},
+		{"enfermedades", &user.EnfermedadesEnc},
+		{"diabetico", &user.DiabeticoEnc},
+	} {
+		plain, err := openField(aead, field.column, 0, *field.target)
+		if err != nil {
+			return fmt.Errorf("error encrypting user %d %s: %w", user.Id, field.column, err)
+		}
+		if *field.target, err = sealField(aead, field.column, user.Id, plain); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// dataAEAD desenvuelve la clave de datos de una fila.
+func (repository *Encrypted) dataAEAD(ctx context.Context, dataKey string) (cipher.AEAD, error) {
+	keyId, wrapped, err := parseDataKey(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	key, err := repository.keys.UnwrapKey(ctx, keyId, wrapped)
+	if err != nil {
+		return nil, err
+	}
+	return newAEAD(key)
+}
+
+// openUser descifra las columnas sensibles y deja vacias las cifradas. Las filas
+// sin DataKey estan en texto plano y se devuelven como estan.
+func (repository *Encrypted) openUser(ctx context.Context, user Model.User) (Model.User, error) {
+	if user.DataKey == "" {
+		return user, nil
+	}
+	aead, err := repository.dataAEAD(ctx, user.DataKey)
+	if err != nil {
+		return Model.User{}, fmt.Errorf("error decrypting user %d: %w", user.Id, err)
+	}
+
+	var diabetico string
+	for _, field := range []struct {
+		column string
+		value  string
+		target *string
+	}{
+		{"atributos", user.AtributosEnc, &user.Atributos},
+		{"enfermedades", user.EnfermedadesEnc, &user.Enfermedades},
+		{"diabetico", user.DiabeticoEnc, &diabetico},
+	} {
+		plain, err := openField(aead, field.column, user.Id, field.value)
+		if err != nil {
+			return Model.User{}, fmt.Errorf("error decrypting user %d %s: %w", user.Id, field.column, err)
+		}
+		*field.target = plain
+	}
+	if user.Diabetico, err = strconv.ParseBool(diabetico); err != nil {
+		return Model.User{}, fmt.Errorf("error decrypting user %d diabetico: %w", user.Id, err)
+	}
+	user.AtributosEnc, user.EnfermedadesEnc, user.DiabeticoEnc = "", "", ""
+	user.DiabeticoIndex, user.DataKey = "", ""
+	return user, nil
+}
+
+func (repository *Encrypted) openAll(ctx context.Context, users []Model.User) ([]Model.User, error) {
+	for i, user := range users {
+		opened, err := repository.openUser(ctx, user)
+		if err != nil {
+			return nil, err
+		}
+		users[i] = opened
+	}
+	return users, nil
+}
+
+// blindIndex es HMAC-SHA256 de la columna y el valor. Incluir la columna
+// evita que dos columnas con el mismo valor compartan indice.
+func (repository *Encrypted) blindIndex(column string, value string) string {
+	mac := hmac.New(sha256.New, repository.indexKey)
+	mac.Write([]byte(column + "\x00" + value))
+	return hex.EncodeToString(mac.Sum(nil))[:blindIndexLength]
+}
+
+// sealedFields son las columnas que escribe un usuario cifrado por sealUser, para
+// PatchUser y RewriteColumns.
+func sealedFields(user Model.User) map[string]interface{} {
+	return map[string]interface{}{
+		"atributos":        "",
+		"enfermedades":     "",
+		"diabetico":        false,
+		"atributos_enc":    user.AtributosEnc,
+		"enfermedades_enc": user.EnfermedadesEnc,
+		"diabetico_enc":    user.DiabeticoEnc,
+		"diabetico_index":  user.DiabeticoIndex,
+		"data_key":         user.DataKey,
+	}
+}
+
+func touchesSensitive(fields map[string]interface{}) bool {
+	for _, column := range sensitiveColumns {
+		if _, ok := fields[column]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// sealField cifra value usando la columna y el id como dato asociado: un
+// texto cifrado copiado a otra columna u otra fila no se descifra.
+func sealField(aead cipher.AEAD, column string, id int, value string) (string, error) {
+	sealed, err := seal(aead, []byte(value), fieldAAD(column, id))
+	if err != nil {
+		return "", err
+	}
+	return rowBoundVersion + ":" + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// openField descifra los textos de sealField y los "v1", que solo tienen la
+// columna como dato asociado.
+func openField(aead cipher.AEAD, column string, id int, value string) (string, error) {
+	additional := fieldAAD(column, id)
+	encoded, ok := strings.CutPrefix(value, rowBoundVersion+":")
+	if !ok {
+		encoded, ok = strings.CutPrefix(value, encryptionVersion+":")
+		additional = []byte(column)
+	}
+	if !ok {
+		return "", errors.New("unknown ciphertext format")
+	}
+	sealed, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", err
+	}
+	plain, err := open(aead, sealed, additional)
+	return string(plain), err
+}
+
+func fieldAAD(column string, id int) []byte {
+	return []byte(column + ":" + strconv.Itoa(id))
+}
+
+// rowBound indica si todas las columnas cifradas de user estan atadas a su
+// id.
+func rowBound(user Model.User) bool {
+	for _, value := range []string{user.AtributosEnc, user.EnfermedadesEnc, user.DiabeticoEnc} {
+		if !strings.HasPrefix(value, rowBoundVersion+":") {
+			return false
+		}
+	}
+	return true
+}
+
+// parseDataKey separa "v1:<id de clave maestra>:<base64 de la clave
+// envuelta>".
+func parseDataKey(dataKey string) (keyId string, wrapped []byte, err error) {
+	parts := strings.SplitN(dataKey, ":", 3)
+	if len(parts) != 3 || parts[0] != encryptionVersion {
+		return "", nil, errors.New("unknown data key format")
+	}
+	wrapped, err = base64.StdEncoding.DecodeString(parts[2])
+	return parts[1], wrapped, err
+}
@@ -0,0 +1,327 @@
+package clientUsers_test
+
+import (
+	"context"
+	"encoding/base64"
+	"strings"
+	"testing"
+	"time"
+
+	clientUsers "Golang/clients"
+	Domain "Golang/domain"
+	Model "Golang/model"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testKey es una clave de 32 bytes distinta para cada seed.
+func testKey(seed byte) string {
+	key := make([]byte, 32)
+	for i := range key {
+		key[i] = seed + byte(i)
+	}
+	return base64.StdEncoding.EncodeToString(key)
+}
+
+func newEncrypted(t *testing.T, next clientUsers.Repository, masterKeys string) *clientUsers.Encrypted {
+	t.Helper()
+	repo, err := clientUsers.NewEncryption(next, clientUsers.EncryptionConfig{MasterKeys: masterKeys, IndexKey: testKey(100)})
+	require.NoError(t, err)
+	require.NotNil(t, repo)
+	return repo
+}
+
+func medicalUser(nombre string, diabetico bool) Model.User {
+	return Model.User{
+		Nombre:       nombre,
+		Genero:       "F",
+		Atributos:    "miope; alergia a la penicilina",
+		Enfermedades: "hipertension",
+		Diabetico:    diabetico,
+		Estado:       true,
+	}
+}
+
+func TestEncrypted_StoresOnlyCiphertext(t *testing.T) {
+	backend, err := clientUsers.NewSQLite(clientUsers.Config{})
+	require.NoError(t, err)
+	defer backend.Close()
+	repo := newEncrypted(t, backend, "k1:"+testKey(1))
+
+	created, err := repo.InsertUser(context.Background(), medicalUser("ana", true))
+	require.NoError(t, err)
+	assert.Equal(t, "hipertension", created.Enfermedades)
+	assert.True(t, created.Diabetico)
+	assert.Empty(t, created.DataKey)
+
+	raw, err := backend.GetUserById(context.Background(), created.Id)
+	require.NoError(t, err)
+	assert.Empty(t, raw.Atributos)
+	assert.Empty(t, raw.Enfermedades)
+	assert.False(t, raw.Diabetico)
+	assert.True(t, strings.HasPrefix(raw.AtributosEnc, "v2:"))
+	assert.NotContains(t, raw.EnfermedadesEnc, "hipertension")
+	assert.True(t, strings.HasPrefix(raw.DataKey, "v1:k1:"))
+	assert.Len(t, raw.DiabeticoIndex, 32)
+
+	read, err := repo.GetUserById(context.Background(), created.Id)
+	require.NoError(t, err)
+	assert.Equal(t, created, read)
+}
+
+func TestEncrypted_SameValueDifferentCiphertext(t *testing.T) {
+	backend := clientUsers.NewMemory()
+	repo := newEncrypted(t, backend, "k1:"+testKey(1))
+
+	_, err := repo.InsertUsers(context.Background(), []Model.User{medicalUser("ana", true), medicalUser("eva", true)})
+	require.NoError(t, err)
+
+	raw, err := backend.GetAllUsers(context.Background())
+	require.NoError(t, err)
+	assert.NotEqual(t, raw[0].EnfermedadesEnc, raw[1].EnfermedadesEnc)
+	assert.NotEqual(t, raw[0].DataKey, raw[1].DataKey)
+	// El indice ciego es deterministico: es lo que permite filtrar.
+	assert.Equal(t, raw[0].DiabeticoIndex, raw[1].DiabeticoIndex)
+}
+
+func TestEncrypted_MovedCiphertextDoesNotDecrypt(t *testing.T) {
+	backend := clientUsers.NewMemory()
+	repo := newEncrypted(t, backend, "k1:"+testKey(1))
+	ana, err := repo.InsertUser(context.Background(), medicalUser("ana", true))
+	require.NoError(t, err)
+	eva, err := repo.InsertUser(context.Background(), medicalUser("eva", false))
+	require.NoError(t, err)
+
+	rawAna, err := backend.GetUserById(context.Background(), ana.Id)
+	require.NoError(t, err)
+	rawEva, err := backend.GetUserById(context.Background(), eva.Id)
+	require.NoError(t, err)
+
+	// Otra fila: cada una tiene su clave de datos.
+	_, err = backend.PatchUser(context.Background(), eva.Id, rawEva.Version, map[string]interface{}{"diabetico_enc": rawAna.DiabeticoEnc})
+	require.NoError(t, err)
+	_, err = repo.GetUserById(context.Background(), eva.Id)
+	assert.Error(t, err)
+
+	// Otra columna de la misma fila: la columna es dato asociado.
+	_, err = backend.PatchUser(context.Background(), ana.Id, rawAna.Version, map[string]interface{}{"atributos_enc": rawAna.EnfermedadesEnc})
+	require.NoError(t, err)
+	_, err = repo.GetUserById(context.Background(), ana.Id)
+	assert.Error(t, err)
+}
+
+func TestEncrypted_CiphertextAndDataKeyDoNotMoveToAnotherRow(t *testing.T) {
+	backend, err := clientUsers.NewSQLite(clientUsers.Config{})
+	require.NoError(t, err)
+	defer backend.Close()
+	repo := newEncrypted(t, backend, "k1:"+testKey(1))
+	users, err := repo.InsertUsers(context.Background(), []Model.User{medicalUser("ana", true), medicalUser("eva", false)})
+	require.NoError(t, err)
+
+	rawAna, err := backend.GetUserById(context.Background(), users[0].Id)
+	require.NoError(t, err)
+	rawEva, err := backend.GetUserById(context.Background(), users[1].Id)
+	require.NoError(t, err)
+	assert.Equal(t, 1, rawAna.Version)
+
+	// Con la clave de datos incluida: el id de la fila es dato asociado.
+	_, err = backend.PatchUser(context.Background(), rawEva.Id, rawEva.Version, map[string]interface{}{
+		"atributos_enc":    rawAna.AtributosEnc,
+		"enfermedades_enc": rawAna.EnfermedadesEnc,
+		"diabetico_enc":    rawAna.DiabeticoEnc,
+		"data_key":         rawAna.DataKey,
+	})
+	require.NoError(t, err)
+	_, err = repo.GetUserById(context.Background(), rawEva.Id)
+	assert.Error(t, err)
+
+	read, err := repo.GetUserById(context.Background(), rawAna.Id)
+	require.NoError(t, err)
+	assert.True(t, read.Diabetico)
+}
+
+func TestEncrypted_ColumnOnlyCiphertextIsReadAndReencrypted(t *testing.T) {
+	backend := clientUsers.NewMemory()
+	repo := newEncrypted(t, backend, "k1:"+testKey(1))
+	created, err := repo.InsertUser(context.Background(), medicalUser("ana", true))
+	require.NoError(t, err)
+	raw, err := backend.GetUserById(context.Background(), created.Id)
+	require.NoError(t, err)
+	require.NoError(t, repo.SealLegacy(context.Background(), &raw))
+	_, err = backend.PatchUser(context.Background(), raw.Id, raw.Version, map[string]interface{}{
+		"atributos_enc":    raw.AtributosEnc,
+		"enfermedades_enc": raw.EnfermedadesEnc,
+		"diabetico_enc":    raw.DiabeticoEnc,
+	})
+	require.NoError(t, err)
+
+	read, err := repo.GetUserById(context.Background(), created.Id)
+	require.NoError(t, err)
+	assert.Equal(t, "hipertension", read.Enfermedades)
+
+	stats, err := repo.Reencrypt(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, clientUsers.ReencryptStats{Scanned: 1, Reencrypted: 1}, stats)
+	raw, err = backend.GetUserById(context.Background(), created.Id)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(raw.EnfermedadesEnc, "v2:"))
+	read, err = repo.GetUserById(context.Background(), created.Id)
+	require.NoError(t, err)
+	assert.Equal(t, "hipertension", read.Enfermedades)
+	assert.True(t, read.Diabetico)
+
+	stats, err = repo.Reencrypt(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, clientUsers.ReencryptStats{Scanned: 1}, stats)
+}
+
+func TestEncrypted_PatchReencryptsOnlyWhenSensitive(t *testing.T) {
+	backend := clientUsers.NewMemory()
+	repo := newEncrypted(t, backend, "k1:"+testKey(1))
+	created, err := repo.InsertUser(context.Background(), medicalUser("ana", false))
+	require.NoError(t, err)
+	before, err := backend.GetUserById(context.Background(), created.Id)
+	require.NoError(t, err)
+
+	patched, err := repo.PatchUser(context.Background(), created.Id, created.Version, map[string]interface{}{"estado": false})
+	require.NoError(t, err)
+	assert.Equal(t, "hipertension", patched.Enfermedades)
+	raw, err := backend.GetUserById(context.Background(), created.Id)
+	require.NoError(t, err)
+	assert.Equal(t, before.DataKey, raw.DataKey)
+
+	patched, err = repo.PatchUser(context.Background(), created.Id, patched.Version, map[string]interface{}{"diabetico": true, "nombre": "ana maria"})
+	require.NoError(t, err)
+	assert.True(t, patched.Diabetico)
+	assert.Equal(t, "ana maria", patched.Nombre)
+	assert.Equal(t, "hipertension", patched.Enfermedades)
+	assert.False(t, patched.Estado)
+	raw, err = backend.GetUserById(context.Background(), created.Id)
+	require.NoError(t, err)
+	assert.NotEqual(t, before.DataKey, raw.DataKey)
+	assert.NotEqual(t, before.DiabeticoIndex, raw.DiabeticoIndex)
+	assert.False(t, raw.Diabetico)
+
+	_, err = repo.PatchUser(context.Background(), created.Id, created.Version, map[string]interface{}{"enfermedades": "asma"})
+	assert.ErrorIs(t, err, Domain.ErrPreconditionFailed)
+}
+
+func TestEncrypted_LegacyRowsAreReadAndReencrypted(t *testing.T) {
+	backend, err := clientUsers.NewSQLite(clientUsers.Config{})
+	require.NoError(t, err)
+	defer backend.Close()
+	legacy, err := backend.InsertUser(context.Background(), medicalUser("ana", true))
+	require.NoError(t, err)
+	repo := newEncrypted(t, backend, "k1:"+testKey(1))
+	_, err = repo.InsertUser(context.Background(), medicalUser("eva", true))
+	require.NoError(t, err)
+	_, err = repo.InsertUser(context.Background(), medicalUser("luz", false))
+	require.NoError(t, err)
+
+	read, err := repo.GetUserById(context.Background(), legacy.Id)
+	require.NoError(t, err)
+	assert.Equal(t, legacy, read)
+
+	// El filtro por diabetico alcanza a las filas en texto plano y a las
+	// cifradas.
+	diabetic := func() []string {
+		yes := true
+		var nombres []string
+		err := repo.ScanUsers(context.Background(), Model.UserFilter{Diabetico: &yes}, func(user Model.User) error {
+			assert.True(t, user.Diabetico)
+			nombres = append(nombres, user.Nombre)
+			return nil
+		})
+		require.NoError(t, err)
+		return nombres
+	}
+	assert.Equal(t, []string{"ana", "eva"}, diabetic())
+
+	stats, err := repo.Reencrypt(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, clientUsers.ReencryptStats{Scanned: 3, Reencrypted: 1}, stats)
+
+	raw, err := backend.GetUserById(context.Background(), legacy.Id)
+	require.NoError(t, err)
+	assert.Empty(t, raw.Enfermedades)
+	assert.NotEmpty(t, raw.DataKey)
+	// Re-cifrar no es un cambio de los datos: la version y UpdatedAt quedan.
+	assert.Equal(t, legacy.Version, raw.Version)
+	assert.Equal(t, legacy.UpdatedAt, raw.UpdatedAt)
+	assert.Equal(t, []string{"ana", "eva"}, diabetic())
+
+	stats, err = repo.Reencrypt(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, clientUsers.ReencryptStats{Scanned: 3}, stats)
+}
+
+func TestEncrypted_KeyRotation(t *testing.T) {
+	backend := clientUsers.NewMemory()
+	old := newEncrypted(t, backend, "k1:"+testKey(1))
+	created, err := old.InsertUser(context.Background(), medicalUser("ana", true))
+	require.NoError(t, err)
+
+	// La clave nueva pasa a ser la activa y la vieja queda para leer.
+	rotated := newEncrypted(t, backend, "k2:"+testKey(2)+",k1:"+testKey(1))
+	read, err := rotated.GetUserById(context.Background(), created.Id)
+	require.NoError(t, err)
+	assert.Equal(t, "hipertension", read.Enfermedades)
+
+	stats, err := rotated.Reencrypt(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 1, stats.Reencrypted)
+
+	// Terminada la rotacion la clave vieja ya no hace falta.
+	onlyNew := newEncrypted(t, backend, "k2:"+testKey(2))
+	read, err = onlyNew.GetUserById(context.Background(), created.Id)
+	require.NoError(t, err)
+	assert.Equal(t, "hipertension", read.Enfermedades)
+	assert.True(t, read.Diabetico)
+
+	// Sin la clave de una fila, la pasada la cuenta como fallida y sigue.
+	_, err = old.InsertUser(context.Background(), medicalUser("eva", false))
+	require.NoError(t, err)
+	stats, err = onlyNew.Reencrypt(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, clientUsers.ReencryptStats{Scanned: 2, Failed: 1}, stats)
+	stats, err = newEncrypted(t, backend, "k3:"+testKey(3)).Reencrypt(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, clientUsers.ReencryptStats{Scanned: 2, Failed: 2}, stats)
+}
+
+func TestEncrypted_StartReencryptionStops(t *testing.T) {
+	backend := clientUsers.NewMemory()
+	_, err := backend.InsertUser(context.Background(), medicalUser("ana", true))
+	require.NoError(t, err)
+	repo := newEncrypted(t, backend, "k1:"+testKey(1))
+
+	stop := repo.StartReencryption(time.Hour)
+	require.Eventually(t, func() bool {
+		raw, err := backend.GetUserById(context.Background(), 1)
+		return err == nil && raw.DataKey != ""
+	}, time.Second, 10*time.Millisecond)
+	require.NoError(t, stop(context.Background()))
+}
+
+func TestNewEncryption(t *testing.T) {
+	repo, err := clientUsers.NewEncryption(clientUsers.NewMemory(), clientUsers.EncryptionConfig{})
+	require.NoError(t, err)
+	assert.Nil(t, repo)
+
+	for name, config := range map[string]clientUsers.EncryptionConfig{
+		"sin id":              {MasterKeys: testKey(1), IndexKey: testKey(2)},
+		"clave corta":         {MasterKeys: "k1:c2hvcnQ=", IndexKey: testKey(2)},
+		"id repetido":         {MasterKeys: "k1:" + testKey(1) + ",k1:" + testKey(2), IndexKey: testKey(2)},
+		"activa inexistente":  {MasterKeys: "k1:" + testKey(1), ActiveKey: "k9", IndexKey: testKey(2)},
+		"sin clave de indice": {MasterKeys: "k1:" + testKey(1)},
+	} {
+		_, err := clientUsers.NewEncryption(clientUsers.NewMemory(), config)
+		assert.Error(t, err, name)
+	}
+
+	// Base64 URL y sin relleno tambien valen.
+	urlKey := strings.TrimRight(strings.NewReplacer("+", "-", "/", "_").Replace(testKey(200)), "=")
+	_, err = clientUsers.NewEncryption(clientUsers.NewMemory(), clientUsers.EncryptionConfig{MasterKeys: "k1:" + urlKey, IndexKey: testKey(2)})
+	assert.NoError(t, err)
+}
@@ -0,0 +1,44 @@
+package clientUsers
+
+import (
+	Domain "Golang/domain"
+	"errors"
+	"fmt"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/jinzhu/gorm"
+	"github.com/lib/pq"
+	"github.com/mattn/go-sqlite3"
+)
+
+// classify traduce un error de gorm o del driver a los errores de domain,
+// conservando el original para los logs.
+func classify(err error, action string) error {
+	switch {
+	case err == nil:
+		return nil
+	case gorm.IsRecordNotFoundError(err):
+		return fmt.Errorf("%s: %w", action, Domain.ErrNotFound)
+	case isDuplicateKey(err):
+		return fmt.Errorf("%s: %w", action, Domain.ErrConflict)
+	default:
+		return fmt.Errorf("%s: %v", action, err)
+	}
+}
+
+func isDuplicateKey(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) {
+		return mysqlErr.Number == 1062
+	}
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		return pqErr.Code == "23505"
+	}
+	var sqliteErr sqlite3.Error
+	if errors.As(err, &sqliteErr) {
+		return sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique ||
+			sqliteErr.ExtendedCode == sqlite3.ErrConstraintPrimaryKey
+	}
+	return false
+}
@@ -0,0 +1,51 @@
+package clientUsers
+
+import (
+	Model "Golang/model"
+	"context"
+	"encoding/base64"
+	"time"
+)
+
+// Truncate vacia la tabla de usuarios. Solo existe para que los tests de
+// conformidad puedan reutilizar una base MySQL o PostgreSQL real.
+func Truncate(repository SQL) error {
+	return repository.db.Unscoped().Delete(&Model.User{}).Error
+}
+
+// SetClock reemplaza el reloj de la cache para probar vencimientos sin
+// esperar.
+func (cache *LRU) SetClock(clock func() time.Time) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	cache.clock = clock
+}
+
+// SealLegacy vuelve a cifrar las columnas de user con el formato "v1", que
+// solo usa la columna como dato asociado, para probar la migracion de las
+// filas viejas.
+func (repository *Encrypted) SealLegacy(ctx context.Context, user *Model.User) error {
+	aead, err := repository.dataAEAD(ctx, user.DataKey)
+	if err != nil {
+		return err
+	}
+	for _, field := range []struct {
+		column string
+		target *string
+	}{
+		{"atributos", &user.AtributosEnc},
+		{"enfermedades", &user.EnfermedadesEnc},
+		{"diabetico", &user.DiabeticoEnc},
+	} {
+		plain, err := openField(aead, field.column, user.Id, *field.target)
+		if err != nil {
+			return err
+		}
+		sealed, err := seal(aead, []byte(plain), []byte(field.column))
+		if err != nil {
+			return err
+		}
+		*field.target = encryptionVersion + ":" + base64.StdEncoding.EncodeToString(sealed)
+	}
+	return nil
+}
@@ -0,0 +1,37 @@
+package clientUsers
+
+import (
+	Model "Golang/model"
+	"context"
+	"fmt"
+)
+
+// Ping verifica que la base responda dentro del plazo de ctx.
+func (repository SQL) Ping(ctx context.Context) error {
+	return repository.db.DB().PingContext(ctx)
+}
+
+// CheckSchema verifica que la migracion este aplicada: todas las tablas
+// existen, tienen todas las columnas del modelo y esta el indice unico de
+// nombre. gorm v1 no acepta contexto, el plazo lo controla quien llama.
+func (repository SQL) CheckSchema(ctx context.Context) error {
+	for _, model := range []interface{}{&Model.User{}, &Model.ConsentVersion{}, &Model.Consent{}, &Model.Login{}, &Model.JobLock{}} {
+		scope := repository.db.NewScope(model)
+		table := scope.TableName()
+		if !scope.Dialect().HasTable(table) {
+			return fmt.Errorf("table %s does not exist", table)
+		}
+		for _, field := range scope.GetModelStruct().StructFields {
+			if field.IsIgnored || !field.IsNormal {
+				continue
+			}
+			if !scope.Dialect().HasColumn(table, field.DBName) {
+				return fmt.Errorf("column %s.%s does not exist", table, field.DBName)
+			}
+		}
+	}
+	if table := repository.db.NewScope(&Model.User{}).TableName(); !repository.db.Dialect().HasIndex(table, nombreIndex) {
+		return fmt.Errorf("index %s.%s does not exist", table, nombreIndex)
+	}
+	return nil
+}
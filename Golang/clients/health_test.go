@@ -0,0 +1,46 @@
+package clientUsers_test
+
+import (
+	"context"
+	"testing"
+
+	clientUsers "Golang/clients"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSQL_PingAndSchema(t *testing.T) {
+	repo, err := clientUsers.NewSQLite(clientUsers.Config{})
+	require.NoError(t, err)
+
+	assert.NoError(t, repo.Ping(context.Background()))
+	assert.NoError(t, repo.CheckSchema(context.Background()))
+
+	_, err = repo.DB().Exec("DROP TABLE users")
+	require.NoError(t, err)
+	assert.ErrorContains(t, repo.CheckSchema(context.Background()), "users does not exist")
+
+	require.NoError(t, repo.Close())
+	assert.Error(t, repo.Ping(context.Background()))
+}
+
+func TestSQL_CheckSchemaCoversConsents(t *testing.T) {
+	repo, err := clientUsers.NewSQLite(clientUsers.Config{})
+	require.NoError(t, err)
+	defer repo.Close()
+
+	_, err = repo.DB().Exec("DROP TABLE consents")
+	require.NoError(t, err)
+	assert.ErrorContains(t, repo.CheckSchema(context.Background()), "consents does not exist")
+}
+
+func TestSQL_CheckSchemaCoversNombreIndex(t *testing.T) {
+	repo, err := clientUsers.NewSQLite(clientUsers.Config{})
+	require.NoError(t, err)
+	defer repo.Close()
+
+	_, err = repo.DB().Exec("DROP INDEX idx_nombre")
+	require.NoError(t, err)
+	assert.ErrorContains(t, repo.CheckSchema(context.Background()), "idx_nombre does not exist")
+}
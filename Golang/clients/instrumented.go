@@ -0,0 +1,136 @@
+package clientUsers
+
+import (
+	Domain "Golang/domain"
+	"Golang/metrics"
+	Model "Golang/model"
+	"Golang/tracing"
+	"context"
+	"errors"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Instrumented mide la duracion de cada llamada al repositorio envuelto y la
+// publica en metrics, por metodo y resultado, y abre un span por llamada del
+// que cuelgan los de cada sentencia SQL. Va directamente sobre el backend,
+// por debajo de Cached, para medir las consultas reales.
+type Instrumented struct {
+	next Repository
+}
+
+func NewInstrumented(next Repository) Instrumented {
+	return Instrumented{next: next}
+}
+
+func (repository Instrumented) GetUserById(ctx context.Context, Id int) (result Model.User, err error) {
+	ctx, span := tracer.Start(ctx, "Repository.GetUserById")
+	defer observe(span, "GetUserById", time.Now(), &err)
+	return repository.next.GetUserById(ctx, Id)
+}
+
+func (repository Instrumented) UpdateUser(ctx context.Context, User Model.User) (result Model.User, err error) {
+	ctx, span := tracer.Start(ctx, "Repository.UpdateUser")
+	defer observe(span, "UpdateUser", time.Now(), &err)
+	return repository.next.UpdateUser(ctx, User)
+}
+
+func (repository Instrumented) PatchUser(ctx context.Context, Id int, version int, fields map[string]interface{}) (result Model.User, err error) {
+	ctx, span := tracer.Start(ctx, "Repository.PatchUser")
+	defer observe(span, "PatchUser", time.Now(), &err)
+	return repository.next.PatchUser(ctx, Id, version, fields)
+}
+
+func (repository Instrumented) InsertUser(ctx context.Context, user Model.User) (result Model.User, err error) {
+	ctx, span := tracer.Start(ctx, "Repository.InsertUser")
+	defer observe(span, "InsertUser", time.Now(), &err)
+	return repository.next.InsertUser(ctx, user)
+}
+
+func (repository Instrumented) GetUserByName(ctx context.Context, Usuario Model.User) (result Model.User, err error) {
+	ctx, span := tracer.Start(ctx, "Repository.GetUserByName")
+	defer observe(span, "GetUserByName", time.Now(), &err)
+	return repository.next.GetUserByName(ctx, Usuario)
+}
+
+func (repository Instrumented) GetAllUsers(ctx context.Context) (result []Model.User, err error) {
+	ctx, span := tracer.Start(ctx, "Repository.GetAllUsers")
+	defer observe(span, "GetAllUsers", time.Now(), &err)
+	return repository.next.GetAllUsers(ctx)
+}
+
+func (repository Instrumented) GetUsersStamp(ctx context.Context) (result Model.UsersStamp, err error) {
+	ctx, span := tracer.Start(ctx, "Repository.GetUsersStamp")
+	defer observe(span, "GetUsersStamp", time.Now(), &err)
+	return repository.next.GetUsersStamp(ctx)
+}
+
+func (repository Instrumented) InsertUsers(ctx context.Context, users []Model.User) (result []Model.User, err error) {
+	ctx, span := tracer.Start(ctx, "Repository.InsertUsers", trace.WithAttributes(attribute.Int("repository.batch_size", len(users))))
+	defer observe(span, "InsertUsers", time.Now(), &err)
+	return repository.next.InsertUsers(ctx, users)
+}
+
+func (repository Instrumented) InsertSealed(ctx context.Context, users []Model.User, seal func(*Model.User) error) (result []Model.User, err error) {
+	ctx, span := tracer.Start(ctx, "Repository.InsertSealed", trace.WithAttributes(attribute.Int("repository.batch_size", len(users))))
+	defer observe(span, "InsertSealed", time.Now(), &err)
+	return repository.next.InsertSealed(ctx, users, seal)
+}
+
+func (repository Instrumented) RewriteColumns(ctx context.Context, Id int, version int, fields map[string]interface{}) (err error) {
+	ctx, span := tracer.Start(ctx, "Repository.RewriteColumns")
+	defer observe(span, "RewriteColumns", time.Now(), &err)
+	return repository.next.RewriteColumns(ctx, Id, version, fields)
+}
+
+func (repository Instrumented) ExistingNames(ctx context.Context, nombres []string) (result []string, err error) {
+	ctx, span := tracer.Start(ctx, "Repository.ExistingNames", trace.WithAttributes(attribute.Int("repository.batch_size", len(nombres))))
+	defer observe(span, "ExistingNames", time.Now(), &err)
+	return repository.next.ExistingNames(ctx, nombres)
+}
+
+func (repository Instrumented) DeleteUser(ctx context.Context, Id int) (err error) {
+	ctx, span := tracer.Start(ctx, "Repository.DeleteUser")
+	defer observe(span, "DeleteUser", time.Now(), &err)
+	return repository.next.DeleteUser(ctx, Id)
+}
+
+func (repository Instrumented) ScanUsers(ctx context.Context, filter Model.UserFilter, fn func(Model.User) error) (err error) {
+	ctx, span := tracer.Start(ctx, "Repository.ScanUsers")
+	defer observe(span, "ScanUsers", time.Now(), &err)
+	return repository.next.ScanUsers(ctx, filter, fn)
+}
+
+// observe publica la duracion y cierra el span de la llamada. Solo las
+// fallas de la base marcan el span como error; un no encontrado o un
+// conflicto quedan como atributo.
+func observe(span trace.Span, method string, start time.Time, err *error) {
+	result := outcome(*err)
+	metrics.ObserveRepository(method, result, time.Since(start))
+
+	span.SetAttributes(attribute.String("repository.result", result))
+	if result == "error" {
+		tracing.End(span, err)
+		return
+	}
+	span.End()
+}
+
+// outcome separa los errores esperables (no existe, duplicado, version
+// vieja) de las fallas de la base.
+func outcome(err error) string {
+	switch {
+	case err == nil:
+		return "ok"
+	case errors.Is(err, Domain.ErrNotFound):
+		return "not_found"
+	case errors.Is(err, Domain.ErrConflict):
+		return "conflict"
+	case errors.Is(err, Domain.ErrPreconditionFailed):
+		return "precondition_failed"
+	default:
+		return "error"
+	}
+}
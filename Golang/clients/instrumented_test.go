@@ -0,0 +1,56 @@
+package clientUsers_test
+
+import (
+	"context"
+	"testing"
+
+	clientUsers "Golang/clients"
+	Domain "Golang/domain"
+	"Golang/metrics"
+	Model "Golang/model"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func repositoryCalls(method string, result string) float64 {
+	return metrics.Sample("users_repository_call_duration_seconds", prometheus.Labels{"method": method, "result": result})
+}
+
+func TestInstrumented_RecordsMethodAndResult(t *testing.T) {
+	repo := clientUsers.NewInstrumented(clientUsers.NewMemory())
+
+	insertOk := repositoryCalls("InsertUser", "ok")
+	insertConflict := repositoryCalls("InsertUser", "conflict")
+	getNotFound := repositoryCalls("GetUserById", "not_found")
+	updateStale := repositoryCalls("UpdateUser", "precondition_failed")
+
+	created, err := repo.InsertUser(context.Background(), Model.User{Nombre: "ana"})
+	require.NoError(t, err)
+	_, err = repo.InsertUser(context.Background(), Model.User{Nombre: "ana"})
+	assert.ErrorIs(t, err, Domain.ErrConflict)
+	_, err = repo.GetUserById(context.Background(), 99)
+	assert.ErrorIs(t, err, Domain.ErrNotFound)
+	created.Version = 5
+	_, err = repo.UpdateUser(context.Background(), created)
+	assert.ErrorIs(t, err, Domain.ErrPreconditionFailed)
+
+	assert.Equal(t, insertOk+1, repositoryCalls("InsertUser", "ok"))
+	assert.Equal(t, insertConflict+1, repositoryCalls("InsertUser", "conflict"))
+	assert.Equal(t, getNotFound+1, repositoryCalls("GetUserById", "not_found"))
+	assert.Equal(t, updateStale+1, repositoryCalls("UpdateUser", "precondition_failed"))
+}
+
+func TestInstrumented_PublishesPoolStats(t *testing.T) {
+	repo, err := clientUsers.NewSQLite(clientUsers.Config{})
+	require.NoError(t, err)
+	defer repo.Close()
+
+	require.NoError(t, metrics.RegisterDB(repo.DB(), "instrumented-test"))
+
+	_, err = repo.InsertUser(context.Background(), Model.User{Nombre: "ana"})
+	require.NoError(t, err)
+
+	assert.Equal(t, float64(1), metrics.Sample("go_sql_max_open_connections", prometheus.Labels{"db_name": "instrumented-test"}))
+}
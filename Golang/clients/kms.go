@@ -0,0 +1,145 @@
+package clientUsers
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// KeyManager envuelve y desenvuelve las claves de datos con claves maestras
+// que nunca salen de el. LocalKMS lo implementa con claves de la
+// configuracion; un KMS real (AWS, GCP, Vault) cumpliria la misma interfaz.
+type KeyManager interface {
+	// WrapKey cifra dataKey con la clave maestra activa y devuelve su id.
+	WrapKey(ctx context.Context, dataKey []byte) (keyId string, wrapped []byte, err error)
+	// UnwrapKey descifra una clave de datos envuelta con la clave keyId.
+	UnwrapKey(ctx context.Context, keyId string, wrapped []byte) ([]byte, error)
+	// ActiveKeyId es la clave con la que se envuelven las claves nuevas. Las
+	// filas envueltas con otra se re-cifran en segundo plano.
+	ActiveKeyId() string
+}
+
+// keySize es el largo de las claves maestras, de datos y del indice: AES-256.
+const keySize = 32
+
+var keyIdPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// errUnknownKey se devuelve al desenvolver con una clave maestra que ya no
+// esta en la configuracion.
+var errUnknownKey = errors.New("unknown master key")
+
+// LocalKMS es un KeyManager en proceso, con las claves maestras de la
+// configuracion. Reemplaza a un KMS externo en desarrollo y en instalaciones
+// chicas.
+type LocalKMS struct {
+	keys   map[string]cipher.AEAD
+	active string
+}
+
+// NewLocalKMS interpreta masterKeys, una lista "id:base64" separada por
+// comas con claves de 32 bytes. active es el id de la clave activa; vacio
+// usa la primera de la lista.
+func NewLocalKMS(masterKeys string, active string) (*LocalKMS, error) {
+	kms := &LocalKMS{keys: make(map[string]cipher.AEAD)}
+	for _, entry := range strings.Split(masterKeys, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		id, encoded, ok := strings.Cut(entry, ":")
+		if !ok || !keyIdPattern.MatchString(id) {
+			return nil, fmt.Errorf("master key %q: must be id:base64 with an id of letters, digits, - or _", id)
+		}
+		if _, repeated := kms.keys[id]; repeated {
+			return nil, fmt.Errorf("master key %q: repeated id", id)
+		}
+		key, err := decodeKey(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("master key %q: %w", id, err)
+		}
+		aead, err := newAEAD(key)
+		if err != nil {
+			return nil, fmt.Errorf("master key %q: %w", id, err)
+		}
+		kms.keys[id] = aead
+		if kms.active == "" {
+			kms.active = id
+		}
+	}
+	if len(kms.keys) == 0 {
+		return nil, errors.New("no master keys configured")
+	}
+	if active != "" {
+		if _, ok := kms.keys[active]; !ok {
+			return nil, fmt.Errorf("active master key %q is not configured", active)
+		}
+		kms.active = active
+	}
+	return kms, nil
+}
+
+func (kms *LocalKMS) WrapKey(ctx context.Context, dataKey []byte) (string, []byte, error) {
+	wrapped, err := seal(kms.keys[kms.active], dataKey, []byte(kms.active))
+	return kms.active, wrapped, err
+}
+
+// UnwrapKey usa el id como dato asociado, asi una clave envuelta no se puede
+// presentar como envuelta por otra clave maestra.
+func (kms *LocalKMS) UnwrapKey(ctx context.Context, keyId string, wrapped []byte) ([]byte, error) {
+	aead, ok := kms.keys[keyId]
+	if !ok {
+		return nil, fmt.Errorf("%w %q", errUnknownKey, keyId)
+	}
+	return open(aead, wrapped, []byte(keyId))
+}
+
+func (kms *LocalKMS) ActiveKeyId() string {
+	return kms.active
+}
+
+// decodeKey acepta base64 estandar o URL, con o sin relleno.
+func decodeKey(encoded string) ([]byte, error) {
+	encoded = strings.TrimRight(strings.TrimSpace(encoded), "=")
+	key, err := base64.RawStdEncoding.DecodeString(encoded)
+	if err != nil {
+		key, err = base64.RawURLEncoding.DecodeString(encoded)
+	}
+	if err != nil {
+		return nil, errors.New("invalid base64")
+	}
+	if len(key) != keySize {
+		return nil, fmt.Errorf("must be %d bytes (got %d)", keySize, len(key))
+	}
+	return key, nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// seal cifra con AES-GCM y antepone el nonce aleatorio al resultado.
+func seal(aead cipher.AEAD, plaintext []byte, additional []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plaintext)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("generating nonce: %w", err)
+	}
+	return aead.Seal(nonce, nonce, plaintext, additional), nil
+}
+
+func open(aead cipher.AEAD, sealed []byte, additional []byte) ([]byte, error) {
+	if len(sealed) < aead.NonceSize() {
+		return nil, errors.New("ciphertext too short")
+	}
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	return aead.Open(nil, nonce, ciphertext, additional)
+}
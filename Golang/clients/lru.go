@@ -0,0 +1,99 @@
+package clientUsers
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// LRU es una cache en memoria del proceso con capacidad fija: al llenarse
+// descarta la entrada usada hace mas tiempo. Las entradas vencidas se
+// descartan al leerlas.
+type LRU struct {
+	mu       sync.Mutex
+	capacity int
+	order    *list.List
+	entries  map[string]*list.Element
+	clock    func() time.Time
+}
+
+type lruEntry struct {
+	key     string
+	value   []byte
+	expires time.Time
+}
+
+func NewLRU(capacity int) *LRU {
+	if capacity <= 0 {
+		capacity = defaultCacheSize
+	}
+	return &LRU{
+		capacity: capacity,
+		order:    list.New(),
+		entries:  make(map[string]*list.Element),
+		clock:    time.Now,
+	}
+}
+
+func (cache *LRU) Get(key string) ([]byte, bool, error) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	element, ok := cache.entries[key]
+	if !ok {
+		return nil, false, nil
+	}
+	entry := element.Value.(*lruEntry)
+	if !cache.clock().Before(entry.expires) {
+		cache.remove(element)
+		return nil, false, nil
+	}
+	cache.order.MoveToFront(element)
+
+	return entry.value, true, nil
+}
+
+func (cache *LRU) Set(key string, value []byte, ttl time.Duration) error {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	expires := cache.clock().Add(ttl)
+	if element, ok := cache.entries[key]; ok {
+		entry := element.Value.(*lruEntry)
+		entry.value = value
+		entry.expires = expires
+		cache.order.MoveToFront(element)
+		return nil
+	}
+
+	cache.entries[key] = cache.order.PushFront(&lruEntry{key: key, value: value, expires: expires})
+	for cache.order.Len() > cache.capacity {
+		cache.remove(cache.order.Back())
+	}
+	return nil
+}
+
+func (cache *LRU) Delete(keys ...string) error {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	for _, key := range keys {
+		if element, ok := cache.entries[key]; ok {
+			cache.remove(element)
+		}
+	}
+	return nil
+}
+
+// Len devuelve la cantidad de entradas guardadas, vencidas o no.
+func (cache *LRU) Len() int {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	return cache.order.Len()
+}
+
+// remove debe llamarse con el lock tomado.
+func (cache *LRU) remove(element *list.Element) {
+	cache.order.Remove(element)
+	delete(cache.entries, element.Value.(*lruEntry).key)
+}
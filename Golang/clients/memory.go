@@ -0,0 +1,351 @@
+package clientUsers
+
+import (
+	Domain "Golang/domain"
+	Model "Golang/model"
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Memory es un repositorio de usuarios en memoria, pensado para tests y
+// desarrollo local. Respeta las mismas reglas que SQL: ids autoincrementales
+// y nombres unicos.
+type Memory struct {
+	mu     sync.RWMutex
+	users  map[int]Model.User
+	nextId int
+
+	consentVersions []Model.ConsentVersion
+	consents        []Model.Consent
+	logins          []Model.Login
+	locks           map[string]Model.JobLock
+}
+
+func NewMemory() *Memory {
+	return &Memory{
+		users:  make(map[int]Model.User),
+		nextId: 1,
+		locks:  make(map[string]Model.JobLock),
+	}
+}
+
+func (repository *Memory) InsertUser(ctx context.Context, user Model.User) (Model.User, error) {
+	repository.mu.Lock()
+	defer repository.mu.Unlock()
+
+	if _, found := repository.findByName(user.Nombre); found {
+		return user, fmt.Errorf("error creating user: %w", Domain.ErrConflict)
+	}
+
+	user.Id = repository.nextId
+	user.Version = 1
+	user.UpdatedAt = now()
+	repository.nextId++
+	repository.users[user.Id] = user
+
+	return user, nil
+}
+
+// InsertUsers controla todos los nombres antes de guardar, asi un conflicto
+// no deja el lote a medias.
+func (repository *Memory) InsertUsers(ctx context.Context, users []Model.User) ([]Model.User, error) {
+	return repository.InsertSealed(ctx, users, nil)
+}
+
+func (repository *Memory) InsertSealed(ctx context.Context, users []Model.User, seal func(*Model.User) error) ([]Model.User, error) {
+	repository.mu.Lock()
+	defer repository.mu.Unlock()
+
+	nombres := make(map[string]bool, len(users))
+	for _, user := range users {
+		if _, found := repository.findByName(user.Nombre); found || nombres[user.Nombre] {
+			return nil, fmt.Errorf("error importing users: %w", Domain.ErrConflict)
+		}
+		nombres[user.Nombre] = true
+	}
+
+	created := make([]Model.User, 0, len(users))
+	for i, user := range users {
+		user.Id = repository.nextId + i
+		user.Version = 1
+		user.UpdatedAt = now()
+		if seal != nil {
+			if err := seal(&user); err != nil {
+				return nil, err
+			}
+		}
+		created = append(created, user)
+	}
+	for _, user := range created {
+		repository.users[user.Id] = user
+	}
+	repository.nextId += len(created)
+
+	return created, nil
+}
+
+func (repository *Memory) DeleteUser(ctx context.Context, Id int) error {
+	repository.mu.Lock()
+	defer repository.mu.Unlock()
+
+	if _, ok := repository.users[Id]; !ok {
+		return fmt.Errorf("error deleting user %d: %w", Id, Domain.ErrNotFound)
+	}
+	delete(repository.users, Id)
+
+	return nil
+}
+
+func (repository *Memory) GetUserById(ctx context.Context, Id int) (Model.User, error) {
+	repository.mu.RLock()
+	defer repository.mu.RUnlock()
+
+	user, ok := repository.users[Id]
+	if !ok {
+		return Model.User{}, fmt.Errorf("error finding user %d: %w", Id, Domain.ErrNotFound)
+	}
+
+	return user, nil
+}
+
+func (repository *Memory) UpdateUser(ctx context.Context, User Model.User) (Model.User, error) {
+	repository.mu.Lock()
+	defer repository.mu.Unlock()
+
+	stored, ok := repository.users[User.Id]
+	if !ok {
+		return Model.User{}, fmt.Errorf("error finding document %d: %w", User.Id, Domain.ErrNotFound)
+	}
+	if stored.Version != User.Version {
+		return Model.User{}, fmt.Errorf("error updating user %d: %w", User.Id, Domain.ErrPreconditionFailed)
+	}
+	if other, found := repository.findByName(User.Nombre); found && other.Id != User.Id {
+		return User, fmt.Errorf("error updating user: %w", Domain.ErrConflict)
+	}
+
+	User.Password = stored.Password
+	User.Version++
+	User.UpdatedAt = now()
+	repository.users[User.Id] = User
+
+	return User, nil
+}
+
+func (repository *Memory) PatchUser(ctx context.Context, Id int, version int, fields map[string]interface{}) (Model.User, error) {
+	repository.mu.Lock()
+	defer repository.mu.Unlock()
+
+	user, ok := repository.users[Id]
+	if !ok {
+		return Model.User{}, fmt.Errorf("error finding document %d: %w", Id, Domain.ErrNotFound)
+	}
+	if user.Version != version {
+		return Model.User{}, fmt.Errorf("error patching user %d: %w", Id, Domain.ErrPreconditionFailed)
+	}
+	if len(fields) == 0 {
+		return user, nil
+	}
+	if err := applyFields(&user, fields); err != nil {
+		return Model.User{}, err
+	}
+	if other, found := repository.findByName(user.Nombre); found && other.Id != Id {
+		return Model.User{}, fmt.Errorf("error patching user: %w", Domain.ErrConflict)
+	}
+
+	user.Version++
+	user.UpdatedAt = now()
+	repository.users[Id] = user
+
+	return user, nil
+}
+
+func (repository *Memory) RewriteColumns(ctx context.Context, Id int, version int, fields map[string]interface{}) error {
+	repository.mu.Lock()
+	defer repository.mu.Unlock()
+
+	user, ok := repository.users[Id]
+	if !ok {
+		return fmt.Errorf("error finding document %d: %w", Id, Domain.ErrNotFound)
+	}
+	if user.Version != version {
+		return fmt.Errorf("error rewriting user %d: %w", Id, Domain.ErrPreconditionFailed)
+	}
+	if err := applyFields(&user, fields); err != nil {
+		return err
+	}
+	repository.users[Id] = user
+
+	return nil
+}
+
+func (repository *Memory) GetUserByName(ctx context.Context, Usuario Model.User) (Model.User, error) {
+	repository.mu.RLock()
+	defer repository.mu.RUnlock()
+
+	user, found := repository.findByName(Usuario.Nombre)
+	if !found {
+		return Model.User{}, fmt.Errorf("error searching user by name: %w", Domain.ErrNotFound)
+	}
+
+	return user, nil
+}
+
+func (repository *Memory) GetAllUsers(ctx context.Context) ([]Model.User, error) {
+	repository.mu.RLock()
+	defer repository.mu.RUnlock()
+
+	users := make([]Model.User, 0, len(repository.users))
+	for _, user := range repository.users {
+		users = append(users, user)
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].Id < users[j].Id })
+
+	return users, nil
+}
+
+func (repository *Memory) GetUsersStamp(ctx context.Context) (Model.UsersStamp, error) {
+	repository.mu.RLock()
+	defer repository.mu.RUnlock()
+
+	var stamp Model.UsersStamp
+	for _, user := range repository.users {
+		stamp.Count++
+		stamp.VersionSum += user.Version
+		if user.Id > stamp.MaxId {
+			stamp.MaxId = user.Id
+		}
+		if user.UpdatedAt.After(stamp.LastModified) {
+			stamp.LastModified = user.UpdatedAt
+		}
+	}
+
+	return stamp, nil
+}
+
+func (repository *Memory) ExistingNames(ctx context.Context, nombres []string) ([]string, error) {
+	repository.mu.RLock()
+	defer repository.mu.RUnlock()
+
+	wanted := make(map[string]bool, len(nombres))
+	for _, nombre := range nombres {
+		wanted[nombre] = true
+	}
+	existing := make([]string, 0)
+	for _, user := range repository.users {
+		if wanted[user.Nombre] {
+			existing = append(existing, user.Nombre)
+		}
+	}
+	sort.Strings(existing)
+
+	return existing, nil
+}
+
+// ScanUsers copia los usuarios que cumplen filter antes de llamar a fn, asi
+// fn puede usar el repositorio sin bloquearse con el lock.
+func (repository *Memory) ScanUsers(ctx context.Context, filter Model.UserFilter, fn func(Model.User) error) error {
+	users, err := repository.GetAllUsers(ctx)
+	if err != nil {
+		return err
+	}
+
+	nombre := strings.ToLower(filter.Nombre)
+	for _, user := range users {
+		if filter.Admin != nil && user.Admin != *filter.Admin {
+			continue
+		}
+		if filter.Estado != nil && user.Estado != *filter.Estado {
+			continue
+		}
+		if filter.Diabetico != nil && !matchesDiabetico(user, filter) {
+			continue
+		}
+		if nombre != "" && !strings.Contains(strings.ToLower(user.Nombre), nombre) {
+			continue
+		}
+		if filter.Anonymized != nil && (user.AnonymizedAt != nil) != *filter.Anonymized {
+			continue
+		}
+		if !filter.DeactivatedBefore.IsZero() && (user.DeactivatedAt == nil || !user.DeactivatedAt.Before(filter.DeactivatedBefore)) {
+			continue
+		}
+		if err := fn(user); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// matchesDiabetico replica el filtro de SQL: el indice ciego en las filas
+// que lo tienen y la columna en texto plano en las demas.
+func matchesDiabetico(user Model.User, filter Model.UserFilter) bool {
+	if filter.DiabeticoIndex != "" && user.DiabeticoIndex != "" {
+		return user.DiabeticoIndex == filter.DiabeticoIndex
+	}
+	return user.Diabetico == *filter.Diabetico
+}
+
+// findByName debe llamarse con el lock tomado.
+func (repository *Memory) findByName(nombre string) (Model.User, bool) {
+	for _, user := range repository.users {
+		if user.Nombre == nombre {
+			return user, true
+		}
+	}
+	return Model.User{}, false
+}
+
+// applyFields replica sobre el struct lo que gorm hace con Updates(map):
+// las claves son nombres de columna.
+func applyFields(user *Model.User, fields map[string]interface{}) error {
+	for column, value := range fields {
+		var ok bool
+		switch column {
+		case "nombre":
+			user.Nombre, ok = value.(string)
+		case "password":
+			user.Password, ok = value.(string)
+		case "genero":
+			user.Genero, ok = value.(string)
+		case "atributos":
+			user.Atributos, ok = value.(string)
+		case "enfermedades":
+			user.Enfermedades, ok = value.(string)
+		case "maneja":
+			user.Maneja, ok = value.(bool)
+		case "lentes":
+			user.Lentes, ok = value.(bool)
+		case "diabetico":
+			user.Diabetico, ok = value.(bool)
+		case "admin":
+			user.Admin, ok = value.(bool)
+		case "estado":
+			user.Estado, ok = value.(bool)
+		case "atributos_enc":
+			user.AtributosEnc, ok = value.(string)
+		case "enfermedades_enc":
+			user.EnfermedadesEnc, ok = value.(string)
+		case "diabetico_enc":
+			user.DiabeticoEnc, ok = value.(string)
+		case "diabetico_index":
+			user.DiabeticoIndex, ok = value.(string)
+		case "data_key":
+			user.DataKey, ok = value.(string)
+		case "anonymized_at":
+			var at time.Time
+			if at, ok = value.(time.Time); ok {
+				user.AnonymizedAt = &at
+			}
+		case "deactivated_at":
+			user.DeactivatedAt, ok = value.(*time.Time)
+		}
+		if !ok {
+			return fmt.Errorf("error patching user: invalid column %q", column)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,25 @@
+package clientUsers
+
+import (
+	"fmt"
+
+	_ "github.com/jinzhu/gorm/dialects/mysql"
+)
+
+// NewMySQL abre una conexion MySQL. Por defecto usa el puerto 3306 y
+// tls=skip-verify, que es lo que necesita Azure Database for MySQL.
+func NewMySQL(config Config) (SQL, error) {
+	port := config.Port
+	if port == 0 {
+		port = 3306
+	}
+	tls := config.TLS
+	if tls == "" {
+		tls = "skip-verify"
+	}
+
+	dsn := fmt.Sprintf("%s:%s@tcp(%s:%d)/%s?charset=utf8&parseTime=True&tls=%s",
+		config.User, config.Pass, config.Host, port, config.Name, tls)
+
+	return openGorm("mysql", dsn, config.Name)
+}
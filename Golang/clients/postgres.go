@@ -0,0 +1,25 @@
+package clientUsers
+
+import (
+	"fmt"
+
+	_ "github.com/jinzhu/gorm/dialects/postgres"
+)
+
+// NewPostgres abre una conexion PostgreSQL. Por defecto usa el puerto 5432
+// y sslmode=require.
+func NewPostgres(config Config) (SQL, error) {
+	port := config.Port
+	if port == 0 {
+		port = 5432
+	}
+	sslmode := config.TLS
+	if sslmode == "" {
+		sslmode = "require"
+	}
+
+	dsn := fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=%s",
+		config.Host, port, config.User, config.Pass, config.Name, sslmode)
+
+	return openGorm("postgres", dsn, config.Name)
+}
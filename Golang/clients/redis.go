@@ -0,0 +1,89 @@
+package clientUsers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	redisTimeout     = 2 * time.Second
+	redisMaxIdle     = 8
+	redisDefaultAddr = "localhost:6379"
+)
+
+// Redis es una cache sobre un servidor Redis, con el cliente go-redis. Solo
+// usa GET, SET con PX y DEL, asi que sirve tambien con servicios
+// compatibles; Azure Cache for Redis exige TLS (puerto 6380), que se activa
+// con CacheConfig.TLS.
+type Redis struct {
+	client *redis.Client
+}
+
+// NewRedis se conecta a config.Addr (localhost:6379 si esta vacio) y
+// verifica la conexion con PING.
+func NewRedis(config CacheConfig) (*Redis, error) {
+	addr := config.Addr
+	if addr == "" {
+		addr = redisDefaultAddr
+	}
+	cache := &Redis{client: redis.NewClient(&redis.Options{
+		Addr:         addr,
+		Password:     config.Password,
+		DB:           config.DB,
+		TLSConfig:    config.TLS,
+		DialTimeout:  redisTimeout,
+		ReadTimeout:  redisTimeout,
+		WriteTimeout: redisTimeout,
+		MaxIdleConns: redisMaxIdle,
+		// Respeta el vencimiento del contexto (Ping desde /readyz).
+		ContextTimeoutEnabled: true,
+		// Un fallo de la cache no es fatal (ver Cached): mejor fallar rapido
+		// que reintentar contra un servidor caido.
+		MaxRetries: -1,
+	})}
+
+	if err := cache.Ping(context.Background()); err != nil {
+		cache.client.Close()
+		return nil, fmt.Errorf("connecting to redis at %s: %w", addr, err)
+	}
+	return cache, nil
+}
+
+func (cache *Redis) Get(key string) ([]byte, bool, error) {
+	value, err := cache.client.Get(context.Background(), key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return value, true, nil
+}
+
+func (cache *Redis) Set(key string, value []byte, ttl time.Duration) error {
+	return cache.client.Set(context.Background(), key, value, ttl).Err()
+}
+
+func (cache *Redis) Delete(keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	return cache.client.Del(context.Background(), keys...).Err()
+}
+
+// Ping verifica que Redis responda antes de que venza ctx o redisTimeout,
+// lo que ocurra primero.
+func (cache *Redis) Ping(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, redisTimeout)
+	defer cancel()
+	return cache.client.Ping(ctx).Err()
+}
+
+// Close cierra las conexiones.
+func (cache *Redis) Close() error {
+	return cache.client.Close()
+}
@@ -0,0 +1,202 @@
+package clientUsers
+
+import (
+	Domain "Golang/domain"
+	Model "Golang/model"
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	DriverMySQL    = "mysql"
+	DriverPostgres = "postgres"
+	DriverSQLite   = "sqlite"
+	DriverMemory   = "memory"
+)
+
+// Config selecciona el backend de almacenamiento y sus datos de conexion.
+// Los campos que no aplican al driver elegido se ignoran.
+type Config struct {
+	Driver string
+	Name   string
+	User   string
+	Pass   string
+	Host   string
+	Port   int
+	// TLS es el parametro tls de MySQL o el sslmode de PostgreSQL.
+	TLS string
+	// Path es el archivo de la base SQLite (":memory:" si esta vacio).
+	Path string
+}
+
+// Repository es el contrato que cumple cualquier backend de usuarios.
+type Repository interface {
+	// GetUserById puede devolver Password vacio: Cached no guarda el hash.
+	// Para validar una contraseña se usa GetUserByName.
+	GetUserById(ctx context.Context, Id int) (Model.User, error)
+	// UpdateUser reemplaza los datos del usuario salvo Password, que solo
+	// cambia con PatchUser.
+	UpdateUser(ctx context.Context, User Model.User) (Model.User, error)
+	PatchUser(ctx context.Context, Id int, version int, fields map[string]interface{}) (Model.User, error)
+	InsertUser(ctx context.Context, user Model.User) (Model.User, error)
+	GetUserByName(ctx context.Context, Usuario Model.User) (Model.User, error)
+	GetAllUsers(ctx context.Context) ([]Model.User, error)
+	GetUsersStamp(ctx context.Context) (Model.UsersStamp, error)
+	// InsertUsers da de alta todos los usuarios o ninguno.
+	InsertUsers(ctx context.Context, users []Model.User) ([]Model.User, error)
+	// InsertSealed es InsertUsers, pero antes de confirmar el alta llama a
+	// seal con cada fila ya numerada y guarda las columnas cifradas que deje
+	// (ver Encrypted). Las filas quedan en la version 1.
+	InsertSealed(ctx context.Context, users []Model.User, seal func(*Model.User) error) ([]Model.User, error)
+	// RewriteColumns escribe fields si el usuario sigue en version, sin
+	// cambiar la version ni UpdatedAt: es para reescribir datos que no
+	// cambian, como el re-cifrado.
+	RewriteColumns(ctx context.Context, Id int, version int, fields map[string]interface{}) error
+	// ExistingNames devuelve cuales de nombres ya estan registrados.
+	ExistingNames(ctx context.Context, nombres []string) ([]string, error)
+	// ScanUsers llama a fn con cada usuario que cumple filter, en orden de
+	// id, sin cargar la tabla entera en memoria. Si fn devuelve un error el
+	// recorrido se corta y ScanUsers lo devuelve tal cual.
+	ScanUsers(ctx context.Context, filter Model.UserFilter, fn func(Model.User) error) error
+	// DeleteUser borra la fila del usuario; ErrNotFound si no existe.
+	DeleteUser(ctx context.Context, Id int) error
+}
+
+// NewRepository construye el backend indicado por config.Driver.
+// Si no se indica driver se usa MySQL para mantener el comportamiento anterior.
+func NewRepository(config Config) (Repository, error) {
+	switch strings.ToLower(strings.TrimSpace(config.Driver)) {
+	case "", DriverMySQL:
+		return NewMySQL(config)
+	case DriverPostgres, "postgresql":
+		return NewPostgres(config)
+	case DriverSQLite, "sqlite3":
+		return NewSQLite(config)
+	case DriverMemory:
+		return NewMemory(), nil
+	default:
+		return nil, fmt.Errorf("unknown database driver %q", config.Driver)
+	}
+}
+
+func openGorm(dialect string, dsn string, database string) (SQL, error) {
+	db, err := gorm.Open(dialect, dsn)
+	if err != nil {
+		return SQL{}, fmt.Errorf("opening %s connection: %w", dialect, err)
+	}
+	db.LogMode(false)
+	db.SetNowFuncOverride(now)
+	registerTracing(db, dbSystem(dialect))
+	if dialect == "sqlite3" {
+		// SQLite no admite escrituras concurrentes y cada conexion a ":memory:"
+		// es una base distinta, asi que se trabaja con una sola conexion.
+		db.DB().SetMaxOpenConns(1)
+	}
+
+	if err := migrate(db); err != nil {
+		db.Close()
+		return SQL{}, err
+	}
+	log.WithField("dialect", dialect).Info("Connection Established")
+
+	return SQL{
+		db:       db,
+		Database: database,
+	}, nil
+}
+
+// nombreIndex es el indice unico de users.nombre.
+const nombreIndex = "idx_nombre"
+
+// now es el reloj de las marcas UpdatedAt. Se trunca al segundo y en UTC
+// porque es la precision de Last-Modified y de las columnas datetime de MySQL,
+// asi todos los backends devuelven el mismo valor que guardaron.
+func now() time.Time {
+	return time.Now().UTC().Truncate(time.Second)
+}
+
+func migrate(db *gorm.DB) error {
+	if err := db.AutoMigrate(&Model.User{}).Error; err != nil {
+		return fmt.Errorf("migrating users table: %w", err)
+	}
+	if err := db.AutoMigrate(&Model.ConsentVersion{}, &Model.Consent{}).Error; err != nil {
+		return fmt.Errorf("migrating consent tables: %w", err)
+	}
+	if err := db.AutoMigrate(&Model.Login{}, &Model.JobLock{}).Error; err != nil {
+		return fmt.Errorf("migrating retention tables: %w", err)
+	}
+	if err := normalizeGeneros(db); err != nil {
+		return err
+	}
+	if err := backfillDeactivatedAt(db); err != nil {
+		return err
+	}
+	return addUniqueNombre(db)
+}
+
+// normalizeGeneros lleva a M, F o X los generos que se guardaron como texto
+// libre antes de que la API validara el campo; sin esto esas filas no
+// pasarian la validacion de un PUT o un PATCH. Lo que no se reconoce queda
+// como X y se informa en el log. Cada fila corregida cambia de version.
+func normalizeGeneros(db *gorm.DB) error {
+	codes := []string{Domain.GeneroMasculino, Domain.GeneroFemenino, Domain.GeneroOtro}
+	var legacy []struct {
+		Id     int
+		Genero string
+	}
+	if err := db.Model(&Model.User{}).Select("id, genero").Where("genero NOT IN (?)", codes).Scan(&legacy).Error; err != nil {
+		return fmt.Errorf("reading legacy genero values: %w", err)
+	}
+	for _, row := range legacy {
+		genero := Domain.NormalizeGenero(row.Genero)
+		if !Domain.IsGenero(genero) {
+			log.WithField("user_id", row.Id).WithField("genero", row.Genero).Warn("unknown genero value, storing X")
+			genero = Domain.GeneroOtro
+		}
+		err := db.Model(&Model.User{}).Where("id = ?", row.Id).
+			Updates(map[string]interface{}{"genero": genero, "version": gorm.Expr("version + 1")}).Error
+		if err != nil {
+			return fmt.Errorf("normalizing genero of user %d: %w", row.Id, err)
+		}
+	}
+	if len(legacy) > 0 {
+		log.WithField("users", len(legacy)).Info("normalized legacy genero values")
+	}
+	return nil
+}
+
+// backfillDeactivatedAt completa deactivated_at en las cuentas desactivadas
+// antes de que existiera la columna. Lo mas cercano que se conoce es
+// updated_at; no cambia la version porque los datos del usuario son los
+// mismos.
+func backfillDeactivatedAt(db *gorm.DB) error {
+	err := db.Model(&Model.User{}).Where("estado = ? AND deactivated_at IS NULL", false).
+		UpdateColumn("deactivated_at", gorm.Expr("updated_at")).Error
+	if err != nil {
+		return fmt.Errorf("filling deactivated_at: %w", err)
+	}
+	return nil
+}
+
+// addUniqueNombre crea el indice unico de nombre, que es lo que hace que un
+// alta repetida sea un ErrConflict igual que en Memory. Si la base ya tiene
+// nombres repetidos falla listandolos: hay que renombrar esos usuarios antes
+// de arrancar, porque sin el indice los backends aplicarian reglas distintas.
+func addUniqueNombre(db *gorm.DB) error {
+	var repeated []string
+	if err := db.Model(&Model.User{}).Group("nombre").Having("COUNT(*) > 1").Pluck("nombre", &repeated).Error; err != nil {
+		return fmt.Errorf("checking for repeated names: %w", err)
+	}
+	if len(repeated) > 0 {
+		return fmt.Errorf("cannot create unique index on users.nombre, rename the users with repeated names first: %s", strings.Join(repeated, ", "))
+	}
+	if err := db.Model(&Model.User{}).AddUniqueIndex(nombreIndex, "nombre").Error; err != nil {
+		return fmt.Errorf("creating unique index on users.nombre: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+package clientUsers
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	Model "Golang/model"
+
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewRepository_Memory(t *testing.T) {
+	repo, err := NewRepository(Config{Driver: "memory"})
+	assert.NoError(t, err)
+	assert.IsType(t, &Memory{}, repo)
+}
+
+func TestNewRepository_SQLite(t *testing.T) {
+	repo, err := NewRepository(Config{Driver: "sqlite"})
+	assert.NoError(t, err)
+
+	created, err := repo.InsertUser(context.Background(), Model.User{Nombre: "sqlite", Password: "p"})
+	assert.NoError(t, err)
+	assert.NotZero(t, created.Id)
+}
+
+func TestNewSQLite_RepeatedNamesFailMigration(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "users.db")
+	db, err := gorm.Open("sqlite3", path)
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&Model.User{}).Error)
+	for _, nombre := range []string{"ana", "bruno", "ana"} {
+		require.NoError(t, db.Create(&Model.User{Nombre: nombre}).Error)
+	}
+	require.NoError(t, db.Close())
+
+	_, err = NewSQLite(Config{Path: path})
+	assert.ErrorContains(t, err, "repeated names first: ana")
+
+	// Renombrados los repetidos, la migracion crea el indice.
+	db, err = gorm.Open("sqlite3", path)
+	require.NoError(t, err)
+	require.NoError(t, db.Model(&Model.User{}).Where("id = ?", 3).Update("nombre", "ana-2").Error)
+	require.NoError(t, db.Close())
+	repo, err := NewSQLite(Config{Path: path})
+	require.NoError(t, err)
+	require.NoError(t, repo.Close())
+
+	// Con el indice ya creado la migracion no falla.
+	repo, err = NewSQLite(Config{Path: path})
+	require.NoError(t, err)
+	defer repo.Close()
+	_, err = repo.InsertUser(context.Background(), Model.User{Nombre: "ana"})
+	assert.Error(t, err)
+}
+
+func TestNewSQLite_NormalizesLegacyGenero(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "users.db")
+	db, err := gorm.Open("sqlite3", path)
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&Model.User{}).Error)
+	for _, genero := range []string{"Masculino", "F", "mujer", "sin dato"} {
+		require.NoError(t, db.Create(&Model.User{Nombre: "u-" + genero, Genero: genero, Version: 1}).Error)
+	}
+	require.NoError(t, db.Close())
+
+	repo, err := NewSQLite(Config{Path: path})
+	require.NoError(t, err)
+	defer repo.Close()
+	users, err := repo.GetAllUsers(context.Background())
+	require.NoError(t, err)
+	generos := make([]string, 0, len(users))
+	versions := make([]int, 0, len(users))
+	for _, user := range users {
+		generos = append(generos, user.Genero)
+		versions = append(versions, user.Version)
+	}
+	assert.Equal(t, []string{"M", "F", "F", "X"}, generos)
+	assert.Equal(t, []int{2, 1, 2, 2}, versions)
+}
+
+func TestNewRepository_UnknownDriver(t *testing.T) {
+	_, err := NewRepository(Config{Driver: "oracle"})
+	assert.Error(t, err)
+}
+
+func TestMemory_CRUD(t *testing.T) {
+	repo := NewMemory()
+
+	created, err := repo.InsertUser(context.Background(), Model.User{Nombre: "mem", Password: "p"})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, created.Id)
+
+	_, err = repo.InsertUser(context.Background(), Model.User{Nombre: "mem"})
+	assert.Error(t, err)
+
+	created.Genero = "F"
+	updated, err := repo.UpdateUser(context.Background(), created)
+	assert.NoError(t, err)
+	assert.Equal(t, "F", updated.Genero)
+
+	byName, err := repo.GetUserByName(context.Background(), Model.User{Nombre: "mem"})
+	assert.NoError(t, err)
+	assert.Equal(t, created.Id, byName.Id)
+
+	_, err = repo.GetUserById(context.Background(), 99)
+	assert.Error(t, err)
+
+	_, err = repo.UpdateUser(context.Background(), Model.User{Id: 99})
+	assert.Error(t, err)
+}
@@ -0,0 +1,682 @@
+// Package repotest contiene la suite de conformidad que debe pasar cualquier
+// implementacion de clientUsers.Repository. Cada backend la ejecuta desde sus
+// propios tests:
+//
+//	repotest.Run(t, func(t *testing.T) clientUsers.Repository {
+//		return clientUsers.NewMemory()
+//	})
+package repotest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	clientUsers "Golang/clients"
+	Domain "Golang/domain"
+	Model "Golang/model"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Factory devuelve un repositorio vacio. Se llama una vez por caso de prueba.
+type Factory func(t *testing.T) clientUsers.Repository
+
+// Run ejecuta todos los casos de la suite contra los repositorios que
+// devuelve newRepo.
+func Run(t *testing.T, newRepo Factory) {
+	cases := []struct {
+		name string
+		fn   func(t *testing.T, repo clientUsers.Repository)
+	}{
+		{"InsertAssignsIncreasingIds", testInsertAssignsIncreasingIds},
+		{"InsertDuplicateNameIsConflict", testInsertDuplicateNameIsConflict},
+		{"GetUserByIdRoundTrip", testGetUserByIdRoundTrip},
+		{"GetUserByIdNotFound", testGetUserByIdNotFound},
+		{"GetUserByName", testGetUserByName},
+		{"GetUserByNameNotFound", testGetUserByNameNotFound},
+		{"UpdateUser", testUpdateUser},
+		{"UpdateUserKeepsPassword", testUpdateUserKeepsPassword},
+		{"UpdateMissingUserIsNotFound", testUpdateMissingUserIsNotFound},
+		{"UpdateToDuplicateNameIsConflict", testUpdateToDuplicateNameIsConflict},
+		{"PatchUserOnlyTouchesGivenColumns", testPatchUserOnlyTouchesGivenColumns},
+		{"PatchUserWithoutFields", testPatchUserWithoutFields},
+		{"PatchMissingUserIsNotFound", testPatchMissingUserIsNotFound},
+		{"PatchToDuplicateNameIsConflict", testPatchToDuplicateNameIsConflict},
+		{"GetAllUsersEmpty", testGetAllUsersEmpty},
+		{"UsersStampTracksChanges", testUsersStampTracksChanges},
+		{"GetAllUsersOrderedById", testGetAllUsersOrderedById},
+		{"ConcurrentInserts", testConcurrentInserts},
+		{"ConcurrentDuplicateInserts", testConcurrentDuplicateInserts},
+		{"ConcurrentReadsAndUpdates", testConcurrentReadsAndUpdates},
+		{"UpdateWithStaleVersionIsPreconditionFailed", testUpdateWithStaleVersionIsPreconditionFailed},
+		{"InsertUsersBatch", testInsertUsersBatch},
+		{"InsertUsersIsAllOrNothing", testInsertUsersIsAllOrNothing},
+		{"InsertSealedSeesAssignedIds", testInsertSealedSeesAssignedIds},
+		{"InsertSealedErrorInsertsNothing", testInsertSealedErrorInsertsNothing},
+		{"RewriteColumnsKeepsVersion", testRewriteColumnsKeepsVersion},
+		{"ExistingNames", testExistingNames},
+		{"DeleteUser", testDeleteUser},
+		{"ScanUsersFilters", testScanUsersFilters},
+		{"ScanUsersByAnonymizedAndAge", testScanUsersByAnonymizedAndAge},
+		{"ScanUsersVisitsEveryRowInOrder", testScanUsersVisitsEveryRowInOrder},
+		{"ScanUsersStopsOnError", testScanUsersStopsOnError},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			tc.fn(t, newRepo(t))
+		})
+	}
+}
+
+func sampleUser(nombre string) Model.User {
+	return Model.User{
+		Nombre:       nombre,
+		Password:     "5f4dcc3b5aa765d61d8327deb882cf99",
+		Genero:       "F",
+		Atributos:    "alta",
+		Maneja:       true,
+		Lentes:       true,
+		Diabetico:    false,
+		Enfermedades: "asma",
+		Admin:        false,
+		Estado:       true,
+	}
+}
+
+func testInsertAssignsIncreasingIds(t *testing.T, repo clientUsers.Repository) {
+	first, err := repo.InsertUser(context.Background(), sampleUser("uno"))
+	require.NoError(t, err)
+	second, err := repo.InsertUser(context.Background(), sampleUser("dos"))
+	require.NoError(t, err)
+
+	assert.NotZero(t, first.Id)
+	assert.Greater(t, second.Id, first.Id)
+}
+
+func testInsertDuplicateNameIsConflict(t *testing.T, repo clientUsers.Repository) {
+	_, err := repo.InsertUser(context.Background(), sampleUser("repetido"))
+	require.NoError(t, err)
+
+	_, err = repo.InsertUser(context.Background(), sampleUser("repetido"))
+	assert.ErrorIs(t, err, Domain.ErrConflict)
+
+	all, err := repo.GetAllUsers(context.Background())
+	require.NoError(t, err)
+	assert.Len(t, all, 1)
+}
+
+func testGetUserByIdRoundTrip(t *testing.T, repo clientUsers.Repository) {
+	want := sampleUser("completo")
+	created, err := repo.InsertUser(context.Background(), want)
+	require.NoError(t, err)
+
+	got, err := repo.GetUserById(context.Background(), created.Id)
+	require.NoError(t, err)
+
+	want.Id = created.Id
+	want.Version = 1
+	want.UpdatedAt = created.UpdatedAt
+	assert.False(t, got.UpdatedAt.IsZero())
+	assert.Equal(t, want, got)
+}
+
+func testGetUserByIdNotFound(t *testing.T, repo clientUsers.Repository) {
+	_, err := repo.GetUserById(context.Background(), 424242)
+	assert.ErrorIs(t, err, Domain.ErrNotFound)
+}
+
+func testGetUserByName(t *testing.T, repo clientUsers.Repository) {
+	created, err := repo.InsertUser(context.Background(), sampleUser("buscado"))
+	require.NoError(t, err)
+	_, err = repo.InsertUser(context.Background(), sampleUser("otro"))
+	require.NoError(t, err)
+
+	got, err := repo.GetUserByName(context.Background(), Model.User{Nombre: "buscado"})
+	require.NoError(t, err)
+	assert.Equal(t, created.Id, got.Id)
+	assert.Equal(t, created.Password, got.Password)
+}
+
+func testGetUserByNameNotFound(t *testing.T, repo clientUsers.Repository) {
+	_, err := repo.GetUserByName(context.Background(), Model.User{Nombre: "nadie"})
+	assert.ErrorIs(t, err, Domain.ErrNotFound)
+}
+
+func testUpdateUser(t *testing.T, repo clientUsers.Repository) {
+	created, err := repo.InsertUser(context.Background(), sampleUser("antes"))
+	require.NoError(t, err)
+
+	created.Nombre = "despues"
+	created.Diabetico = true
+	created.Maneja = false
+	updated, err := repo.UpdateUser(context.Background(), created)
+	require.NoError(t, err)
+	assert.Equal(t, "despues", updated.Nombre)
+
+	created.Version++
+	assert.Equal(t, created.Version, updated.Version)
+	assert.False(t, updated.UpdatedAt.Before(created.UpdatedAt))
+	created.UpdatedAt = updated.UpdatedAt
+	got, err := repo.GetUserById(context.Background(), created.Id)
+	require.NoError(t, err)
+	assert.Equal(t, created, got)
+
+	_, err = repo.GetUserByName(context.Background(), Model.User{Nombre: "antes"})
+	assert.ErrorIs(t, err, Domain.ErrNotFound)
+}
+
+func testUpdateUserKeepsPassword(t *testing.T, repo clientUsers.Repository) {
+	created, err := repo.InsertUser(context.Background(), sampleUser("con-clave"))
+	require.NoError(t, err)
+
+	created.Password = ""
+	created.Nombre = "con-clave-nueva"
+	_, err = repo.UpdateUser(context.Background(), created)
+	require.NoError(t, err)
+
+	got, err := repo.GetUserByName(context.Background(), Model.User{Nombre: "con-clave-nueva"})
+	require.NoError(t, err)
+	assert.Equal(t, sampleUser("x").Password, got.Password)
+}
+
+func testUpdateMissingUserIsNotFound(t *testing.T, repo clientUsers.Repository) {
+	missing := sampleUser("fantasma")
+	missing.Id = 424242
+
+	_, err := repo.UpdateUser(context.Background(), missing)
+	assert.ErrorIs(t, err, Domain.ErrNotFound)
+
+	// Actualizar un id inexistente no debe crearlo.
+	all, err := repo.GetAllUsers(context.Background())
+	require.NoError(t, err)
+	assert.Empty(t, all)
+}
+
+func testUpdateToDuplicateNameIsConflict(t *testing.T, repo clientUsers.Repository) {
+	_, err := repo.InsertUser(context.Background(), sampleUser("primero"))
+	require.NoError(t, err)
+	second, err := repo.InsertUser(context.Background(), sampleUser("segundo"))
+	require.NoError(t, err)
+
+	second.Nombre = "primero"
+	_, err = repo.UpdateUser(context.Background(), second)
+	assert.ErrorIs(t, err, Domain.ErrConflict)
+
+	got, err := repo.GetUserById(context.Background(), second.Id)
+	require.NoError(t, err)
+	assert.Equal(t, "segundo", got.Nombre)
+}
+
+func testPatchUserOnlyTouchesGivenColumns(t *testing.T, repo clientUsers.Repository) {
+	created, err := repo.InsertUser(context.Background(), sampleUser("parcial"))
+	require.NoError(t, err)
+
+	patched, err := repo.PatchUser(context.Background(), created.Id, created.Version, map[string]interface{}{
+		"maneja":    false,
+		"atributos": "baja",
+	})
+	require.NoError(t, err)
+
+	want := created
+	want.Maneja = false
+	want.Atributos = "baja"
+	want.Version = created.Version + 1
+	want.UpdatedAt = patched.UpdatedAt
+	assert.Equal(t, want, patched)
+
+	got, err := repo.GetUserById(context.Background(), created.Id)
+	require.NoError(t, err)
+	assert.Equal(t, want, got)
+}
+
+func testPatchUserWithoutFields(t *testing.T, repo clientUsers.Repository) {
+	created, err := repo.InsertUser(context.Background(), sampleUser("intacto"))
+	require.NoError(t, err)
+
+	patched, err := repo.PatchUser(context.Background(), created.Id, created.Version, map[string]interface{}{})
+	require.NoError(t, err)
+	assert.Equal(t, created, patched)
+}
+
+func testPatchMissingUserIsNotFound(t *testing.T, repo clientUsers.Repository) {
+	_, err := repo.PatchUser(context.Background(), 424242, 1, map[string]interface{}{"lentes": true})
+	assert.ErrorIs(t, err, Domain.ErrNotFound)
+}
+
+func testPatchToDuplicateNameIsConflict(t *testing.T, repo clientUsers.Repository) {
+	_, err := repo.InsertUser(context.Background(), sampleUser("ocupado"))
+	require.NoError(t, err)
+	other, err := repo.InsertUser(context.Background(), sampleUser("libre"))
+	require.NoError(t, err)
+
+	_, err = repo.PatchUser(context.Background(), other.Id, other.Version, map[string]interface{}{"nombre": "ocupado"})
+	assert.ErrorIs(t, err, Domain.ErrConflict)
+
+	got, err := repo.GetUserById(context.Background(), other.Id)
+	require.NoError(t, err)
+	assert.Equal(t, "libre", got.Nombre)
+}
+
+func testGetAllUsersEmpty(t *testing.T, repo clientUsers.Repository) {
+	all, err := repo.GetAllUsers(context.Background())
+	assert.NoError(t, err)
+	assert.Empty(t, all)
+}
+
+func testUsersStampTracksChanges(t *testing.T, repo clientUsers.Repository) {
+	empty, err := repo.GetUsersStamp(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, Model.UsersStamp{}, empty)
+
+	created, err := repo.InsertUser(context.Background(), sampleUser("sellado"))
+	require.NoError(t, err)
+	last, err := repo.InsertUser(context.Background(), sampleUser("otro-sellado"))
+	require.NoError(t, err)
+
+	afterInsert, err := repo.GetUsersStamp(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 2, afterInsert.Count)
+	assert.Equal(t, 2, afterInsert.VersionSum)
+	assert.Equal(t, last.Id, afterInsert.MaxId)
+	assert.False(t, afterInsert.LastModified.IsZero())
+
+	created.Lentes = false
+	updated, err := repo.UpdateUser(context.Background(), created)
+	require.NoError(t, err)
+
+	afterUpdate, err := repo.GetUsersStamp(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 2, afterUpdate.Count)
+	assert.Equal(t, 3, afterUpdate.VersionSum)
+	assert.Equal(t, updated.UpdatedAt, afterUpdate.LastModified)
+	assert.False(t, afterUpdate.LastModified.Before(afterInsert.LastModified))
+}
+
+func testGetAllUsersOrderedById(t *testing.T, repo clientUsers.Repository) {
+	for _, nombre := range []string{"c", "a", "b"} {
+		_, err := repo.InsertUser(context.Background(), sampleUser(nombre))
+		require.NoError(t, err)
+	}
+
+	all, err := repo.GetAllUsers(context.Background())
+	require.NoError(t, err)
+	require.Len(t, all, 3)
+	for i := 1; i < len(all); i++ {
+		assert.Less(t, all[i-1].Id, all[i].Id)
+	}
+	assert.Equal(t, "c", all[0].Nombre)
+}
+
+func testConcurrentInserts(t *testing.T, repo clientUsers.Repository) {
+	const n = 20
+	var wg sync.WaitGroup
+	ids := make(chan int, n)
+	errs := make(chan error, n)
+
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			user, err := repo.InsertUser(context.Background(), sampleUser(fmt.Sprintf("concurrente-%d", i)))
+			if err != nil {
+				errs <- err
+				return
+			}
+			ids <- user.Id
+		}(i)
+	}
+	wg.Wait()
+	close(ids)
+	close(errs)
+
+	for err := range errs {
+		assert.NoError(t, err)
+	}
+	seen := map[int]bool{}
+	for id := range ids {
+		assert.False(t, seen[id], "id %d assigned twice", id)
+		seen[id] = true
+	}
+	assert.Len(t, seen, n)
+}
+
+func testConcurrentDuplicateInserts(t *testing.T, repo clientUsers.Repository) {
+	const n = 10
+	var wg sync.WaitGroup
+	errs := make(chan error, n)
+
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := repo.InsertUser(context.Background(), sampleUser("carrera"))
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	succeeded := 0
+	for err := range errs {
+		if err == nil {
+			succeeded++
+			continue
+		}
+		assert.ErrorIs(t, err, Domain.ErrConflict)
+	}
+	assert.Equal(t, 1, succeeded)
+}
+
+func testConcurrentReadsAndUpdates(t *testing.T, repo clientUsers.Repository) {
+	created, err := repo.InsertUser(context.Background(), sampleUser("compartido"))
+	require.NoError(t, err)
+
+	const n = 10
+	var wg sync.WaitGroup
+	updates := make(chan error, n)
+	reads := make(chan error, n)
+
+	// Todas las escrituras parten de la misma version: solo una puede ganar.
+	for i := 0; i < n; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			user := created
+			user.Atributos = fmt.Sprintf("version-%d", i)
+			_, err := repo.UpdateUser(context.Background(), user)
+			updates <- err
+		}(i)
+		go func() {
+			defer wg.Done()
+			_, err := repo.GetUserById(context.Background(), created.Id)
+			reads <- err
+		}()
+	}
+	wg.Wait()
+	close(updates)
+	close(reads)
+
+	for err := range reads {
+		assert.NoError(t, err)
+	}
+	succeeded := 0
+	for err := range updates {
+		if err == nil {
+			succeeded++
+			continue
+		}
+		assert.ErrorIs(t, err, Domain.ErrPreconditionFailed)
+	}
+	assert.Equal(t, 1, succeeded)
+
+	got, err := repo.GetUserById(context.Background(), created.Id)
+	require.NoError(t, err)
+	assert.Contains(t, got.Atributos, "version-")
+	assert.Equal(t, created.Version+1, got.Version)
+}
+
+func testUpdateWithStaleVersionIsPreconditionFailed(t *testing.T, repo clientUsers.Repository) {
+	created, err := repo.InsertUser(context.Background(), sampleUser("versionado"))
+	require.NoError(t, err)
+
+	first := created
+	first.Atributos = "primera"
+	_, err = repo.UpdateUser(context.Background(), first)
+	require.NoError(t, err)
+
+	stale := created
+	stale.Atributos = "pisada"
+	_, err = repo.UpdateUser(context.Background(), stale)
+	assert.ErrorIs(t, err, Domain.ErrPreconditionFailed)
+
+	_, err = repo.PatchUser(context.Background(), created.Id, created.Version, map[string]interface{}{"lentes": false})
+	assert.ErrorIs(t, err, Domain.ErrPreconditionFailed)
+
+	_, err = repo.PatchUser(context.Background(), created.Id, created.Version, map[string]interface{}{})
+	assert.ErrorIs(t, err, Domain.ErrPreconditionFailed)
+
+	got, err := repo.GetUserById(context.Background(), created.Id)
+	require.NoError(t, err)
+	assert.Equal(t, "primera", got.Atributos)
+}
+
+func testInsertUsersBatch(t *testing.T, repo clientUsers.Repository) {
+	created, err := repo.InsertUsers(context.Background(), []Model.User{sampleUser("lote-1"), sampleUser("lote-2"), sampleUser("lote-3")})
+	require.NoError(t, err)
+	require.Len(t, created, 3)
+
+	for i, user := range created {
+		assert.Equal(t, fmt.Sprintf("lote-%d", i+1), user.Nombre)
+		assert.Equal(t, 1, user.Version)
+		if i > 0 {
+			assert.Greater(t, user.Id, created[i-1].Id)
+		}
+		got, err := repo.GetUserById(context.Background(), user.Id)
+		require.NoError(t, err)
+		assert.Equal(t, user.Nombre, got.Nombre)
+	}
+}
+
+func testInsertUsersIsAllOrNothing(t *testing.T, repo clientUsers.Repository) {
+	_, err := repo.InsertUser(context.Background(), sampleUser("existente"))
+	require.NoError(t, err)
+
+	_, err = repo.InsertUsers(context.Background(), []Model.User{sampleUser("nuevo"), sampleUser("existente")})
+	assert.ErrorIs(t, err, Domain.ErrConflict)
+
+	_, err = repo.InsertUsers(context.Background(), []Model.User{sampleUser("doble"), sampleUser("doble")})
+	assert.ErrorIs(t, err, Domain.ErrConflict)
+
+	all, err := repo.GetAllUsers(context.Background())
+	require.NoError(t, err)
+	require.Len(t, all, 1)
+	assert.Equal(t, "existente", all[0].Nombre)
+}
+
+func testInsertSealedSeesAssignedIds(t *testing.T, repo clientUsers.Repository) {
+	var sealed []int
+	created, err := repo.InsertSealed(context.Background(), []Model.User{sampleUser("uno"), sampleUser("dos")}, func(user *Model.User) error {
+		assert.NotZero(t, user.Id)
+		sealed = append(sealed, user.Id)
+		return nil
+	})
+	require.NoError(t, err)
+	require.Len(t, created, 2)
+	assert.Equal(t, []int{created[0].Id, created[1].Id}, sealed)
+
+	got, err := repo.GetUserById(context.Background(), created[1].Id)
+	require.NoError(t, err)
+	assert.Equal(t, 1, got.Version)
+}
+
+func testInsertSealedErrorInsertsNothing(t *testing.T, repo clientUsers.Repository) {
+	failure := errors.New("sin clave")
+	_, err := repo.InsertSealed(context.Background(), []Model.User{sampleUser("uno")}, func(*Model.User) error {
+		return failure
+	})
+	assert.ErrorIs(t, err, failure)
+
+	all, err := repo.GetAllUsers(context.Background())
+	require.NoError(t, err)
+	assert.Empty(t, all)
+}
+
+func testRewriteColumnsKeepsVersion(t *testing.T, repo clientUsers.Repository) {
+	user := sampleUser("reescrito")
+	user.Lentes = false
+	created, err := repo.InsertUser(context.Background(), user)
+	require.NoError(t, err)
+
+	require.NoError(t, repo.RewriteColumns(context.Background(), created.Id, created.Version, map[string]interface{}{"lentes": true}))
+
+	got, err := repo.GetUserById(context.Background(), created.Id)
+	require.NoError(t, err)
+	assert.True(t, got.Lentes)
+	assert.Equal(t, created.Version, got.Version)
+	assert.Equal(t, created.UpdatedAt, got.UpdatedAt)
+
+	err = repo.RewriteColumns(context.Background(), created.Id, created.Version+1, map[string]interface{}{"lentes": false})
+	assert.ErrorIs(t, err, Domain.ErrPreconditionFailed)
+	err = repo.RewriteColumns(context.Background(), 424242, 1, map[string]interface{}{"lentes": false})
+	assert.ErrorIs(t, err, Domain.ErrNotFound)
+}
+
+func testExistingNames(t *testing.T, repo clientUsers.Repository) {
+	for _, nombre := range []string{"ana", "bruno", "carla"} {
+		_, err := repo.InsertUser(context.Background(), sampleUser(nombre))
+		require.NoError(t, err)
+	}
+
+	existing, err := repo.ExistingNames(context.Background(), []string{"carla", "diego", "ana"})
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"ana", "carla"}, existing)
+
+	existing, err = repo.ExistingNames(context.Background(), nil)
+	require.NoError(t, err)
+	assert.Empty(t, existing)
+}
+
+func testDeleteUser(t *testing.T, repo clientUsers.Repository) {
+	ana, err := repo.InsertUser(context.Background(), sampleUser("ana"))
+	require.NoError(t, err)
+	beto, err := repo.InsertUser(context.Background(), sampleUser("beto"))
+	require.NoError(t, err)
+
+	require.NoError(t, repo.DeleteUser(context.Background(), ana.Id))
+
+	_, err = repo.GetUserById(context.Background(), ana.Id)
+	assert.ErrorIs(t, err, Domain.ErrNotFound)
+	all, err := repo.GetAllUsers(context.Background())
+	require.NoError(t, err)
+	require.Len(t, all, 1)
+	assert.Equal(t, beto.Id, all[0].Id)
+
+	assert.ErrorIs(t, repo.DeleteUser(context.Background(), ana.Id), Domain.ErrNotFound)
+
+	// El nombre queda libre para un alta nueva.
+	_, err = repo.InsertUser(context.Background(), sampleUser("ana"))
+	assert.NoError(t, err)
+}
+
+func testScanUsersFilters(t *testing.T, repo clientUsers.Repository) {
+	admin := sampleUser("Ana_Admin")
+	admin.Admin = true
+	inactiva := sampleUser("anabel")
+	inactiva.Estado = false
+	inactiva.Diabetico = true
+	for _, user := range []Model.User{admin, inactiva, sampleUser("bruno"), sampleUser("anaXadmin")} {
+		_, err := repo.InsertUser(context.Background(), user)
+		require.NoError(t, err)
+	}
+
+	scan := func(filter Model.UserFilter) []string {
+		var nombres []string
+		err := repo.ScanUsers(context.Background(), filter, func(user Model.User) error {
+			nombres = append(nombres, user.Nombre)
+			return nil
+		})
+		require.NoError(t, err)
+		return nombres
+	}
+	yes, no := true, false
+
+	assert.Equal(t, []string{"Ana_Admin", "anabel", "bruno", "anaXadmin"}, scan(Model.UserFilter{}))
+	assert.Equal(t, []string{"Ana_Admin"}, scan(Model.UserFilter{Admin: &yes}))
+	assert.Equal(t, []string{"anabel"}, scan(Model.UserFilter{Estado: &no}))
+	assert.Equal(t, []string{"Ana_Admin", "anabel", "anaXadmin"}, scan(Model.UserFilter{Nombre: "ANA"}))
+	// Los comodines de LIKE se buscan como texto.
+	assert.Equal(t, []string{"Ana_Admin"}, scan(Model.UserFilter{Nombre: "a_a"}))
+	assert.Empty(t, scan(Model.UserFilter{Nombre: "%"}))
+	assert.Empty(t, scan(Model.UserFilter{Admin: &yes, Estado: &no}))
+	assert.Equal(t, []string{"anabel"}, scan(Model.UserFilter{Diabetico: &yes}))
+	assert.Equal(t, []string{"Ana_Admin", "bruno", "anaXadmin"}, scan(Model.UserFilter{Diabetico: &no}))
+}
+
+func testScanUsersByAnonymizedAndAge(t *testing.T, repo clientUsers.Repository) {
+	var ana, bruno Model.User
+	for _, nombre := range []string{"ana", "bruno"} {
+		user, err := repo.InsertUser(context.Background(), sampleUser(nombre))
+		require.NoError(t, err)
+		ana, bruno = bruno, user
+	}
+	at := time.Now().UTC().Truncate(time.Second)
+	anonymized, err := repo.PatchUser(context.Background(), bruno.Id, bruno.Version, map[string]interface{}{"anonymized_at": at, "deactivated_at": &at})
+	require.NoError(t, err)
+	require.NotNil(t, anonymized.AnonymizedAt)
+	assert.True(t, at.Equal(*anonymized.AnonymizedAt))
+	require.NotNil(t, anonymized.DeactivatedAt)
+	assert.True(t, at.Equal(*anonymized.DeactivatedAt))
+
+	scan := func(filter Model.UserFilter) []string {
+		var nombres []string
+		err := repo.ScanUsers(context.Background(), filter, func(user Model.User) error {
+			nombres = append(nombres, user.Nombre)
+			return nil
+		})
+		require.NoError(t, err)
+		return nombres
+	}
+	yes, no := true, false
+
+	assert.Equal(t, []string{"bruno"}, scan(Model.UserFilter{Anonymized: &yes}))
+	assert.Equal(t, []string{"ana"}, scan(Model.UserFilter{Anonymized: &no}))
+	assert.Equal(t, []string{"bruno"}, scan(Model.UserFilter{DeactivatedBefore: time.Now().Add(time.Hour)}))
+	assert.Empty(t, scan(Model.UserFilter{DeactivatedBefore: time.Now().Add(-time.Hour)}))
+
+	// Otra escritura no cambia cuando se desactivo la cuenta.
+	_, err = repo.PatchUser(context.Background(), bruno.Id, anonymized.Version, map[string]interface{}{"lentes": false})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"bruno"}, scan(Model.UserFilter{DeactivatedBefore: time.Now().Add(time.Hour)}))
+
+	// Reactivar la borra.
+	active, err := repo.GetUserById(context.Background(), ana.Id)
+	require.NoError(t, err)
+	assert.Nil(t, active.DeactivatedAt)
+	reactivated, err := repo.PatchUser(context.Background(), bruno.Id, anonymized.Version+1, map[string]interface{}{"deactivated_at": (*time.Time)(nil)})
+	require.NoError(t, err)
+	assert.Nil(t, reactivated.DeactivatedAt)
+	assert.Empty(t, scan(Model.UserFilter{DeactivatedBefore: time.Now().Add(time.Hour)}))
+}
+
+func testScanUsersVisitsEveryRowInOrder(t *testing.T, repo clientUsers.Repository) {
+	users := make([]Model.User, 1203)
+	for i := range users {
+		users[i] = sampleUser(fmt.Sprintf("usuario-%04d", i))
+	}
+	_, err := repo.InsertUsers(context.Background(), users)
+	require.NoError(t, err)
+
+	count, last := 0, 0
+	err = repo.ScanUsers(context.Background(), Model.UserFilter{}, func(user Model.User) error {
+		assert.Greater(t, user.Id, last)
+		assert.Equal(t, fmt.Sprintf("usuario-%04d", count), user.Nombre)
+		last = user.Id
+		count++
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, len(users), count)
+}
+
+func testScanUsersStopsOnError(t *testing.T, repo clientUsers.Repository) {
+	for _, nombre := range []string{"ana", "bruno", "carla"} {
+		_, err := repo.InsertUser(context.Background(), sampleUser(nombre))
+		require.NoError(t, err)
+	}
+
+	stop := errors.New("stop")
+	visited := 0
+	err := repo.ScanUsers(context.Background(), Model.UserFilter{}, func(user Model.User) error {
+		visited++
+		return stop
+	})
+	assert.ErrorIs(t, err, stop)
+	assert.Equal(t, 1, visited)
+}
@@ -0,0 +1,179 @@
+package clientUsers
+
+import (
+	Model "Golang/model"
+	"context"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// LoginHistory guarda los intentos de login de los usuarios registrados.
+type LoginHistory interface {
+	InsertLogin(ctx context.Context, login Model.Login) error
+	// PurgeLogins borra los logins anteriores a before y devuelve cuantos
+	// fueron. Con dryRun solo los cuenta.
+	PurgeLogins(ctx context.Context, before time.Time, dryRun bool) (int, error)
+}
+
+// Locker es un lock con vencimiento compartido por todas las replicas que
+// usan la misma base.
+type Locker interface {
+	// AcquireLock toma el lock name por ttl. Devuelve false, sin error, si
+	// lo tiene otro owner y todavia no vencio.
+	AcquireLock(ctx context.Context, name string, owner string, ttl time.Duration) (bool, error)
+	// RenewLock extiende por ttl, desde ahora, un lock que todavia es de
+	// owner. Devuelve false, sin error, si ya vencio o lo tomo otro.
+	RenewLock(ctx context.Context, name string, owner string, ttl time.Duration) (bool, error)
+	// ReleaseLock suelta el lock si todavia es de owner.
+	ReleaseLock(ctx context.Context, name string, owner string) error
+}
+
+func (repository SQL) InsertLogin(ctx context.Context, login Model.Login) error {
+	if err := repository.with(ctx).Create(&login).Error; err != nil {
+		logQueryError(ctx, err, "Error al registrar el login")
+		return classify(err, "error recording login")
+	}
+	return nil
+}
+
+func (repository SQL) PurgeLogins(ctx context.Context, before time.Time, dryRun bool) (int, error) {
+	query := repository.with(ctx).Model(&Model.Login{}).Where("created_at < ?", before)
+	if dryRun {
+		count := 0
+		if err := query.Count(&count).Error; err != nil {
+			return 0, classify(err, "error counting logins")
+		}
+		return count, nil
+	}
+	result := query.Delete(&Model.Login{})
+	if result.Error != nil {
+		logQueryError(ctx, result.Error, "Error al purgar los logins")
+		return 0, classify(result.Error, "error purging logins")
+	}
+	return int(result.RowsAffected), nil
+}
+
+// AcquireLock primero intenta quedarse con un lock vencido y, si no hay
+// fila, la crea. Dos replicas que compiten por crearla chocan con la clave
+// primaria y solo una gana.
+func (repository SQL) AcquireLock(ctx context.Context, name string, owner string, ttl time.Duration) (bool, error) {
+	at := now()
+	result := repository.with(ctx).Model(&Model.JobLock{}).
+		Where("name = ? AND expires_at < ?", name, at).
+		Updates(map[string]interface{}{"owner": owner, "expires_at": at.Add(ttl)})
+	if result.Error != nil {
+		return false, classify(result.Error, "error acquiring lock")
+	}
+	if result.RowsAffected > 0 {
+		return true, nil
+	}
+
+	err := repository.with(ctx).Create(&Model.JobLock{Name: name, Owner: owner, ExpiresAt: at.Add(ttl)}).Error
+	if isDuplicateKey(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, classify(err, "error acquiring lock")
+	}
+	return true, nil
+}
+
+// RenewLock no se fia de RowsAffected cuando da 0: MySQL cuenta las filas
+// cambiadas y no las encontradas, asi que renovar dos veces en el mismo
+// segundo no cambia expires_at. En ese caso relee el lock.
+func (repository SQL) RenewLock(ctx context.Context, name string, owner string, ttl time.Duration) (bool, error) {
+	at := now()
+	result := repository.with(ctx).Model(&Model.JobLock{}).
+		Where("name = ? AND owner = ? AND expires_at >= ?", name, owner, at).
+		Update("expires_at", at.Add(ttl))
+	if result.Error != nil {
+		return false, classify(result.Error, "error renewing lock")
+	}
+	if result.RowsAffected > 0 {
+		return true, nil
+	}
+
+	var lock Model.JobLock
+	err := repository.with(ctx).Where("name = ?", name).First(&lock).Error
+	if gorm.IsRecordNotFoundError(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, classify(err, "error renewing lock")
+	}
+	return lock.Owner == owner && !lock.ExpiresAt.Before(at), nil
+}
+
+func (repository SQL) ReleaseLock(ctx context.Context, name string, owner string) error {
+	err := repository.with(ctx).Where("name = ? AND owner = ?", name, owner).Delete(&Model.JobLock{}).Error
+	return classify(err, "error releasing lock")
+}
+
+func (repository *Memory) InsertLogin(ctx context.Context, login Model.Login) error {
+	repository.mu.Lock()
+	defer repository.mu.Unlock()
+
+	login.Id = 1
+	if n := len(repository.logins); n > 0 {
+		login.Id = repository.logins[n-1].Id + 1
+	}
+	if login.CreatedAt.IsZero() {
+		login.CreatedAt = now()
+	}
+	repository.logins = append(repository.logins, login)
+	return nil
+}
+
+func (repository *Memory) PurgeLogins(ctx context.Context, before time.Time, dryRun bool) (int, error) {
+	repository.mu.Lock()
+	defer repository.mu.Unlock()
+
+	kept := make([]Model.Login, 0, len(repository.logins))
+	for _, login := range repository.logins {
+		if !login.CreatedAt.Before(before) {
+			kept = append(kept, login)
+		}
+	}
+	purged := len(repository.logins) - len(kept)
+	if !dryRun {
+		repository.logins = kept
+	}
+	return purged, nil
+}
+
+func (repository *Memory) AcquireLock(ctx context.Context, name string, owner string, ttl time.Duration) (bool, error) {
+	repository.mu.Lock()
+	defer repository.mu.Unlock()
+
+	at := now()
+	if lock, held := repository.locks[name]; held && !lock.ExpiresAt.Before(at) {
+		return false, nil
+	}
+	repository.locks[name] = Model.JobLock{Name: name, Owner: owner, ExpiresAt: at.Add(ttl)}
+	return true, nil
+}
+
+func (repository *Memory) RenewLock(ctx context.Context, name string, owner string, ttl time.Duration) (bool, error) {
+	repository.mu.Lock()
+	defer repository.mu.Unlock()
+
+	at := now()
+	lock, held := repository.locks[name]
+	if !held || lock.Owner != owner || lock.ExpiresAt.Before(at) {
+		return false, nil
+	}
+	lock.ExpiresAt = at.Add(ttl)
+	repository.locks[name] = lock
+	return true, nil
+}
+
+func (repository *Memory) ReleaseLock(ctx context.Context, name string, owner string) error {
+	repository.mu.Lock()
+	defer repository.mu.Unlock()
+
+	if repository.locks[name].Owner == owner {
+		delete(repository.locks, name)
+	}
+	return nil
+}
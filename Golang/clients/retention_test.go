@@ -0,0 +1,135 @@
+package clientUsers_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	clientUsers "Golang/clients"
+	Model "Golang/model"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// retentionStore es lo que necesita el job de retencion del backend.
+type retentionStore interface {
+	clientUsers.LoginHistory
+	clientUsers.Locker
+}
+
+func retentionStores() map[string]func(t *testing.T) retentionStore {
+	return map[string]func(t *testing.T) retentionStore{
+		"memory": func(t *testing.T) retentionStore { return clientUsers.NewMemory() },
+		"sqlite": func(t *testing.T) retentionStore {
+			repo, err := clientUsers.NewSQLite(clientUsers.Config{})
+			require.NoError(t, err)
+			t.Cleanup(func() { repo.Close() })
+			return repo
+		},
+	}
+}
+
+func TestLoginHistory_Purge(t *testing.T) {
+	for name, newStore := range retentionStores() {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			store := newStore(t)
+			cutoff := time.Now().UTC().Add(-24 * time.Hour)
+
+			for _, login := range []Model.Login{
+				{UserId: 1, Success: true, CreatedAt: cutoff.Add(-time.Hour)},
+				{UserId: 2, Success: false, IP: "10.0.0.1", CreatedAt: cutoff.Add(-time.Minute)},
+				{UserId: 1, Success: true},
+			} {
+				require.NoError(t, store.InsertLogin(ctx, login))
+			}
+
+			purged, err := store.PurgeLogins(ctx, cutoff, true)
+			require.NoError(t, err)
+			assert.Equal(t, 2, purged)
+			purged, err = store.PurgeLogins(ctx, cutoff, false)
+			require.NoError(t, err)
+			assert.Equal(t, 2, purged)
+
+			// Solo queda el reciente.
+			purged, err = store.PurgeLogins(ctx, cutoff, false)
+			require.NoError(t, err)
+			assert.Zero(t, purged)
+			purged, err = store.PurgeLogins(ctx, time.Now().Add(time.Hour), true)
+			require.NoError(t, err)
+			assert.Equal(t, 1, purged)
+		})
+	}
+}
+
+func TestLocker(t *testing.T) {
+	for name, newStore := range retentionStores() {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			store := newStore(t)
+
+			acquired, err := store.AcquireLock(ctx, "job", "replica-1", time.Hour)
+			require.NoError(t, err)
+			assert.True(t, acquired)
+			acquired, err = store.AcquireLock(ctx, "job", "replica-2", time.Hour)
+			require.NoError(t, err)
+			assert.False(t, acquired, "the lock is held")
+			acquired, err = store.AcquireLock(ctx, "otro", "replica-2", time.Hour)
+			require.NoError(t, err)
+			assert.True(t, acquired, "locks are per name")
+
+			// Solo lo suelta quien lo tiene.
+			require.NoError(t, store.ReleaseLock(ctx, "job", "replica-2"))
+			acquired, err = store.AcquireLock(ctx, "job", "replica-2", time.Hour)
+			require.NoError(t, err)
+			assert.False(t, acquired)
+			require.NoError(t, store.ReleaseLock(ctx, "job", "replica-1"))
+			acquired, err = store.AcquireLock(ctx, "job", "replica-2", -time.Hour)
+			require.NoError(t, err)
+			assert.True(t, acquired)
+
+			// Un lock vencido lo toma otro aunque no se haya soltado.
+			acquired, err = store.AcquireLock(ctx, "job", "replica-3", time.Hour)
+			require.NoError(t, err)
+			assert.True(t, acquired)
+		})
+	}
+}
+
+func TestLocker_Renew(t *testing.T) {
+	for name, newStore := range retentionStores() {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			store := newStore(t)
+
+			renewed, err := store.RenewLock(ctx, "job", "replica-1", time.Hour)
+			require.NoError(t, err)
+			assert.False(t, renewed, "nobody holds the lock")
+
+			acquired, err := store.AcquireLock(ctx, "job", "replica-1", -time.Hour)
+			require.NoError(t, err)
+			require.True(t, acquired)
+			renewed, err = store.RenewLock(ctx, "job", "replica-1", time.Hour)
+			require.NoError(t, err)
+			assert.False(t, renewed, "an expired lock is not renewed")
+
+			acquired, err = store.AcquireLock(ctx, "job", "replica-2", time.Hour)
+			require.NoError(t, err)
+			require.True(t, acquired)
+			renewed, err = store.RenewLock(ctx, "job", "replica-1", time.Hour)
+			require.NoError(t, err)
+			assert.False(t, renewed, "replica-2 took it")
+
+			// Renovar dos veces seguidas no lo pierde.
+			for i := 0; i < 2; i++ {
+				renewed, err = store.RenewLock(ctx, "job", "replica-2", 2*time.Hour)
+				require.NoError(t, err)
+				assert.True(t, renewed)
+			}
+			acquired, err = store.AcquireLock(ctx, "job", "replica-1", time.Hour)
+			require.NoError(t, err)
+			assert.False(t, acquired)
+		})
+	}
+}
@@ -0,0 +1,16 @@
+package clientUsers
+
+import (
+	_ "github.com/jinzhu/gorm/dialects/sqlite"
+)
+
+// NewSQLite abre una base SQLite en config.Path, o en memoria si no se indica.
+// Requiere compilar con CGO habilitado.
+func NewSQLite(config Config) (SQL, error) {
+	path := config.Path
+	if path == "" {
+		path = ":memory:"
+	}
+
+	return openGorm("sqlite3", path, path)
+}
@@ -0,0 +1,115 @@
+package clientUsers
+
+import (
+	"context"
+	stdlog "log"
+	"os"
+	"regexp"
+
+	"github.com/jinzhu/gorm"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	traceContextKey = "otel:context"
+	traceSpanKey    = "otel:span"
+)
+
+var tracer = otel.Tracer("Golang/clients")
+
+type quietLogger struct{}
+
+func (quietLogger) Print(...interface{}) {}
+
+// registerTracing agrega callbacks de gorm que abren un span por sentencia
+// SQL. Solo se traza si la consulta lleva un contexto con un span activo
+// (ver SQL.with), asi la migracion y los tests no generan trazas sueltas.
+func registerTracing(db *gorm.DB, system string) {
+	// gorm anuncia en stdout cada callback que se registra; el logger se
+	// silencia solo para obtener el registro de callbacks.
+	db.SetLogger(quietLogger{})
+	callbacks := db.Callback()
+	db.SetLogger(gorm.Logger{LogWriter: stdlog.New(os.Stdout, "\r\n", 0)})
+
+	callbacks.Create().Before("gorm:create").Register("otel:before_create", startSpan(system, "INSERT"))
+	callbacks.Create().After("gorm:create").Register("otel:after_create", endSpan)
+	callbacks.Query().Before("gorm:query").Register("otel:before_query", startSpan(system, "SELECT"))
+	callbacks.Query().After("gorm:query").Register("otel:after_query", endSpan)
+	callbacks.RowQuery().Before("gorm:row_query").Register("otel:before_row_query", startSpan(system, "SELECT"))
+	callbacks.RowQuery().After("gorm:row_query").Register("otel:after_row_query", endSpan)
+	callbacks.Update().Before("gorm:update").Register("otel:before_update", startSpan(system, "UPDATE"))
+	callbacks.Update().After("gorm:update").Register("otel:after_update", endSpan)
+	callbacks.Delete().Before("gorm:delete").Register("otel:before_delete", startSpan(system, "DELETE"))
+	callbacks.Delete().After("gorm:delete").Register("otel:after_delete", endSpan)
+}
+
+func startSpan(system string, operation string) func(*gorm.Scope) {
+	return func(scope *gorm.Scope) {
+		value, ok := scope.Get(traceContextKey)
+		if !ok {
+			return
+		}
+		ctx, ok := value.(context.Context)
+		if !ok || !trace.SpanFromContext(ctx).SpanContext().IsValid() {
+			return
+		}
+
+		table := scope.TableName()
+		_, span := tracer.Start(ctx, operation+" "+table,
+			trace.WithSpanKind(trace.SpanKindClient),
+			trace.WithAttributes(
+				attribute.String("db.system", system),
+				attribute.String("db.operation", operation),
+				attribute.String("db.sql.table", table),
+			),
+		)
+		scope.Set(traceSpanKey, span)
+	}
+}
+
+func endSpan(scope *gorm.Scope) {
+	value, ok := scope.Get(traceSpanKey)
+	if !ok {
+		return
+	}
+	span := value.(trace.Span)
+
+	span.SetAttributes(
+		attribute.String("db.statement", sanitizeSQL(scope.SQL)),
+		attribute.Int64("db.rows_affected", scope.DB().RowsAffected),
+	)
+	if err := scope.DB().Error; err != nil && !gorm.IsRecordNotFoundError(err) {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+var (
+	sqlStringLiteral  = regexp.MustCompile(`'(?:[^']|'')*'`)
+	sqlNumericLiteral = regexp.MustCompile(`([^\w$.])\d+(?:\.\d+)?\b`)
+)
+
+// sanitizeSQL reemplaza los literales por ?. gorm ya envia los valores como
+// parametros, pero cualquier literal que quede en el texto (un LIMIT, un
+// valor armado a mano) no debe terminar en el colector de trazas.
+func sanitizeSQL(statement string) string {
+	statement = sqlStringLiteral.ReplaceAllString(statement, "?")
+	return sqlNumericLiteral.ReplaceAllString(statement, "${1}?")
+}
+
+// dbSystem traduce el dialecto de gorm al nombre de db.system de
+// OpenTelemetry.
+func dbSystem(dialect string) string {
+	switch dialect {
+	case "postgres":
+		return "postgresql"
+	case "sqlite3":
+		return "sqlite"
+	default:
+		return dialect
+	}
+}
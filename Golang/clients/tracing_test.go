@@ -0,0 +1,118 @@
+package clientUsers
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+
+	Model "Golang/model"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var (
+	recorderOnce sync.Once
+	recorder     *tracetest.SpanRecorder
+)
+
+// spanRecorder instala una sola vez el proveedor global: los tracers de
+// otel.Tracer solo delegan en el primero que se registra.
+func spanRecorder() *tracetest.SpanRecorder {
+	recorderOnce.Do(func() {
+		recorder = tracetest.NewSpanRecorder()
+		otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	})
+	return recorder
+}
+
+func spansOf(traceID trace.TraceID) []sdktrace.ReadOnlySpan {
+	var spans []sdktrace.ReadOnlySpan
+	for _, span := range spanRecorder().Ended() {
+		if span.SpanContext().TraceID() == traceID {
+			spans = append(spans, span)
+		}
+	}
+	return spans
+}
+
+func attributeOf(span sdktrace.ReadOnlySpan, key attribute.Key) string {
+	for _, kv := range span.Attributes() {
+		if kv.Key == key {
+			return kv.Value.Emit()
+		}
+	}
+	return ""
+}
+
+func TestTracing_SQLStatementsAreChildSpans(t *testing.T) {
+	spanRecorder()
+	repo, err := NewSQLite(Config{})
+	require.NoError(t, err)
+	defer repo.Close()
+
+	ctx, parent := otel.Tracer("test").Start(context.Background(), "PUT /users")
+	instrumented := NewInstrumented(repo)
+
+	created, err := instrumented.InsertUser(ctx, Model.User{Nombre: "secreta", Password: "hash-secreto"})
+	require.NoError(t, err)
+	created.Atributos = "muy privado"
+	_, err = instrumented.UpdateUser(ctx, created)
+	require.NoError(t, err)
+	parent.End()
+
+	spans := spansOf(parent.SpanContext().TraceID())
+	byName := map[string][]sdktrace.ReadOnlySpan{}
+	for _, span := range spans {
+		byName[span.Name()] = append(byName[span.Name()], span)
+	}
+
+	require.Len(t, byName["Repository.UpdateUser"], 1)
+	update := byName["Repository.UpdateUser"][0]
+	require.Len(t, byName["UPDATE users"], 1)
+	assert.Equal(t, update.SpanContext().SpanID(), byName["UPDATE users"][0].Parent().SpanID())
+	assert.Equal(t, "sqlite", attributeOf(byName["UPDATE users"][0], "db.system"))
+	assert.Len(t, byName["INSERT users"], 1)
+	// UpdateUser relee la fila despues del UPDATE: son dos idas a la base.
+	assert.NotEmpty(t, byName["SELECT users"])
+
+	for _, span := range spans {
+		statement := attributeOf(span, "db.statement")
+		assert.NotContains(t, statement, "secreta")
+		assert.NotContains(t, statement, "hash-secreto")
+		assert.NotContains(t, statement, "muy privado")
+	}
+	assert.Contains(t, attributeOf(byName["UPDATE users"][0], "db.statement"), "WHERE (id = ? AND version = ?)")
+}
+
+func TestTracing_WithoutParentSpanNothingIsRecorded(t *testing.T) {
+	spanRecorder()
+	repo, err := NewSQLite(Config{})
+	require.NoError(t, err)
+	defer repo.Close()
+
+	before := len(spanRecorder().Ended())
+	_, err = repo.InsertUser(context.Background(), Model.User{Nombre: "ana"})
+	require.NoError(t, err)
+
+	assert.Len(t, spanRecorder().Ended(), before)
+}
+
+func TestSanitizeSQL(t *testing.T) {
+	cases := map[string]string{
+		"SELECT * FROM users WHERE (nombre = 'ana') LIMIT 1":       "SELECT * FROM users WHERE (nombre = ?) LIMIT ?",
+		"SELECT * FROM users WHERE (id = $1) ORDER BY id LIMIT 1":  "SELECT * FROM users WHERE (id = $1) ORDER BY id LIMIT ?",
+		"UPDATE users SET version = version + 1 WHERE (id = ?)":    "UPDATE users SET version = version + ? WHERE (id = ?)",
+		"SELECT * FROM t1 WHERE (atributos = 'it''s' AND x = 2.5)": "SELECT * FROM t1 WHERE (atributos = ? AND x = ?)",
+	}
+	for in, want := range cases {
+		assert.Equal(t, want, sanitizeSQL(in), in)
+		assert.False(t, strings.Contains(sanitizeSQL(in), "'"))
+	}
+}
@@ -1,116 +1,346 @@
 package clientUsers
 
 import (
+	Domain "Golang/domain"
+	"Golang/logging"
 	Model "Golang/model"
 	"context"
+	"database/sql"
 	"fmt"
-
-	_ "github.com/jinzhu/gorm/dialects/mysql"
+	"strings"
 
 	"github.com/jinzhu/gorm"
-	log "github.com/sirupsen/logrus"
 )
 
-type MySQLConfig struct {
-	Name string
-	User string
-	Pass string
-	Host string
-}
-
 type SQL struct {
 	db       *gorm.DB
 	Database string
 }
 
-func NewSql(config MySQLConfig) SQL {
-	dsn := fmt.Sprintf("%s:%s@tcp(%s:3306)/%s?charset=utf8&parseTime=True&tls=skip-verify",
-		config.User, config.Pass, config.Host, config.Name)
+func (repository SQL) InsertUser(ctx context.Context, user Model.User) (Model.User, error) {
+	user.Version = 1
 
-	db, err := gorm.Open("mysql", dsn)
-	if err != nil {
-		log.Println("Connection Failed to Open")
-		log.Fatal(err)
-	} else {
-		log.Println("Connection Established (Azure MySQL, TLS enabled)")
+	if err := repository.with(ctx).Create(&user).Error; err != nil {
+		logQueryError(ctx, err, "Error al crear el usuario")
+		return user, classify(err, "error creating user")
 	}
+	logging.FromContext(ctx).WithField("user_id", user.Id).Debug("User Created")
+	return user, nil
+}
 
-	db.AutoMigrate(&Model.User{})
+// InsertUsers inserta el lote en una sola transaccion: si una fila falla, por
+// ejemplo por un nombre repetido, no queda ninguna.
+func (repository SQL) InsertUsers(ctx context.Context, users []Model.User) ([]Model.User, error) {
+	return repository.InsertSealed(ctx, users, nil)
+}
 
-	return SQL{
-		db:       db,
-		Database: config.Name,
+// InsertSealed sella cada fila dentro de la transaccion del alta, asi no
+// queda ninguna guardada sin el id en el dato asociado.
+func (repository SQL) InsertSealed(ctx context.Context, users []Model.User, seal func(*Model.User) error) (_ []Model.User, err error) {
+	tx := repository.with(ctx).Begin()
+	if tx.Error != nil {
+		return nil, classify(tx.Error, "error importing users")
 	}
-}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
 
-func (repository SQL) InsertUser(user Model.User) (Model.User, error) {
+	created := make([]Model.User, 0, len(users))
+	for _, user := range users {
+		user.Version = 1
+		if err := tx.Create(&user).Error; err != nil {
+			logQueryError(ctx, err, "Error al importar los usuarios")
+			return nil, classify(err, "error importing users")
+		}
+		if seal != nil {
+			if err := seal(&user); err != nil {
+				return nil, err
+			}
+			if err := tx.Model(&Model.User{}).Where("id = ?", user.Id).UpdateColumns(sealedFields(user)).Error; err != nil {
+				return nil, classify(err, "error importing users")
+			}
+		}
+		created = append(created, user)
+	}
+	if err := tx.Commit().Error; err != nil {
+		return nil, classify(err, "error importing users")
+	}
 
-	result := repository.db.Create(&user)
+	logging.FromContext(ctx).WithField("count", len(created)).Debug("Users Imported")
+	return created, nil
+}
 
+func (repository SQL) DeleteUser(ctx context.Context, Id int) error {
+	result := repository.with(ctx).Where("id = ?", Id).Delete(&Model.User{})
 	if result.Error != nil {
-		log.Error("Error al crear el usuario")
-		log.Error(result.Error)
-		return user, fmt.Errorf("error creating user")
+		logQueryError(ctx, result.Error, "Error al borrar el usuario")
+		return classify(result.Error, "error deleting user")
 	}
-	log.Debug("User Created: ", user.Id)
-	return user, nil
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("error deleting user %d: %w", Id, Domain.ErrNotFound)
+	}
+	logging.FromContext(ctx).WithField("user_id", Id).Debug("User Deleted")
+	return nil
 }
 
-func (repository SQL) GetUserById(Id int) (Model.User, error) {
+// existingNamesChunk acota la lista del IN; los drivers tienen limites de
+// parametros por sentencia.
+const existingNamesChunk = 500
+
+func (repository SQL) ExistingNames(ctx context.Context, nombres []string) ([]string, error) {
+	existing := make([]string, 0)
+	for start := 0; start < len(nombres); start += existingNamesChunk {
+		end := start + existingNamesChunk
+		if end > len(nombres) {
+			end = len(nombres)
+		}
+
+		var found []string
+		result := repository.with(ctx).Model(&Model.User{}).Where("nombre IN (?)", nombres[start:end]).Order("nombre").Pluck("nombre", &found)
+		if result.Error != nil {
+			logQueryError(ctx, result.Error, "Error al buscar nombres registrados")
+			return nil, classify(result.Error, "error searching existing names")
+		}
+		existing = append(existing, found...)
+	}
+
+	return existing, nil
+}
+
+func (repository SQL) GetUserById(ctx context.Context, Id int) (Model.User, error) {
 	var userId Model.User
 
-	result := repository.db.Where("id = ?", Id).First(&userId)
-	log.Debug("id: ", userId)
+	result := repository.with(ctx).Where("id = ?", Id).First(&userId)
 	if result.Error != nil {
-		log.Error("Error al buscar el usuario")
-		log.Error(result.Error)
-		return userId, fmt.Errorf("error creating user")
+		logQueryError(ctx, result.Error, "Error al buscar el usuario")
+		return userId, classify(result.Error, "error finding user")
 	}
 
 	return userId, nil
 }
 
+// UpdateUser reemplaza la fila solo si su version sigue siendo User.Version.
+// Si otra escritura la cambio antes devuelve ErrPreconditionFailed.
 func (repository SQL) UpdateUser(ctx context.Context, User Model.User) (Model.User, error) {
-	var buscado Model.User
-	fmt.Println("db busca: ", User)
+	fields := map[string]interface{}{
+		"nombre":       User.Nombre,
+		"genero":       User.Genero,
+		"atributos":    User.Atributos,
+		"maneja":       User.Maneja,
+		"lentes":       User.Lentes,
+		"diabetico":    User.Diabetico,
+		"enfermedades": User.Enfermedades,
+		"admin":        User.Admin,
+		"estado":       User.Estado,
+		// Columnas del cifrado en reposo; vacias si no esta activo.
+		"atributos_enc":    User.AtributosEnc,
+		"enfermedades_enc": User.EnfermedadesEnc,
+		"diabetico_enc":    User.DiabeticoEnc,
+		"diabetico_index":  User.DiabeticoIndex,
+		"data_key":         User.DataKey,
+		"anonymized_at":    User.AnonymizedAt,
+		"deactivated_at":   User.DeactivatedAt,
+	}
+
+	return repository.conditionalUpdate(ctx, User.Id, User.Version, fields, "error updating user")
+}
+
+// PatchUser actualiza solo las columnas de fields, con la misma condicion de
+// version que UpdateUser, y devuelve la fila completa.
+func (repository SQL) PatchUser(ctx context.Context, Id int, version int, fields map[string]interface{}) (Model.User, error) {
+	if len(fields) == 0 {
+		user, err := repository.GetUserById(ctx, Id)
+		if err == nil && user.Version != version {
+			return Model.User{}, fmt.Errorf("error patching user %d: %w", Id, Domain.ErrPreconditionFailed)
+		}
+		return user, err
+	}
 
-	result := repository.db.Where("id = ?", User.Id).First(&buscado)
+	return repository.conditionalUpdate(ctx, Id, version, fields, "error patching user")
+}
 
+func (repository SQL) conditionalUpdate(ctx context.Context, Id int, version int, fields map[string]interface{}, action string) (Model.User, error) {
+	values := make(map[string]interface{}, len(fields)+1)
+	for column, value := range fields {
+		values[column] = value
+	}
+	values["version"] = gorm.Expr("version + 1")
+
+	result := repository.with(ctx).Model(&Model.User{}).
+		Where("id = ? AND version = ?", Id, version).
+		Updates(values)
 	if result.Error != nil {
-		return Model.User{}, fmt.Errorf("error finding document: %v", result.Error)
+		return Model.User{}, classify(result.Error, action)
 	}
 
-	if err := repository.db.Save(&User).Error; err != nil {
-		return User, fmt.Errorf("error updating user: %w", err)
+	if result.RowsAffected == 0 {
+		// No coincidio ninguna fila: o no existe, o cambio de version.
+		if _, err := repository.GetUserById(ctx, Id); err != nil {
+			return Model.User{}, err
+		}
+		return Model.User{}, fmt.Errorf("%s %d: %w", action, Id, Domain.ErrPreconditionFailed)
 	}
 
-	return User, nil
+	return repository.GetUserById(ctx, Id)
+}
+
+// RewriteColumns usa UpdateColumns, que no corre los callbacks de gorm y por
+// eso no toca updated_at.
+func (repository SQL) RewriteColumns(ctx context.Context, Id int, version int, fields map[string]interface{}) error {
+	result := repository.with(ctx).Model(&Model.User{}).
+		Where("id = ? AND version = ?", Id, version).
+		UpdateColumns(fields)
+	if result.Error != nil {
+		logQueryError(ctx, result.Error, "Error al reescribir el usuario")
+		return classify(result.Error, "error rewriting user")
+	}
+	if result.RowsAffected == 0 {
+		if _, err := repository.GetUserById(ctx, Id); err != nil {
+			return err
+		}
+		return fmt.Errorf("error rewriting user %d: %w", Id, Domain.ErrPreconditionFailed)
+	}
+	return nil
 }
 
-func (repository SQL) GetUserByName(Usuario Model.User) (Model.User, error) {
+func (repository SQL) GetUserByName(ctx context.Context, Usuario Model.User) (Model.User, error) {
 	var user Model.User
-	fmt.Println("esto busca: ", Usuario.Nombre)
-	result := repository.db.Where("nombre = ?", Usuario.Nombre).First(&user)
+	result := repository.with(ctx).Where("nombre = ?", Usuario.Nombre).First(&user)
 	if result.Error != nil {
-		log.Error("Error al buscar el usuario")
-		log.Error(result.Error)
-		return user, fmt.Errorf("Error searching user by name.")
+		logQueryError(ctx, result.Error, "Error al buscar el usuario")
+		return user, classify(result.Error, "error searching user by name")
 	}
-	fmt.Println("esto encuenetra: ", user.Nombre)
-	fmt.Println("esto encuenetra: ", user.Password)
 
 	return user, nil
 }
 
-func (repository SQL) GetAllUsers() ([]Model.User, error) {
+func (repository SQL) GetAllUsers(ctx context.Context) ([]Model.User, error) {
 	var users []Model.User
 
-	result := repository.db.Find(&users)
+	result := repository.with(ctx).Order("id").Find(&users)
 	if result.Error != nil {
-		log.Error("Error al obtener los usuarios")
-		log.Error(result.Error)
-		return nil, fmt.Errorf("Error retrieving all users.")
+		logQueryError(ctx, result.Error, "Error al obtener los usuarios")
+		return nil, classify(result.Error, "error retrieving all users")
 	}
 
 	return users, nil
 }
+
+// GetUsersStamp calcula el validador del listado con dos consultas agregadas,
+// sin traer las filas.
+func (repository SQL) GetUsersStamp(ctx context.Context) (Model.UsersStamp, error) {
+	var stamp Model.UsersStamp
+
+	row := repository.with(ctx).Model(&Model.User{}).Select("COUNT(*), COALESCE(SUM(version), 0), COALESCE(MAX(id), 0)").Row()
+	if err := row.Scan(&stamp.Count, &stamp.VersionSum, &stamp.MaxId); err != nil {
+		return stamp, classify(err, "error computing users stamp")
+	}
+	if stamp.Count == 0 {
+		return stamp, nil
+	}
+
+	// MAX(updated_at) pierde el tipo en SQLite, asi que se lee la fila mas
+	// reciente usando el indice de updated_at.
+	var latest Model.User
+	result := repository.with(ctx).Select("updated_at").Order("updated_at DESC").First(&latest)
+	if result.Error != nil {
+		return stamp, classify(result.Error, "error computing users stamp")
+	}
+	stamp.LastModified = latest.UpdatedAt
+
+	return stamp, nil
+}
+
+// scanPageSize es la cantidad de filas que ScanUsers lee por consulta.
+const scanPageSize = 500
+
+// ScanUsers pagina por id (id > ultimo visto) en lugar de mantener un
+// cursor abierto: la conexion se libera entre paginas y fn puede tardar, por
+// ejemplo escribiendo a un cliente lento, sin bloquear la base. Con SQLite
+// hay una sola conexion y un cursor abierto frenaria todos los pedidos.
+func (repository SQL) ScanUsers(ctx context.Context, filter Model.UserFilter, fn func(Model.User) error) error {
+	query := repository.with(ctx).Model(&Model.User{})
+	if filter.Admin != nil {
+		query = query.Where("admin = ?", *filter.Admin)
+	}
+	if filter.Estado != nil {
+		query = query.Where("estado = ?", *filter.Estado)
+	}
+	if filter.Diabetico != nil {
+		if filter.DiabeticoIndex != "" {
+			query = query.Where("(diabetico_index = ? OR (COALESCE(diabetico_index, '') = '' AND diabetico = ?))", filter.DiabeticoIndex, *filter.Diabetico)
+		} else {
+			query = query.Where("diabetico = ?", *filter.Diabetico)
+		}
+	}
+	if filter.Nombre != "" {
+		query = query.Where("LOWER(nombre) LIKE ? ESCAPE '!'", "%"+escapeLike(strings.ToLower(filter.Nombre))+"%")
+	}
+	if filter.Anonymized != nil {
+		if *filter.Anonymized {
+			query = query.Where("anonymized_at IS NOT NULL")
+		} else {
+			query = query.Where("anonymized_at IS NULL")
+		}
+	}
+	if !filter.DeactivatedBefore.IsZero() {
+		query = query.Where("deactivated_at < ?", filter.DeactivatedBefore)
+	}
+
+	last := 0
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		var page []Model.User
+		result := query.Where("id > ?", last).Order("id").Limit(scanPageSize).Find(&page)
+		if result.Error != nil {
+			logQueryError(ctx, result.Error, "Error al recorrer los usuarios")
+			return classify(result.Error, "error scanning users")
+		}
+		for _, user := range page {
+			if err := fn(user); err != nil {
+				return err
+			}
+		}
+		if len(page) < scanPageSize {
+			return nil
+		}
+		last = page[len(page)-1].Id
+	}
+}
+
+// escapeLike escapa los comodines de LIKE con '!', que a diferencia de la
+// barra invertida se interpreta igual en MySQL, PostgreSQL y SQLite.
+func escapeLike(text string) string {
+	return strings.NewReplacer("!", "!!", "%", "!%", "_", "!_").Replace(text)
+}
+
+// logQueryError registra una consulta fallida. Que no exista la fila es
+// parte del uso normal y queda en debug.
+func logQueryError(ctx context.Context, err error, message string) {
+	entry := logging.FromContext(ctx).WithError(err)
+	if gorm.IsRecordNotFoundError(err) {
+		entry.Debug(message)
+		return
+	}
+	entry.Error(message)
+}
+
+// with asocia ctx a la consulta para que los callbacks de tracing.go
+// cuelguen el span de la sentencia del span del pedido.
+func (repository SQL) with(ctx context.Context) *gorm.DB {
+	return repository.db.Set(traceContextKey, ctx)
+}
+
+// DB devuelve el pool de conexiones, para publicar sus estadisticas.
+func (repository SQL) DB() *sql.DB {
+	return repository.db.DB()
+}
+
+// Close libera el pool de conexiones de la base.
+func (repository SQL) Close() error {
+	return repository.db.Close()
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"testing"
 
+	Domain "Golang/domain"
 	Model "Golang/model"
 
 	"github.com/jinzhu/gorm"
@@ -26,12 +27,12 @@ func TestInsertAndGetUserById(t *testing.T) {
 	repo := setupInMemoryDB(t)
 
 	u := Model.User{Nombre: "test", Password: "p"}
-	created, err := repo.InsertUser(u)
+	created, err := repo.InsertUser(context.Background(), u)
 	assert.NoError(t, err)
 	// created.Id should be set by GORM
 	assert.NotZero(t, created.Id)
 
-	fetched, err2 := repo.GetUserById(created.Id)
+	fetched, err2 := repo.GetUserById(context.Background(), created.Id)
 	assert.NoError(t, err2)
 	assert.Equal(t, "test", fetched.Nombre)
 }
@@ -39,7 +40,7 @@ func TestInsertAndGetUserById(t *testing.T) {
 func TestUpdateUser(t *testing.T) {
 	repo := setupInMemoryDB(t)
 	u := Model.User{Nombre: "before", Password: "p"}
-	created, _ := repo.InsertUser(u)
+	created, _ := repo.InsertUser(context.Background(), u)
 
 	created.Nombre = "after"
 	updated, err := repo.UpdateUser(context.Background(), created)
@@ -49,14 +50,14 @@ func TestUpdateUser(t *testing.T) {
 
 func TestGetUserByNameAndGetAll(t *testing.T) {
 	repo := setupInMemoryDB(t)
-	repo.InsertUser(Model.User{Nombre: "alpha", Password: "a"})
-	repo.InsertUser(Model.User{Nombre: "beta", Password: "b"})
+	repo.InsertUser(context.Background(), Model.User{Nombre: "alpha", Password: "a"})
+	repo.InsertUser(context.Background(), Model.User{Nombre: "beta", Password: "b"})
 
-	u, err := repo.GetUserByName(Model.User{Nombre: "alpha"})
+	u, err := repo.GetUserByName(context.Background(), Model.User{Nombre: "alpha"})
 	assert.NoError(t, err)
 	assert.Equal(t, "alpha", u.Nombre)
 
-	all, err2 := repo.GetAllUsers()
+	all, err2 := repo.GetAllUsers(context.Background())
 	assert.NoError(t, err2)
 	assert.GreaterOrEqual(t, len(all), 2)
 }
@@ -64,7 +65,7 @@ func TestGetUserByNameAndGetAll(t *testing.T) {
 func TestGetUserById_NotFound(t *testing.T) {
 	repo := setupInMemoryDB(t)
 
-	_, err := repo.GetUserById(9999)
+	_, err := repo.GetUserById(context.Background(), 9999)
 	assert.Error(t, err)
 }
 
@@ -83,11 +84,11 @@ func TestGetUserByName_NotFound(t *testing.T) {
 
 	// 2. Act
 	// Buscamos un usuario que no existe
-	_, err := repo.GetUserByName(Model.User{Nombre: "usuario-inexistente"})
+	_, err := repo.GetUserByName(context.Background(), Model.User{Nombre: "usuario-inexistente"})
 
 	// 3. Assert
 	assert.Error(t, err)
-	assert.Equal(t, "Error searching user by name.", err.Error())
+	assert.ErrorIs(t, err, Domain.ErrNotFound)
 }
 
 func TestInsertUser_Duplicate(t *testing.T) {
@@ -96,14 +97,14 @@ func TestInsertUser_Duplicate(t *testing.T) {
 
 	// Insertamos el primer usuario, esto debe funcionar
 	user1 := Model.User{Nombre: "usuario-duplicado", Password: "p1"}
-	_, err1 := repo.InsertUser(user1)
+	_, err1 := repo.InsertUser(context.Background(), user1)
 	assert.NoError(t, err1) // Verificamos que el primero si funciono
 
 	// 2. Act
 	user2 := Model.User{Nombre: "usuario-duplicado", Password: "p2"}
-	_, err2 := repo.InsertUser(user2)
+	_, err2 := repo.InsertUser(context.Background(), user2)
 
 	// 3. Assert
 	assert.Error(t, err2)
-	assert.Equal(t, "error creating user", err2.Error())
+	assert.ErrorIs(t, err2, Domain.ErrConflict)
 }
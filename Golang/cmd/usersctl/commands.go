@@ -0,0 +1,580 @@
+package main
+
+import (
+	clientUsers "Golang/clients"
+	"Golang/config"
+	Domain "Golang/domain"
+	services "Golang/service"
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type command func(ctx context.Context, c *commandContext) int
+
+var commands = map[string]command{
+	"create":          createUser,
+	"promote":         setAdmin(true),
+	"demote":          setAdmin(false),
+	"reset-password":  resetPassword,
+	"deactivate":      setEstado(false),
+	"activate":        setEstado(true),
+	"list":            listUsers,
+	"import":          importUsers,
+	"migrate":         migrate,
+	"check":           check,
+	"reencrypt":       reencrypt,
+	"publish-consent": publishConsent,
+	"purge":           purge,
+}
+
+// commandContext es el estado de una ejecucion de un comando.
+type commandContext struct {
+	env
+	name   string
+	args   []string
+	config config.Config
+	output string
+	repo   clientUsers.Repository
+}
+
+// flags crea el FlagSet del comando con el flag -o comun a todos.
+func (c *commandContext) flags() *flag.FlagSet {
+	flags := flag.NewFlagSet("usersctl "+c.name, flag.ContinueOnError)
+	flags.SetOutput(c.stderr)
+	flags.StringVar(&c.output, "o", formatTable, "output format: table or json")
+	return flags
+}
+
+// parse interpreta los flags del comando. Si devuelve false el comando
+// termina con exitUsage.
+func (c *commandContext) parse(flags *flag.FlagSet) bool {
+	if err := flags.Parse(c.args); err != nil {
+		return false
+	}
+	if flags.NArg() > 0 {
+		fmt.Fprintf(c.stderr, "usersctl %s: unexpected arguments %v\n", c.name, flags.Args())
+		return false
+	}
+	if c.output != formatTable && c.output != formatJSON {
+		fmt.Fprintf(c.stderr, "usersctl %s: -o must be table or json\n", c.name)
+		return false
+	}
+	return true
+}
+
+// service abre el repositorio configurado y arma el mismo Service que usa
+// la API, con el cifrado en reposo si esta configurado, los consentimientos
+// y el historial de logins.
+func (c *commandContext) service() (services.Service, error) {
+	repo, err := c.open(c.config.Database)
+	if err != nil {
+		return services.Service{}, err
+	}
+	c.repo = repo
+	encrypted, err := c.encryption(repo)
+	if err != nil {
+		c.close()
+		return services.Service{}, err
+	}
+	svc := services.NewService(repo)
+	if encrypted != nil {
+		svc = services.NewService(encrypted)
+	}
+	if consents, ok := repo.(clientUsers.ConsentStore); ok {
+		svc = svc.WithConsents(consents)
+	}
+	if logins, ok := repo.(clientUsers.LoginHistory); ok {
+		svc = svc.WithLoginHistory(logins)
+	}
+	return svc, nil
+}
+
+// encryption envuelve repo con el cifrado de la configuracion. Devuelve nil
+// si esta apagado.
+func (c *commandContext) encryption(repo clientUsers.Repository) (*clientUsers.Encrypted, error) {
+	return clientUsers.NewEncryption(repo, clientUsers.EncryptionConfig{
+		MasterKeys: c.config.Encryption.MasterKeys,
+		ActiveKey:  c.config.Encryption.ActiveKey,
+		IndexKey:   c.config.Encryption.IndexKey,
+	})
+}
+
+func (c *commandContext) close() {
+	if closer, ok := c.repo.(io.Closer); ok {
+		closer.Close()
+	}
+}
+
+func (c *commandContext) fail(err error) int {
+	fmt.Fprintf(c.stderr, "usersctl %s: %s\n", c.name, describe(err))
+	return exitError
+}
+
+func (c *commandContext) usageError(format string, args ...interface{}) int {
+	fmt.Fprintf(c.stderr, "usersctl %s: %s\n", c.name, fmt.Sprintf(format, args...))
+	return exitUsage
+}
+
+// describe traduce los errores del dominio a un mensaje para el operador.
+func describe(err error) string {
+	switch {
+	case errors.Is(err, Domain.ErrNotFound):
+		return "user not found"
+	case errors.Is(err, Domain.ErrConflict):
+		return "a user with that name already exists"
+	case errors.Is(err, Domain.ErrPreconditionFailed):
+		return "the user was modified concurrently, try again"
+	default:
+		return err.Error()
+	}
+}
+
+// target es el usuario sobre el que actua un comando, por id o por nombre.
+type target struct {
+	id   int
+	name string
+}
+
+func (t *target) register(flags *flag.FlagSet) {
+	flags.IntVar(&t.id, "id", 0, "user id")
+	flags.StringVar(&t.name, "name", "", "user name")
+}
+
+func (t *target) validate() error {
+	if (t.id == 0) == (t.name == "") {
+		return errors.New("exactly one of --id or --name is required")
+	}
+	return nil
+}
+
+func (t *target) resolve(ctx context.Context, svc services.Service) (int, error) {
+	if t.id != 0 {
+		return t.id, nil
+	}
+	profile, err := svc.GetUserByName(ctx, t.name)
+	if err != nil {
+		return 0, err
+	}
+	return profile.Id, nil
+}
+
+// readPassword lee la primera linea de stdin, sin el salto de linea.
+func readPassword(stdin io.Reader) (string, error) {
+	line, err := bufio.NewReader(stdin).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return "", fmt.Errorf("reading password from stdin: %w", err)
+	}
+	password := strings.TrimRight(line, "\r\n")
+	if password == "" {
+		return "", errors.New("no password on stdin")
+	}
+	return password, nil
+}
+
+func createUser(ctx context.Context, c *commandContext) int {
+	flags := c.flags()
+	var req Domain.CreateUserRequest
+	flags.StringVar(&req.Nombre, "name", "", "user name")
+	flags.StringVar(&req.Genero, "genero", "", "M, F or X")
+	admin := flags.Bool("admin", false, "grant the admin role")
+	flags.IntVar(&req.Consentimiento, "consent", 0, "consent version the user accepted")
+	passwordStdin := flags.Bool("password-stdin", false, "read the password from stdin")
+	if !c.parse(flags) {
+		return exitUsage
+	}
+	if !*passwordStdin {
+		return c.usageError("a password is required: pass it on stdin with --password-stdin")
+	}
+
+	password, err := readPassword(c.stdin)
+	if err != nil {
+		return c.fail(err)
+	}
+	req.Password = password
+	req.Normalize()
+	if err := req.Validate(); err != nil {
+		return c.fail(err)
+	}
+
+	svc, err := c.service()
+	if err != nil {
+		return c.fail(err)
+	}
+	defer c.close()
+
+	insert := svc.InsertUsuario
+	if *admin {
+		insert = svc.InsertAdmin
+	}
+	user, err := insert(ctx, req, "")
+	var validation *Domain.ValidationError
+	if errors.As(err, &validation) && validation.Violations[0].Field == "consentimiento" {
+		version := validation.Violations[0].Param
+		return c.fail(fmt.Errorf("the user has to accept consent version %s; pass --consent %s once they have", version, version))
+	}
+	if err != nil {
+		return c.fail(err)
+	}
+	return c.printUser(user)
+}
+
+func setAdmin(admin bool) command {
+	return func(ctx context.Context, c *commandContext) int {
+		return changeUser(ctx, c, func(svc services.Service, id int) (Domain.UserResponse, error) {
+			return svc.SetAdmin(ctx, id, admin)
+		})
+	}
+}
+
+func setEstado(estado bool) command {
+	return func(ctx context.Context, c *commandContext) int {
+		return changeUser(ctx, c, func(svc services.Service, id int) (Domain.UserResponse, error) {
+			return svc.SetEstado(ctx, id, estado)
+		})
+	}
+}
+
+func changeUser(ctx context.Context, c *commandContext, change func(services.Service, int) (Domain.UserResponse, error)) int {
+	flags := c.flags()
+	var t target
+	t.register(flags)
+	if !c.parse(flags) {
+		return exitUsage
+	}
+	if err := t.validate(); err != nil {
+		return c.usageError("%v", err)
+	}
+
+	svc, err := c.service()
+	if err != nil {
+		return c.fail(err)
+	}
+	defer c.close()
+
+	id, err := t.resolve(ctx, svc)
+	if err != nil {
+		return c.fail(err)
+	}
+	user, err := change(svc, id)
+	if err != nil {
+		return c.fail(err)
+	}
+	return c.printUser(user)
+}
+
+func resetPassword(ctx context.Context, c *commandContext) int {
+	flags := c.flags()
+	var t target
+	t.register(flags)
+	passwordStdin := flags.Bool("password-stdin", false, "read the new password from stdin")
+	if !c.parse(flags) {
+		return exitUsage
+	}
+	if err := t.validate(); err != nil {
+		return c.usageError("%v", err)
+	}
+	if !*passwordStdin {
+		return c.usageError("a password is required: pass it on stdin with --password-stdin")
+	}
+
+	password, err := readPassword(c.stdin)
+	if err != nil {
+		return c.fail(err)
+	}
+	svc, err := c.service()
+	if err != nil {
+		return c.fail(err)
+	}
+	defer c.close()
+
+	id, err := t.resolve(ctx, svc)
+	if err != nil {
+		return c.fail(err)
+	}
+	user, err := svc.ResetPassword(ctx, id, Domain.PasswordReset{Password: password})
+	if err != nil {
+		return c.fail(err)
+	}
+	return c.printUser(user)
+}
+
+// optionalBool es un flag booleano que distingue "no indicado" de false.
+type optionalBool struct {
+	value *bool
+}
+
+func (o *optionalBool) String() string {
+	if o.value == nil {
+		return ""
+	}
+	return strconv.FormatBool(*o.value)
+}
+
+func (o *optionalBool) Set(text string) error {
+	value, err := strconv.ParseBool(text)
+	if err != nil {
+		return err
+	}
+	o.value = &value
+	return nil
+}
+
+func listUsers(ctx context.Context, c *commandContext) int {
+	flags := c.flags()
+	var admin, active, diabetic optionalBool
+	var filter Domain.UserFilter
+	flags.Var(&admin, "admin", "only admins (true) or only non-admins (false)")
+	flags.Var(&active, "active", "only active (true) or inactive (false) accounts")
+	flags.Var(&diabetic, "diabetic", "only diabetic (true) or non-diabetic (false) users")
+	flags.StringVar(&filter.Nombre, "name", "", "only names containing this text")
+	if !c.parse(flags) {
+		return exitUsage
+	}
+	filter.Admin = admin.value
+	filter.Estado = active.value
+	filter.Diabetico = diabetic.value
+
+	svc, err := c.service()
+	if err != nil {
+		return c.fail(err)
+	}
+	defer c.close()
+
+	users, err := svc.ListUsers(ctx, filter)
+	if err != nil {
+		return c.fail(err)
+	}
+	return c.printUsers(users)
+}
+
+func importUsers(ctx context.Context, c *commandContext) int {
+	flags := c.flags()
+	var opts Domain.ImportOptions
+	file := flags.String("file", "", "CSV or JSONL file to import, - for stdin")
+	flags.StringVar(&opts.Format, "format", "", "csv or jsonl (default: from the file extension)")
+	flags.BoolVar(&opts.DryRun, "dry-run", false, "validate the file without importing")
+	flags.BoolVar(&opts.SkipInvalid, "skip-invalid", false, "import the valid rows even if others have errors")
+	if !c.parse(flags) {
+		return exitUsage
+	}
+	if *file == "" {
+		return c.usageError("--file is required")
+	}
+	if opts.Format == "" {
+		opts.Format = formatFromExtension(*file)
+	}
+	if opts.Format != Domain.ImportCSV && opts.Format != Domain.ImportJSONL {
+		return c.usageError("--format must be csv or jsonl")
+	}
+
+	data := c.stdin
+	if *file != "-" {
+		f, err := os.Open(*file)
+		if err != nil {
+			return c.fail(err)
+		}
+		defer f.Close()
+		data = f
+	}
+
+	svc, err := c.service()
+	if err != nil {
+		return c.fail(err)
+	}
+	defer c.close()
+
+	report, err := svc.ImportUsers(ctx, data, opts)
+	if err != nil {
+		return c.fail(err)
+	}
+	code := c.printReport(report)
+	if report.Aborted || (report.DryRun && report.Invalid > 0) {
+		return exitError
+	}
+	return code
+}
+
+func formatFromExtension(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".csv":
+		return Domain.ImportCSV
+	case ".jsonl", ".ndjson":
+		return Domain.ImportJSONL
+	default:
+		return ""
+	}
+}
+
+// migrate abre la base, lo que crea o actualiza la tabla de usuarios, y
+// verifica que el esquema haya quedado completo.
+func migrate(ctx context.Context, c *commandContext) int {
+	if !c.parse(c.flags()) {
+		return exitUsage
+	}
+	repo, err := c.open(c.config.Database)
+	if err != nil {
+		return c.fail(err)
+	}
+	c.repo = repo
+	defer c.close()
+
+	if sql, ok := repo.(clientUsers.SQL); ok {
+		if err := sql.CheckSchema(ctx); err != nil {
+			return c.fail(fmt.Errorf("schema incomplete after migration: %w", err))
+		}
+	}
+	return c.printResults([]result{{Check: "migrations", Status: "ok"}})
+}
+
+// check verifica la conexion y el esquema, igual que /readyz.
+func check(ctx context.Context, c *commandContext) int {
+	if !c.parse(c.flags()) {
+		return exitUsage
+	}
+
+	start := time.Now()
+	repo, err := c.open(c.config.Database)
+	results := []result{newResult("connect", start, err)}
+	if err == nil {
+		c.repo = repo
+		defer c.close()
+		if sql, ok := repo.(clientUsers.SQL); ok {
+			start = time.Now()
+			results = append(results, newResult("ping", start, sql.Ping(ctx)))
+			start = time.Now()
+			results = append(results, newResult("schema", start, sql.CheckSchema(ctx)))
+		}
+	}
+
+	code := c.printResults(results)
+	for _, r := range results {
+		if r.Error != "" {
+			return exitError
+		}
+	}
+	return code
+}
+
+// reencrypt hace una pasada del re-cifrado que el servidor corre en segundo
+// plano, por ejemplo para terminar una rotacion de clave antes de quitar la
+// clave vieja de la configuracion. Termina con error si quedaron filas sin
+// re-cifrar.
+func reencrypt(ctx context.Context, c *commandContext) int {
+	if !c.parse(c.flags()) {
+		return exitUsage
+	}
+	repo, err := c.open(c.config.Database)
+	if err != nil {
+		return c.fail(err)
+	}
+	c.repo = repo
+	defer c.close()
+
+	encrypted, err := c.encryption(repo)
+	if err != nil {
+		return c.fail(err)
+	}
+	if encrypted == nil {
+		return c.fail(errors.New("encryption is not configured (set ENCRYPTION_MASTER_KEYS)"))
+	}
+	stats, err := encrypted.Reencrypt(ctx)
+	if err != nil {
+		return c.fail(err)
+	}
+	code := c.printReencryptStats(stats)
+	if stats.Failed > 0 || stats.Skipped > 0 {
+		return exitError
+	}
+	return code
+}
+
+// publishConsent publica un texto de consentimiento nuevo. Desde ese momento
+// las altas tienen que aceptarlo y la API se lo vuelve a pedir a quienes
+// aceptaron uno anterior.
+func publishConsent(ctx context.Context, c *commandContext) int {
+	flags := c.flags()
+	file := flags.String("file", "", "file with the consent text, - for stdin")
+	if !c.parse(flags) {
+		return exitUsage
+	}
+	if *file == "" {
+		return c.usageError("--file is required")
+	}
+
+	var text []byte
+	var err error
+	if *file == "-" {
+		text, err = io.ReadAll(c.stdin)
+	} else {
+		text, err = os.ReadFile(*file)
+	}
+	if err != nil {
+		return c.fail(err)
+	}
+
+	svc, err := c.service()
+	if err != nil {
+		return c.fail(err)
+	}
+	defer c.close()
+
+	consent, err := svc.PublishConsent(ctx, string(text))
+	if err != nil {
+		return c.fail(err)
+	}
+	return c.printConsent(consent)
+}
+
+// purge hace una pasada del job de retencion que el servidor corre en
+// segundo plano, con los plazos de la configuracion. Con --dry-run solo
+// informa cuantos registros estan vencidos. Comparte el lock con el
+// servidor, asi que falla si una replica esta corriendo la suya.
+func purge(ctx context.Context, c *commandContext) int {
+	flags := c.flags()
+	dryRun := flags.Bool("dry-run", false, "only count the expired records")
+	if !c.parse(flags) {
+		return exitUsage
+	}
+	policy := Domain.RetentionPolicy{
+		InactiveAccounts: c.config.Retention.InactiveAccounts,
+		LoginHistory:     c.config.Retention.LoginHistory,
+		ConsentRecords:   c.config.Retention.ConsentRecords,
+	}
+	if policy.InactiveAccounts == 0 && policy.LoginHistory == 0 && policy.ConsentRecords == 0 {
+		return c.fail(errors.New("no retention period configured (set RETENTION_INACTIVE_ACCOUNTS, RETENTION_LOGIN_HISTORY or RETENTION_CONSENT_RECORDS)"))
+	}
+
+	svc, err := c.service()
+	if err != nil {
+		return c.fail(err)
+	}
+	defer c.close()
+	locker, ok := c.repo.(clientUsers.Locker)
+	if !ok {
+		return c.fail(errors.New("the configured database does not support locks"))
+	}
+
+	report, err := services.NewRetention(svc, policy, locker).Run(ctx, *dryRun)
+	if err != nil {
+		return c.fail(err)
+	}
+	if report.Skipped {
+		return c.fail(errors.New("another process is running the retention job, try again later"))
+	}
+	code := c.printRetention(report)
+	for _, category := range report.Categories {
+		if category.Failed > 0 {
+			return exitError
+		}
+	}
+	return code
+}
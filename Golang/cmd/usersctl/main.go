@@ -0,0 +1,132 @@
+// usersctl es la herramienta de administracion del servicio de usuarios.
+// Usa la misma configuracion que el servidor (--config, .env y variables de
+// entorno) y trabaja directo sobre la base, sin pasar por la API:
+//
+//	usersctl create --name admin --genero X --admin --password-stdin < clave.txt
+//	usersctl promote --name ana
+//	usersctl list --admin=true -o json
+//	usersctl import --file pacientes.csv --dry-run
+//	usersctl publish-consent --file consentimiento-v2.txt
+//	usersctl purge --dry-run
+//	usersctl check
+//
+// Las contraseñas solo se leen de stdin para que no queden en el historial
+// de la shell ni en la lista de procesos.
+package main
+
+import (
+	clientUsers "Golang/clients"
+	"Golang/config"
+	"Golang/logging"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+const usage = `usage: usersctl [--config file] [-v] <command> [flags]
+
+Commands:
+  create          create a user (--name, --genero, --admin, --consent, --password-stdin)
+  promote         grant the admin role (--id or --name)
+  demote          revoke the admin role (--id or --name)
+  reset-password  set a new password (--id or --name, --password-stdin)
+  deactivate      mark an account as inactive (--id or --name)
+  activate        mark an account as active (--id or --name)
+  list            list users (--admin, --active, --diabetic, --name)
+  import          import users from CSV or JSONL (--file, --format, --dry-run, --skip-invalid)
+  migrate         create or update the database schema
+  check           check database connectivity and schema
+  reencrypt       encrypt plaintext rows and rows under an old master key
+  publish-consent publish a new consent text for health data (--file)
+  purge           anonymize and purge data past its retention period (--dry-run)
+
+Every command accepts -o table (default) or -o json.
+`
+
+// Codigos de salida.
+const (
+	exitOK    = 0
+	exitError = 1
+	exitUsage = 2
+)
+
+// env es lo que run toma del proceso; los tests lo reemplazan.
+type env struct {
+	stdin   io.Reader
+	stdout  io.Writer
+	stderr  io.Writer
+	environ []string
+	envFile string
+	open    func(config.Database) (clientUsers.Repository, error)
+}
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	os.Exit(run(ctx, os.Args[1:], env{
+		stdin:   os.Stdin,
+		stdout:  os.Stdout,
+		stderr:  os.Stderr,
+		environ: os.Environ(),
+		envFile: ".env",
+		open:    openRepository,
+	}))
+}
+
+func run(ctx context.Context, args []string, e env) int {
+	global := flag.NewFlagSet("usersctl", flag.ContinueOnError)
+	global.SetOutput(e.stderr)
+	global.Usage = func() { fmt.Fprint(e.stderr, usage) }
+	configFile := global.String("config", "", "YAML or TOML config file (also CONFIG_FILE)")
+	verbose := global.Bool("v", false, "log what each command changes")
+	if err := global.Parse(args); err != nil {
+		return exitUsage
+	}
+	if global.NArg() == 0 {
+		global.Usage()
+		return exitUsage
+	}
+
+	name := global.Arg(0)
+	command, ok := commands[name]
+	if !ok {
+		fmt.Fprintf(e.stderr, "usersctl: unknown command %q\n\n%s", name, usage)
+		return exitUsage
+	}
+
+	level := "warn"
+	if *verbose {
+		level = "info"
+	}
+	if err := logging.Setup(logging.Config{Level: level, Format: logging.FormatText, Output: e.stderr}); err != nil {
+		fmt.Fprintln(e.stderr, "usersctl:", err)
+		return exitError
+	}
+
+	cfg, err := config.Load(config.Options{File: *configFile, EnvFile: e.envFile, Environ: e.environ})
+	if err != nil {
+		fmt.Fprintln(e.stderr, "usersctl:", err)
+		return exitError
+	}
+
+	return command(ctx, &commandContext{env: e, name: name, args: global.Args()[1:], config: cfg})
+}
+
+// openRepository abre el backend configurado. Abrirlo aplica la migracion.
+func openRepository(database config.Database) (clientUsers.Repository, error) {
+	return clientUsers.NewRepository(clientUsers.Config{
+		Driver: database.Driver,
+		Name:   database.Name,
+		User:   database.User,
+		Pass:   database.Password,
+		Host:   database.Host,
+		Port:   database.Port,
+		TLS:    database.TLS,
+		Path:   database.Path,
+	})
+}
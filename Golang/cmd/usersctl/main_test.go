@@ -0,0 +1,320 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	clientUsers "Golang/clients"
+	"Golang/config"
+	Domain "Golang/domain"
+	Model "Golang/model"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type harness struct {
+	repo *clientUsers.Memory
+	// environ se agrega al entorno de cada ejecucion.
+	environ []string
+	stdout  bytes.Buffer
+	stderr  bytes.Buffer
+}
+
+func newHarness(t *testing.T) *harness {
+	t.Helper()
+	h := &harness{repo: clientUsers.NewMemory()}
+	for _, user := range []Model.User{
+		{Nombre: "ana", Genero: "F", Password: "hash", Estado: true},
+		{Nombre: "bruno", Genero: "M", Password: "hash", Estado: true, Admin: true},
+		{Nombre: "mariana", Genero: "F", Password: "hash", Estado: false},
+	} {
+		_, err := h.repo.InsertUser(context.Background(), user)
+		require.NoError(t, err)
+	}
+	return h
+}
+
+func (h *harness) run(stdin string, args ...string) int {
+	h.stdout.Reset()
+	h.stderr.Reset()
+	return run(context.Background(), args, env{
+		stdin:   strings.NewReader(stdin),
+		stdout:  &h.stdout,
+		stderr:  &h.stderr,
+		environ: append([]string{"DB_DRIVER=memory"}, h.environ...),
+		open: func(config.Database) (clientUsers.Repository, error) {
+			return h.repo, nil
+		},
+	})
+}
+
+func (h *harness) user(t *testing.T, name string) Model.User {
+	t.Helper()
+	user, err := h.repo.GetUserByName(context.Background(), Model.User{Nombre: name})
+	require.NoError(t, err)
+	return user
+}
+
+func TestCreate_AdminFromStdin(t *testing.T) {
+	h := newHarness(t)
+
+	code := h.run("s3creto!\n", "create", "--name", "root", "--genero", "X", "--admin", "--password-stdin", "-o", "json")
+
+	require.Equal(t, exitOK, code, h.stderr.String())
+	var row userRow
+	require.NoError(t, json.Unmarshal(h.stdout.Bytes(), &row))
+	assert.Equal(t, "root", row.Nombre)
+	assert.True(t, row.Admin)
+	assert.True(t, row.Estado)
+
+	saved := h.user(t, "root")
+	assert.NotEqual(t, "s3creto!", saved.Password)
+	assert.Len(t, saved.Password, 32)
+	// El rol se guarda con el alta, sin una segunda escritura.
+	assert.True(t, saved.Admin)
+	assert.Equal(t, 1, saved.Version)
+}
+
+func TestCreate_RequiresPasswordOnStdin(t *testing.T) {
+	h := newHarness(t)
+
+	assert.Equal(t, exitUsage, h.run("", "create", "--name", "root", "--genero", "X"))
+	assert.Contains(t, h.stderr.String(), "--password-stdin")
+
+	assert.Equal(t, exitError, h.run("corta\n", "create", "--name", "root", "--genero", "X", "--password-stdin"))
+	assert.Contains(t, h.stderr.String(), "password: min")
+}
+
+func TestCreate_DuplicateName(t *testing.T) {
+	h := newHarness(t)
+
+	assert.Equal(t, exitError, h.run("s3creto!\n", "create", "--name", "ana", "--genero", "F", "--password-stdin"))
+	assert.Contains(t, h.stderr.String(), "already exists")
+}
+
+func TestPromoteAndDemote(t *testing.T) {
+	h := newHarness(t)
+
+	require.Equal(t, exitOK, h.run("", "promote", "--name", "ana"), h.stderr.String())
+	assert.True(t, h.user(t, "ana").Admin)
+	assert.Contains(t, h.stdout.String(), "ana")
+
+	id := h.user(t, "ana").Id
+	require.Equal(t, exitOK, h.run("", "demote", "--id", strconv.Itoa(id)), h.stderr.String())
+	assert.False(t, h.user(t, "ana").Admin)
+}
+
+func TestDeactivateAndActivate(t *testing.T) {
+	h := newHarness(t)
+
+	require.Equal(t, exitOK, h.run("", "deactivate", "--name", "ana"))
+	assert.False(t, h.user(t, "ana").Estado)
+
+	require.Equal(t, exitOK, h.run("", "activate", "--name", "mariana"))
+	assert.True(t, h.user(t, "mariana").Estado)
+}
+
+func TestResetPassword(t *testing.T) {
+	h := newHarness(t)
+	before := h.user(t, "ana").Password
+
+	require.Equal(t, exitOK, h.run("nueva-clave\n", "reset-password", "--name", "ana", "--password-stdin"), h.stderr.String())
+
+	after := h.user(t, "ana").Password
+	assert.NotEqual(t, before, after)
+	assert.NotContains(t, h.stdout.String(), after)
+}
+
+func TestTargetErrors(t *testing.T) {
+	h := newHarness(t)
+
+	assert.Equal(t, exitUsage, h.run("", "promote"))
+	assert.Contains(t, h.stderr.String(), "exactly one of --id or --name")
+
+	assert.Equal(t, exitUsage, h.run("", "promote", "--id", "1", "--name", "ana"))
+
+	assert.Equal(t, exitError, h.run("", "promote", "--name", "nadie"))
+	assert.Contains(t, h.stderr.String(), "user not found")
+}
+
+func TestList_FiltersAndFormats(t *testing.T) {
+	h := newHarness(t)
+
+	require.Equal(t, exitOK, h.run("", "list", "--admin=false", "--active=true", "-o", "json"))
+	var rows []userRow
+	require.NoError(t, json.Unmarshal(h.stdout.Bytes(), &rows))
+	require.Len(t, rows, 1)
+	assert.Equal(t, "ana", rows[0].Nombre)
+	assert.NotContains(t, h.stdout.String(), "enfermedades")
+
+	require.Equal(t, exitOK, h.run("", "list", "--name", "ANA"))
+	lines := strings.Split(strings.TrimSpace(h.stdout.String()), "\n")
+	assert.Len(t, lines, 3)
+	assert.True(t, strings.HasPrefix(lines[0], "ID"))
+}
+
+func TestCheck(t *testing.T) {
+	h := newHarness(t)
+
+	require.Equal(t, exitOK, h.run("", "check", "-o", "json"))
+	assert.Contains(t, h.stdout.String(), `"status": "ok"`)
+}
+
+func TestCheck_ConnectionFailure(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	code := run(context.Background(), []string{"check"}, env{
+		stdout:  &stdout,
+		stderr:  &stderr,
+		environ: []string{"DB_DRIVER=memory"},
+		open: func(config.Database) (clientUsers.Repository, error) {
+			return nil, errors.New("dial tcp db:3306: connection refused")
+		},
+	})
+
+	assert.Equal(t, exitError, code)
+	assert.Contains(t, stdout.String(), "connection refused")
+}
+
+func TestUsageErrors(t *testing.T) {
+	h := newHarness(t)
+
+	assert.Equal(t, exitUsage, h.run(""))
+	assert.Equal(t, exitUsage, h.run("", "explode"))
+	assert.Contains(t, h.stderr.String(), `unknown command "explode"`)
+	assert.Equal(t, exitUsage, h.run("", "list", "-o", "yaml"))
+	assert.Equal(t, exitUsage, h.run("", "list", "extra"))
+}
+
+func TestInvalidConfiguration(t *testing.T) {
+	var stderr bytes.Buffer
+	code := run(context.Background(), []string{"check"}, env{
+		stdout:  &bytes.Buffer{},
+		stderr:  &stderr,
+		environ: []string{"DB_DRIVER=oracle"},
+	})
+
+	assert.Equal(t, exitError, code)
+	assert.Contains(t, stderr.String(), "database.driver")
+}
+
+func TestImport_FromFile(t *testing.T) {
+	h := newHarness(t)
+	path := filepath.Join(t.TempDir(), "pacientes.csv")
+	require.NoError(t, os.WriteFile(path, []byte("nombre;password;genero;lentes\ncarla;secreto1;F;si\ndiego;secreto2;M;no\n"), 0o600))
+
+	require.Equal(t, exitOK, h.run("", "import", "--file", path), h.stderr.String())
+	assert.Contains(t, h.stdout.String(), "IMPORTADAS")
+	assert.True(t, h.user(t, "carla").Lentes)
+	assert.True(t, h.user(t, "diego").Estado)
+}
+
+func TestImport_DryRunWithErrors(t *testing.T) {
+	h := newHarness(t)
+	data := "{\"nombre\":\"ana\",\"password\":\"secreto1\",\"genero\":\"F\"}\n{\"nombre\":\"eva\",\"password\":\"secreto2\",\"genero\":\"F\"}\n"
+
+	code := h.run(data, "import", "--file", "-", "--format", "jsonl", "--dry-run", "-o", "json")
+
+	assert.Equal(t, exitError, code)
+	var report Domain.ImportReport
+	require.NoError(t, json.Unmarshal(h.stdout.Bytes(), &report))
+	assert.Equal(t, 1, report.Valid)
+	require.Len(t, report.Errors, 1)
+	assert.Equal(t, Domain.ImportRuleExists, report.Errors[0].Rule)
+
+	_, err := h.repo.GetUserByName(context.Background(), Model.User{Nombre: "eva"})
+	assert.ErrorIs(t, err, Domain.ErrNotFound)
+}
+
+func TestImport_UsageAndFileErrors(t *testing.T) {
+	h := newHarness(t)
+
+	assert.Equal(t, exitUsage, h.run("", "import"))
+	assert.Equal(t, exitUsage, h.run("", "import", "--file", "usuarios.xlsx"))
+	assert.Equal(t, exitError, h.run("", "import", "--file", filepath.Join(t.TempDir(), "no-existe.csv")))
+
+	assert.Equal(t, exitError, h.run("nombre,edad\n", "import", "--file", "-", "--format", "csv"))
+	assert.Contains(t, h.stderr.String(), `unknown_column "edad"`)
+}
+
+func TestReencrypt(t *testing.T) {
+	h := newHarness(t)
+
+	require.Equal(t, exitError, h.run("", "reencrypt"))
+	assert.Contains(t, h.stderr.String(), "encryption is not configured")
+
+	key := base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{7}, 32))
+	h.environ = []string{"ENCRYPTION_MASTER_KEYS=k1:" + key, "ENCRYPTION_INDEX_KEY=" + key}
+	require.Equal(t, exitOK, h.run("", "reencrypt", "-o", "json"), h.stderr.String())
+	var stats reencryptRow
+	require.NoError(t, json.Unmarshal(h.stdout.Bytes(), &stats))
+	assert.Equal(t, reencryptRow{Scanned: 3, Reencrypted: 3}, stats)
+	assert.NotEmpty(t, h.user(t, "ana").DataKey)
+
+	// Con el cifrado configurado los comandos leen las filas cifradas.
+	require.Equal(t, exitOK, h.run("", "list", "--diabetic=false", "--active=true", "-o", "json"), h.stderr.String())
+	var rows []userRow
+	require.NoError(t, json.Unmarshal(h.stdout.Bytes(), &rows))
+	assert.Len(t, rows, 2)
+}
+
+func TestPublishConsentAndCreate(t *testing.T) {
+	h := newHarness(t)
+
+	code := h.run("Acepto el tratamiento de mis datos de salud.\n", "publish-consent", "--file", "-", "-o", "json")
+	require.Equal(t, exitOK, code, h.stderr.String())
+	var published Domain.ConsentText
+	require.NoError(t, json.Unmarshal(h.stdout.Bytes(), &published))
+	assert.Equal(t, 1, published.Version)
+	assert.Equal(t, "Acepto el tratamiento de mis datos de salud.", published.Text)
+
+	code = h.run("s3creto!\n", "create", "--name", "eva", "--genero", "F", "--password-stdin")
+	assert.Equal(t, exitError, code)
+	assert.Contains(t, h.stderr.String(), "pass --consent 1")
+
+	code = h.run("s3creto!\n", "create", "--name", "eva", "--genero", "F", "--consent", "1", "--password-stdin")
+	require.Equal(t, exitOK, code, h.stderr.String())
+	consent, err := h.repo.GetConsent(context.Background(), h.user(t, "eva").Id)
+	require.NoError(t, err)
+	assert.True(t, consent.Granted)
+
+	assert.Equal(t, exitUsage, h.run("", "publish-consent"))
+	assert.Equal(t, exitError, h.run("  \n", "publish-consent", "--file", "-"))
+}
+
+func TestPurge(t *testing.T) {
+	h := newHarness(t)
+	old := Model.Login{UserId: 3, Success: true, CreatedAt: time.Now().Add(-100 * 24 * time.Hour)}
+	require.NoError(t, h.repo.InsertLogin(context.Background(), old))
+	require.NoError(t, h.repo.InsertLogin(context.Background(), Model.Login{UserId: 1, Success: true}))
+
+	require.Equal(t, exitError, h.run("", "purge"))
+	assert.Contains(t, h.stderr.String(), "no retention period configured")
+
+	h.environ = []string{"RETENTION_INACTIVE_ACCOUNTS=17520h", "RETENTION_LOGIN_HISTORY=720h"}
+	require.Equal(t, exitOK, h.run("", "purge", "--dry-run"), h.stderr.String())
+	assert.Contains(t, h.stdout.String(), "login_history")
+	assert.Contains(t, h.stdout.String(), "purge (dry-run)")
+
+	require.Equal(t, exitOK, h.run("", "purge", "-o", "json"), h.stderr.String())
+	var report Domain.RetentionReport
+	require.NoError(t, json.Unmarshal(h.stdout.Bytes(), &report))
+	assert.False(t, report.DryRun)
+	require.Len(t, report.Categories, 2)
+	assert.Zero(t, report.Categories[0].Records)
+	assert.Equal(t, 1, report.Categories[1].Records)
+	assert.Equal(t, "mariana", h.user(t, "mariana").Nombre)
+
+	remaining, err := h.repo.PurgeLogins(context.Background(), time.Now().Add(time.Hour), true)
+	require.NoError(t, err)
+	assert.Equal(t, 1, remaining)
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	clientUsers "Golang/clients"
+	Domain "Golang/domain"
+	"encoding/json"
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+)
+
+const (
+	formatTable = "table"
+	formatJSON  = "json"
+)
+
+// userRow es lo que se muestra de un usuario. Deja afuera los datos medicos:
+// ninguna tarea de administracion los necesita.
+type userRow struct {
+	Id        int       `json:"id"`
+	Nombre    string    `json:"nombre"`
+	Admin     bool      `json:"admin"`
+	Estado    bool      `json:"estado"`
+	Version   int       `json:"version"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+func toRow(user Domain.UserResponse) userRow {
+	return userRow{
+		Id:        user.Id,
+		Nombre:    user.Nombre,
+		Admin:     user.Admin,
+		Estado:    user.Estado,
+		Version:   user.Version,
+		UpdatedAt: user.UpdatedAt,
+	}
+}
+
+func (c *commandContext) printUser(user Domain.UserResponse) int {
+	if c.output == formatJSON {
+		return c.printJSON(toRow(user))
+	}
+	return c.printUserTable([]Domain.UserResponse{user})
+}
+
+func (c *commandContext) printUsers(users []Domain.UserResponse) int {
+	if c.output == formatJSON {
+		rows := make([]userRow, 0, len(users))
+		for _, user := range users {
+			rows = append(rows, toRow(user))
+		}
+		return c.printJSON(rows)
+	}
+	return c.printUserTable(users)
+}
+
+func (c *commandContext) printUserTable(users []Domain.UserResponse) int {
+	return c.printTable(func(w io.Writer) {
+		fmt.Fprintln(w, "ID\tNOMBRE\tADMIN\tACTIVO\tVERSION\tACTUALIZADO")
+		for _, user := range users {
+			fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%d\t%s\n", user.Id, user.Nombre, yesNo(user.Admin), yesNo(user.Estado),
+				user.Version, user.UpdatedAt.UTC().Format(time.RFC3339))
+		}
+	})
+}
+
+// printReport muestra el resumen de una importacion y, debajo, los errores
+// por fila.
+func (c *commandContext) printReport(report Domain.ImportReport) int {
+	if c.output == formatJSON {
+		return c.printJSON(report)
+	}
+	return c.printTable(func(w io.Writer) {
+		fmt.Fprintln(w, "FILAS\tVALIDAS\tINVALIDAS\tIMPORTADAS\tDRY-RUN\tCANCELADA")
+		fmt.Fprintf(w, "%d\t%d\t%d\t%d\t%s\t%s\n", report.Rows, report.Valid, report.Invalid, report.Imported,
+			yesNo(report.DryRun), yesNo(report.Aborted))
+		if len(report.Errors) == 0 {
+			return
+		}
+		fmt.Fprintln(w)
+		fmt.Fprintln(w, "FILA\tNOMBRE\tCAMPO\tREGLA\tPARAMETRO")
+		for _, rowErr := range report.Errors {
+			fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\n", rowErr.Row, rowErr.Nombre, rowErr.Field, rowErr.Rule, rowErr.Param)
+		}
+		if report.ErrorsTruncated {
+			fmt.Fprintln(w, "...")
+		}
+	})
+}
+
+// printConsent muestra la version publicada; el texto solo va en -o json.
+func (c *commandContext) printConsent(consent Domain.ConsentText) int {
+	if c.output == formatJSON {
+		return c.printJSON(consent)
+	}
+	return c.printTable(func(w io.Writer) {
+		fmt.Fprintln(w, "VERSION\tPUBLICADA\tCARACTERES")
+		fmt.Fprintf(w, "%d\t%s\t%d\n", consent.Version, consent.PublishedAt.UTC().Format(time.RFC3339), len([]rune(consent.Text)))
+	})
+}
+
+func (c *commandContext) printRetention(report Domain.RetentionReport) int {
+	if c.output == formatJSON {
+		return c.printJSON(report)
+	}
+	return c.printTable(func(w io.Writer) {
+		fmt.Fprintln(w, "CATEGORIA\tACCION\tANTERIORES A\tREGISTROS\tFALLIDOS")
+		for _, category := range report.Categories {
+			action := category.Action
+			if report.DryRun {
+				action += " (dry-run)"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%d\n", category.Category, action, category.Cutoff.UTC().Format(time.RFC3339), category.Records, category.Failed)
+		}
+	})
+}
+
+// result es el resultado de un paso de check o migrate.
+type result struct {
+	Check     string  `json:"check"`
+	Status    string  `json:"status"`
+	LatencyMs float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+}
+
+func newResult(check string, start time.Time, err error) result {
+	r := result{
+		Check:     check,
+		Status:    "ok",
+		LatencyMs: float64(time.Since(start).Microseconds()) / 1000,
+	}
+	if err != nil {
+		r.Status = "failed"
+		r.Error = err.Error()
+	}
+	return r
+}
+
+func (c *commandContext) printResults(results []result) int {
+	if c.output == formatJSON {
+		return c.printJSON(results)
+	}
+	return c.printTable(func(w io.Writer) {
+		fmt.Fprintln(w, "CHECK\tSTATUS\tLATENCY\tERROR")
+		for _, r := range results {
+			fmt.Fprintf(w, "%s\t%s\t%.1fms\t%s\n", r.Check, r.Status, r.LatencyMs, r.Error)
+		}
+	})
+}
+
+// reencryptRow es el resumen de una pasada de reencrypt.
+type reencryptRow struct {
+	Scanned     int `json:"scanned"`
+	Reencrypted int `json:"reencrypted"`
+	Skipped     int `json:"skipped"`
+	Failed      int `json:"failed"`
+}
+
+func (c *commandContext) printReencryptStats(stats clientUsers.ReencryptStats) int {
+	row := reencryptRow(stats)
+	if c.output == formatJSON {
+		return c.printJSON(row)
+	}
+	return c.printTable(func(w io.Writer) {
+		fmt.Fprintln(w, "RECORRIDAS\tRECIFRADAS\tSALTEADAS\tFALLIDAS")
+		fmt.Fprintf(w, "%d\t%d\t%d\t%d\n", row.Scanned, row.Reencrypted, row.Skipped, row.Failed)
+	})
+}
+
+func (c *commandContext) printJSON(value interface{}) int {
+	encoder := json.NewEncoder(c.stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(value); err != nil {
+		return c.fail(err)
+	}
+	return exitOK
+}
+
+func (c *commandContext) printTable(write func(io.Writer)) int {
+	w := tabwriter.NewWriter(c.stdout, 0, 0, 2, ' ', 0)
+	write(w)
+	if err := w.Flush(); err != nil {
+		return c.fail(err)
+	}
+	return exitOK
+}
+
+func yesNo(value bool) string {
+	if value {
+		return "si"
+	}
+	return "no"
+}
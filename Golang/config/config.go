@@ -0,0 +1,223 @@
+// Package config reune toda la configuracion del servicio en un struct
+// tipado. Load la arma desde estas fuentes, de menor a mayor prioridad:
+//
+//  1. los valores por defecto de Default;
+//  2. un archivo YAML o TOML (--config o CONFIG_FILE);
+//  3. el archivo .env;
+//  4. las variables de entorno.
+//
+// Los secretos aceptan tambien la convencion *_FILE: DB_PASS_FILE=/run/secrets/db
+// carga DB_PASS desde ese archivo. Validate se corre al final y reporta todos
+// los problemas juntos.
+package config
+
+import (
+	"time"
+)
+
+// Config es la configuracion efectiva del servicio. El tag yaml es el nombre
+// de la clave en el archivo (YAML o TOML) y env la variable que la pisa.
+type Config struct {
+	Server     Server     `yaml:"server"`
+	Database   Database   `yaml:"database"`
+	Cache      Cache      `yaml:"cache"`
+	Auth       Auth       `yaml:"auth"`
+	CORS       CORS       `yaml:"cors"`
+	Log        Log        `yaml:"log"`
+	Tracing    Tracing    `yaml:"tracing"`
+	Health     Health     `yaml:"health"`
+	Import     Import     `yaml:"import"`
+	Encryption Encryption `yaml:"encryption"`
+	Retention  Retention  `yaml:"retention"`
+}
+
+// Server es el servidor HTTP (ver el paquete server). Environment es
+// development o production; fuera de development la firma de los JWT exige
+// una JWTSecret configurada.
+type Server struct {
+	Environment       string        `yaml:"environment" env:"APP_ENV"`
+	Port              int           `yaml:"port" env:"PORT"`
+	ReadHeaderTimeout time.Duration `yaml:"read_header_timeout" env:"HTTP_READ_HEADER_TIMEOUT"`
+	ReadTimeout       time.Duration `yaml:"read_timeout" env:"HTTP_READ_TIMEOUT"`
+	WriteTimeout      time.Duration `yaml:"write_timeout" env:"HTTP_WRITE_TIMEOUT"`
+	IdleTimeout       time.Duration `yaml:"idle_timeout" env:"HTTP_IDLE_TIMEOUT"`
+	ShutdownTimeout   time.Duration `yaml:"shutdown_timeout" env:"HTTP_SHUTDOWN_TIMEOUT"`
+	MaxHeaderBytes    int           `yaml:"max_header_bytes" env:"HTTP_MAX_HEADER_BYTES"`
+	TLSCertFile       string        `yaml:"tls_cert_file" env:"TLS_CERT_FILE"`
+	TLSKeyFile        string        `yaml:"tls_key_file" env:"TLS_KEY_FILE"`
+	TLSMinVersion     string        `yaml:"tls_min_version" env:"TLS_MIN_VERSION"`
+}
+
+// Database es el backend de usuarios (ver clientUsers.Config). Si Port es 0
+// se completa con el puerto del driver.
+type Database struct {
+	Driver   string `yaml:"driver" env:"DB_DRIVER"`
+	Name     string `yaml:"name" env:"DB_NAME"`
+	User     string `yaml:"user" env:"DB_USER"`
+	Password string `yaml:"password" env:"DB_PASS" secret:"true"`
+	Host     string `yaml:"host" env:"DB_HOST"`
+	Port     int    `yaml:"port" env:"DB_PORT"`
+	TLS      string `yaml:"tls" env:"DB_TLS"`
+	Path     string `yaml:"path" env:"DB_PATH"`
+}
+
+// Cache es la cache de lecturas por id (ver clientUsers.CacheConfig).
+type Cache struct {
+	Driver        string        `yaml:"driver" env:"CACHE_DRIVER"`
+	TTL           time.Duration `yaml:"ttl" env:"CACHE_TTL"`
+	Size          int           `yaml:"size" env:"CACHE_SIZE"`
+	RedisAddr     string        `yaml:"redis_addr" env:"REDIS_ADDR"`
+	RedisPassword string        `yaml:"redis_password" env:"REDIS_PASSWORD" secret:"true"`
+	RedisDB       int           `yaml:"redis_db" env:"REDIS_DB"`
+	RedisTLS      bool          `yaml:"redis_tls" env:"REDIS_TLS"`
+}
+
+// Auth es la firma de los JWT. Sin JWTSecret se usa auth.LegacySecret, que
+// solo alcanza para /readyz en development.
+type Auth struct {
+	JWTSecret string `yaml:"jwt_secret" env:"JWT_SECRET" secret:"true"`
+}
+
+// CORS es la politica de CORS (ver middleware.CORSConfig). Los origenes
+// pueden ser exactos o "https://*.example.com". En una variable de entorno
+// las listas se separan con comas.
+type CORS struct {
+	AllowedOrigins   []string      `yaml:"allowed_origins" env:"CORS_ALLOWED_ORIGINS"`
+	AllowedHeaders   []string      `yaml:"allowed_headers" env:"CORS_ALLOWED_HEADERS"`
+	ExposedHeaders   []string      `yaml:"exposed_headers" env:"CORS_EXPOSED_HEADERS"`
+	AllowCredentials bool          `yaml:"allow_credentials" env:"CORS_ALLOW_CREDENTIALS"`
+	MaxAge           time.Duration `yaml:"max_age" env:"CORS_MAX_AGE"`
+}
+
+// Log es el nivel y formato de los logs (ver el paquete logging).
+type Log struct {
+	Level  string `yaml:"level" env:"LOG_LEVEL"`
+	Format string `yaml:"format" env:"LOG_FORMAT"`
+}
+
+// Tracing es el exportador de trazas (ver el paquete tracing). El colector
+// OTLP se toma de OTEL_EXPORTER_OTLP_ENDPOINT, que lee el propio exportador.
+type Tracing struct {
+	Exporter    string  `yaml:"exporter" env:"OTEL_TRACES_EXPORTER"`
+	ServiceName string  `yaml:"service_name" env:"OTEL_SERVICE_NAME"`
+	SampleRatio float64 `yaml:"sample_ratio" env:"OTEL_TRACES_SAMPLER_ARG"`
+}
+
+// Health son las sondas /readyz y /status.
+type Health struct {
+	CheckTimeout time.Duration `yaml:"check_timeout" env:"HEALTH_CHECK_TIMEOUT"`
+}
+
+// Import es la importacion masiva de POST /users/import. Los archivos de mas
+// de SyncMaxBytes se procesan en segundo plano y su estado se conserva
+// JobRetention despues de terminar.
+type Import struct {
+	MaxBytes     int           `yaml:"max_bytes" env:"IMPORT_MAX_BYTES"`
+	SyncMaxBytes int           `yaml:"sync_max_bytes" env:"IMPORT_SYNC_MAX_BYTES"`
+	JobRetention time.Duration `yaml:"job_retention" env:"IMPORT_JOB_RETENTION"`
+}
+
+// Encryption es el cifrado en reposo de los datos medicos (ver
+// clientUsers.EncryptionConfig). Sin MasterKeys esta apagado. Las claves son
+// de 32 bytes en base64; MasterKeys las lista como "id:base64" separadas por
+// comas y ActiveKey elige la que envuelve las claves nuevas. Cada
+// ReencryptInterval se re-cifran las filas en texto plano o con una clave
+// maestra vieja; 0 no las re-cifra.
+type Encryption struct {
+	MasterKeys        string        `yaml:"master_keys" env:"ENCRYPTION_MASTER_KEYS" secret:"true"`
+	ActiveKey         string        `yaml:"active_key" env:"ENCRYPTION_ACTIVE_KEY"`
+	IndexKey          string        `yaml:"index_key" env:"ENCRYPTION_INDEX_KEY" secret:"true"`
+	ReencryptInterval time.Duration `yaml:"reencrypt_interval" env:"ENCRYPTION_REENCRYPT_INTERVAL"`
+}
+
+// Retention es la politica de retencion de datos (ver
+// Domain.RetentionPolicy). Cada Interval se anonimizan las cuentas que
+// siguen desactivadas despues de InactiveAccounts, se borran los logins mas
+// viejos que LoginHistory y se anonimiza la IP de los consentimientos mas
+// viejos que ConsentRecords; 0 conserva esa categoria para siempre y un
+// Interval 0 apaga el job. Con DryRun el job solo informa lo que haria.
+type Retention struct {
+	Interval         time.Duration `yaml:"interval" env:"RETENTION_INTERVAL"`
+	InactiveAccounts time.Duration `yaml:"inactive_accounts" env:"RETENTION_INACTIVE_ACCOUNTS"`
+	LoginHistory     time.Duration `yaml:"login_history" env:"RETENTION_LOGIN_HISTORY"`
+	ConsentRecords   time.Duration `yaml:"consent_records" env:"RETENTION_CONSENT_RECORDS"`
+	DryRun           bool          `yaml:"dry_run" env:"RETENTION_DRY_RUN"`
+}
+
+// Default devuelve la configuracion sin ninguna fuente aplicada. Reproduce
+// lo que hacia el servicio antes de tener este paquete.
+func Default() Config {
+	return Config{
+		Server: Server{
+			Environment:       "production",
+			Port:              8080,
+			ReadHeaderTimeout: 5 * time.Second,
+			ReadTimeout:       15 * time.Second,
+			WriteTimeout:      30 * time.Second,
+			IdleTimeout:       60 * time.Second,
+			ShutdownTimeout:   20 * time.Second,
+			MaxHeaderBytes:    1 << 20,
+			TLSMinVersion:     "1.2",
+		},
+		Database: Database{
+			Driver: "mysql",
+		},
+		Cache: Cache{
+			Driver:    "none",
+			TTL:       time.Minute,
+			Size:      1024,
+			RedisAddr: "localhost:6379",
+		},
+		CORS: CORS{
+			AllowedOrigins: []string{
+				"https://localhost:3000",
+				"http://localhost:3000",
+				"http://127.0.0.1:3000",
+				"https://webapp-qa-2025.azurewebsites.net",
+				"https://webapp-produ-2025.azurewebsites.net",
+				"http://frontend-instance-qa.brazilsouth.azurecontainer.io",
+				"http://frontend-instance-prod.brazilsouth.azurecontainer.io",
+				"https://frontend-qa-production.up.railway.app",
+				"https://frontend-prod-production-5d36.up.railway.app",
+			},
+			AllowedHeaders: []string{
+				"Origin", "Authorization", "Content-Type", "X-Auth-Token", "X-Request-ID",
+				"If-Match", "If-None-Match", "If-Modified-Since", "traceparent", "tracestate",
+			},
+			ExposedHeaders:   []string{"Content-Disposition", "Content-Length", "ETag", "Last-Modified", "X-Request-ID"},
+			AllowCredentials: true,
+			MaxAge:           10 * time.Minute,
+		},
+		Log: Log{
+			Level:  "info",
+			Format: "json",
+		},
+		Tracing: Tracing{
+			Exporter:    "none",
+			ServiceName: "users-api",
+			SampleRatio: 1,
+		},
+		Health: Health{
+			CheckTimeout: 2 * time.Second,
+		},
+		Import: Import{
+			MaxBytes:     10 << 20,
+			SyncMaxBytes: 1 << 20,
+			JobRetention: time.Hour,
+		},
+		Encryption: Encryption{
+			ReencryptInterval: time.Hour,
+		},
+		Retention: Retention{
+			Interval: 24 * time.Hour,
+		},
+	}
+}
+
+// defaultPorts completa el puerto de la base segun el driver, para que
+// --print-config muestre el que se va a usar.
+var defaultPorts = map[string]int{
+	"mysql":      3306,
+	"postgres":   5432,
+	"postgresql": 5432,
+}
@@ -0,0 +1,234 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// baseEnv es lo minimo para que MySQL valide.
+var baseEnv = []string{"DB_HOST=db", "DB_NAME=mydb", "DB_USER=user"}
+
+func write(t *testing.T, name string, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func problems(t *testing.T, err error) []string {
+	t.Helper()
+	var validation *ValidationError
+	require.True(t, errors.As(err, &validation), "expected a ValidationError, got %v", err)
+	return validation.Problems
+}
+
+func TestLoad_DefaultsMatchPreviousBehaviour(t *testing.T) {
+	config, err := Load(Options{Environ: baseEnv})
+	require.NoError(t, err)
+
+	assert.Equal(t, 8080, config.Server.Port)
+	assert.Equal(t, "production", config.Server.Environment)
+	assert.Equal(t, "mysql", config.Database.Driver)
+	assert.Equal(t, 3306, config.Database.Port)
+	assert.Equal(t, "none", config.Cache.Driver)
+	assert.Equal(t, time.Minute, config.Cache.TTL)
+	assert.Contains(t, config.CORS.AllowedOrigins, "http://localhost:3000")
+	assert.Equal(t, "users-api", config.Tracing.ServiceName)
+}
+
+func TestLoad_Precedence(t *testing.T) {
+	file := write(t, "config.yaml", `
+server:
+  port: 9000
+  write_timeout: 45s
+database:
+  driver: postgres
+  host: from-file
+  name: mydb
+  user: user
+cache:
+  driver: memory
+`)
+	envFile := write(t, ".env", "DB_HOST=from-dotenv\nCACHE_DRIVER=lru\n")
+
+	config, err := Load(Options{File: file, EnvFile: envFile, Environ: []string{"CACHE_DRIVER=redis", "REDIS_TLS=true"}})
+	require.NoError(t, err)
+
+	assert.Equal(t, 9000, config.Server.Port, "file over defaults")
+	assert.Equal(t, 45*time.Second, config.Server.WriteTimeout)
+	assert.Equal(t, 5432, config.Database.Port, "driver default port")
+	assert.Equal(t, "from-dotenv", config.Database.Host, ".env over file")
+	assert.Equal(t, "redis", config.Cache.Driver, "environment over .env")
+	assert.True(t, config.Cache.RedisTLS)
+}
+
+func TestLoad_TOMLFromConfigFileVariable(t *testing.T) {
+	file := write(t, "config.toml", `
+[database]
+driver = "sqlite"
+path = "/data/users.db"
+
+[cors]
+allowed_origins = ["https://app.example.com"]
+
+[tracing]
+sample_ratio = 0.25
+`)
+
+	config, err := Load(Options{Environ: []string{"CONFIG_FILE=" + file}})
+	require.NoError(t, err)
+
+	assert.Equal(t, "sqlite", config.Database.Driver)
+	assert.Equal(t, "/data/users.db", config.Database.Path)
+	assert.Equal(t, []string{"https://app.example.com"}, config.CORS.AllowedOrigins)
+	assert.Equal(t, 0.25, config.Tracing.SampleRatio)
+}
+
+func TestLoad_ListFromEnvironment(t *testing.T) {
+	config, err := Load(Options{Environ: append(baseEnv, "CORS_ALLOWED_ORIGINS=https://a.example.com, https://b.example.com")})
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"https://a.example.com", "https://b.example.com"}, config.CORS.AllowedOrigins)
+}
+
+func TestLoad_SecretsFromFiles(t *testing.T) {
+	secret := write(t, "db_pass", "s3cr3t\n")
+
+	config, err := Load(Options{Environ: append(baseEnv, "DB_PASS_FILE="+secret)})
+	require.NoError(t, err)
+	assert.Equal(t, "s3cr3t", config.Database.Password)
+
+	_, err = Load(Options{Environ: append(baseEnv, "DB_PASS_FILE="+secret, "DB_PASS=other")})
+	assert.Contains(t, problems(t, err), "database.password: DB_PASS and DB_PASS_FILE are both set, use only one")
+
+	_, err = Load(Options{Environ: append(baseEnv, "JWT_SECRET_FILE=/does/not/exist")})
+	assert.Len(t, problems(t, err), 1)
+}
+
+func TestLoad_ReportsEveryProblem(t *testing.T) {
+	file := write(t, "config.yaml", `
+server:
+  read_timeout: 30
+  colour: blue
+metrics:
+  enabled: true
+`)
+
+	_, err := Load(Options{File: file, Environ: []string{
+		"PORT=abc",
+		"APP_ENV=staging",
+		"DB_DRIVER=oracle",
+		"CACHE_DRIVER=redis",
+		"REDIS_ADDR=localhost",
+		"LOG_LEVEL=loud",
+		"OTEL_TRACES_SAMPLER_ARG=2",
+		"CORS_ALLOWED_ORIGINS=https://ok.example.com,not-an-origin",
+		"TLS_CERT_FILE=/tmp/cert.pem",
+		"IMPORT_SYNC_MAX_BYTES=20971520",
+	}})
+
+	assert.ElementsMatch(t, []string{
+		`metrics: unknown section in ` + file,
+		`server.colour: unknown setting in ` + file,
+		`server.read_timeout: expected a duration string like "30s", got 30 in ` + file,
+		`server.port: PORT: expected an integer, got "abc"`,
+		`server.environment: must be one of development, production (got "staging")`,
+		`server.tls_cert_file: tls_cert_file and tls_key_file must be set together`,
+		`server.tls_cert_file: cannot read "/tmp/cert.pem": stat /tmp/cert.pem: no such file or directory`,
+		`database.driver: must be one of mysql, postgres, postgresql, sqlite, sqlite3, memory (got "oracle")`,
+		`cache.redis_addr: must be host:port (got "localhost")`,
+		`cors.allowed_origins[1]: must be an origin like https://example.com or https://*.example.com (got "not-an-origin")`,
+		`log.level: must be one of trace, debug, info, warn, error (got "loud")`,
+		`tracing.sample_ratio: must be between 0 and 1 (got 2)`,
+		`import.sync_max_bytes: must be positive and at most import.max_bytes (got 20971520)`,
+	}, problems(t, err))
+}
+
+func TestLoad_RequiresConnectionDataForServerDatabases(t *testing.T) {
+	_, err := Load(Options{Environ: []string{"DB_DRIVER=postgres"}})
+
+	assert.Equal(t, []string{
+		"database.host: is required for the postgres driver",
+		"database.name: is required for the postgres driver",
+		"database.user: is required for the postgres driver",
+	}, problems(t, err))
+}
+
+func TestLoad_Encryption(t *testing.T) {
+	key := "AAECAwQFBgcICQoLDA0ODxAREhMUFRYXGBkaGxwdHh8="
+	config, err := Load(Options{Environ: append(baseEnv,
+		"ENCRYPTION_MASTER_KEYS=2026:"+key+", 2025:"+key,
+		"ENCRYPTION_ACTIVE_KEY=2025",
+		"ENCRYPTION_INDEX_KEY="+key,
+	)})
+	require.NoError(t, err)
+	assert.Equal(t, "2025", config.Encryption.ActiveKey)
+	assert.Equal(t, time.Hour, config.Encryption.ReencryptInterval)
+
+	_, err = Load(Options{Environ: append(baseEnv,
+		"ENCRYPTION_MASTER_KEYS=2026:"+key+",sin-id,2025:c2hvcnQ",
+		"ENCRYPTION_ACTIVE_KEY=2024",
+	)})
+	assert.Equal(t, []string{
+		"encryption.master_keys[1]: must be a unique id followed by :base64",
+		"encryption.master_keys[2]: must be a base64-encoded 32-byte key",
+		`encryption.active_key: must be one of the ids in master_keys (got "2024")`,
+		"encryption.index_key: is required when master_keys is set",
+	}, problems(t, err))
+
+	_, err = Load(Options{Environ: append(baseEnv, "ENCRYPTION_INDEX_KEY="+key)})
+	assert.Equal(t, []string{"encryption.master_keys: is required when active_key or index_key is set"}, problems(t, err))
+}
+
+func TestLoad_Retention(t *testing.T) {
+	config, err := Load(Options{Environ: baseEnv})
+	require.NoError(t, err)
+	assert.Equal(t, Retention{Interval: 24 * time.Hour}, config.Retention)
+
+	config, err = Load(Options{Environ: append(baseEnv,
+		"RETENTION_INACTIVE_ACCOUNTS=17520h",
+		"RETENTION_LOGIN_HISTORY=2160h",
+		"RETENTION_DRY_RUN=true",
+	)})
+	require.NoError(t, err)
+	assert.Equal(t, Retention{Interval: 24 * time.Hour, InactiveAccounts: 17520 * time.Hour, LoginHistory: 2160 * time.Hour, DryRun: true}, config.Retention)
+
+	_, err = Load(Options{Environ: append(baseEnv, "RETENTION_LOGIN_HISTORY=-1h")})
+	assert.Equal(t, []string{"retention.login_history: must not be negative (got -1h0m0s)"}, problems(t, err))
+}
+
+func TestLoad_EmptyVariablesAreUnset(t *testing.T) {
+	envFile := write(t, ".env", "PORT=\nJWT_SECRET=\n")
+
+	config, err := Load(Options{EnvFile: envFile, Environ: baseEnv})
+	require.NoError(t, err)
+	assert.Equal(t, 8080, config.Server.Port)
+}
+
+func TestLoad_UnsupportedFile(t *testing.T) {
+	_, err := Load(Options{File: write(t, "config.json", "{}"), Environ: baseEnv})
+	assert.ErrorContains(t, err, "unsupported format")
+}
+
+func TestPrint_RedactsSecrets(t *testing.T) {
+	config, err := Load(Options{Environ: append(baseEnv, "DB_PASS=hunter2", "JWT_SECRET=jwt-secret-value")})
+	require.NoError(t, err)
+
+	var out bytes.Buffer
+	require.NoError(t, config.Print(&out))
+
+	assert.NotContains(t, out.String(), "hunter2")
+	assert.NotContains(t, out.String(), "jwt-secret-value")
+	assert.Contains(t, out.String(), "password: '[REDACTED]'")
+	assert.Contains(t, out.String(), "write_timeout: 30s")
+	assert.Contains(t, out.String(), "redis_password: \"\"")
+	// La copia impresa no modifica la configuracion original.
+	assert.Equal(t, "hunter2", config.Database.Password)
+}
@@ -0,0 +1,332 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"Golang/logging"
+
+	"github.com/joho/godotenv"
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// FileEnv es la variable que indica el archivo de configuracion si no se
+// pasa --config.
+const FileEnv = "CONFIG_FILE"
+
+// fileSuffix es el sufijo de las variables que apuntan a un archivo con el
+// secreto, como los secrets de Docker y Kubernetes.
+const fileSuffix = "_FILE"
+
+// Options indica de donde se lee la configuracion.
+type Options struct {
+	// File es un archivo .yaml, .yml o .toml. Si esta vacio se usa
+	// CONFIG_FILE; si tampoco esta, no se lee ningun archivo.
+	File string
+	// EnvFile es el archivo .env. Si no existe se ignora.
+	EnvFile string
+	// Environ son las variables de entorno como KEY=VALUE; os.Environ() si
+	// es nil.
+	Environ []string
+}
+
+// Load arma la configuracion desde todas las fuentes y la valida. Si algo
+// falla devuelve un *ValidationError con todos los problemas encontrados,
+// incluidos los valores que no se pudieron interpretar.
+func Load(options Options) (Config, error) {
+	environ := options.Environ
+	if environ == nil {
+		environ = os.Environ()
+	}
+	vars, err := variables(options.EnvFile, environ)
+	if err != nil {
+		return Config{}, err
+	}
+
+	config := Default()
+	var problems []string
+
+	file := options.File
+	if file == "" {
+		file = vars[FileEnv]
+	}
+	if file != "" {
+		fileProblems, err := applyFile(&config, file)
+		if err != nil {
+			return Config{}, err
+		}
+		problems = append(problems, fileProblems...)
+	}
+	problems = append(problems, applyEnv(&config, vars)...)
+
+	if config.Database.Port == 0 {
+		config.Database.Port = defaultPorts[strings.ToLower(config.Database.Driver)]
+	}
+
+	if err := config.Validate(); err != nil {
+		var validation *ValidationError
+		errors.As(err, &validation)
+		problems = append(problems, validation.Problems...)
+	}
+	if len(problems) > 0 {
+		return config, &ValidationError{Problems: problems}
+	}
+	return config, nil
+}
+
+// variables junta el .env y el entorno; el entorno tiene prioridad, igual
+// que con godotenv.Load.
+func variables(envFile string, environ []string) (map[string]string, error) {
+	vars := map[string]string{}
+	if envFile != "" {
+		dotenv, err := godotenv.Read(envFile)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("reading %s: %w", envFile, err)
+		}
+		for key, value := range dotenv {
+			vars[key] = value
+		}
+	}
+	for _, entry := range environ {
+		if key, value, ok := strings.Cut(entry, "="); ok {
+			vars[key] = value
+		}
+	}
+	return vars, nil
+}
+
+// setting es un campo configurable con su clave de archivo y su variable.
+type setting struct {
+	key    string
+	env    string
+	secret bool
+	value  reflect.Value
+}
+
+// settings recorre las secciones de config en el orden del struct.
+func settings(config *Config) []setting {
+	var result []setting
+	root := reflect.ValueOf(config).Elem()
+	for i := 0; i < root.NumField(); i++ {
+		section := root.Type().Field(i)
+		for j := 0; j < section.Type.NumField(); j++ {
+			field := section.Type.Field(j)
+			result = append(result, setting{
+				key:    section.Tag.Get("yaml") + "." + field.Tag.Get("yaml"),
+				env:    field.Tag.Get("env"),
+				secret: field.Tag.Get("secret") == "true",
+				value:  root.Field(i).Field(j),
+			})
+		}
+	}
+	return result
+}
+
+func applyFile(config *Config, path string) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading config file: %w", err)
+	}
+
+	var raw map[string]interface{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &raw)
+	case ".toml":
+		err = toml.Unmarshal(data, &raw)
+	default:
+		return nil, fmt.Errorf("config file %s: unsupported format, use .yaml, .yml or .toml", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parsing config file %s: %w", path, err)
+	}
+
+	known := map[string]setting{}
+	sections := map[string]bool{}
+	for _, s := range settings(config) {
+		known[s.key] = s
+		sections[strings.SplitN(s.key, ".", 2)[0]] = true
+	}
+
+	var problems []string
+	for _, sectionName := range sortedKeys(raw) {
+		sectionValue := raw[sectionName]
+		if !sections[sectionName] {
+			problems = append(problems, fmt.Sprintf("%s: unknown section in %s", sectionName, path))
+			continue
+		}
+		entries, ok := sectionValue.(map[string]interface{})
+		if !ok {
+			problems = append(problems, fmt.Sprintf("%s: must be a table of settings in %s", sectionName, path))
+			continue
+		}
+		for _, name := range sortedKeys(entries) {
+			value := entries[name]
+			s, ok := known[sectionName+"."+name]
+			if !ok {
+				problems = append(problems, fmt.Sprintf("%s.%s: unknown setting in %s", sectionName, name, path))
+				continue
+			}
+			if err := setValue(s.value, value); err != nil {
+				problems = append(problems, fmt.Sprintf("%s: %v in %s", s.key, err, path))
+			}
+		}
+	}
+	return problems, nil
+}
+
+func sortedKeys(values map[string]interface{}) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func applyEnv(config *Config, vars map[string]string) []string {
+	var problems []string
+	for _, s := range settings(config) {
+		// Una variable vacia (PORT= en el .env) cuenta como no definida.
+		value := vars[s.env]
+		ok := value != ""
+		if s.secret {
+			if path := vars[s.env+fileSuffix]; path != "" {
+				if ok {
+					problems = append(problems, fmt.Sprintf("%s: %s and %s%s are both set, use only one", s.key, s.env, s.env, fileSuffix))
+					continue
+				}
+				secret, err := readSecret(path)
+				if err != nil {
+					problems = append(problems, fmt.Sprintf("%s: %s%s: %v", s.key, s.env, fileSuffix, err))
+					continue
+				}
+				value, ok = secret, true
+			}
+		}
+		if !ok {
+			continue
+		}
+		if err := setString(s.value, value); err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %s: %v", s.key, s.env, err))
+		}
+	}
+	return problems
+}
+
+// readSecret lee un secreto de un archivo sin el salto de linea final que
+// suelen agregar los editores y `echo`.
+func readSecret(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// setValue asigna un valor leido de YAML o TOML.
+func setValue(target reflect.Value, raw interface{}) error {
+	switch value := raw.(type) {
+	case string:
+		return setString(target, value)
+	case []interface{}:
+		if target.Kind() != reflect.Slice {
+			return fmt.Errorf("expected a single value, got a list")
+		}
+		items := make([]string, 0, len(value))
+		for _, item := range value {
+			items = append(items, fmt.Sprint(item))
+		}
+		target.Set(reflect.ValueOf(items))
+		return nil
+	case map[string]interface{}:
+		return fmt.Errorf("expected a value, got a table")
+	case nil:
+		return nil
+	default:
+		if target.Type() == durationType {
+			return fmt.Errorf("expected a duration string like \"30s\", got %v", value)
+		}
+		return setString(target, fmt.Sprint(value))
+	}
+}
+
+// setString interpreta text segun el tipo del campo.
+func setString(target reflect.Value, text string) error {
+	text = strings.TrimSpace(text)
+	if target.Type() == durationType {
+		duration, err := time.ParseDuration(text)
+		if err != nil {
+			return fmt.Errorf("expected a duration like \"30s\" or \"1m\", got %q", text)
+		}
+		target.SetInt(int64(duration))
+		return nil
+	}
+
+	switch target.Kind() {
+	case reflect.String:
+		target.SetString(text)
+	case reflect.Int:
+		number, err := strconv.Atoi(text)
+		if err != nil {
+			return fmt.Errorf("expected an integer, got %q", text)
+		}
+		target.SetInt(int64(number))
+	case reflect.Bool:
+		value, err := strconv.ParseBool(text)
+		if err != nil {
+			return fmt.Errorf("expected true or false, got %q", text)
+		}
+		target.SetBool(value)
+	case reflect.Float64:
+		number, err := strconv.ParseFloat(text, 64)
+		if err != nil {
+			return fmt.Errorf("expected a number, got %q", text)
+		}
+		target.SetFloat(number)
+	case reflect.Slice:
+		items := []string{}
+		for _, item := range strings.Split(text, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		target.Set(reflect.ValueOf(items))
+	default:
+		return fmt.Errorf("unsupported setting type %s", target.Type())
+	}
+	return nil
+}
+
+// Redacted devuelve una copia con los secretos no vacios tapados.
+func (config Config) Redacted() Config {
+	for _, s := range settings(&config) {
+		if s.secret && s.value.String() != "" {
+			s.value.SetString(logging.Redacted)
+		}
+	}
+	return config
+}
+
+// Print escribe la configuracion efectiva en YAML, con los secretos
+// tapados. Es la salida de --print-config.
+func (config Config) Print(w io.Writer) error {
+	data, err := yaml.Marshal(config.Redacted())
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
@@ -0,0 +1,193 @@
+package config
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// ValidationError lista todos los problemas de una configuracion, para
+// corregirlos de una vez en lugar de uno por arranque.
+type ValidationError struct {
+	Problems []string
+}
+
+func (err *ValidationError) Error() string {
+	return "invalid configuration:\n  - " + strings.Join(err.Problems, "\n  - ")
+}
+
+type validator struct {
+	problems []string
+}
+
+func (v *validator) add(key string, format string, args ...interface{}) {
+	v.problems = append(v.problems, key+": "+fmt.Sprintf(format, args...))
+}
+
+func (v *validator) oneOf(key string, value string, allowed ...string) {
+	for _, option := range allowed {
+		if strings.EqualFold(value, option) {
+			return
+		}
+	}
+	v.add(key, "must be one of %s (got %q)", strings.Join(allowed, ", "), value)
+}
+
+func (v *validator) port(key string, value int, min int) {
+	if value < min || value > 65535 {
+		v.add(key, "must be between %d and 65535 (got %d)", min, value)
+	}
+}
+
+func (v *validator) nonNegative(key string, value time.Duration) {
+	if value < 0 {
+		v.add(key, "must not be negative (got %s)", value)
+	}
+}
+
+func (v *validator) required(key string, value string, reason string) {
+	if strings.TrimSpace(value) == "" {
+		v.add(key, "is required %s", reason)
+	}
+}
+
+func (v *validator) file(key string, path string) {
+	if path == "" {
+		return
+	}
+	if _, err := os.Stat(path); err != nil {
+		v.add(key, "cannot read %q: %v", path, err)
+	}
+}
+
+// encryptionKey controla una clave de cifrado: 32 bytes en base64, estandar
+// o URL, con o sin relleno.
+func (v *validator) encryptionKey(key string, value string) {
+	value = strings.TrimRight(strings.TrimSpace(value), "=")
+	decoded, err := base64.RawStdEncoding.DecodeString(value)
+	if err != nil {
+		decoded, err = base64.RawURLEncoding.DecodeString(value)
+	}
+	if err != nil || len(decoded) != 32 {
+		v.add(key, "must be a base64-encoded 32-byte key")
+	}
+}
+
+// Validate revisa la configuracion completa y devuelve un *ValidationError
+// con todos los problemas, o nil.
+func (config Config) Validate() error {
+	v := &validator{}
+
+	server := config.Server
+	v.oneOf("server.environment", server.Environment, "development", "production")
+	v.port("server.port", server.Port, 1)
+	v.nonNegative("server.read_header_timeout", server.ReadHeaderTimeout)
+	v.nonNegative("server.read_timeout", server.ReadTimeout)
+	v.nonNegative("server.write_timeout", server.WriteTimeout)
+	v.nonNegative("server.idle_timeout", server.IdleTimeout)
+	v.nonNegative("server.shutdown_timeout", server.ShutdownTimeout)
+	if server.MaxHeaderBytes < 0 {
+		v.add("server.max_header_bytes", "must not be negative (got %d)", server.MaxHeaderBytes)
+	}
+	if (server.TLSCertFile == "") != (server.TLSKeyFile == "") {
+		v.add("server.tls_cert_file", "tls_cert_file and tls_key_file must be set together")
+	}
+	v.file("server.tls_cert_file", server.TLSCertFile)
+	v.file("server.tls_key_file", server.TLSKeyFile)
+	v.oneOf("server.tls_min_version", server.TLSMinVersion, "1.2", "1.3")
+
+	database := config.Database
+	v.oneOf("database.driver", database.Driver, "mysql", "postgres", "postgresql", "sqlite", "sqlite3", "memory")
+	switch strings.ToLower(database.Driver) {
+	case "mysql", "postgres", "postgresql":
+		reason := "for the " + database.Driver + " driver"
+		v.required("database.host", database.Host, reason)
+		v.required("database.name", database.Name, reason)
+		v.required("database.user", database.User, reason)
+	}
+	v.port("database.port", database.Port, 0)
+
+	cache := config.Cache
+	v.oneOf("cache.driver", cache.Driver, "none", "memory", "lru", "redis")
+	v.nonNegative("cache.ttl", cache.TTL)
+	if cache.Size < 0 {
+		v.add("cache.size", "must not be negative (got %d)", cache.Size)
+	}
+	if strings.EqualFold(cache.Driver, "redis") {
+		if _, _, err := net.SplitHostPort(cache.RedisAddr); err != nil {
+			v.add("cache.redis_addr", "must be host:port (got %q)", cache.RedisAddr)
+		}
+	}
+	if cache.RedisDB < 0 {
+		v.add("cache.redis_db", "must not be negative (got %d)", cache.RedisDB)
+	}
+
+	for i, origin := range config.CORS.AllowedOrigins {
+		parsed, err := url.Parse(origin)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" || strings.Trim(parsed.Path, "/") != "" {
+			v.add(fmt.Sprintf("cors.allowed_origins[%d]", i), "must be an origin like https://example.com or https://*.example.com (got %q)", origin)
+		}
+	}
+	v.nonNegative("cors.max_age", config.CORS.MaxAge)
+
+	if _, err := log.ParseLevel(config.Log.Level); err != nil {
+		v.add("log.level", "must be one of trace, debug, info, warn, error (got %q)", config.Log.Level)
+	}
+	v.oneOf("log.format", config.Log.Format, "json", "text")
+
+	v.oneOf("tracing.exporter", config.Tracing.Exporter, "none", "stdout", "console", "otlp")
+	if config.Tracing.SampleRatio < 0 || config.Tracing.SampleRatio > 1 {
+		v.add("tracing.sample_ratio", "must be between 0 and 1 (got %g)", config.Tracing.SampleRatio)
+	}
+
+	v.nonNegative("health.check_timeout", config.Health.CheckTimeout)
+
+	if config.Import.MaxBytes <= 0 {
+		v.add("import.max_bytes", "must be positive (got %d)", config.Import.MaxBytes)
+	}
+	if config.Import.SyncMaxBytes <= 0 || config.Import.SyncMaxBytes > config.Import.MaxBytes {
+		v.add("import.sync_max_bytes", "must be positive and at most import.max_bytes (got %d)", config.Import.SyncMaxBytes)
+	}
+	v.nonNegative("import.job_retention", config.Import.JobRetention)
+
+	encryption := config.Encryption
+	if strings.TrimSpace(encryption.MasterKeys) != "" {
+		ids := make(map[string]bool)
+		for i, entry := range strings.Split(encryption.MasterKeys, ",") {
+			key := fmt.Sprintf("encryption.master_keys[%d]", i)
+			id, encoded, ok := strings.Cut(strings.TrimSpace(entry), ":")
+			if !ok || id == "" || ids[id] {
+				v.add(key, "must be a unique id followed by :base64")
+				continue
+			}
+			ids[id] = true
+			v.encryptionKey(key, encoded)
+		}
+		if encryption.ActiveKey != "" && !ids[encryption.ActiveKey] {
+			v.add("encryption.active_key", "must be one of the ids in master_keys (got %q)", encryption.ActiveKey)
+		}
+		v.required("encryption.index_key", encryption.IndexKey, "when master_keys is set")
+		if encryption.IndexKey != "" {
+			v.encryptionKey("encryption.index_key", encryption.IndexKey)
+		}
+	} else if encryption.IndexKey != "" || encryption.ActiveKey != "" {
+		v.add("encryption.master_keys", "is required when active_key or index_key is set")
+	}
+	v.nonNegative("encryption.reencrypt_interval", encryption.ReencryptInterval)
+
+	v.nonNegative("retention.interval", config.Retention.Interval)
+	v.nonNegative("retention.inactive_accounts", config.Retention.InactiveAccounts)
+	v.nonNegative("retention.login_history", config.Retention.LoginHistory)
+	v.nonNegative("retention.consent_records", config.Retention.ConsentRecords)
+
+	if len(v.problems) > 0 {
+		return &ValidationError{Problems: v.problems}
+	}
+	return nil
+}
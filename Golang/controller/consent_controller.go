@@ -0,0 +1,119 @@
+package usersController
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	Domain "Golang/domain"
+	"Golang/logging"
+	"Golang/problem"
+)
+
+// ConsentService es lo que ConsentController necesita de service.Service.
+type ConsentService interface {
+	CurrentConsent(ctx context.Context) (Domain.ConsentText, error)
+	GetConsent(ctx context.Context, userId int, viewer Domain.Viewer) (Domain.ConsentStatus, error)
+	GrantConsent(ctx context.Context, userId int, req Domain.ConsentGrant, ip string, viewer Domain.Viewer) (Domain.ConsentStatus, error)
+	WithdrawConsent(ctx context.Context, userId int, ip string, viewer Domain.Viewer) (Domain.ConsentStatus, error)
+}
+
+var detailNoConsent = problem.Text{ES: "No hay un texto de consentimiento publicado.", EN: "No consent text has been published."}
+
+// ConsentController expone el consentimiento para el tratamiento de datos de
+// salud. Las aplicaciones consultan GET /users/:id/consent al iniciar sesion
+// y, si pending es true, muestran el texto de current y lo envian con PUT.
+type ConsentController struct {
+	service ConsentService
+}
+
+func NewConsentController(service ConsentService) ConsentController {
+	return ConsentController{service: service}
+}
+
+// CurrentConsent responde el texto vigente, para el formulario de registro.
+func (controller ConsentController) CurrentConsent(c *gin.Context) {
+	consent, err := controller.service.CurrentConsent(c.Request.Context())
+	if err != nil {
+		abortConsent(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, consent)
+}
+
+// GetConsent responde el estado del consentimiento del usuario.
+func (controller ConsentController) GetConsent(c *gin.Context) {
+	id, ok := consentUserId(c)
+	if !ok {
+		return
+	}
+	status, err := controller.service.GetConsent(c.Request.Context(), id, viewer(c))
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, status)
+}
+
+// GrantConsent registra que el usuario acepto la version del cuerpo.
+func (controller ConsentController) GrantConsent(c *gin.Context) {
+	id, ok := consentUserId(c)
+	if !ok {
+		return
+	}
+	var req Domain.ConsentGrant
+	if err := c.ShouldBindJSON(&req); err != nil {
+		abortWithError(c, fmt.Errorf("decoding body: %v: %w", err, Domain.ErrValidation))
+		return
+	}
+	if err := req.Validate(); err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	status, err := controller.service.GrantConsent(c.Request.Context(), id, req, c.ClientIP(), viewer(c))
+	if err != nil {
+		abortConsent(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, status)
+}
+
+// WithdrawConsent registra el retiro del consentimiento.
+func (controller ConsentController) WithdrawConsent(c *gin.Context) {
+	id, ok := consentUserId(c)
+	if !ok {
+		return
+	}
+	status, err := controller.service.WithdrawConsent(c.Request.Context(), id, c.ClientIP(), viewer(c))
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, status)
+}
+
+func consentUserId(c *gin.Context) (int, bool) {
+	userId := c.Param("id")
+	id, err := strconv.Atoi(userId)
+	if err != nil {
+		abortWithError(c, fmt.Errorf("invalid id %q: %w", userId, Domain.ErrValidation))
+		return 0, false
+	}
+	return id, true
+}
+
+// abortConsent es abortWithError con un mensaje propio para cuando no hay
+// texto publicado: el generico de 404 habla de un usuario inexistente.
+func abortConsent(c *gin.Context, err error) {
+	if errors.Is(err, Domain.ErrNoConsent) {
+		logging.FromContext(c.Request.Context()).WithError(err).WithField("status", http.StatusNotFound).Debug("request rejected")
+		problem.Write(c, http.StatusNotFound, problem.TypeNotFound, detailNoConsent)
+		return
+	}
+	abortWithError(c, err)
+}
@@ -0,0 +1,140 @@
+package usersController
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	clientUsers "Golang/clients"
+	Domain "Golang/domain"
+	"Golang/problem"
+	services "Golang/service"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// consentRouter arma las rutas de alta y de consentimiento. El header
+// X-User reemplaza al token: es el id del usuario que hace el pedido.
+func consentRouter(t *testing.T) (*gin.Engine, *clientUsers.Memory) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	repo := clientUsers.NewMemory()
+	service := services.NewService(repo).WithConsents(repo)
+
+	users := NewController(service)
+	consents := NewConsentController(service)
+	router := gin.New()
+	auth := func(c *gin.Context) {
+		id, _ := strconv.Atoi(c.GetHeader("X-User"))
+		c.Set("userID", float64(id))
+	}
+	router.POST("/users", users.UsuarioInsert)
+	router.GET("/consent", consents.CurrentConsent)
+	router.GET("/users/:id/consent", auth, consents.GetConsent)
+	router.PUT("/users/:id/consent", auth, consents.GrantConsent)
+	router.DELETE("/users/:id/consent", auth, consents.WithdrawConsent)
+	return router, repo
+}
+
+func consentRequest(router *gin.Engine, method string, path string, user string, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept-Language", "en")
+	req.Header.Set("X-User", user)
+	req.RemoteAddr = "192.0.2.10:4000"
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestConsent_Controller_SinTextoPublicado(t *testing.T) {
+	router, _ := consentRouter(t)
+
+	w := consentRequest(router, http.MethodGet, "/consent", "", "")
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	var got problem.Problem
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &got))
+	assert.Equal(t, "No consent text has been published.", got.Detail)
+
+	w = consentRequest(router, http.MethodPost, "/users", "", `{"nombre": "ana", "password": "secreto", "genero": "F"}`)
+	assert.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+}
+
+func TestConsent_Controller_RegistroYNuevaVersion(t *testing.T) {
+	router, repo := consentRouter(t)
+	_, err := repo.PublishConsentVersion(context.Background(), "v1")
+	require.NoError(t, err)
+
+	w := consentRequest(router, http.MethodGet, "/consent", "", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	var current Domain.ConsentText
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &current))
+	assert.Equal(t, 1, current.Version)
+
+	w = consentRequest(router, http.MethodPost, "/users", "", `{"nombre": "ana", "password": "secreto", "genero": "F"}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	var got problem.Problem
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &got))
+	assert.Equal(t, []problem.FieldError{{Field: "consentimiento", Message: "Must be 1."}}, got.Errors)
+
+	w = consentRequest(router, http.MethodPost, "/users", "", `{"nombre": "ana", "password": "secreto", "genero": "F", "consentimiento": 1}`)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	consent, err := repo.GetConsent(context.Background(), 1)
+	require.NoError(t, err)
+	assert.Equal(t, "192.0.2.10", consent.IP)
+
+	_, err = repo.PublishConsentVersion(context.Background(), "v2")
+	require.NoError(t, err)
+	w = consentRequest(router, http.MethodGet, "/users/1/consent", "1", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `true`, jsonField(t, w, "pending"))
+
+	w = consentRequest(router, http.MethodPut, "/users/1/consent", "1", `{"version": 2}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.JSONEq(t, `false`, jsonField(t, w, "pending"))
+
+	w = consentRequest(router, http.MethodDelete, "/users/1/consent", "1", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `false`, jsonField(t, w, "granted"))
+}
+
+func TestConsent_Controller_Errores(t *testing.T) {
+	router, repo := consentRouter(t)
+	_, err := repo.PublishConsentVersion(context.Background(), "v1")
+	require.NoError(t, err)
+	w := consentRequest(router, http.MethodPost, "/users", "", `{"nombre": "ana", "password": "secreto", "genero": "F", "consentimiento": 1}`)
+	require.Equal(t, http.StatusCreated, w.Code)
+
+	cases := []struct {
+		method string
+		path   string
+		user   string
+		body   string
+		want   int
+	}{
+		{http.MethodGet, "/users/1/consent", "2", "", http.StatusForbidden},
+		{http.MethodPut, "/users/1/consent", "2", `{"version": 1}`, http.StatusForbidden},
+		{http.MethodPut, "/users/1/consent", "1", `{"version": 0}`, http.StatusBadRequest},
+		{http.MethodPut, "/users/1/consent", "1", `{"version": "1"}`, http.StatusBadRequest},
+		{http.MethodGet, "/users/x/consent", "1", "", http.StatusBadRequest},
+		{http.MethodDelete, "/users/1/consent", "2", "", http.StatusForbidden},
+	}
+	for _, tc := range cases {
+		w := consentRequest(router, tc.method, tc.path, tc.user, tc.body)
+		assert.Equal(t, tc.want, w.Code, "%s %s as %s: %s", tc.method, tc.path, tc.user, w.Body.String())
+	}
+}
+
+func jsonField(t *testing.T, w *httptest.ResponseRecorder, field string) string {
+	t.Helper()
+	var body map[string]json.RawMessage
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	return string(body[field])
+}
@@ -0,0 +1,94 @@
+package usersController
+
+import (
+	Domain "Golang/domain"
+	"Golang/logging"
+	"Golang/problem"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	detailValidation   = problem.Text{ES: "La solicitud no es válida.", EN: "The request is not valid."}
+	detailUnauthorized = problem.Text{ES: "Credenciales inválidas.", EN: "Invalid credentials."}
+	detailForbidden    = problem.Text{ES: "No tiene permisos para esta operación.", EN: "You are not allowed to perform this operation."}
+	detailNotFound     = problem.Text{ES: "El usuario no existe.", EN: "The user does not exist."}
+	detailConflict     = problem.Text{ES: "El usuario ya existe.", EN: "The user already exists."}
+	detailInternal     = problem.Text{ES: "Error al procesar la solicitud.", EN: "The request could not be processed."}
+
+	detailPreconditionFailed = problem.Text{ES: "El usuario fue modificado por otra persona. Vuelva a cargarlo e intente de nuevo.", EN: "The user was modified by someone else. Reload it and try again."}
+	detailIfMatchRequired    = problem.Text{ES: "Se requiere el header If-Match con el ETag del usuario.", EN: "The If-Match header with the user's ETag is required."}
+	detailUnsupportedPatch   = problem.Text{ES: "Formato de PATCH no soportado.", EN: "Unsupported PATCH format."}
+)
+
+// errorResponse traduce un error de las capas inferiores a un codigo HTTP y
+// un mensaje generico. El detalle del error solo va al log, nunca al cliente.
+func errorResponse(err error) (int, string, problem.Text) {
+	switch {
+	case errors.Is(err, Domain.ErrValidation):
+		return http.StatusBadRequest, problem.TypeValidation, detailValidation
+	case errors.Is(err, Domain.ErrUnauthorized):
+		return http.StatusUnauthorized, problem.TypeUnauthorized, detailUnauthorized
+	case errors.Is(err, Domain.ErrForbidden):
+		return http.StatusForbidden, problem.TypeForbidden, detailForbidden
+	case errors.Is(err, Domain.ErrNotFound):
+		return http.StatusNotFound, problem.TypeNotFound, detailNotFound
+	case errors.Is(err, Domain.ErrConflict):
+		return http.StatusConflict, problem.TypeConflict, detailConflict
+	case errors.Is(err, Domain.ErrPreconditionFailed):
+		return http.StatusPreconditionFailed, problem.TypePreconditionFailed, detailPreconditionFailed
+	default:
+		return http.StatusInternalServerError, problem.TypeBlank, detailInternal
+	}
+}
+
+// fieldMessages son los mensajes de cada regla de validacion de domain.
+// %s se reemplaza por el parametro de la regla.
+var fieldMessages = map[string]problem.Text{
+	"required": {ES: "El campo es obligatorio.", EN: "This field is required."},
+	"notblank": {ES: "El campo es obligatorio.", EN: "This field is required."},
+	"min":      {ES: "El valor no alcanza el mínimo permitido (%s).", EN: "The value is below the minimum allowed (%s)."},
+	"max":      {ES: "El valor supera el máximo permitido (%s).", EN: "The value exceeds the maximum allowed (%s)."},
+	"oneof":    {ES: "Debe ser uno de: %s.", EN: "Must be one of: %s."},
+	"unique":   {ES: "El valor está repetido: %s.", EN: "The value is repeated: %s."},
+	"eq":       {ES: "Debe ser %s.", EN: "Must be %s."},
+}
+
+var fieldMessageDefault = problem.Text{ES: "Valor inválido.", EN: "Invalid value."}
+
+func fieldErrors(c *gin.Context, err error) []problem.FieldError {
+	var validationErr *Domain.ValidationError
+	if !errors.As(err, &validationErr) {
+		return nil
+	}
+
+	lang := problem.Language(c.Request)
+	fields := make([]problem.FieldError, 0, len(validationErr.Violations))
+	for _, v := range validationErr.Violations {
+		text, ok := fieldMessages[v.Rule]
+		if !ok {
+			text = fieldMessageDefault
+		}
+		message := text.In(lang)
+		if strings.Contains(message, "%s") {
+			message = fmt.Sprintf(message, v.Param)
+		}
+		fields = append(fields, problem.FieldError{Field: v.Field, Message: message})
+	}
+	return fields
+}
+
+func abortWithError(c *gin.Context, err error) {
+	status, problemType, detail := errorResponse(err)
+	logger := logging.FromContext(c.Request.Context()).WithError(err).WithField("status", status)
+	if status >= http.StatusInternalServerError {
+		logger.Error("request failed")
+	} else {
+		logger.Debug("request rejected")
+	}
+	problem.Write(c, status, problemType, detail, fieldErrors(c, err)...)
+}
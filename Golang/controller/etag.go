@@ -0,0 +1,131 @@
+package usersController
+
+import (
+	Domain "Golang/domain"
+	"Golang/problem"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// El ETag de un usuario es su version entre comillas, por ejemplo "3".
+func etag(version int) string {
+	return strconv.Quote(strconv.Itoa(version))
+}
+
+// userETag es el ETag de la representacion del usuario id que ve vis. El
+// cuerpo depende del perfil de quien pide y de ?fields=, asi que el ETag
+// suma el perfil y los campos visibles a la version, por ejemplo
+// "3-clinician-nombre.estado". Sin ?fields= y con todos los campos queda
+// la version sola. If-Match solo mira la version (ver parseETag).
+func userETag(id int, version int, vis Domain.Visibility) string {
+	visible := vis.VisibleFields(id)
+	if len(vis.Fields) == 0 && len(visible) == len(Domain.UserFields) {
+		return etag(version)
+	}
+	tag := strconv.Itoa(version) + "-" + vis.Viewer.Profile(id)
+	if len(vis.Fields) > 0 {
+		tag += "-" + strings.Join(visible, ".")
+	}
+	return strconv.Quote(tag)
+}
+
+// ifMatchVersion devuelve la version que el cliente espera modificar segun
+// If-Match. "*" acepta cualquier version y devuelve 0. Si el header falta
+// responde 428 y devuelve ok=false.
+func ifMatchVersion(c *gin.Context) (version int, ok bool) {
+	header := strings.TrimSpace(c.GetHeader("If-Match"))
+	if header == "" {
+		problem.Write(c, http.StatusPreconditionRequired, problem.TypePreconditionFailed, detailIfMatchRequired)
+		return 0, false
+	}
+	if header == "*" {
+		return 0, true
+	}
+
+	// Solo se acepta un ETag fuerte: If-Match usa comparacion fuerte y una
+	// lista de versiones no se puede expresar como una sola condicion.
+	version, err := parseETag(header)
+	if err != nil {
+		abortWithError(c, fmt.Errorf("If-Match %q: %v: %w", header, err, Domain.ErrPreconditionFailed))
+		return 0, false
+	}
+	return version, true
+}
+
+func parseETag(value string) (int, error) {
+	if strings.HasPrefix(value, "W/") {
+		return 0, fmt.Errorf("weak ETag")
+	}
+	unquoted, err := strconv.Unquote(value)
+	if err != nil {
+		return 0, fmt.Errorf("malformed ETag")
+	}
+	// Los ETag de userETag agregan la representacion despues de un guion.
+	unquoted, _, _ = strings.Cut(unquoted, "-")
+	version, err := strconv.Atoi(unquoted)
+	if err != nil || version < 1 {
+		return 0, fmt.Errorf("unknown ETag")
+	}
+	return version, nil
+}
+
+// cacheControl obliga a revalidar en cada uso y evita caches compartidos:
+// las respuestas tienen datos medicos y dependen del token.
+const cacheControl = "private, no-cache"
+
+// notModified publica los validadores de la respuesta y, si la solicitud es
+// condicional y el cliente ya tiene esa version, responde 304 y devuelve true.
+// If-None-Match tiene prioridad sobre If-Modified-Since (RFC 9110 13.2.2).
+func notModified(c *gin.Context, tag string, lastModified time.Time) bool {
+	c.Header("ETag", tag)
+	c.Header("Cache-Control", cacheControl)
+	if !lastModified.IsZero() {
+		c.Header("Last-Modified", lastModified.UTC().Format(http.TimeFormat))
+	}
+
+	if c.Request.Method != http.MethodGet && c.Request.Method != http.MethodHead {
+		return false
+	}
+
+	fresh := false
+	if header := c.GetHeader("If-None-Match"); header != "" {
+		fresh = etagListMatches(header, tag)
+	} else if header := c.GetHeader("If-Modified-Since"); header != "" && !lastModified.IsZero() {
+		since, err := http.ParseTime(header)
+		fresh = err == nil && !lastModified.Truncate(time.Second).After(since)
+	}
+
+	if fresh {
+		c.AbortWithStatus(http.StatusNotModified)
+	}
+	return fresh
+}
+
+// etagListMatches aplica la comparacion debil de If-None-Match.
+func etagListMatches(header string, tag string) bool {
+	want := strings.TrimPrefix(tag, "W/")
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == want {
+			return true
+		}
+	}
+	return false
+}
+
+// collectionETag es el validador debil del listado. La cantidad y la suma
+// de versiones solas no alcanzan: una baja, un alta y una modificacion entre
+// dos lecturas las dejan iguales. El id mas alto cambia con cada alta y
+// LastModified con cada alta o modificacion.
+func collectionETag(stamp Domain.UsersStamp) string {
+	var modified int64
+	if !stamp.LastModified.IsZero() {
+		modified = stamp.LastModified.UnixNano()
+	}
+	return fmt.Sprintf(`W/"%d-%d-%d-%d"`, stamp.Count, stamp.VersionSum, stamp.MaxId, modified)
+}
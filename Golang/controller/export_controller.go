@@ -0,0 +1,150 @@
+package usersController
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	Domain "Golang/domain"
+	"Golang/logging"
+	"Golang/xlsx"
+)
+
+// UserExporter es lo que ExportController necesita de service.Service.
+type UserExporter interface {
+	ExportUsers(ctx context.Context, w io.Writer, opts Domain.ExportOptions) (int, error)
+}
+
+// exportWriteTimeout es el plazo de cada escritura al cliente. Reemplaza al
+// WriteTimeout del servidor, que cortaria una descarga larga aunque avance.
+const exportWriteTimeout = time.Minute
+
+// exportContentTypes son los media types de cada formato de exportacion.
+var exportContentTypes = map[string]string{
+	Domain.ExportCSV:   "text/csv; charset=utf-8",
+	Domain.ExportJSONL: "application/x-ndjson",
+	Domain.ExportXLSX:  xlsx.ContentType,
+}
+
+type ExportController struct {
+	exporter UserExporter
+}
+
+func NewExportController(exporter UserExporter) ExportController {
+	return ExportController{exporter: exporter}
+}
+
+// ExportUsers descarga los usuarios como archivo. ?format= es csv (por
+// defecto), jsonl o xlsx; ?columns= elige y ordena las columnas separadas por
+// coma; ?mask_medical=true oculta los datos de salud, que de los usuarios
+// sin consentimiento salen ocultos siempre. ?admin=, ?estado= y ?nombre=
+// filtran igual que el listado de administracion.
+func (controller ExportController) ExportUsers(c *gin.Context) {
+	filter, err := userFilter(c)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+	opts := Domain.ExportOptions{
+		Format:  strings.ToLower(c.DefaultQuery("format", Domain.ExportCSV)),
+		Columns: splitColumns(c.Query("columns")),
+		Filter:  filter,
+	}
+	if value, ok, err := queryBool(c, "mask_medical"); err != nil {
+		abortWithError(c, err)
+		return
+	} else if ok {
+		opts.MaskMedical = value
+	}
+
+	streamDownload(c, "usuarios", opts.Format, exportContentTypes[opts.Format], func(w io.Writer) (int, error) {
+		return controller.exporter.ExportUsers(c.Request.Context(), w, opts)
+	})
+}
+
+// userFilter lee ?admin=, ?estado=, ?diabetico= y ?nombre=, los filtros del
+// listado de administracion.
+func userFilter(c *gin.Context) (Domain.UserFilter, error) {
+	filter := Domain.UserFilter{Nombre: c.Query("nombre")}
+	for _, flag := range []struct {
+		name   string
+		target **bool
+	}{{"admin", &filter.Admin}, {"estado", &filter.Estado}, {"diabetico", &filter.Diabetico}} {
+		value, ok, err := queryBool(c, flag.name)
+		if err != nil {
+			return Domain.UserFilter{}, err
+		}
+		if ok {
+			*flag.target = &value
+		}
+	}
+	return filter, nil
+}
+
+// streamDownload manda lo que escribe write como archivo adjunto. Si write
+// falla antes de escribir se responde el error; si falla despues, el 200 ya
+// salio y se corta la conexion para que el cliente no tome el archivo
+// truncado como completo.
+func streamDownload(c *gin.Context, name string, extension string, contentType string, write func(io.Writer) (int, error)) {
+	filename := name + "-" + time.Now().UTC().Format("20060102-150405") + "." + extension
+	c.Header("Content-Type", contentType)
+	c.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+	c.Header("Cache-Control", "no-store")
+
+	rows, err := write(exportWriter{writer: c.Writer, controller: http.NewResponseController(c.Writer)})
+	if err == nil {
+		return
+	}
+	if !c.Writer.Written() {
+		c.Writer.Header().Del("Content-Disposition")
+		c.Writer.Header().Del("Cache-Control")
+		abortWithError(c, err)
+		return
+	}
+
+	logging.FromContext(c.Request.Context()).WithError(err).WithField("rows", rows).Error("export interrupted")
+	closeConnection(c)
+	c.Abort()
+}
+
+// closeConnection cierra la conexion HTTP/1.x sin terminar la respuesta. En
+// HTTP/2 o con un ResponseRecorder no hay conexion que cortar; gin entra en
+// panico si se intenta.
+func closeConnection(c *gin.Context) {
+	unwrapper, ok := c.Writer.(interface{ Unwrap() http.ResponseWriter })
+	if !ok {
+		return
+	}
+	if _, ok := unwrapper.Unwrap().(http.Hijacker); !ok {
+		return
+	}
+	if conn, _, err := c.Writer.Hijack(); err == nil {
+		conn.Close()
+	}
+}
+
+func splitColumns(text string) []string {
+	var columns []string
+	for _, column := range strings.Split(text, ",") {
+		if column = strings.TrimSpace(column); column != "" {
+			columns = append(columns, column)
+		}
+	}
+	return columns
+}
+
+// exportWriter extiende el plazo de escritura antes de cada envio.
+type exportWriter struct {
+	writer     io.Writer
+	controller *http.ResponseController
+}
+
+func (w exportWriter) Write(p []byte) (int, error) {
+	// httptest.ResponseRecorder no admite plazos; el error se ignora.
+	w.controller.SetWriteDeadline(time.Now().Add(exportWriteTimeout))
+	return w.writer.Write(p)
+}
@@ -0,0 +1,129 @@
+package usersController
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	clientUsers "Golang/clients"
+	Domain "Golang/domain"
+	Model "Golang/model"
+	"Golang/problem"
+	services "Golang/service"
+	"Golang/xlsx"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func exportRouter(t *testing.T, exporter UserExporter) *gin.Engine {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/users/export", NewExportController(exporter).ExportUsers)
+	return router
+}
+
+func exportService(t *testing.T) UserExporter {
+	t.Helper()
+	repo := clientUsers.NewMemory()
+	for _, user := range []Model.User{
+		{Nombre: "ana", Genero: "F", Diabetico: true, Enfermedades: "asma", Estado: true},
+		{Nombre: "bruno", Genero: "M", Admin: true, Estado: true},
+		{Nombre: "carla", Genero: "F", Estado: false},
+	} {
+		_, err := repo.InsertUser(context.Background(), user)
+		require.NoError(t, err)
+	}
+	return services.NewService(repo)
+}
+
+func getExport(router *gin.Engine, query string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/users/export"+query, nil)
+	req.Header.Set("Accept-Language", "en")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestExportUsers_Controller_CSVByDefault(t *testing.T) {
+	w := getExport(exportRouter(t, exportService(t)), "")
+
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, "text/csv; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.Regexp(t, `^attachment; filename="usuarios-\d{8}-\d{6}\.csv"$`, w.Header().Get("Content-Disposition"))
+	assert.Equal(t, "no-store", w.Header().Get("Cache-Control"))
+	assert.True(t, strings.HasPrefix(w.Body.String(), "\ufeffid,nombre,genero,"))
+	assert.Equal(t, 4, strings.Count(w.Body.String(), "\n"))
+}
+
+func TestExportUsers_Controller_FiltersColumnsAndMask(t *testing.T) {
+	w := getExport(exportRouter(t, exportService(t)), "?format=jsonl&columns=nombre,+diabetico,enfermedades&mask_medical&estado=true&admin=false")
+
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, "application/x-ndjson", w.Header().Get("Content-Type"))
+	assert.Equal(t, `{"nombre":"ana","diabetico":"***","enfermedades":"***"}`+"\n", w.Body.String())
+
+	w = getExport(exportRouter(t, exportService(t)), "?format=jsonl&columns=nombre&diabetico=false")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, `{"nombre":"bruno"}`+"\n"+`{"nombre":"carla"}`+"\n", w.Body.String())
+}
+
+func TestExportUsers_Controller_XLSX(t *testing.T) {
+	w := getExport(exportRouter(t, exportService(t)), "?format=XLSX&nombre=AR")
+
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, xlsx.ContentType, w.Header().Get("Content-Type"))
+	assert.True(t, strings.HasSuffix(w.Header().Get("Content-Disposition"), `.xlsx"`))
+	assert.True(t, strings.HasPrefix(w.Body.String(), "PK"))
+}
+
+func TestExportUsers_Controller_RejectsOptions(t *testing.T) {
+	router := exportRouter(t, exportService(t))
+
+	w := getExport(router, "?format=pdf&columns=nombre,password")
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, problem.ContentType, w.Header().Get("Content-Type"))
+	assert.Empty(t, w.Header().Get("Content-Disposition"))
+	assert.Contains(t, w.Body.String(), `{"field":"format","message":"Must be one of: csv jsonl xlsx."}`)
+	assert.Contains(t, w.Body.String(), `"field":"columns"`)
+
+	assert.Equal(t, http.StatusBadRequest, getExport(router, "?estado=quizas").Code)
+	assert.Equal(t, http.StatusBadRequest, getExport(router, "?columns=id,id").Code)
+}
+
+type exporterFunc func(w io.Writer) (int, error)
+
+func (f exporterFunc) ExportUsers(ctx context.Context, w io.Writer, opts Domain.ExportOptions) (int, error) {
+	return f(w)
+}
+
+func TestExportUsers_Controller_ErrorBeforeWriting(t *testing.T) {
+	router := exportRouter(t, exporterFunc(func(w io.Writer) (int, error) {
+		return 0, errors.New("connection refused")
+	}))
+
+	w := getExport(router, "")
+
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Equal(t, problem.ContentType, w.Header().Get("Content-Type"))
+	assert.Empty(t, w.Header().Get("Content-Disposition"))
+}
+
+func TestExportUsers_Controller_ErrorAfterWritingKeepsStatus(t *testing.T) {
+	router := exportRouter(t, exporterFunc(func(w io.Writer) (int, error) {
+		io.WriteString(w, "id\n1\n")
+		return 1, errors.New("connection lost")
+	}))
+
+	w := getExport(router, "")
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "id\n1\n", w.Body.String())
+}
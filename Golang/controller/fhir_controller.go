@@ -0,0 +1,91 @@
+package usersController
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	Domain "Golang/domain"
+	"Golang/fhir"
+)
+
+// PatientService es lo que FHIRController necesita de service.Service.
+type PatientService interface {
+	GetPatient(ctx context.Context, id int) (fhir.Patient, error)
+	ExportFHIR(ctx context.Context, w io.Writer, base string, filter Domain.UserFilter) (int, error)
+}
+
+// FHIRController expone los usuarios como recursos de HL7 FHIR R4 para los
+// sistemas de salud con los que se integra la API. Los errores siguen siendo
+// problem+json, igual que en el resto de las rutas.
+type FHIRController struct {
+	service PatientService
+}
+
+func NewFHIRController(service PatientService) FHIRController {
+	return FHIRController{service: service}
+}
+
+// GetPatient responde el Patient con ETag y Last-Modified, como GET
+// /users/:id.
+func (controller FHIRController) GetPatient(c *gin.Context) {
+	userId := c.Param("id")
+	id, err := strconv.Atoi(userId)
+	if err != nil {
+		abortWithError(c, fmt.Errorf("invalid id %q: %w", userId, Domain.ErrValidation))
+		return
+	}
+
+	patient, err := controller.service.GetPatient(c.Request.Context(), id)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	version, _ := strconv.Atoi(patient.Meta.VersionId)
+	lastModified, _ := time.Parse(time.RFC3339, patient.Meta.LastUpdated)
+	if notModified(c, etag(version), lastModified) {
+		return
+	}
+	body, err := json.Marshal(patient)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+	c.Data(http.StatusOK, fhir.ContentType, body)
+}
+
+// ExportBundle descarga un Bundle collection con los pacientes que cumplen
+// ?admin=, ?estado= y ?nombre=, con sus Condition y Observation.
+func (controller FHIRController) ExportBundle(c *gin.Context) {
+	filter, err := userFilter(c)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	base := fhirBase(c)
+	streamDownload(c, "pacientes", "json", fhir.ContentType, func(w io.Writer) (int, error) {
+		return controller.service.ExportFHIR(c.Request.Context(), w, base, filter)
+	})
+}
+
+// fhirBase es la URL de /fhir tal como la ve el cliente, para los fullUrl
+// del Bundle. Detras de un proxy que termina TLS se respeta
+// X-Forwarded-Proto.
+func fhirBase(c *gin.Context) string {
+	scheme := "http"
+	if c.Request.TLS != nil {
+		scheme = "https"
+	}
+	if proto := c.GetHeader("X-Forwarded-Proto"); proto == "http" || proto == "https" {
+		scheme = proto
+	}
+	return scheme + "://" + c.Request.Host + "/fhir"
+}
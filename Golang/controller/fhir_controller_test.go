@@ -0,0 +1,108 @@
+package usersController
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	clientUsers "Golang/clients"
+	"Golang/fhir"
+	Model "Golang/model"
+	"Golang/problem"
+	services "Golang/service"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func fhirRouter(t *testing.T) *gin.Engine {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	repo := clientUsers.NewMemory()
+	for _, user := range []Model.User{
+		{Nombre: "ana", Genero: "F", Diabetico: true, Lentes: true, Estado: true},
+		{Nombre: "bruno", Genero: "M", Estado: false},
+	} {
+		_, err := repo.InsertUser(context.Background(), user)
+		require.NoError(t, err)
+	}
+
+	controller := NewFHIRController(services.NewService(repo))
+	router := gin.New()
+	router.GET("/fhir/Patient/:id", controller.GetPatient)
+	router.GET("/fhir/export", controller.ExportBundle)
+	return router
+}
+
+func TestGetPatient_Controller(t *testing.T) {
+	router := fhirRouter(t)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/fhir/Patient/1", nil))
+
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, fhir.ContentType, w.Header().Get("Content-Type"))
+	assert.Equal(t, etag(1), w.Header().Get("ETag"))
+	assert.NotEmpty(t, w.Header().Get("Last-Modified"))
+	var patient fhir.Patient
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &patient))
+	assert.Equal(t, fhir.TypePatient, patient.ResourceType)
+	assert.Equal(t, "1", patient.Id)
+	assert.Equal(t, fhir.GenderFemale, patient.Gender)
+
+	req := httptest.NewRequest(http.MethodGet, "/fhir/Patient/1", nil)
+	req.Header.Set("If-None-Match", etag(1))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotModified, w.Code)
+}
+
+func TestGetPatient_Controller_Errors(t *testing.T) {
+	router := fhirRouter(t)
+
+	for path, status := range map[string]int{"/fhir/Patient/99": http.StatusNotFound, "/fhir/Patient/abc": http.StatusBadRequest} {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		assert.Equal(t, status, w.Code, path)
+		assert.Equal(t, problem.ContentType, w.Header().Get("Content-Type"), path)
+	}
+}
+
+func TestExportBundle_Controller(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/fhir/export?estado=true", nil)
+	req.Header.Set("X-Forwarded-Proto", "https")
+	w := httptest.NewRecorder()
+
+	fhirRouter(t).ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, fhir.ContentType, w.Header().Get("Content-Type"))
+	assert.Regexp(t, `^attachment; filename="pacientes-\d{8}-\d{6}\.json"$`, w.Header().Get("Content-Disposition"))
+	var bundle struct {
+		ResourceType string `json:"resourceType"`
+		Type         string `json:"type"`
+		Entry        []struct {
+			FullUrl  string `json:"fullUrl"`
+			Resource struct {
+				ResourceType string `json:"resourceType"`
+			} `json:"resource"`
+		} `json:"entry"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &bundle))
+	assert.Equal(t, fhir.TypeBundle, bundle.ResourceType)
+	assert.Equal(t, fhir.BundleCollection, bundle.Type)
+	require.Len(t, bundle.Entry, 3)
+	assert.Equal(t, "https://example.com/fhir/Patient/1", bundle.Entry[0].FullUrl)
+	assert.Equal(t, fhir.TypeCondition, bundle.Entry[1].Resource.ResourceType)
+	assert.Equal(t, fhir.TypeObservation, bundle.Entry[2].Resource.ResourceType)
+}
+
+func TestExportBundle_Controller_RejectsFilter(t *testing.T) {
+	w := httptest.NewRecorder()
+	fhirRouter(t).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/fhir/export?admin=talvez", nil))
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
@@ -0,0 +1,234 @@
+package usersController
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	Domain "Golang/domain"
+	"Golang/problem"
+)
+
+// UserImporter es lo que ImportController necesita de service.Importer.
+type UserImporter interface {
+	ImportUsers(ctx context.Context, data io.Reader, opts Domain.ImportOptions) (Domain.ImportReport, error)
+	StartImport(ctx context.Context, data []byte, opts Domain.ImportOptions) (Domain.ImportJob, error)
+	GetImportJob(ctx context.Context, id string) (Domain.ImportJob, error)
+}
+
+// ImportConfig limita los archivos de POST /users/import. Los que superan
+// SyncMaxBytes se importan en segundo plano. Los ceros toman los valores por
+// defecto: 10 MiB y 1 MiB.
+type ImportConfig struct {
+	MaxBytes     int64
+	SyncMaxBytes int64
+}
+
+const (
+	defaultImportMaxBytes     = 10 << 20
+	defaultImportSyncMaxBytes = 1 << 20
+)
+
+type ImportController struct {
+	importer UserImporter
+	config   ImportConfig
+}
+
+func NewImportController(importer UserImporter, config ImportConfig) ImportController {
+	if config.MaxBytes <= 0 {
+		config.MaxBytes = defaultImportMaxBytes
+	}
+	if config.SyncMaxBytes <= 0 {
+		config.SyncMaxBytes = defaultImportSyncMaxBytes
+	}
+	return ImportController{
+		importer: importer,
+		config:   config,
+	}
+}
+
+var (
+	detailUnsupportedImport = problem.Text{ES: "Formato de archivo no soportado. Use CSV o JSON Lines.", EN: "Unsupported file format. Use CSV or JSON Lines."}
+	detailImportTooLarge    = problem.Text{ES: "El archivo supera el tamaño máximo permitido.", EN: "The file exceeds the maximum allowed size."}
+	detailImportJobNotFound = problem.Text{ES: "La importación no existe o ya venció.", EN: "The import does not exist or has expired."}
+)
+
+// importMessages son los mensajes de las reglas propias de la importacion.
+var importMessages = map[string]problem.Text{
+	Domain.ImportRuleFormat:    {ES: "El valor no tiene el formato esperado.", EN: "The value does not have the expected format."},
+	Domain.ImportRuleDuplicate: {ES: "El nombre ya aparece en la fila %s.", EN: "The name already appears in row %s."},
+	Domain.ImportRuleExists:    {ES: "Ya existe un usuario con ese nombre.", EN: "A user with this name already exists."},
+	Domain.ImportRuleConflict:  {ES: "El usuario se registró mientras corría la importación.", EN: "The user was registered while the import was running."},
+}
+
+// importFileMessages explican por que no se pudo leer el archivo.
+var importFileMessages = map[string]problem.Text{
+	Domain.ImportFileEmpty:          {ES: "El archivo está vacío.", EN: "The file is empty."},
+	Domain.ImportFileMalformed:      {ES: "El archivo está mal formado.", EN: "The file is malformed."},
+	Domain.ImportFileUnknownColumn:  {ES: "Columna desconocida: \"%s\".", EN: "Unknown column: \"%s\"."},
+	Domain.ImportFileRepeatedColumn: {ES: "Columna repetida: \"%s\".", EN: "Repeated column: \"%s\"."},
+	Domain.ImportFileMissingColumn:  {ES: "Falta la columna obligatoria \"%s\".", EN: "The required column \"%s\" is missing."},
+	Domain.ImportFileTooManyRows:    {ES: "El archivo supera las %s filas. Divídalo en partes.", EN: "The file has more than %s rows. Split it into parts."},
+	Domain.ImportFileUnsupported:    {ES: "Formato de archivo no soportado: \"%s\".", EN: "Unsupported file format: \"%s\"."},
+}
+
+// importContentTypes asocia los content types aceptados con su formato.
+var importContentTypes = map[string]string{
+	"text/csv":                Domain.ImportCSV,
+	"application/csv":         Domain.ImportCSV,
+	"application/x-ndjson":    Domain.ImportJSONL,
+	"application/jsonl":       Domain.ImportJSONL,
+	"application/jsonlines":   Domain.ImportJSONL,
+	"application/x-jsonlines": Domain.ImportJSONL,
+}
+
+// ImportUsers recibe el archivo como cuerpo del pedido. El formato sale de
+// ?format= o del Content-Type. Con ?dry_run=true solo valida; con
+// ?skip_invalid=true importa las filas validas aunque haya errores. Con
+// ?async=true, o si el archivo es grande, responde 202 con el trabajo.
+func (controller ImportController) ImportUsers(c *gin.Context) {
+	format := strings.ToLower(c.Query("format"))
+	if format == "" {
+		format = importContentTypes[c.ContentType()]
+	}
+	if format != Domain.ImportCSV && format != Domain.ImportJSONL {
+		problem.Write(c, http.StatusUnsupportedMediaType, problem.TypeBlank, detailUnsupportedImport)
+		return
+	}
+
+	opts := Domain.ImportOptions{Format: format}
+	var async bool
+	for _, flag := range []struct {
+		name   string
+		target *bool
+	}{{"dry_run", &opts.DryRun}, {"skip_invalid", &opts.SkipInvalid}, {"async", &async}} {
+		value, ok, err := queryBool(c, flag.name)
+		if err != nil {
+			abortWithError(c, err)
+			return
+		}
+		if ok {
+			*flag.target = value
+		}
+	}
+
+	data, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, controller.config.MaxBytes))
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			problem.Write(c, http.StatusRequestEntityTooLarge, problem.TypeValidation, detailImportTooLarge)
+			return
+		}
+		abortWithError(c, fmt.Errorf("reading body: %v: %w", err, Domain.ErrValidation))
+		return
+	}
+
+	if async || int64(len(data)) > controller.config.SyncMaxBytes {
+		job, err := controller.importer.StartImport(c.Request.Context(), data, opts)
+		if err != nil {
+			abortWithError(c, err)
+			return
+		}
+		c.Header("Location", "/users/import/"+job.Id)
+		c.JSON(http.StatusAccepted, job)
+		return
+	}
+
+	report, err := controller.importer.ImportUsers(c.Request.Context(), bytes.NewReader(data), opts)
+	var fileErr *Domain.ImportFileError
+	if errors.As(err, &fileErr) {
+		problem.Write(c, http.StatusBadRequest, problem.TypeValidation, detailValidation, fileError(c, fileErr))
+		return
+	}
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+	localizeReport(c, &report)
+
+	switch {
+	case report.Aborted:
+		c.JSON(http.StatusUnprocessableEntity, report)
+	case report.DryRun:
+		c.JSON(http.StatusOK, report)
+	default:
+		c.JSON(http.StatusCreated, report)
+	}
+}
+
+// GetImportJob devuelve el estado de una importacion en segundo plano.
+func (controller ImportController) GetImportJob(c *gin.Context) {
+	job, err := controller.importer.GetImportJob(c.Request.Context(), c.Param("id"))
+	if errors.Is(err, Domain.ErrNotFound) {
+		problem.Write(c, http.StatusNotFound, problem.TypeNotFound, detailImportJobNotFound)
+		return
+	}
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+	if job.Report != nil {
+		localizeReport(c, job.Report)
+	}
+	c.JSON(http.StatusOK, job)
+}
+
+func queryBool(c *gin.Context, name string) (bool, bool, error) {
+	text, ok := c.GetQuery(name)
+	if !ok {
+		return false, false, nil
+	}
+	if text == "" {
+		return true, true, nil
+	}
+	value, err := strconv.ParseBool(text)
+	if err != nil {
+		return false, false, fmt.Errorf("invalid %s %q: %w", name, text, Domain.ErrValidation)
+	}
+	return value, true, nil
+}
+
+// fileError describe el problema del archivo en el campo "file" del
+// problem+json, con la linea si se conoce.
+func fileError(c *gin.Context, err *Domain.ImportFileError) problem.FieldError {
+	text, ok := importFileMessages[err.Reason]
+	if !ok {
+		text = fieldMessageDefault
+	}
+	message := text.In(problem.Language(c.Request))
+	if strings.Contains(message, "%s") {
+		message = fmt.Sprintf(message, err.Param)
+	}
+	field := "file"
+	if err.Line > 0 {
+		field = "file:" + strconv.Itoa(err.Line)
+	}
+	return problem.FieldError{Field: field, Message: message}
+}
+
+// localizeReport completa el mensaje de cada error en el idioma del pedido,
+// con los mismos textos que la validacion de POST /users.
+func localizeReport(c *gin.Context, report *Domain.ImportReport) {
+	lang := problem.Language(c.Request)
+	for i, rowErr := range report.Errors {
+		text, ok := importMessages[rowErr.Rule]
+		if !ok {
+			text, ok = fieldMessages[rowErr.Rule]
+		}
+		if !ok {
+			text = fieldMessageDefault
+		}
+		message := text.In(lang)
+		if strings.Contains(message, "%s") {
+			message = fmt.Sprintf(message, rowErr.Param)
+		}
+		report.Errors[i].Message = message
+	}
+}
@@ -0,0 +1,160 @@
+package usersController
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	clientUsers "Golang/clients"
+	Domain "Golang/domain"
+	"Golang/problem"
+	services "Golang/service"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const importCSV = "nombre,password,genero\nana,secreto1,F\nbruno,secreto2,M\n"
+
+func importRouter(t *testing.T, config ImportConfig) (*gin.Engine, *clientUsers.Memory) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	repo := clientUsers.NewMemory()
+	importer := services.NewImporter(services.NewService(repo), time.Hour)
+	t.Cleanup(func() { importer.Close(context.Background()) })
+
+	controller := NewImportController(importer, config)
+	router := gin.New()
+	router.GET("/users/:id", func(c *gin.Context) { c.Status(http.StatusOK) })
+	router.POST("/users/import", controller.ImportUsers)
+	router.GET("/users/import/:id", controller.GetImportJob)
+	return router, repo
+}
+
+func postImport(router *gin.Engine, query string, contentType string, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/users/import"+query, strings.NewReader(body))
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	req.Header.Set("Accept-Language", "en")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestImportUsers_Controller_CSV(t *testing.T) {
+	router, repo := importRouter(t, ImportConfig{})
+
+	w := postImport(router, "", "text/csv; charset=utf-8", importCSV)
+
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var report Domain.ImportReport
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &report))
+	assert.Equal(t, Domain.ImportCSV, report.Format)
+	assert.Equal(t, 2, report.Imported)
+	assert.NotContains(t, w.Body.String(), "secreto")
+
+	all, _ := repo.GetAllUsers(context.Background())
+	assert.Len(t, all, 2)
+}
+
+func TestImportUsers_Controller_DryRunLocalizesRowErrors(t *testing.T) {
+	router, repo := importRouter(t, ImportConfig{})
+	body := `{"nombre":"ana","password":"secreto1","genero":"F"}
+{"nombre":"ana","password":"corta","genero":"F"}
+`
+
+	w := postImport(router, "?format=jsonl&dry_run", "", body)
+
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var report Domain.ImportReport
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &report))
+	assert.True(t, report.DryRun)
+	require.Len(t, report.Errors, 2)
+	assert.Equal(t, "The value is below the minimum allowed (6).", report.Errors[0].Message)
+	assert.Equal(t, "The name already appears in row 1.", report.Errors[1].Message)
+
+	all, _ := repo.GetAllUsers(context.Background())
+	assert.Empty(t, all)
+}
+
+func TestImportUsers_Controller_AbortedIsUnprocessable(t *testing.T) {
+	router, _ := importRouter(t, ImportConfig{})
+
+	w := postImport(router, "", "text/csv", "nombre,password,genero\nana,secreto1,F\nbruno,secreto2,Q\n")
+
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	assert.Contains(t, w.Body.String(), `"aborted":true`)
+
+	w = postImport(router, "?skip_invalid=true", "text/csv", "nombre,password,genero\nana,secreto1,F\nbruno,secreto2,Q\n")
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Contains(t, w.Body.String(), `"imported":1`)
+}
+
+func TestImportUsers_Controller_FileErrors(t *testing.T) {
+	router, _ := importRouter(t, ImportConfig{})
+
+	w := postImport(router, "", "text/csv", "nombre,password,genero,edad\n")
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, problem.ContentType, w.Header().Get("Content-Type"))
+	var body problem.Problem
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.Equal(t, problem.TypeValidation, body.Type)
+	assert.Equal(t, []problem.FieldError{{Field: "file:1", Message: `Unknown column: "edad".`}}, body.Errors)
+}
+
+func TestImportUsers_Controller_RejectsRequest(t *testing.T) {
+	router, _ := importRouter(t, ImportConfig{MaxBytes: 64})
+
+	assert.Equal(t, http.StatusUnsupportedMediaType, postImport(router, "", "application/json", importCSV).Code)
+	assert.Equal(t, http.StatusUnsupportedMediaType, postImport(router, "?format=xlsx", "", importCSV).Code)
+	assert.Equal(t, http.StatusBadRequest, postImport(router, "?dry_run=maybe", "text/csv", importCSV).Code)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, postImport(router, "", "text/csv", importCSV+strings.Repeat("x", 64)).Code)
+}
+
+func TestImportUsers_Controller_LargeFilesRunInBackground(t *testing.T) {
+	router, repo := importRouter(t, ImportConfig{SyncMaxBytes: 16})
+
+	w := postImport(router, "", "text/csv", importCSV)
+
+	require.Equal(t, http.StatusAccepted, w.Code, w.Body.String())
+	var job Domain.ImportJob
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &job))
+	assert.Equal(t, "/users/import/"+job.Id, w.Header().Get("Location"))
+
+	require.Eventually(t, func() bool {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/users/import/"+job.Id, nil))
+		require.Equal(t, http.StatusOK, w.Code)
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &job))
+		return job.Status == Domain.ImportSucceeded
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, 2, job.Report.Imported)
+
+	all, _ := repo.GetAllUsers(context.Background())
+	assert.Len(t, all, 2)
+}
+
+func TestImportUsers_Controller_AsyncOnRequest(t *testing.T) {
+	router, _ := importRouter(t, ImportConfig{})
+
+	w := postImport(router, "?async=true&dry_run=true", "text/csv", importCSV)
+
+	assert.Equal(t, http.StatusAccepted, w.Code)
+}
+
+func TestGetImportJob_Controller_NotFound(t *testing.T) {
+	router, _ := importRouter(t, ImportConfig{})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/users/import/0123", nil))
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, problem.ContentType, w.Header().Get("Content-Type"))
+}
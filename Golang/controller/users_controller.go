@@ -1,6 +1,7 @@
 package usersController
 
 import (
+	"context"
 	"fmt"
 	"strconv"
 	"strings"
@@ -10,18 +11,20 @@ import (
 	Domain "Golang/domain"
 
 	middle "Golang/middleware"
+	"Golang/problem"
 	"net/http"
-
-	log "github.com/sirupsen/logrus"
 )
 
 type UserService interface {
-	InsertUsuario(usuarioDomain Domain.UserData) (Domain.UserData, error)
-	GetUserByName(usuarioDomain Domain.UserData) (Domain.UserData, error)
-	UpdateUser(usuarioDomain Domain.UserData) (Domain.UserData, error)
-	Login(User Domain.UserData) (Domain.LoginData, error)
-	GetAllUsers() ([]Domain.UserData, error)
-	GetUserById(userId int) (Domain.UserData, error)
+	InsertUsuario(ctx context.Context, req Domain.CreateUserRequest, ip string) (Domain.UserResponse, error)
+	InsertUsuarioByAdmin(ctx context.Context, req Domain.CreateUserRequest) (Domain.UserResponse, error)
+	GetUserByName(ctx context.Context, nombre string) (Domain.PublicProfile, error)
+	UpdateUser(ctx context.Context, req Domain.UpdateUserRequest, version int, vis Domain.Visibility) (Domain.UserResponse, error)
+	Login(ctx context.Context, User Domain.LoginRequest, ip string) (Domain.LoginData, error)
+	GetAllUsers(ctx context.Context, vis Domain.Visibility) ([]Domain.UserResponse, error)
+	GetUserById(ctx context.Context, userId int, vis Domain.Visibility) (Domain.UserResponse, error)
+	GetUsersStamp(ctx context.Context) (Domain.UsersStamp, error)
+	PatchUser(ctx context.Context, id int, version int, contentType string, patch []byte, vis Domain.Visibility) (Domain.UserResponse, error)
 }
 
 type Controller struct {
@@ -35,17 +38,16 @@ func NewController(service UserService) Controller {
 }
 
 func (controller Controller) Login(c *gin.Context) {
-	var userData Domain.UserData
-	c.BindJSON(&userData)
+	var userData Domain.LoginRequest
+	if err := c.ShouldBindJSON(&userData); err != nil {
+		abortWithError(c, fmt.Errorf("decoding body: %v: %w", err, Domain.ErrValidation))
+		return
+	}
 
-	loginResponse, err := controller.service.Login(userData)
+	loginResponse, err := controller.service.Login(c.Request.Context(), userData, c.ClientIP())
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "Error al procesar la solicitud",
-		})
-
+		abortWithError(c, err)
 		return
-
 	}
 	c.JSON(http.StatusOK, loginResponse)
 
@@ -53,36 +55,29 @@ func (controller Controller) Login(c *gin.Context) {
 func (controller Controller) Extrac(c *gin.Context) {
 
 	data := strings.TrimSpace(c.GetHeader("Authorization"))
-	log.Println("token buscado: ", data)
 	response, err := middle.ExtractClaims(data)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "Error al procesar la solicitud",
-		})
-
+		abortWithError(c, fmt.Errorf("extracting claims: %v: %w", err, Domain.ErrUnauthorized))
 		return
-
 	}
 	c.JSON(http.StatusOK, response)
 
 }
 
 func (controller Controller) GetUserByName(c *gin.Context) {
-	fmt.Println("llego al controller")
-
-	var userDomain Domain.UserData
-	c.BindJSON(&userDomain)
+	var lookup Domain.UserLookup
+	if err := c.ShouldBindJSON(&lookup); err != nil {
+		abortWithError(c, fmt.Errorf("decoding body: %v: %w", err, Domain.ErrValidation))
+		return
+	}
 
-	userDomain, err := controller.service.GetUserByName(userDomain)
+	profile, err := controller.service.GetUserByName(c.Request.Context(), lookup.Nombre)
 
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "Error al procesar la solicitud",
-		})
-
+		abortWithError(c, err)
 		return
 	}
-	c.JSON(http.StatusOK, userDomain)
+	c.JSON(http.StatusOK, profile)
 
 }
 
@@ -91,75 +86,189 @@ func (controller Controller) GetUserById(c *gin.Context) {
 
 	id, err := strconv.Atoi(userId)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "ID inválido"})
+		abortWithError(c, fmt.Errorf("invalid id %q: %w", userId, Domain.ErrValidation))
 		return
 	}
 
-	user, err := controller.service.GetUserById(id)
+	vis := visibility(c)
+	user, err := controller.service.GetUserById(c.Request.Context(), id, vis)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al obtener el usuario"})
+		abortWithError(c, err)
 		return
 	}
 
+	if notModified(c, userETag(id, user.Version, vis), user.UpdatedAt) {
+		return
+	}
 	c.JSON(http.StatusOK, user)
 }
 
 func (controller Controller) GetAllUsers(c *gin.Context) {
-	users, err := controller.service.GetAllUsers()
+	// visibility agrega Vary antes del validador: el 304 tiene que llevar
+	// los mismos Vary que el 200.
+	vis := visibility(c)
+	// El validador se calcula antes de leer el listado para que un 304 no
+	// tenga que traer todas las filas.
+	stamp, err := controller.service.GetUsersStamp(c.Request.Context())
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+	if notModified(c, collectionETag(stamp), stamp.LastModified) {
+		return
+	}
+
+	users, err := controller.service.GetAllUsers(c.Request.Context(), vis)
 
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Error al obtener la lista de usuarios",
-		})
+		abortWithError(c, err)
 		return
 	}
 
 	c.JSON(http.StatusOK, users)
 }
 
+// UsuarioInsert es el registro de un usuario nuevo. Con el token de un
+// administrador es un alta en nombre de otro, sin consentimiento (ver
+// InsertUsuarioByAdmin).
 func (controller Controller) UsuarioInsert(c *gin.Context) {
-	var userDomain Domain.UserData
-	err := c.BindJSON(&userDomain)
+	var req Domain.CreateUserRequest
+	err := c.ShouldBindJSON(&req)
 
 	if err != nil {
-		log.Error(err.Error())
-		c.JSON(http.StatusBadRequest, err.Error())
+		abortWithError(c, fmt.Errorf("decoding body: %v: %w", err, Domain.ErrValidation))
+		return
+	}
+	req.Normalize()
+	if err := req.Validate(); err != nil {
+		abortWithError(c, err)
 		return
 	}
-	userDomain, er := controller.service.InsertUsuario(userDomain)
+	var user Domain.UserResponse
+	var er error
+	if viewer(c).Admin {
+		user, er = controller.service.InsertUsuarioByAdmin(c.Request.Context(), req)
+	} else {
+		user, er = controller.service.InsertUsuario(c.Request.Context(), req, c.ClientIP())
+	}
 
 	if er != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "Error al procesar la solicitud",
-		})
-
+		abortWithError(c, er)
 		return
 	}
 
-	c.JSON(http.StatusCreated, userDomain)
+	c.JSON(http.StatusCreated, user)
 
 }
 
 func (controller Controller) UpdateUser(c *gin.Context) {
-	var userDomain Domain.UserData
-	err := c.BindJSON(&userDomain)
+	var req Domain.UpdateUserRequest
+	err := c.ShouldBindJSON(&req)
 
 	if err != nil {
-		log.Error(err.Error())
-		c.JSON(http.StatusBadRequest, err.Error())
+		abortWithError(c, fmt.Errorf("decoding body: %v: %w", err, Domain.ErrValidation))
+		return
+	}
+	req.Normalize()
+	if err := req.Validate(); err != nil {
+		abortWithError(c, err)
+		return
+	}
+	version, ok := ifMatchVersion(c)
+	if !ok {
 		return
 	}
 
-	userDomain, er := controller.service.UpdateUser(userDomain)
+	vis := visibility(c)
+	user, er := controller.service.UpdateUser(c.Request.Context(), req, version, vis)
 
 	if er != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "Error al procesar la solicitud",
-		})
+		abortWithError(c, er)
+		return
+	}
+
+	c.Header("ETag", userETag(req.Id, user.Version, vis))
+	c.JSON(http.StatusCreated, user)
 
+}
+
+// acceptPatch es el valor del header Accept-Patch (RFC 5789).
+var acceptPatch = Domain.MergePatchContentType + ", " + Domain.JSONPatchContentType
+
+func (controller Controller) PatchUser(c *gin.Context) {
+	userId := c.Param("id")
+
+	id, err := strconv.Atoi(userId)
+	if err != nil {
+		abortWithError(c, fmt.Errorf("invalid id %q: %w", userId, Domain.ErrValidation))
+		return
+	}
+
+	contentType := c.ContentType()
+	switch contentType {
+	case Domain.MergePatchContentType, Domain.JSONPatchContentType, "application/json":
+	default:
+		c.Header("Accept-Patch", acceptPatch)
+		problem.Write(c, http.StatusUnsupportedMediaType, problem.TypeBlank, detailUnsupportedPatch)
+		return
+	}
+
+	version, ok := ifMatchVersion(c)
+	if !ok {
+		return
+	}
+
+	patch, err := c.GetRawData()
+	if err != nil {
+		abortWithError(c, fmt.Errorf("reading body: %v: %w", err, Domain.ErrValidation))
 		return
 	}
 
-	c.JSON(http.StatusCreated, userDomain)
+	vis := visibility(c)
+	user, err := controller.service.PatchUser(c.Request.Context(), id, version, contentType, patch, vis)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
 
+	c.Header("Accept-Patch", acceptPatch)
+	if notModified(c, userETag(id, user.Version, vis), user.UpdatedAt) {
+		return
+	}
+	c.JSON(http.StatusOK, user)
+}
+
+// visibility arma la politica de visibilidad del pedido con los claims que
+// dejo AuthMiddleware y los campos de ?fields=. La respuesta depende del
+// token, por eso se agrega Vary: Authorization.
+func visibility(c *gin.Context) Domain.Visibility {
+	c.Writer.Header().Add("Vary", "Authorization")
+	return Domain.Visibility{
+		Viewer: viewer(c),
+		Fields: splitColumns(c.Query("fields")),
+	}
+}
+
+// viewer es quien hace el pedido segun los claims que dejo AuthMiddleware.
+func viewer(c *gin.Context) Domain.Viewer {
+	userId, _ := c.Get("userID")
+	admin, _ := c.Get("admin")
+	role, _ := c.Get("role")
+	roleName, _ := role.(string)
+	return Domain.Viewer{UserId: claimInt(userId), Admin: admin == true, Role: roleName}
+}
+
+// claimInt lee un id de un claim: los JWT decodifican los numeros como
+// float64, pero otras herramientas los firman como texto.
+func claimInt(value interface{}) int {
+	switch v := value.(type) {
+	case float64:
+		return int(v)
+	case int:
+		return v
+	case string:
+		id, _ := strconv.Atoi(v)
+		return id
+	}
+	return 0
 }
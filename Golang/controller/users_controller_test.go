@@ -1,13 +1,20 @@
 package usersController
 
 import (
+	"context"
 	"bytes"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
+	clientUsers "Golang/clients"
 	Domain "Golang/domain"
+	"Golang/problem"
+	services "Golang/service"
 
 	"github.com/dgrijalva/jwt-go"
 	"github.com/gin-gonic/gin"
@@ -18,31 +25,51 @@ import (
 // MockService para el controlador
 type MockServiceController struct{
     mock.Mock
+    // visibility es la ultima politica que recibio el servicio.
+    visibility Domain.Visibility
 }
 
-func (m *MockServiceController) InsertUsuario(usuarioDomain Domain.UserData) (Domain.UserData, error) {
-    args := m.Called(usuarioDomain)
-    return args.Get(0).(Domain.UserData), args.Error(1)
+func (m *MockServiceController) InsertUsuario(ctx context.Context, req Domain.CreateUserRequest, ip string) (Domain.UserResponse, error) {
+    args := m.Called(req)
+    return args.Get(0).(Domain.UserResponse), args.Error(1)
 }
-func (m *MockServiceController) GetUserByName(usuarioDomain Domain.UserData) (Domain.UserData, error) {
-    args := m.Called(usuarioDomain)
-    return args.Get(0).(Domain.UserData), args.Error(1)
+func (m *MockServiceController) InsertUsuarioByAdmin(ctx context.Context, req Domain.CreateUserRequest) (Domain.UserResponse, error) {
+    args := m.Called(req)
+    return args.Get(0).(Domain.UserResponse), args.Error(1)
 }
-func (m *MockServiceController) UpdateUser(usuarioDomain Domain.UserData) (Domain.UserData, error) {
-    args := m.Called(usuarioDomain)
-    return args.Get(0).(Domain.UserData), args.Error(1)
+func (m *MockServiceController) GetUserByName(ctx context.Context, nombre string) (Domain.PublicProfile, error) {
+    args := m.Called(nombre)
+    return args.Get(0).(Domain.PublicProfile), args.Error(1)
 }
-func (m *MockServiceController) Login(User Domain.UserData) (Domain.LoginData, error) {
+func (m *MockServiceController) UpdateUser(ctx context.Context, req Domain.UpdateUserRequest, version int, vis Domain.Visibility) (Domain.UserResponse, error) {
+    m.visibility = vis
+    args := m.Called(req, version)
+    return args.Get(0).(Domain.UserResponse), args.Error(1)
+}
+func (m *MockServiceController) Login(ctx context.Context, User Domain.LoginRequest, ip string) (Domain.LoginData, error) {
     args := m.Called(User)
     return args.Get(0).(Domain.LoginData), args.Error(1)
 }
-func (m *MockServiceController) GetAllUsers() ([]Domain.UserData, error) {
+func (m *MockServiceController) GetAllUsers(ctx context.Context, vis Domain.Visibility) ([]Domain.UserResponse, error) {
+    m.visibility = vis
     args := m.Called()
-    return args.Get(0).([]Domain.UserData), args.Error(1)
+    return args.Get(0).([]Domain.UserResponse), args.Error(1)
 }
-func (m *MockServiceController) GetUserById(userId int) (Domain.UserData, error) {
+func (m *MockServiceController) GetUserById(ctx context.Context, userId int, vis Domain.Visibility) (Domain.UserResponse, error) {
+    m.visibility = vis
     args := m.Called(userId)
-    return args.Get(0).(Domain.UserData), args.Error(1)
+    return args.Get(0).(Domain.UserResponse), args.Error(1)
+}
+
+func (m *MockServiceController) PatchUser(ctx context.Context, id int, version int, contentType string, patch []byte, vis Domain.Visibility) (Domain.UserResponse, error) {
+    m.visibility = vis
+    args := m.Called(id, version, contentType, patch)
+    return args.Get(0).(Domain.UserResponse), args.Error(1)
+}
+
+func (m *MockServiceController) GetUsersStamp(ctx context.Context) (Domain.UsersStamp, error) {
+    args := m.Called()
+    return args.Get(0).(Domain.UsersStamp), args.Error(1)
 }
 
 func TestLogin_Controller_OK(t *testing.T) {
@@ -53,7 +80,7 @@ func TestLogin_Controller_OK(t *testing.T) {
     loginResp := Domain.LoginData{Token: "tok", IdU: 1}
     mockSvc.On("Login", mock.Anything).Return(loginResp, nil)
 
-    body, _ := json.Marshal(Domain.UserData{Nombre: "u", Password: "p"})
+    body, _ := json.Marshal(Domain.LoginRequest{Nombre: "u", Password: "p"})
     req := httptest.NewRequest(http.MethodPost, "/users/login", bytes.NewReader(body))
     req.Header.Set("Content-Type", "application/json")
     w := httptest.NewRecorder()
@@ -89,8 +116,27 @@ func TestUsuarioInsert_Controller_OK(t *testing.T) {
     mockSvc := new(MockServiceController)
     ctrl := NewController(mockSvc)
 
-    input := Domain.UserData{Nombre: "nuevo"}
-    mockSvc.On("InsertUsuario", mock.Anything).Return(input, nil)
+    input := Domain.CreateUserRequest{Nombre: "nuevo", Password: "secreto", Genero: "F"}
+    mockSvc.On("InsertUsuario", input).Return(Domain.UserResponse{Id: 1, Nombre: "nuevo", Genero: "F"}, nil)
+
+    body, _ := json.Marshal(input)
+    req := httptest.NewRequest(http.MethodPost, "/users", bytes.NewReader(body))
+    req.Header.Set("Content-Type", "application/json")
+    w := httptest.NewRecorder()
+    c, _ := gin.CreateTestContext(w)
+    c.Request = req
+
+    ctrl.UsuarioInsert(c)
+    assert.Equal(t, http.StatusCreated, w.Code)
+}
+
+func TestUsuarioInsert_Controller_AdminInsertsWithoutConsent(t *testing.T) {
+    gin.SetMode(gin.TestMode)
+    mockSvc := new(MockServiceController)
+    ctrl := NewController(mockSvc)
+
+    input := Domain.CreateUserRequest{Nombre: "nuevo", Password: "secreto", Genero: "F"}
+    mockSvc.On("InsertUsuarioByAdmin", input).Return(Domain.UserResponse{Id: 1, Nombre: "nuevo", Genero: "F"}, nil)
 
     body, _ := json.Marshal(input)
     req := httptest.NewRequest(http.MethodPost, "/users", bytes.NewReader(body))
@@ -98,9 +144,31 @@ func TestUsuarioInsert_Controller_OK(t *testing.T) {
     w := httptest.NewRecorder()
     c, _ := gin.CreateTestContext(w)
     c.Request = req
+    c.Set("userID", float64(1))
+    c.Set("admin", true)
 
     ctrl.UsuarioInsert(c)
     assert.Equal(t, http.StatusCreated, w.Code)
+    mockSvc.AssertNotCalled(t, "InsertUsuario", mock.Anything)
+}
+
+func TestUsuarioInsert_Controller_NormalizesLegacyGenero(t *testing.T) {
+    gin.SetMode(gin.TestMode)
+    mockSvc := new(MockServiceController)
+    ctrl := NewController(mockSvc)
+
+    mockSvc.On("InsertUsuario", Domain.CreateUserRequest{Nombre: "nuevo", Password: "secreto", Genero: "M"}).Return(Domain.UserResponse{Id: 1}, nil)
+
+    body := []byte(`{"nombre":"nuevo","password":"secreto","genero":"Masculino"}`)
+    req := httptest.NewRequest(http.MethodPost, "/users", bytes.NewReader(body))
+    req.Header.Set("Content-Type", "application/json")
+    w := httptest.NewRecorder()
+    c, _ := gin.CreateTestContext(w)
+    c.Request = req
+
+    ctrl.UsuarioInsert(c)
+    assert.Equal(t, http.StatusCreated, w.Code)
+    mockSvc.AssertExpectations(t)
 }
 
 func TestGetAllUsers_Controller_OK(t *testing.T) {
@@ -108,7 +176,8 @@ func TestGetAllUsers_Controller_OK(t *testing.T) {
     mockSvc := new(MockServiceController)
     ctrl := NewController(mockSvc)
 
-    users := []Domain.UserData{{Id: 1, Nombre: "a"}}
+    users := []Domain.UserResponse{{Id: 1, Nombre: "a"}}
+    mockSvc.On("GetUsersStamp").Return(Domain.UsersStamp{Count: 1, VersionSum: 1}, nil)
     mockSvc.On("GetAllUsers").Return(users, nil)
 
     req := httptest.NewRequest(http.MethodGet, "/users/all", nil)
@@ -144,8 +213,8 @@ func TestGetUserByName_Controller_OK(t *testing.T) {
     mockSvc := new(MockServiceController)
     ctrl := NewController(mockSvc)
 
-    in := Domain.UserData{Nombre: "pepe"}
-    mockSvc.On("GetUserByName", mock.Anything).Return(in, nil)
+    in := Domain.UserLookup{Nombre: "pepe"}
+    mockSvc.On("GetUserByName", "pepe").Return(Domain.PublicProfile{Id: 1, Nombre: "pepe"}, nil)
 
     body, _ := json.Marshal(in)
     req := httptest.NewRequest(http.MethodGet, "/users", bytes.NewReader(body))
@@ -163,18 +232,20 @@ func TestUpdateUser_Controller_OK(t *testing.T) {
     mockSvc := new(MockServiceController)
     ctrl := NewController(mockSvc)
 
-    in := Domain.UserData{Id: 3, Nombre: "upd"}
-    mockSvc.On("UpdateUser", mock.Anything).Return(in, nil)
+    in := Domain.UpdateUserRequest{Id: 3, Nombre: "upd", Genero: "M"}
+    mockSvc.On("UpdateUser", in, 2).Return(Domain.UserResponse{Id: 3, Nombre: "upd", Genero: "M", Version: 3}, nil)
 
     body, _ := json.Marshal(in)
     req := httptest.NewRequest(http.MethodPut, "/users", bytes.NewReader(body))
     req.Header.Set("Content-Type", "application/json")
+    req.Header.Set("If-Match", `"2"`)
     w := httptest.NewRecorder()
     c, _ := gin.CreateTestContext(w)
     c.Request = req
 
     ctrl.UpdateUser(c)
     assert.Equal(t, http.StatusCreated, w.Code)
+    assert.Equal(t, `"3-public"`, w.Header().Get("ETag"))
 }
 
 func TestUpdateUser_Controller_BadJSON(t *testing.T) {
@@ -197,7 +268,7 @@ func TestGetUserById_Controller_OK(t *testing.T) {
     mockSvc := new(MockServiceController)
     ctrl := NewController(mockSvc)
 
-    user := Domain.UserData{Id: 9, Nombre: "ok"}
+    user := Domain.UserResponse{Id: 9, Nombre: "ok", Version: 4}
     mockSvc.On("GetUserById", 9).Return(user, nil)
 
     req := httptest.NewRequest(http.MethodGet, "/users/9", nil)
@@ -208,6 +279,7 @@ func TestGetUserById_Controller_OK(t *testing.T) {
 
     ctrl.GetUserById(c)
     assert.Equal(t, http.StatusOK, w.Code)
+    assert.Equal(t, `"4-public"`, w.Header().Get("ETag"))
 }
 
 func TestUsuarioInsert_Controller_BadJSON(t *testing.T) {
@@ -230,9 +302,9 @@ func TestLogin_Controller_ErrorFromService(t *testing.T) {
     mockSvc := new(MockServiceController)
     ctrl := NewController(mockSvc)
 
-    mockSvc.On("Login", mock.Anything).Return(Domain.LoginData{}, assert.AnError)
+    mockSvc.On("Login", mock.Anything).Return(Domain.LoginData{}, fmt.Errorf("login: %w", Domain.ErrUnauthorized))
 
-    body, _ := json.Marshal(Domain.UserData{Nombre: "u", Password: "p"})
+    body, _ := json.Marshal(Domain.LoginRequest{Nombre: "u", Password: "p"})
     req := httptest.NewRequest(http.MethodPost, "/users/login", bytes.NewReader(body))
     req.Header.Set("Content-Type", "application/json")
     w := httptest.NewRecorder()
@@ -240,5 +312,478 @@ func TestLogin_Controller_ErrorFromService(t *testing.T) {
     c.Request = req
 
     ctrl.Login(c)
+    assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
+func TestGetUserById_Controller_NotFound(t *testing.T) {
+    gin.SetMode(gin.TestMode)
+    mockSvc := new(MockServiceController)
+    ctrl := NewController(mockSvc)
+
+    mockSvc.On("GetUserById", 404).Return(Domain.UserResponse{}, fmt.Errorf("Error al obtener el usuario: %w", Domain.ErrNotFound))
+
+    req := httptest.NewRequest(http.MethodGet, "/users/404", nil)
+    w := httptest.NewRecorder()
+    c, _ := gin.CreateTestContext(w)
+    c.Params = gin.Params{{Key: "id", Value: "404"}}
+    c.Request = req
+
+    ctrl.GetUserById(c)
+    assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestUsuarioInsert_Controller_Conflict(t *testing.T) {
+    gin.SetMode(gin.TestMode)
+    mockSvc := new(MockServiceController)
+    ctrl := NewController(mockSvc)
+
+    mockSvc.On("InsertUsuario", mock.Anything).Return(Domain.UserResponse{}, fmt.Errorf("Error Inserting User: %w", Domain.ErrConflict))
+
+    body, _ := json.Marshal(Domain.CreateUserRequest{Nombre: "repetido", Password: "secreto", Genero: "M"})
+    req := httptest.NewRequest(http.MethodPost, "/users", bytes.NewReader(body))
+    req.Header.Set("Content-Type", "application/json")
+    w := httptest.NewRecorder()
+    c, _ := gin.CreateTestContext(w)
+    c.Request = req
+
+    ctrl.UsuarioInsert(c)
+    assert.Equal(t, http.StatusConflict, w.Code)
+}
+
+func TestGetAllUsers_Controller_InternalErrorDoesNotLeak(t *testing.T) {
+    gin.SetMode(gin.TestMode)
+    mockSvc := new(MockServiceController)
+    ctrl := NewController(mockSvc)
+
+    mockSvc.On("GetUsersStamp").Return(Domain.UsersStamp{}, nil)
+    mockSvc.On("GetAllUsers").Return([]Domain.UserResponse(nil), fmt.Errorf("dial tcp 10.0.0.5:3306: connection refused"))
+
+    req := httptest.NewRequest(http.MethodGet, "/users/all", nil)
+    w := httptest.NewRecorder()
+    c, _ := gin.CreateTestContext(w)
+    c.Request = req
+
+    ctrl.GetAllUsers(c)
+    assert.Equal(t, http.StatusInternalServerError, w.Code)
+    assert.NotContains(t, w.Body.String(), "10.0.0.5")
+}
+
+func TestGetUserById_Controller_ProblemJSON(t *testing.T) {
+    gin.SetMode(gin.TestMode)
+    mockSvc := new(MockServiceController)
+    ctrl := NewController(mockSvc)
+
+    mockSvc.On("GetUserById", 8).Return(Domain.UserResponse{}, fmt.Errorf("Error al obtener el usuario: %w", Domain.ErrNotFound))
+
+    req := httptest.NewRequest(http.MethodGet, "/users/8", nil)
+    req.Header.Set("Accept-Language", "en-US")
+    w := httptest.NewRecorder()
+    c, _ := gin.CreateTestContext(w)
+    c.Params = gin.Params{{Key: "id", Value: "8"}}
+    c.Request = req
+
+    ctrl.GetUserById(c)
+
+    assert.Equal(t, problem.ContentType, w.Header().Get("Content-Type"))
+    var got problem.Problem
+    assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &got))
+    assert.Equal(t, http.StatusNotFound, got.Status)
+    assert.Equal(t, problem.TypeNotFound, got.Type)
+    assert.Equal(t, "Not Found", got.Title)
+    assert.Equal(t, "/users/8", got.Instance)
+}
+
+func TestUsuarioInsert_Controller_ValidationReportsEveryField(t *testing.T) {
+    gin.SetMode(gin.TestMode)
+    mockSvc := new(MockServiceController)
+    ctrl := NewController(mockSvc)
+
+    body, _ := json.Marshal(Domain.CreateUserRequest{Nombre: "  ", Genero: "Z", Atributos: strings.Repeat("a", 601)})
+    req := httptest.NewRequest(http.MethodPost, "/users", bytes.NewReader(body))
+    req.Header.Set("Content-Type", "application/json")
+    w := httptest.NewRecorder()
+    c, _ := gin.CreateTestContext(w)
+    c.Request = req
+
+    ctrl.UsuarioInsert(c)
+
     assert.Equal(t, http.StatusBadRequest, w.Code)
+    var got problem.Problem
+    assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &got))
+    assert.Equal(t, problem.TypeValidation, got.Type)
+    fields := map[string]string{}
+    for _, f := range got.Errors {
+        fields[f.Field] = f.Message
+    }
+    assert.Len(t, fields, 4)
+    assert.Contains(t, fields, "nombre")
+    assert.Contains(t, fields, "password")
+    assert.Contains(t, fields, "genero")
+    assert.Equal(t, "El valor supera el máximo permitido (600).", fields["atributos"])
+    mockSvc.AssertNotCalled(t, "InsertUsuario", mock.Anything)
+}
+
+func TestUpdateUser_Controller_MissingId(t *testing.T) {
+    gin.SetMode(gin.TestMode)
+    mockSvc := new(MockServiceController)
+    ctrl := NewController(mockSvc)
+
+    body, _ := json.Marshal(Domain.UpdateUserRequest{Nombre: "upd", Genero: "M"})
+    req := httptest.NewRequest(http.MethodPut, "/users", bytes.NewReader(body))
+    req.Header.Set("Content-Type", "application/json")
+    req.Header.Set("Accept-Language", "en")
+    w := httptest.NewRecorder()
+    c, _ := gin.CreateTestContext(w)
+    c.Request = req
+
+    ctrl.UpdateUser(c)
+
+    assert.Equal(t, http.StatusBadRequest, w.Code)
+    var got problem.Problem
+    assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &got))
+    assert.Equal(t, []problem.FieldError{{Field: "id", Message: "This field is required."}}, got.Errors)
+    mockSvc.AssertNotCalled(t, "UpdateUser", mock.Anything)
+}
+
+// Recorre todos los endpoints con el service y un repositorio reales y
+// verifica que ninguna respuesta incluya la contraseña ni su hash.
+func TestResponses_NeverContainPassword(t *testing.T) {
+    gin.SetMode(gin.TestMode)
+    ctrl := NewController(services.NewService(clientUsers.NewMemory()))
+
+    router := gin.New()
+    // Como el propio paciente: ve y puede modificar todos sus datos.
+    router.Use(func(c *gin.Context) { c.Set("userID", float64(1)) })
+    router.POST("/users", ctrl.UsuarioInsert)
+    router.GET("/users/all", ctrl.GetAllUsers)
+    router.GET("/users", ctrl.GetUserByName)
+    router.GET("/users/:id", ctrl.GetUserById)
+    router.PUT("/users", ctrl.UpdateUser)
+    router.PATCH("/users/:id", ctrl.PatchUser)
+
+    const hash = "737c04bbf91056509f2fd3e25b7b3dc8" // md5("supersecreto")
+    requests := []struct {
+        method string
+        path   string
+        body   interface{}
+    }{
+        {http.MethodPost, "/users", Domain.CreateUserRequest{Nombre: "paciente", Password: "supersecreto", Genero: "F"}},
+        {http.MethodGet, "/users/1", nil},
+        {http.MethodGet, "/users/all", nil},
+        {http.MethodGet, "/users", Domain.UserLookup{Nombre: "paciente"}},
+        {http.MethodPut, "/users", Domain.UpdateUserRequest{Id: 1, Nombre: "paciente", Genero: "F", Estado: true}},
+        {http.MethodPatch, "/users/1", map[string]interface{}{"lentes": true}},
+    }
+
+    for _, r := range requests {
+        var body []byte
+        if r.body != nil {
+            body, _ = json.Marshal(r.body)
+        }
+        req := httptest.NewRequest(r.method, r.path, bytes.NewReader(body))
+        req.Header.Set("Content-Type", "application/json")
+        req.Header.Set("If-Match", "*")
+        w := httptest.NewRecorder()
+
+        router.ServeHTTP(w, req)
+
+        assert.Less(t, w.Code, 300, "%s %s", r.method, r.path)
+        got := strings.ToLower(w.Body.String())
+        assert.NotContains(t, got, "password", "%s %s", r.method, r.path)
+        assert.NotContains(t, got, hash, "%s %s", r.method, r.path)
+    }
+}
+
+func TestPatchUser_Controller_MergePatch(t *testing.T) {
+    gin.SetMode(gin.TestMode)
+    mockSvc := new(MockServiceController)
+    ctrl := NewController(mockSvc)
+
+    patch := []byte(`{"maneja":false}`)
+    mockSvc.On("PatchUser", 5, 0, Domain.MergePatchContentType, patch).Return(Domain.UserResponse{Id: 5}, nil)
+
+    req := httptest.NewRequest(http.MethodPatch, "/users/5", bytes.NewReader(patch))
+    req.Header.Set("Content-Type", Domain.MergePatchContentType+"; charset=utf-8")
+    req.Header.Set("If-Match", "*")
+    w := httptest.NewRecorder()
+    c, _ := gin.CreateTestContext(w)
+    c.Params = gin.Params{{Key: "id", Value: "5"}}
+    c.Request = req
+
+    ctrl.PatchUser(c)
+
+    assert.Equal(t, http.StatusOK, w.Code)
+    assert.Contains(t, w.Header().Get("Accept-Patch"), Domain.JSONPatchContentType)
+    mockSvc.AssertExpectations(t)
+}
+
+func TestPatchUser_Controller_UnsupportedMediaType(t *testing.T) {
+    gin.SetMode(gin.TestMode)
+    mockSvc := new(MockServiceController)
+    ctrl := NewController(mockSvc)
+
+    req := httptest.NewRequest(http.MethodPatch, "/users/5", strings.NewReader("maneja=false"))
+    req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+    w := httptest.NewRecorder()
+    c, _ := gin.CreateTestContext(w)
+    c.Params = gin.Params{{Key: "id", Value: "5"}}
+    c.Request = req
+
+    ctrl.PatchUser(c)
+
+    assert.Equal(t, http.StatusUnsupportedMediaType, w.Code)
+    assert.NotEmpty(t, w.Header().Get("Accept-Patch"))
+    mockSvc.AssertNotCalled(t, "PatchUser", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestUpdateUser_Controller_IfMatchRequired(t *testing.T) {
+    gin.SetMode(gin.TestMode)
+    mockSvc := new(MockServiceController)
+    ctrl := NewController(mockSvc)
+
+    body, _ := json.Marshal(Domain.UpdateUserRequest{Id: 3, Nombre: "upd", Genero: "M"})
+    req := httptest.NewRequest(http.MethodPut, "/users", bytes.NewReader(body))
+    req.Header.Set("Content-Type", "application/json")
+    w := httptest.NewRecorder()
+    c, _ := gin.CreateTestContext(w)
+    c.Request = req
+
+    ctrl.UpdateUser(c)
+
+    assert.Equal(t, http.StatusPreconditionRequired, w.Code)
+    mockSvc.AssertNotCalled(t, "UpdateUser", mock.Anything, mock.Anything)
+}
+
+func TestUpdateUser_Controller_StaleVersion(t *testing.T) {
+    gin.SetMode(gin.TestMode)
+    mockSvc := new(MockServiceController)
+    ctrl := NewController(mockSvc)
+
+    in := Domain.UpdateUserRequest{Id: 3, Nombre: "upd", Genero: "M"}
+    mockSvc.On("UpdateUser", in, 1).Return(Domain.UserResponse{}, fmt.Errorf("user 3: %w", Domain.ErrPreconditionFailed))
+
+    body, _ := json.Marshal(in)
+    req := httptest.NewRequest(http.MethodPut, "/users", bytes.NewReader(body))
+    req.Header.Set("Content-Type", "application/json")
+    req.Header.Set("If-Match", `"1"`)
+    w := httptest.NewRecorder()
+    c, _ := gin.CreateTestContext(w)
+    c.Request = req
+
+    ctrl.UpdateUser(c)
+
+    assert.Equal(t, http.StatusPreconditionFailed, w.Code)
+}
+
+func TestPatchUser_Controller_WeakETagRejected(t *testing.T) {
+    gin.SetMode(gin.TestMode)
+    mockSvc := new(MockServiceController)
+    ctrl := NewController(mockSvc)
+
+    req := httptest.NewRequest(http.MethodPatch, "/users/5", strings.NewReader(`{}`))
+    req.Header.Set("Content-Type", Domain.MergePatchContentType)
+    req.Header.Set("If-Match", `W/"2"`)
+    w := httptest.NewRecorder()
+    c, _ := gin.CreateTestContext(w)
+    c.Params = gin.Params{{Key: "id", Value: "5"}}
+    c.Request = req
+
+    ctrl.PatchUser(c)
+
+    assert.Equal(t, http.StatusPreconditionFailed, w.Code)
+    mockSvc.AssertNotCalled(t, "PatchUser", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestGetUserById_Controller_IfNoneMatch(t *testing.T) {
+    gin.SetMode(gin.TestMode)
+    mockSvc := new(MockServiceController)
+    ctrl := NewController(mockSvc)
+
+    modified := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+    mockSvc.On("GetUserById", 9).Return(Domain.UserResponse{Id: 9, Version: 4, UpdatedAt: modified}, nil)
+
+    req := httptest.NewRequest(http.MethodGet, "/users/9", nil)
+    req.Header.Set("If-None-Match", `"3-public", "4-public"`)
+    w := httptest.NewRecorder()
+    c, _ := gin.CreateTestContext(w)
+    c.Params = gin.Params{{Key: "id", Value: "9"}}
+    c.Request = req
+
+    ctrl.GetUserById(c)
+    c.Writer.WriteHeaderNow()
+
+    assert.Equal(t, http.StatusNotModified, w.Code)
+    assert.Empty(t, w.Body.String())
+    assert.Equal(t, `"4-public"`, w.Header().Get("ETag"))
+    assert.Equal(t, "Sat, 01 Mar 2025 12:00:00 GMT", w.Header().Get("Last-Modified"))
+    assert.Equal(t, "private, no-cache", w.Header().Get("Cache-Control"))
+}
+
+func TestGetUserById_Controller_IfModifiedSince(t *testing.T) {
+    gin.SetMode(gin.TestMode)
+    modified := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+
+    cases := map[string]int{
+        "Sat, 01 Mar 2025 12:00:00 GMT": http.StatusNotModified,
+        "Sat, 01 Mar 2025 11:59:59 GMT": http.StatusOK,
+        "no es una fecha":               http.StatusOK,
+    }
+    for since, want := range cases {
+        mockSvc := new(MockServiceController)
+        ctrl := NewController(mockSvc)
+        mockSvc.On("GetUserById", 9).Return(Domain.UserResponse{Id: 9, Version: 4, UpdatedAt: modified}, nil)
+
+        req := httptest.NewRequest(http.MethodGet, "/users/9", nil)
+        req.Header.Set("If-Modified-Since", since)
+        w := httptest.NewRecorder()
+        c, _ := gin.CreateTestContext(w)
+        c.Params = gin.Params{{Key: "id", Value: "9"}}
+        c.Request = req
+
+        ctrl.GetUserById(c)
+        c.Writer.WriteHeaderNow()
+
+        assert.Equal(t, want, w.Code, since)
+    }
+}
+
+func TestGetAllUsers_Controller_NotModifiedSkipsList(t *testing.T) {
+    gin.SetMode(gin.TestMode)
+    mockSvc := new(MockServiceController)
+    ctrl := NewController(mockSvc)
+
+    mockSvc.On("GetUsersStamp").Return(Domain.UsersStamp{Count: 3, VersionSum: 7, MaxId: 4}, nil)
+
+    req := httptest.NewRequest(http.MethodGet, "/users/all", nil)
+    req.Header.Set("If-None-Match", `W/"3-7-4-0"`)
+    w := httptest.NewRecorder()
+    c, _ := gin.CreateTestContext(w)
+    c.Request = req
+
+    ctrl.GetAllUsers(c)
+    c.Writer.WriteHeaderNow()
+
+    assert.Equal(t, http.StatusNotModified, w.Code)
+    assert.Equal(t, "Authorization", w.Header().Get("Vary"))
+    mockSvc.AssertNotCalled(t, "GetAllUsers")
+}
+
+func TestGetUserById_Controller_PassesVisibility(t *testing.T) {
+    gin.SetMode(gin.TestMode)
+    mockSvc := new(MockServiceController)
+    ctrl := NewController(mockSvc)
+
+    mockSvc.On("GetUserById", 9).Return(Domain.UserResponse{Id: 9, Version: 1}, nil)
+
+    req := httptest.NewRequest(http.MethodGet, "/users/9?fields=nombre,diabetico", nil)
+    w := httptest.NewRecorder()
+    c, _ := gin.CreateTestContext(w)
+    c.Params = gin.Params{{Key: "id", Value: "9"}}
+    c.Request = req
+    c.Set("userID", float64(4))
+    c.Set("admin", false)
+    c.Set("role", Domain.RoleClinician)
+
+    ctrl.GetUserById(c)
+
+    assert.Equal(t, http.StatusOK, w.Code)
+    assert.Equal(t, "Authorization", w.Header().Get("Vary"))
+    assert.Equal(t, `"1-clinician-nombre.diabetico"`, w.Header().Get("ETag"))
+    assert.Equal(t, Domain.Visibility{
+        Viewer: Domain.Viewer{UserId: 4, Role: Domain.RoleClinician},
+        Fields: []string{"nombre", "diabetico"},
+    }, mockSvc.visibility)
+}
+
+func TestUserETag_DependsOnRepresentation(t *testing.T) {
+    admin := Domain.Visibility{Viewer: Domain.Viewer{UserId: 1, Admin: true}}
+    self := Domain.Visibility{Viewer: Domain.Viewer{UserId: 9}}
+    clinician := Domain.Visibility{Viewer: Domain.Viewer{UserId: 4, Role: Domain.RoleClinician}}
+
+    assert.Equal(t, `"3"`, userETag(9, 3, admin))
+    assert.Equal(t, `"3"`, userETag(9, 3, self))
+    assert.Equal(t, `"3-clinician"`, userETag(9, 3, clinician))
+    admin.Fields = []string{"estado", "nombre", "estado"}
+    assert.Equal(t, `"3-admin-nombre.estado"`, userETag(9, 3, admin))
+
+    // If-Match solo compara la version.
+    version, err := parseETag(`"3-admin-nombre.estado"`)
+    assert.NoError(t, err)
+    assert.Equal(t, 3, version)
+}
+
+func TestCollectionETag_ChangesAfterDeleteInsertAndUpdate(t *testing.T) {
+    modified := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+    before := Domain.UsersStamp{Count: 2, VersionSum: 3, MaxId: 2, LastModified: modified}
+    // Baja del usuario 2 (version 2), alta del 3 y una modificacion del 1.
+    after := Domain.UsersStamp{Count: 2, VersionSum: 3, MaxId: 3, LastModified: modified}
+
+    assert.NotEqual(t, collectionETag(before), collectionETag(after))
+}
+
+func TestGetUserById_Controller_UnknownField(t *testing.T) {
+    gin.SetMode(gin.TestMode)
+    ctrl := NewController(services.NewService(clientUsers.NewMemory()))
+
+    req := httptest.NewRequest(http.MethodGet, "/users/1?fields=nombre,password", nil)
+    req.Header.Set("Accept-Language", "en")
+    w := httptest.NewRecorder()
+    c, _ := gin.CreateTestContext(w)
+    c.Params = gin.Params{{Key: "id", Value: "1"}}
+    c.Request = req
+
+    ctrl.GetUserById(c)
+
+    assert.Equal(t, http.StatusBadRequest, w.Code)
+    var got problem.Problem
+    assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &got))
+    assert.Len(t, got.Errors, 1)
+    assert.Equal(t, "fields", got.Errors[0].Field)
+}
+
+func TestPatchUser_Controller_OtherUserForbidden(t *testing.T) {
+    gin.SetMode(gin.TestMode)
+    repo := clientUsers.NewMemory()
+    ctrl := NewController(services.NewService(repo))
+    _, err := services.NewService(repo).InsertUsuario(context.Background(), Domain.CreateUserRequest{Nombre: "paciente", Password: "supersecreto", Genero: "F"}, "")
+    assert.NoError(t, err)
+
+    req := httptest.NewRequest(http.MethodPatch, "/users/1", strings.NewReader(`[{"op": "test", "path": "/diabetico", "value": true}]`))
+    req.Header.Set("Content-Type", Domain.JSONPatchContentType)
+    req.Header.Set("If-Match", "*")
+    w := httptest.NewRecorder()
+    c, _ := gin.CreateTestContext(w)
+    c.Params = gin.Params{{Key: "id", Value: "1"}}
+    c.Request = req
+    c.Set("userID", float64(2))
+
+    ctrl.PatchUser(c)
+
+    assert.Equal(t, http.StatusForbidden, w.Code)
+}
+
+func TestPatchUser_Controller_ClinicianForbidden(t *testing.T) {
+    gin.SetMode(gin.TestMode)
+    repo := clientUsers.NewMemory()
+    ctrl := NewController(services.NewService(repo))
+    _, err := services.NewService(repo).InsertUsuario(context.Background(), Domain.CreateUserRequest{Nombre: "paciente", Password: "supersecreto", Genero: "F"}, "")
+    assert.NoError(t, err)
+
+    req := httptest.NewRequest(http.MethodPatch, "/users/1", strings.NewReader(`{"enfermedades": "asma"}`))
+    req.Header.Set("Content-Type", Domain.MergePatchContentType)
+    req.Header.Set("If-Match", "*")
+    w := httptest.NewRecorder()
+    c, _ := gin.CreateTestContext(w)
+    c.Params = gin.Params{{Key: "id", Value: "1"}}
+    c.Request = req
+    c.Set("userID", float64(2))
+    c.Set("role", Domain.RoleClinician)
+
+    ctrl.PatchUser(c)
+
+    assert.Equal(t, http.StatusForbidden, w.Code)
+    user, err := repo.GetUserById(context.Background(), 1)
+    assert.NoError(t, err)
+    assert.Empty(t, user.Enfermedades)
+    assert.Equal(t, 1, user.Version)
 }
@@ -0,0 +1,40 @@
+package domain
+
+import "time"
+
+// ConsentText es el texto de consentimiento para el tratamiento de datos de
+// salud que el usuario acepta al registrarse.
+type ConsentText struct {
+	Version     int       `json:"version"`
+	Text        string    `json:"text"`
+	PublishedAt time.Time `json:"publishedAt"`
+}
+
+// ConsentStatus es el estado del consentimiento de un usuario. Un
+// consentimiento otorgado sigue valiendo aunque se publique un texto nuevo,
+// hasta que el usuario lo retire; Pending indica que hay que volver a
+// pedirlo.
+type ConsentStatus struct {
+	UserId int `json:"userId"`
+	// Version es la version del ultimo registro; 0 si el usuario nunca
+	// otorgo ni retiro el consentimiento.
+	Version    int       `json:"version"`
+	Granted    bool      `json:"granted"`
+	RecordedAt time.Time `json:"recordedAt"`
+	// Pending es true si el usuario no otorgo la version vigente, porque
+	// nunca lo hizo, lo retiro o se publico una version nueva.
+	Pending bool `json:"pending"`
+	// Current es el texto vigente que hay que mostrarle si Pending.
+	Current *ConsentText `json:"current,omitempty"`
+}
+
+// ConsentGrant es el cuerpo de PUT /users/:id/consent: la version del texto
+// que el usuario acepta.
+type ConsentGrant struct {
+	Version int `json:"version" validate:"required,min=1"`
+}
+
+// Validate controla el otorgamiento.
+func (r ConsentGrant) Validate() error {
+	return toValidationError(validate.Struct(r))
+}
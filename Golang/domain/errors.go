@@ -0,0 +1,23 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Errores tipados que atraviesan las capas. clients los produce, service los
+// envuelve agregando contexto y controller los traduce a codigos HTTP. Quien
+// los recibe debe compararlos con errors.Is, ya que suelen venir envueltos.
+var (
+	ErrNotFound     = errors.New("not found")
+	ErrConflict     = errors.New("conflict")
+	ErrValidation   = errors.New("validation failed")
+	ErrUnauthorized = errors.New("unauthorized")
+	ErrForbidden    = errors.New("forbidden")
+	// ErrPreconditionFailed indica que el recurso cambio desde que el
+	// cliente lo leyo (la version no coincide).
+	ErrPreconditionFailed = errors.New("precondition failed")
+	// ErrNoConsent indica que no se publico ningun texto de consentimiento.
+	// Es un ErrNotFound.
+	ErrNoConsent = fmt.Errorf("no consent version published: %w", ErrNotFound)
+)
@@ -0,0 +1,66 @@
+package domain
+
+import "strings"
+
+// Formatos de la exportacion masiva.
+const (
+	ExportCSV   = "csv"
+	ExportJSONL = "jsonl"
+	ExportXLSX  = "xlsx"
+)
+
+// ExportColumns son las columnas exportables, en el orden en que salen si no
+// se eligen otras. Los nombres son las claves JSON de UserResponse; la
+// contraseña nunca se exporta.
+var ExportColumns = []string{
+	"id", "nombre", "genero", "atributos", "maneja", "lentes", "diabetico",
+	"enfermedades", "admin", "estado", "version", "updatedAt",
+}
+
+// MedicalColumns son los datos de salud que ExportOptions.MaskMedical oculta.
+var MedicalColumns = []string{"lentes", "diabetico", "enfermedades"}
+
+// MaskedValue reemplaza el valor de una columna enmascarada, en todos los
+// formatos.
+const MaskedValue = "***"
+
+// ExportOptions controla una exportacion. Filter es el mismo del listado de
+// administracion.
+type ExportOptions struct {
+	Format string
+	// Columns elige y ordena las columnas. Vacio exporta ExportColumns.
+	Columns     []string
+	MaskMedical bool
+	Filter      UserFilter
+}
+
+// Validate controla el formato y las columnas pedidas. Devuelve todas las
+// reglas incumplidas juntas.
+func (o ExportOptions) Validate() error {
+	var violations []FieldViolation
+	switch o.Format {
+	case ExportCSV, ExportJSONL, ExportXLSX:
+	default:
+		violations = append(violations, FieldViolation{Field: "format", Rule: "oneof", Param: "csv jsonl xlsx"})
+	}
+
+	known := make(map[string]bool, len(ExportColumns))
+	for _, column := range ExportColumns {
+		known[column] = true
+	}
+	seen := make(map[string]bool, len(o.Columns))
+	for _, column := range o.Columns {
+		switch {
+		case !known[column]:
+			violations = append(violations, FieldViolation{Field: "columns", Rule: "oneof", Param: strings.Join(ExportColumns, " ")})
+		case seen[column]:
+			violations = append(violations, FieldViolation{Field: "columns", Rule: "unique", Param: column})
+		}
+		seen[column] = true
+	}
+
+	if len(violations) > 0 {
+		return &ValidationError{Violations: violations}
+	}
+	return nil
+}
@@ -0,0 +1,122 @@
+package domain
+
+import (
+	"strconv"
+	"time"
+)
+
+// Formatos de archivo aceptados por la importacion masiva. Las columnas del
+// CSV y las claves de cada linea JSONL son las de CreateUserRequest.
+const (
+	ImportCSV   = "csv"
+	ImportJSONL = "jsonl"
+)
+
+// Reglas propias de la importacion, ademas de las de validate.
+const (
+	// ImportRuleFormat: el valor o la linea no se pudo interpretar.
+	ImportRuleFormat = "format"
+	// ImportRuleDuplicate: el nombre se repite en el archivo; Param es la
+	// fila donde aparece primero.
+	ImportRuleDuplicate = "duplicate"
+	// ImportRuleExists: ya hay un usuario registrado con ese nombre.
+	ImportRuleExists = "exists"
+	// ImportRuleConflict: el nombre se registro mientras corria la
+	// importacion.
+	ImportRuleConflict = "conflict"
+)
+
+// ImportOptions controla una importacion.
+type ImportOptions struct {
+	Format string
+	// DryRun valida el archivo completo sin escribir nada.
+	DryRun bool
+	// SkipInvalid importa las filas validas aunque otras tengan errores. Sin
+	// este flag un solo error cancela la importacion entera.
+	SkipInvalid bool
+}
+
+// ImportRowError es un problema de una fila del archivo. Row es el numero de
+// linea en el archivo, contando el encabezado del CSV.
+type ImportRowError struct {
+	Row     int    `json:"row"`
+	Nombre  string `json:"nombre,omitempty"`
+	Field   string `json:"field,omitempty"`
+	Rule    string `json:"rule"`
+	Param   string `json:"param,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+// ImportReport es el resultado de una importacion o de su dry run.
+type ImportReport struct {
+	Format   string `json:"format"`
+	DryRun   bool   `json:"dryRun"`
+	Rows     int    `json:"rows"`
+	Valid    int    `json:"valid"`
+	Invalid  int    `json:"invalid"`
+	Imported int    `json:"imported"`
+	// Aborted indica que habia filas con errores y, sin SkipInvalid, no se
+	// escribio ninguna.
+	Aborted bool             `json:"aborted"`
+	Errors  []ImportRowError `json:"errors"`
+	// ErrorsTruncated indica que Errors se corto y hay mas problemas que los
+	// listados.
+	ErrorsTruncated bool `json:"errorsTruncated,omitempty"`
+}
+
+// Estados de una importacion asincronica.
+const (
+	ImportQueued    = "queued"
+	ImportRunning   = "running"
+	ImportSucceeded = "succeeded"
+	ImportFailed    = "failed"
+)
+
+// ImportJob es una importacion que corre en segundo plano. Report esta
+// presente cuando termina, incluso si fallo a mitad de camino. Un trabajo
+// succeeded puede no haber importado nada: Report.Aborted lo indica.
+type ImportJob struct {
+	Id         string        `json:"id"`
+	Status     string        `json:"status"`
+	CreatedAt  time.Time     `json:"createdAt"`
+	FinishedAt *time.Time    `json:"finishedAt,omitempty"`
+	Report     *ImportReport `json:"report,omitempty"`
+	Error      string        `json:"error,omitempty"`
+}
+
+// Motivos por los que no se puede leer un archivo de importacion.
+const (
+	ImportFileEmpty          = "empty"
+	ImportFileMalformed      = "malformed"
+	ImportFileUnknownColumn  = "unknown_column"
+	ImportFileRepeatedColumn = "repeated_column"
+	ImportFileMissingColumn  = "missing_column"
+	ImportFileTooManyRows    = "too_many_rows"
+	ImportFileUnsupported    = "unsupported_format"
+)
+
+// ImportFileError es un problema que invalida el archivo entero, a
+// diferencia de ImportRowError. errors.Is(err, ErrValidation) es verdadero
+// para este error.
+type ImportFileError struct {
+	Reason string
+	// Param es la columna, el limite de filas o el formato, segun Reason.
+	Param string
+	// Line es la linea del problema, si se conoce.
+	Line int
+}
+
+func (e *ImportFileError) Error() string {
+	message := "import file: " + e.Reason
+	if e.Param != "" {
+		message += " " + strconv.Quote(e.Param)
+	}
+	if e.Line > 0 {
+		message += " at line " + strconv.Itoa(e.Line)
+	}
+	return message
+}
+
+func (e *ImportFileError) Unwrap() error {
+	return ErrValidation
+}
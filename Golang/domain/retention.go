@@ -0,0 +1,62 @@
+package domain
+
+import "time"
+
+// Categorias de datos con plazo de retencion.
+const (
+	// RetentionInactiveAccounts son las cuentas que siguen desactivadas
+	// despues del plazo. No se borran, se anonimizan: se quitan
+	// el nombre, la contraseña y los datos de salud y queda la fila para
+	// no romper las referencias.
+	RetentionInactiveAccounts = "inactive_accounts"
+	// RetentionLoginHistory es el historial de intentos de login. Se borra.
+	RetentionLoginHistory = "login_history"
+	// RetentionConsentRecords son los registros de consentimiento. Se
+	// conservan como prueba de lo que acepto cada usuario, pero se anonimiza
+	// la IP desde la que se hizo el pedido.
+	RetentionConsentRecords = "consent_records"
+)
+
+// Acciones de retencion.
+const (
+	RetentionAnonymize = "anonymize"
+	RetentionPurge     = "purge"
+)
+
+// RetentionPolicy es cuanto se conserva cada categoria. Cero conserva los
+// datos para siempre.
+//
+// Los cambios administrativos solo van al log del proceso y el servicio no
+// guarda una tabla de auditoria, asi que su retencion es la del destino de
+// los logs.
+type RetentionPolicy struct {
+	InactiveAccounts time.Duration
+	LoginHistory     time.Duration
+	ConsentRecords   time.Duration
+}
+
+// RetentionReport es el resultado de una pasada de retencion.
+type RetentionReport struct {
+	// DryRun indica que solo se contaron los registros vencidos, sin
+	// modificarlos.
+	DryRun bool `json:"dryRun"`
+	// Skipped indica que la pasada no corrio porque otra replica tenia el
+	// lock.
+	Skipped    bool                      `json:"skipped"`
+	StartedAt  time.Time                 `json:"startedAt"`
+	Categories []RetentionCategoryReport `json:"categories"`
+}
+
+// RetentionCategoryReport es lo que hizo una pasada con una categoria.
+type RetentionCategoryReport struct {
+	Category string `json:"category"`
+	Action   string `json:"action"`
+	// Cutoff es la fecha limite: se tratan los registros anteriores.
+	Cutoff time.Time `json:"cutoff"`
+	// Records son los registros tratados, o los que se tratarian en una
+	// pasada de prueba.
+	Records int `json:"records"`
+	// Failed son los registros que no se pudieron tratar; se reintentan en
+	// la pasada siguiente.
+	Failed int `json:"failed"`
+}
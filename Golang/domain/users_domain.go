@@ -1,9 +1,115 @@
 package domain
 
-type UserData struct {
+import (
+	"strings"
+	"time"
+)
+
+// Generos aceptados: masculino, femenino y no binario/otro.
+const (
+	GeneroMasculino = "M"
+	GeneroFemenino  = "F"
+	GeneroOtro      = "X"
+)
+
+// generoAliases son, en minusculas, los valores de texto libre que la API
+// aceptaba en genero antes de validarlo y que el frontend todavia puede
+// enviar.
+var generoAliases = map[string]string{
+	"m":          GeneroMasculino,
+	"masculino":  GeneroMasculino,
+	"hombre":     GeneroMasculino,
+	"male":       GeneroMasculino,
+	"f":          GeneroFemenino,
+	"femenino":   GeneroFemenino,
+	"mujer":      GeneroFemenino,
+	"female":     GeneroFemenino,
+	"x":          GeneroOtro,
+	"otro":       GeneroOtro,
+	"no binario": GeneroOtro,
+	"other":      GeneroOtro,
+}
+
+// NormalizeGenero devuelve el codigo de genero de value, que puede ser uno
+// de los valores anteriores a la validacion ("Masculino", "mujer"...). Lo
+// que no reconoce lo devuelve igual, para que la validacion lo rechace.
+func NormalizeGenero(value string) string {
+	if code, ok := generoAliases[strings.ToLower(strings.TrimSpace(value))]; ok {
+		return code
+	}
+	return value
+}
+
+// IsGenero indica si value es uno de los codigos Genero*.
+func IsGenero(value string) bool {
+	return value == GeneroMasculino || value == GeneroFemenino || value == GeneroOtro
+}
+
+// Los tipos de entrada y de salida estan separados a proposito: ninguna
+// respuesta tiene campo de contraseña y ninguna solicitud publica puede
+// cambiar el flag de administrador.
+
+// CreateUserRequest es el cuerpo de POST /users.
+type CreateUserRequest struct {
+	Nombre       string `json:"nombre" validate:"notblank,max=600"`
+	Password     string `json:"password" validate:"required,min=6,max=128"`
+	Genero       string `json:"genero" validate:"required,oneof=M F X"`
+	Atributos    string `json:"atributos" validate:"max=600"`
+	Maneja       bool   `json:"maneja"`
+	Lentes       bool   `json:"lentes"`
+	Diabetico    bool   `json:"diabetico"`
+	Enfermedades string `json:"enfermedades" validate:"max=600"`
+	// Consentimiento es la version del texto de consentimiento que el
+	// usuario acepto en el formulario; tiene que ser la vigente.
+	Consentimiento int `json:"consentimiento" validate:"min=0"`
+}
+
+// UpdateUserRequest es el cuerpo de PUT /users. La contraseña y el flag de
+// administrador no se modifican por esta via.
+type UpdateUserRequest struct {
+	Id           int    `json:"id" validate:"required,min=1"`
+	Nombre       string `json:"nombre" validate:"notblank,max=600"`
+	Genero       string `json:"genero" validate:"required,oneof=M F X"`
+	Atributos    string `json:"atributos" validate:"max=600"`
+	Maneja       bool   `json:"maneja"`
+	Lentes       bool   `json:"lentes"`
+	Diabetico    bool   `json:"diabetico"`
+	Enfermedades string `json:"enfermedades" validate:"max=600"`
+	Estado       bool   `json:"estado"`
+}
+
+// PasswordReset es la contraseña nueva que fija un administrador. Tiene las
+// mismas reglas que la del alta.
+type PasswordReset struct {
+	Password string `json:"password" validate:"required,min=6,max=128"`
+}
+
+// UserFilter filtra el listado de usuarios de la herramienta de
+// administracion. Los punteros nil no filtran; Nombre busca una subcadena
+// sin distinguir mayusculas.
+type UserFilter struct {
+	Admin     *bool
+	Estado    *bool
+	Diabetico *bool
+	Nombre    string
+}
+
+// LoginRequest es el cuerpo de POST /users/login.
+type LoginRequest struct {
+	Nombre   string `json:"nombre"`
+	Password string `json:"password"`
+}
+
+// UserLookup es el cuerpo de GET /users.
+type UserLookup struct {
+	Nombre string `json:"nombre"`
+}
+
+// UserResponse es la vista de un usuario, sin la contraseña. Redact la
+// recorta segun la politica de visibilidad (ver Visibility).
+type UserResponse struct {
 	Id           int    `json:"id"`
 	Nombre       string `json:"nombre"`
-	Password     string `json: "password"`
 	Genero       string `json:"genero"`
 	Atributos    string `json:"atributos"`
 	Maneja       bool   `json:"maneja"`
@@ -12,10 +118,41 @@ type UserData struct {
 	Enfermedades string `json:"enfermedades"`
 	Admin        bool   `json:"admin"`
 	Estado       bool   `json:"estado"`
+	// Version es la misma que viaja en el ETag; se envia en If-Match al
+	// modificar el usuario.
+	Version   int       `json:"version"`
+	UpdatedAt time.Time `json:"updatedAt"`
+
+	// fields son los campos que se serializan si la respuesta paso por
+	// Redact; nil los incluye a todos.
+	fields []string
+}
+
+// UsersStamp identifica el estado del listado de usuarios; sirve como
+// validador de cache de GET /users/all sin leer todas las filas.
+type UsersStamp struct {
+	Count        int
+	VersionSum   int
+	MaxId        int
+	LastModified time.Time
+}
+
+// PublicProfile es lo que cualquier usuario autenticado puede ver de otro:
+// sin datos medicos ni flags de administracion.
+type PublicProfile struct {
+	Id     int    `json:"id"`
+	Nombre string `json:"nombre"`
+	Estado bool   `json:"estado"`
 }
 
 type LoginData struct {
-	Token  string `json: "token"`
-	IdU    int    `json: "idu"`
+	Token  string `json:"Token"`
+	IdU    int    `json:"IdU"`
 	AdminU bool   `json:"adminu"`
 }
+
+// Formatos aceptados por PATCH /users/:id.
+const (
+	MergePatchContentType = "application/merge-patch+json"
+	JSONPatchContentType  = "application/json-patch+json"
+)
@@ -0,0 +1,101 @@
+package domain
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// Las reglas de las solicitudes se declaran con el tag `validate`. Los largos
+// maximos coinciden con las columnas de model.User.
+var validate = newValidator()
+
+func newValidator() *validator.Validate {
+	v := validator.New()
+	v.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name := strings.SplitN(field.Tag.Get("json"), ",", 2)[0]
+		if name == "-" {
+			return ""
+		}
+		return name
+	})
+	v.RegisterValidation("notblank", func(fl validator.FieldLevel) bool {
+		return strings.TrimSpace(fl.Field().String()) != ""
+	})
+	return v
+}
+
+// FieldViolation es una regla incumplida por un campo.
+type FieldViolation struct {
+	Field string
+	Rule  string
+	Param string
+}
+
+// ValidationError agrupa todas las reglas incumplidas de una solicitud.
+// errors.Is(err, ErrValidation) es verdadero para este error.
+type ValidationError struct {
+	Violations []FieldViolation
+}
+
+func (e *ValidationError) Error() string {
+	parts := make([]string, 0, len(e.Violations))
+	for _, v := range e.Violations {
+		parts = append(parts, fmt.Sprintf("%s: %s", v.Field, v.Rule))
+	}
+	return "validation failed: " + strings.Join(parts, ", ")
+}
+
+func (e *ValidationError) Unwrap() error {
+	return ErrValidation
+}
+
+// Normalize lleva los valores heredados a su forma canonica (ver
+// NormalizeGenero). Se llama antes de Validate.
+func (r *CreateUserRequest) Normalize() {
+	r.Genero = NormalizeGenero(r.Genero)
+}
+
+// Validate controla los datos de un alta. Devuelve todas las reglas
+// incumplidas juntas.
+func (r CreateUserRequest) Validate() error {
+	return toValidationError(validate.Struct(r))
+}
+
+// Normalize lleva los valores heredados a su forma canonica (ver
+// NormalizeGenero). Se llama antes de Validate.
+func (r *UpdateUserRequest) Normalize() {
+	r.Genero = NormalizeGenero(r.Genero)
+}
+
+// Validate controla los datos de una modificacion.
+func (r UpdateUserRequest) Validate() error {
+	return toValidationError(validate.Struct(r))
+}
+
+// Validate controla la contraseña nueva.
+func (r PasswordReset) Validate() error {
+	return toValidationError(validate.Struct(r))
+}
+
+func toValidationError(err error) error {
+	if err == nil {
+		return nil
+	}
+	fieldErrors, ok := err.(validator.ValidationErrors)
+	if !ok {
+		return fmt.Errorf("%v: %w", err, ErrValidation)
+	}
+
+	violations := make([]FieldViolation, 0, len(fieldErrors))
+	for _, fe := range fieldErrors {
+		violations = append(violations, FieldViolation{
+			Field: fe.Field(),
+			Rule:  fe.Tag(),
+			Param: fe.Param(),
+		})
+	}
+	return &ValidationError{Violations: violations}
+}
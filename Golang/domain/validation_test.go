@@ -0,0 +1,98 @@
+package domain
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func validUser() CreateUserRequest {
+	return CreateUserRequest{
+		Nombre:       "ana",
+		Password:     "secreto",
+		Genero:       GeneroFemenino,
+		Atributos:    "alta",
+		Enfermedades: "asma",
+	}
+}
+
+func rules(err error) map[string]string {
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) {
+		return nil
+	}
+	out := map[string]string{}
+	for _, v := range validationErr.Violations {
+		out[v.Field] = v.Rule
+	}
+	return out
+}
+
+func TestValidateCreate_OK(t *testing.T) {
+	assert.NoError(t, validUser().Validate())
+}
+
+func TestValidateCreate_ReportsEveryField(t *testing.T) {
+	u := CreateUserRequest{
+		Nombre:       strings.Repeat("n", 601),
+		Genero:       "hombre",
+		Enfermedades: strings.Repeat("e", 601),
+	}
+
+	err := u.Validate()
+
+	assert.ErrorIs(t, err, ErrValidation)
+	assert.Equal(t, map[string]string{
+		"nombre":       "max",
+		"password":     "required",
+		"genero":       "oneof",
+		"enfermedades": "max",
+	}, rules(err))
+}
+
+func TestValidateCreate_BlankNombre(t *testing.T) {
+	u := validUser()
+	u.Nombre = "   "
+
+	assert.Equal(t, map[string]string{"nombre": "notblank"}, rules(u.Validate()))
+}
+
+func TestValidateCreate_ShortPassword(t *testing.T) {
+	u := validUser()
+	u.Password = "123"
+
+	assert.Equal(t, map[string]string{"password": "min"}, rules(u.Validate()))
+}
+
+func TestValidateUpdate_RequiresId(t *testing.T) {
+	u := UpdateUserRequest{Nombre: "ana", Genero: GeneroFemenino}
+
+	assert.Equal(t, map[string]string{"id": "required"}, rules(u.Validate()))
+
+	u.Id = 4
+	assert.NoError(t, u.Validate())
+}
+
+func TestNormalize_LegacyGenero(t *testing.T) {
+	for value, want := range map[string]string{
+		"Masculino":  GeneroMasculino,
+		" mujer ":    GeneroFemenino,
+		"f":          GeneroFemenino,
+		"No binario": GeneroOtro,
+		"X":          GeneroOtro,
+		"":           "",
+		"Q":          "Q",
+	} {
+		u := validUser()
+		u.Genero = value
+		u.Normalize()
+		assert.Equal(t, want, u.Genero, value)
+	}
+
+	u := validUser()
+	u.Genero = "Femenino"
+	u.Normalize()
+	assert.NoError(t, u.Validate())
+}
@@ -0,0 +1,246 @@
+package domain
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+)
+
+// Perfiles de visibilidad: que campos de un usuario ve quien hace el pedido.
+const (
+	// ProfileSelf es el propio usuario: ve todos sus datos.
+	ProfileSelf = "self"
+	// ProfileAdmin es un administrador: ve todo de todos.
+	ProfileAdmin = "admin"
+	// ProfileClinician es personal de salud: ve los datos medicos pero no los
+	// flags de administracion.
+	ProfileClinician = "clinician"
+	// ProfilePublic es cualquier otro usuario autenticado o servicio externo:
+	// lo mismo que PublicProfile.
+	ProfilePublic = "public"
+)
+
+// RoleClinician es el valor del claim "role" que identifica a personal de
+// salud. El login no lo emite; lo firman las herramientas que dan acceso a
+// profesionales.
+const RoleClinician = "clinician"
+
+// UserFields son las claves JSON de UserResponse, en el orden en que se
+// serializan.
+var UserFields = []string{
+	"id", "nombre", "genero", "atributos", "maneja", "lentes", "diabetico",
+	"enfermedades", "admin", "estado", "version", "updatedAt",
+}
+
+// EditableFields son los campos de UpdateUserRequest, lo que PUT y PATCH
+// pueden cambiar.
+var EditableFields = []string{
+	"id", "nombre", "genero", "atributos", "maneja", "lentes", "diabetico",
+	"enfermedades", "estado",
+}
+
+// ProfileFields son los campos visibles de cada perfil.
+var ProfileFields = map[string][]string{
+	ProfileSelf:  UserFields,
+	ProfileAdmin: UserFields,
+	ProfileClinician: {
+		"id", "nombre", "genero", "atributos", "maneja", "lentes", "diabetico",
+		"enfermedades", "estado", "version", "updatedAt",
+	},
+	ProfilePublic: {"id", "nombre", "estado"},
+}
+
+// Viewer es quien hace el pedido, segun su token.
+type Viewer struct {
+	UserId int
+	Admin  bool
+	Role   string
+}
+
+// Profile devuelve el perfil con el que el viewer ve al usuario userId.
+func (v Viewer) Profile(userId int) string {
+	switch {
+	case v.Admin:
+		return ProfileAdmin
+	case v.UserId != 0 && v.UserId == userId:
+		return ProfileSelf
+	case v.Role == RoleClinician:
+		return ProfileClinician
+	default:
+		return ProfilePublic
+	}
+}
+
+// CanWrite indica si el viewer puede modificar al usuario userId: solo el
+// propio usuario o un administrador. No depende de ProfileFields; que un
+// perfil vea todos los datos, como el de personal de salud, no lo habilita
+// a cambiarlos.
+func (v Viewer) CanWrite(userId int) bool {
+	return v.Admin || (v.UserId != 0 && v.UserId == userId)
+}
+
+// Visibility es como se muestran los usuarios de una respuesta: el perfil
+// sale del Viewer y Fields (?fields=) recorta todavia mas. Un campo pedido
+// que el perfil no permite se omite, igual que en un listado donde el
+// perfil cambia de fila en fila.
+type Visibility struct {
+	Viewer Viewer
+	// Fields son los campos pedidos; vacio pide todos los del perfil.
+	Fields []string
+}
+
+// Validate controla que los campos pedidos existan y no se repitan.
+func (v Visibility) Validate() error {
+	known := make(map[string]bool, len(UserFields))
+	for _, field := range UserFields {
+		known[field] = true
+	}
+	var violations []FieldViolation
+	seen := make(map[string]bool, len(v.Fields))
+	for _, field := range v.Fields {
+		switch {
+		case !known[field]:
+			violations = append(violations, FieldViolation{Field: "fields", Rule: "oneof", Param: strings.Join(UserFields, " ")})
+		case seen[field]:
+			violations = append(violations, FieldViolation{Field: "fields", Rule: "unique", Param: field})
+		}
+		seen[field] = true
+	}
+	if len(violations) > 0 {
+		return &ValidationError{Violations: violations}
+	}
+	return nil
+}
+
+// VisibleFields devuelve los campos del usuario userId que se muestran, en
+// el orden de UserFields.
+func (v Visibility) VisibleFields(userId int) []string {
+	requested := make(map[string]bool, len(v.Fields))
+	for _, field := range v.Fields {
+		requested[field] = true
+	}
+	allowed := ProfileFields[v.Viewer.Profile(userId)]
+	visible := make([]string, 0, len(allowed))
+	for _, field := range allowed {
+		if len(requested) == 0 || requested[field] {
+			visible = append(visible, field)
+		}
+	}
+	return visible
+}
+
+// Redact devuelve una copia de u con los campos que no estan en fields en
+// cero, que al serializarse solo incluye fields. Version y UpdatedAt se
+// conservan aunque no se muestren porque de ellos salen ETag y
+// Last-Modified. Con todos los campos devuelve u sin cambios.
+func (u UserResponse) Redact(fields []string) UserResponse {
+	visible := make(map[string]bool, len(fields))
+	for _, field := range fields {
+		visible[field] = true
+	}
+	if sameFields(fields, UserFields) {
+		return u
+	}
+	if fields == nil {
+		fields = []string{}
+	}
+	redacted := UserResponse{Id: u.Id, Version: u.Version, UpdatedAt: u.UpdatedAt, fields: fields}
+	for _, field := range fields {
+		redacted.set(field, u)
+	}
+	if !visible["id"] {
+		redacted.Id = 0
+	}
+	return redacted
+}
+
+func sameFields(a []string, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// set copia el campo field de from.
+func (u *UserResponse) set(field string, from UserResponse) {
+	switch field {
+	case "nombre":
+		u.Nombre = from.Nombre
+	case "genero":
+		u.Genero = from.Genero
+	case "atributos":
+		u.Atributos = from.Atributos
+	case "maneja":
+		u.Maneja = from.Maneja
+	case "lentes":
+		u.Lentes = from.Lentes
+	case "diabetico":
+		u.Diabetico = from.Diabetico
+	case "enfermedades":
+		u.Enfermedades = from.Enfermedades
+	case "admin":
+		u.Admin = from.Admin
+	case "estado":
+		u.Estado = from.Estado
+	}
+}
+
+// value devuelve el valor del campo field.
+func (u UserResponse) value(field string) interface{} {
+	switch field {
+	case "id":
+		return u.Id
+	case "nombre":
+		return u.Nombre
+	case "genero":
+		return u.Genero
+	case "atributos":
+		return u.Atributos
+	case "maneja":
+		return u.Maneja
+	case "lentes":
+		return u.Lentes
+	case "diabetico":
+		return u.Diabetico
+	case "enfermedades":
+		return u.Enfermedades
+	case "admin":
+		return u.Admin
+	case "estado":
+		return u.Estado
+	case "version":
+		return u.Version
+	case "updatedAt":
+		return u.UpdatedAt
+	}
+	return nil
+}
+
+// MarshalJSON serializa todos los campos, o solo los de Redact.
+func (u UserResponse) MarshalJSON() ([]byte, error) {
+	type plain UserResponse
+	if u.fields == nil {
+		return json.Marshal(plain(u))
+	}
+
+	var out bytes.Buffer
+	out.WriteByte('{')
+	for i, field := range u.fields {
+		value, err := json.Marshal(u.value(field))
+		if err != nil {
+			return nil, err
+		}
+		if i > 0 {
+			out.WriteByte(',')
+		}
+		out.WriteString(`"` + field + `":`)
+		out.Write(value)
+	}
+	out.WriteByte('}')
+	return out.Bytes(), nil
+}
@@ -0,0 +1,62 @@
+package domain
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestViewer_Profile(t *testing.T) {
+	assert.Equal(t, ProfileAdmin, Viewer{UserId: 3, Admin: true}.Profile(3))
+	assert.Equal(t, ProfileSelf, Viewer{UserId: 3, Role: RoleClinician}.Profile(3))
+	assert.Equal(t, ProfileClinician, Viewer{UserId: 4, Role: RoleClinician}.Profile(3))
+	assert.Equal(t, ProfilePublic, Viewer{UserId: 4}.Profile(3))
+	// Sin token no hay id: nunca coincide con un usuario.
+	assert.Equal(t, ProfilePublic, Viewer{}.Profile(0))
+}
+
+func TestViewer_CanWrite(t *testing.T) {
+	assert.True(t, Viewer{UserId: 1, Admin: true}.CanWrite(3))
+	assert.True(t, Viewer{UserId: 3}.CanWrite(3))
+	assert.False(t, Viewer{UserId: 4, Role: RoleClinician}.CanWrite(3))
+	assert.False(t, Viewer{UserId: 4}.CanWrite(3))
+	assert.False(t, Viewer{}.CanWrite(0))
+}
+
+func TestVisibility_VisibleFields(t *testing.T) {
+	vis := Visibility{Viewer: Viewer{UserId: 3}, Fields: []string{"admin", "nombre"}}
+	assert.Equal(t, []string{"nombre", "admin"}, vis.VisibleFields(3))
+	assert.Equal(t, []string{"nombre"}, vis.VisibleFields(4))
+	assert.Equal(t, UserFields, Visibility{Viewer: Viewer{Admin: true}}.VisibleFields(4))
+}
+
+func TestUserResponse_Redact(t *testing.T) {
+	updated := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	user := UserResponse{Id: 3, Nombre: "ana", Diabetico: true, Enfermedades: "asma", Admin: true, Version: 2, UpdatedAt: updated}
+
+	assert.Equal(t, user, user.Redact(UserFields))
+
+	redacted := user.Redact([]string{"nombre", "diabetico"})
+	assert.Zero(t, redacted.Id)
+	assert.Empty(t, redacted.Enfermedades)
+	assert.False(t, redacted.Admin)
+	assert.Equal(t, 2, redacted.Version)
+	assert.Equal(t, updated, redacted.UpdatedAt)
+	data, err := json.Marshal(redacted)
+	require.NoError(t, err)
+	assert.Equal(t, `{"nombre":"ana","diabetico":true}`, string(data))
+
+	data, err = json.Marshal(user.Redact(nil))
+	require.NoError(t, err)
+	assert.Equal(t, `{}`, string(data))
+}
+
+func TestVisibility_Validate(t *testing.T) {
+	assert.NoError(t, Visibility{}.Validate())
+	assert.NoError(t, Visibility{Fields: []string{"id", "updatedAt"}}.Validate())
+	assert.Equal(t, map[string]string{"fields": "oneof"}, rules(Visibility{Fields: []string{"password"}}.Validate()))
+	assert.Equal(t, map[string]string{"fields": "unique"}, rules(Visibility{Fields: []string{"id", "id"}}.Validate()))
+}
@@ -0,0 +1,118 @@
+// Package fhir define los recursos de HL7 FHIR R4 que expone la API. Solo
+// estan los elementos que se completan a partir de un usuario; el resto del
+// estandar se omite.
+package fhir
+
+// ContentType es el media type de FHIR en JSON.
+const ContentType = "application/fhir+json"
+
+// IdentifierSystem identifica los ids de usuario de esta API dentro de
+// Patient.identifier.
+const IdentifierSystem = "urn:users-api:user-id"
+
+// Tipos de recurso.
+const (
+	TypePatient     = "Patient"
+	TypeCondition   = "Condition"
+	TypeObservation = "Observation"
+	TypeBundle      = "Bundle"
+)
+
+// Sistemas de codificacion usados en los recursos.
+const (
+	SystemSNOMED              = "http://snomed.info/sct"
+	SystemConditionClinical   = "http://terminology.hl7.org/CodeSystem/condition-clinical"
+	SystemConditionVerStatus  = "http://terminology.hl7.org/CodeSystem/condition-ver-status"
+	SystemConditionCategory   = "http://terminology.hl7.org/CodeSystem/condition-category"
+	SystemObservationCategory = "http://terminology.hl7.org/CodeSystem/observation-category"
+)
+
+// Valores de Patient.gender (AdministrativeGender).
+const (
+	GenderMale    = "male"
+	GenderFemale  = "female"
+	GenderOther   = "other"
+	GenderUnknown = "unknown"
+)
+
+// BundleCollection es el tipo de Bundle de una exportacion: un conjunto de
+// recursos sin relacion con una busqueda.
+const BundleCollection = "collection"
+
+type Meta struct {
+	VersionId string `json:"versionId,omitempty"`
+	// LastUpdated es un instant: RFC 3339 con segundos y zona horaria.
+	LastUpdated string `json:"lastUpdated,omitempty"`
+}
+
+type Coding struct {
+	System  string `json:"system,omitempty"`
+	Code    string `json:"code,omitempty"`
+	Display string `json:"display,omitempty"`
+}
+
+type CodeableConcept struct {
+	Coding []Coding `json:"coding,omitempty"`
+	Text   string   `json:"text,omitempty"`
+}
+
+type Identifier struct {
+	System string `json:"system,omitempty"`
+	Value  string `json:"value,omitempty"`
+}
+
+type HumanName struct {
+	Text string `json:"text,omitempty"`
+}
+
+// Reference apunta a otro recurso con la forma "Tipo/id".
+type Reference struct {
+	Reference string `json:"reference"`
+}
+
+type Patient struct {
+	ResourceType string       `json:"resourceType"`
+	Id           string       `json:"id"`
+	Meta         *Meta        `json:"meta,omitempty"`
+	Identifier   []Identifier `json:"identifier,omitempty"`
+	Active       *bool        `json:"active,omitempty"`
+	Name         []HumanName  `json:"name,omitempty"`
+	Gender       string       `json:"gender,omitempty"`
+}
+
+type Condition struct {
+	ResourceType       string            `json:"resourceType"`
+	Id                 string            `json:"id"`
+	Meta               *Meta             `json:"meta,omitempty"`
+	ClinicalStatus     *CodeableConcept  `json:"clinicalStatus,omitempty"`
+	VerificationStatus *CodeableConcept  `json:"verificationStatus,omitempty"`
+	Category           []CodeableConcept `json:"category,omitempty"`
+	Code               *CodeableConcept  `json:"code,omitempty"`
+	Subject            Reference         `json:"subject"`
+}
+
+type Observation struct {
+	ResourceType      string            `json:"resourceType"`
+	Id                string            `json:"id"`
+	Meta              *Meta             `json:"meta,omitempty"`
+	Status            string            `json:"status"`
+	Category          []CodeableConcept `json:"category,omitempty"`
+	Code              CodeableConcept   `json:"code"`
+	Subject           *Reference        `json:"subject,omitempty"`
+	EffectiveDateTime string            `json:"effectiveDateTime,omitempty"`
+	ValueBoolean      *bool             `json:"valueBoolean,omitempty"`
+}
+
+// Bundle agrupa recursos. Entry.Resource es un Patient, Condition u
+// Observation.
+type Bundle struct {
+	ResourceType string        `json:"resourceType"`
+	Type         string        `json:"type"`
+	Timestamp    string        `json:"timestamp,omitempty"`
+	Entry        []BundleEntry `json:"entry,omitempty"`
+}
+
+type BundleEntry struct {
+	FullUrl  string      `json:"fullUrl,omitempty"`
+	Resource interface{} `json:"resource"`
+}
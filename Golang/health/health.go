@@ -0,0 +1,205 @@
+// Package health expone las sondas del servicio:
+//
+//   - /healthz responde mientras el proceso este vivo, sin tocar dependencias.
+//   - /readyz corre los chequeos obligatorios (base, migracion, clave de
+//     firma) y responde 503 si alguno falla, para que el orquestador no mande
+//     trafico a una instancia que no puede atenderlo.
+//   - /status agrega version, commit, uptime y la latencia de cada
+//     dependencia; es solo para administradores.
+package health
+
+import (
+	"Golang/logging"
+	"context"
+	"errors"
+	"net/http"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Version y Commit se fijan al compilar:
+//
+//	go build -ldflags "-X Golang/health.Version=1.4.0 -X Golang/health.Commit=$(git rev-parse --short HEAD)"
+var (
+	Version = "dev"
+	Commit  = "unknown"
+)
+
+const (
+	StatusOK          = "ok"
+	StatusUnavailable = "unavailable"
+	StatusDegraded    = "degraded"
+
+	defaultTimeout = 2 * time.Second
+)
+
+// errTimeout se informa cuando un chequeo no termina dentro del plazo.
+var errTimeout = errors.New("check timed out")
+
+// Check es una dependencia a verificar. Los chequeos opcionales (por ejemplo
+// la cache, que tiene fallback a la base) solo se informan en /status y no
+// afectan la readiness.
+type Check struct {
+	Name     string
+	Run      func(ctx context.Context) error
+	Optional bool
+}
+
+// Result es el resultado de un chequeo.
+type Result struct {
+	Name      string  `json:"name"`
+	Status    string  `json:"status"`
+	Optional  bool    `json:"optional,omitempty"`
+	LatencyMs float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+
+	err error
+}
+
+// Checker agrupa los chequeos del servicio y sirve las sondas.
+type Checker struct {
+	started time.Time
+	timeout time.Duration
+	checks  []Check
+}
+
+// New crea un Checker cuyo reloj de uptime arranca ahora. timeout es el
+// plazo de cada chequeo (2s si es 0).
+func New(timeout time.Duration) *Checker {
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+	return &Checker{started: time.Now(), timeout: timeout}
+}
+
+// Add registra un chequeo. Debe llamarse antes de servir pedidos.
+func (checker *Checker) Add(check Check) {
+	checker.checks = append(checker.checks, check)
+}
+
+// Run corre todos los chequeos en paralelo, cada uno con su plazo, y
+// devuelve los resultados en el orden en que se registraron. ready es false
+// si fallo algun chequeo obligatorio.
+func (checker *Checker) Run(ctx context.Context) (results []Result, ready bool) {
+	results = make([]Result, len(checker.checks))
+	var wg sync.WaitGroup
+	for i, check := range checker.checks {
+		wg.Add(1)
+		go func(i int, check Check) {
+			defer wg.Done()
+			results[i] = checker.run(ctx, check)
+		}(i, check)
+	}
+	wg.Wait()
+
+	ready = true
+	for _, result := range results {
+		if result.err != nil && !result.Optional {
+			ready = false
+		}
+	}
+	return results, ready
+}
+
+// run espera al chequeo hasta el plazo. Si el chequeo no respeta ctx (gorm v1
+// no lo hace) sigue corriendo en su goroutine, pero la sonda ya respondio.
+func (checker *Checker) run(ctx context.Context, check Check) Result {
+	ctx, cancel := context.WithTimeout(ctx, checker.timeout)
+	defer cancel()
+
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() { done <- check.Run(ctx) }()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = errTimeout
+	}
+
+	result := Result{
+		Name:      check.Name,
+		Status:    StatusOK,
+		Optional:  check.Optional,
+		LatencyMs: float64(time.Since(start).Microseconds()) / 1000,
+		err:       err,
+	}
+	if err != nil {
+		result.Status = StatusUnavailable
+		result.Error = logging.RedactText(err.Error())
+	}
+	return result
+}
+
+// Liveness responde 200 mientras el proceso pueda atender pedidos.
+func (checker *Checker) Liveness(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": StatusOK})
+}
+
+// Readiness responde 200 si todos los chequeos obligatorios pasan y 503 si
+// no. No incluye el texto de los errores porque la sonda es publica.
+func (checker *Checker) Readiness(c *gin.Context) {
+	results, ready := checker.Run(c.Request.Context())
+
+	checks := make(map[string]string, len(results))
+	for _, result := range results {
+		if result.Optional {
+			continue
+		}
+		checks[result.Name] = result.Status
+		if result.err != nil {
+			logging.FromContext(c.Request.Context()).WithError(result.err).
+				WithField("check", result.Name).Warn("readiness check failed")
+		}
+	}
+
+	if !ready {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"status": StatusUnavailable, "checks": checks})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": StatusOK, "checks": checks})
+}
+
+// StatusReport es el cuerpo de /status.
+type StatusReport struct {
+	Status        string    `json:"status"`
+	Version       string    `json:"version"`
+	Commit        string    `json:"commit"`
+	GoVersion     string    `json:"go_version"`
+	StartedAt     time.Time `json:"started_at"`
+	UptimeSeconds int64     `json:"uptime_seconds"`
+	Checks        []Result  `json:"checks"`
+}
+
+// Status devuelve el detalle de la instancia. Responde 503 si no esta lista,
+// y "degraded" si solo falla un chequeo opcional.
+func (checker *Checker) Status(c *gin.Context) {
+	results, ready := checker.Run(c.Request.Context())
+
+	report := StatusReport{
+		Status:        StatusOK,
+		Version:       Version,
+		Commit:        Commit,
+		GoVersion:     runtime.Version(),
+		StartedAt:     checker.started.UTC(),
+		UptimeSeconds: int64(time.Since(checker.started).Seconds()),
+		Checks:        results,
+	}
+	status := http.StatusOK
+	switch {
+	case !ready:
+		report.Status = StatusUnavailable
+		status = http.StatusServiceUnavailable
+	default:
+		for _, result := range results {
+			if result.err != nil {
+				report.Status = StatusDegraded
+			}
+		}
+	}
+	c.JSON(status, report)
+}
@@ -0,0 +1,132 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func ok(context.Context) error { return nil }
+
+func router(checker *Checker) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/healthz", checker.Liveness)
+	r.GET("/readyz", checker.Readiness)
+	r.GET("/status", checker.Status)
+	return r
+}
+
+func get(t *testing.T, checker *Checker, path string) (int, map[string]interface{}) {
+	t.Helper()
+	w := httptest.NewRecorder()
+	router(checker).ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+	var body map[string]interface{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	return w.Code, body
+}
+
+func TestLiveness_IgnoresChecks(t *testing.T) {
+	checker := New(0)
+	checker.Add(Check{Name: "database", Run: func(context.Context) error { return errors.New("down") }})
+
+	code, body := get(t, checker, "/healthz")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "ok", body["status"])
+}
+
+func TestReadiness_AllChecksPass(t *testing.T) {
+	checker := New(0)
+	checker.Add(Check{Name: "database", Run: ok})
+	checker.Add(Check{Name: "signing_key", Run: ok})
+
+	code, body := get(t, checker, "/readyz")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, map[string]interface{}{"database": "ok", "signing_key": "ok"}, body["checks"])
+}
+
+func TestReadiness_FailingCheckReturns503WithoutDetails(t *testing.T) {
+	checker := New(0)
+	checker.Add(Check{Name: "database", Run: func(context.Context) error {
+		return errors.New("dial tcp db:3306: connection refused")
+	}})
+
+	code, body := get(t, checker, "/readyz")
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, "unavailable", body["status"])
+	assert.Equal(t, map[string]interface{}{"database": "unavailable"}, body["checks"])
+	assert.NotContains(t, body, "error")
+}
+
+func TestReadiness_OptionalCheckDoesNotBlock(t *testing.T) {
+	checker := New(0)
+	checker.Add(Check{Name: "database", Run: ok})
+	checker.Add(Check{Name: "cache", Optional: true, Run: func(context.Context) error { return errors.New("down") }})
+
+	code, _ := get(t, checker, "/readyz")
+	assert.Equal(t, http.StatusOK, code)
+
+	code, body := get(t, checker, "/status")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "degraded", body["status"])
+}
+
+func TestRun_TimesOutSlowChecks(t *testing.T) {
+	checker := New(20 * time.Millisecond)
+	release := make(chan struct{})
+	defer close(release)
+	checker.Add(Check{Name: "database", Run: func(context.Context) error {
+		<-release // no respeta ctx, como gorm v1
+		return nil
+	}})
+
+	start := time.Now()
+	results, ready := checker.Run(context.Background())
+	assert.Less(t, time.Since(start), time.Second)
+	assert.False(t, ready)
+	require.Len(t, results, 1)
+	assert.Equal(t, "check timed out", results[0].Error)
+}
+
+func TestStatus_ReportsBuildAndLatencies(t *testing.T) {
+	defer func(version, commit string) { Version, Commit = version, commit }(Version, Commit)
+	Version, Commit = "1.2.3", "abc1234"
+
+	checker := New(0)
+	checker.Add(Check{Name: "database", Run: func(context.Context) error {
+		time.Sleep(5 * time.Millisecond)
+		return nil
+	}})
+
+	code, body := get(t, checker, "/status")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "ok", body["status"])
+	assert.Equal(t, "1.2.3", body["version"])
+	assert.Equal(t, "abc1234", body["commit"])
+	assert.Contains(t, body, "uptime_seconds")
+
+	checks := body["checks"].([]interface{})
+	require.Len(t, checks, 1)
+	database := checks[0].(map[string]interface{})
+	assert.Equal(t, "database", database["name"])
+	assert.GreaterOrEqual(t, database["latency_ms"], 5.0)
+}
+
+func TestStatus_RedactsErrors(t *testing.T) {
+	checker := New(0)
+	checker.Add(Check{Name: "cache", Optional: true, Run: func(context.Context) error {
+		return errors.New("auth failed: password=hunter2")
+	}})
+
+	_, body := get(t, checker, "/status")
+	cache := body["checks"].([]interface{})[0].(map[string]interface{})
+	assert.Equal(t, "auth failed: password=[REDACTED]", cache["error"])
+}
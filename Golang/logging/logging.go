@@ -0,0 +1,110 @@
+// Package logging configura logrus para todo el servicio: salida JSON, nivel
+// configurable, un logger por pedido que viaja en el context.Context y una
+// capa de redaccion que impide que contraseñas, tokens o datos medicos
+// lleguen a los logs.
+//
+//	log := logging.FromContext(ctx)
+//	log.WithField("user_id", user.Id).Info("usuario actualizado")
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	FormatJSON = "json"
+	FormatText = "text"
+)
+
+// Config elige el nivel y el formato de los logs.
+type Config struct {
+	// Level es un nivel de logrus: trace, debug, info (por defecto), warn,
+	// error.
+	Level string
+	// Format es "json" (por defecto) o "text".
+	Format string
+	// Output reemplaza la salida (stderr si es nil).
+	Output io.Writer
+}
+
+// Setup aplica config al logger estandar de logrus, que es el que usan todos
+// los paquetes, e instala la redaccion.
+func Setup(config Config) error {
+	return configure(log.StandardLogger(), config)
+}
+
+// New crea un logger independiente con la misma configuracion que Setup.
+// Sirve para tests que necesitan capturar la salida.
+func New(config Config) (*log.Logger, error) {
+	logger := log.New()
+	if err := configure(logger, config); err != nil {
+		return nil, err
+	}
+	return logger, nil
+}
+
+func configure(logger *log.Logger, config Config) error {
+	level := log.InfoLevel
+	if strings.TrimSpace(config.Level) != "" {
+		parsed, err := log.ParseLevel(config.Level)
+		if err != nil {
+			return fmt.Errorf("invalid log level %q: %w", config.Level, err)
+		}
+		level = parsed
+	}
+
+	switch strings.ToLower(strings.TrimSpace(config.Format)) {
+	case "", FormatJSON:
+		logger.SetFormatter(&log.JSONFormatter{})
+	case FormatText:
+		logger.SetFormatter(&log.TextFormatter{DisableColors: true, FullTimestamp: true})
+	default:
+		return fmt.Errorf("unknown log format %q", config.Format)
+	}
+
+	logger.SetLevel(level)
+	if config.Output != nil {
+		logger.SetOutput(config.Output)
+	}
+	logger.ReplaceHooks(log.LevelHooks{})
+	logger.AddHook(redactHook{})
+	return nil
+}
+
+type contextKey struct{}
+
+// WithContext guarda entry en ctx para que las capas de abajo logueen con los
+// mismos campos (request_id, metodo, ruta).
+func WithContext(ctx context.Context, entry *log.Entry) context.Context {
+	return context.WithValue(ctx, contextKey{}, entry)
+}
+
+// FromContext devuelve el logger del pedido, o uno sin campos si ctx no trae
+// ninguno. Si hay un span activo agrega trace_id y span_id para cruzar los
+// logs con las trazas.
+func FromContext(ctx context.Context) *log.Entry {
+	var entry *log.Entry
+	if ctx != nil {
+		entry, _ = ctx.Value(contextKey{}).(*log.Entry)
+	}
+	if entry == nil {
+		entry = log.NewEntry(log.StandardLogger())
+	}
+	if ctx == nil {
+		return entry
+	}
+
+	if span := trace.SpanContextFromContext(ctx); span.IsValid() {
+		entry = entry.WithFields(log.Fields{
+			"trace_id": span.TraceID().String(),
+			"span_id":  span.SpanID().String(),
+		})
+	}
+	return entry.WithContext(ctx)
+}
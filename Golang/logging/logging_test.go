@@ -0,0 +1,75 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+func decodeLine(t *testing.T, buf *bytes.Buffer) map[string]interface{} {
+	t.Helper()
+	var line map[string]interface{}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &line), buf.String())
+	return line
+}
+
+func TestNew_JSONWithLevel(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := New(Config{Level: "warn", Output: &buf})
+	require.NoError(t, err)
+
+	logger.Info("no se ve")
+	assert.Empty(t, buf.String())
+
+	logger.WithField("user_id", 3).Warn("algo paso")
+	line := decodeLine(t, &buf)
+	assert.Equal(t, "algo paso", line["msg"])
+	assert.Equal(t, "warning", line["level"])
+	assert.Equal(t, float64(3), line["user_id"])
+}
+
+func TestNew_InvalidConfig(t *testing.T) {
+	_, err := New(Config{Level: "verbose"})
+	assert.Error(t, err)
+
+	_, err = New(Config{Format: "xml"})
+	assert.Error(t, err)
+}
+
+func TestFromContext(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := New(Config{Output: &buf})
+	require.NoError(t, err)
+
+	// Sin logger en el contexto se usa el estandar, sin campos.
+	assert.Empty(t, FromContext(context.Background()).Data)
+
+	ctx := WithContext(context.Background(), logger.WithField("request_id", "abc"))
+	provider := sdktrace.NewTracerProvider()
+	ctx, span := provider.Tracer("test").Start(ctx, "op")
+	defer span.End()
+
+	FromContext(ctx).Info("con contexto")
+	line := decodeLine(t, &buf)
+	assert.Equal(t, "abc", line["request_id"])
+	assert.Equal(t, span.SpanContext().TraceID().String(), line["trace_id"])
+	assert.Equal(t, span.SpanContext().SpanID().String(), line["span_id"])
+}
+
+func TestSetup_ConfiguresStandardLogger(t *testing.T) {
+	original := log.StandardLogger().Out
+	var buf bytes.Buffer
+	require.NoError(t, Setup(Config{Level: "debug", Output: &buf}))
+	defer Setup(Config{Output: original})
+
+	log.WithField("password", "secreta").Debug("debug visible")
+	line := decodeLine(t, &buf)
+	assert.Equal(t, "debug visible", line["msg"])
+	assert.Equal(t, Redacted, line["password"])
+}
@@ -0,0 +1,186 @@
+package logging
+
+import (
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Redacted reemplaza cualquier valor sensible en los logs.
+const Redacted = "[REDACTED]"
+
+// sensitiveKeys son nombres de campo (de log, de struct o su tag json) que
+// nunca se loguean: credenciales y los datos medicos del usuario.
+var sensitiveKeys = map[string]bool{
+	"password":      true,
+	"psw":           true,
+	"contrasena":    true,
+	"contraseña":    true,
+	"token":         true,
+	"authorization": true,
+	"cookie":        true,
+	"secret":        true,
+	"enfermedades":  true,
+	"diabetico":     true,
+	"lentes":        true,
+	"atributos":     true,
+}
+
+// trustedKeys son campos que pone este paquete o los middlewares y que se
+// parecen a un hash (trace_id tiene 32 hex) sin serlo.
+var trustedKeys = map[string]bool{
+	"request_id": true,
+	"trace_id":   true,
+	"span_id":    true,
+}
+
+var textPatterns = []struct {
+	pattern     *regexp.Regexp
+	replacement string
+}{
+	{regexp.MustCompile(`(?i)\bbearer\s+[A-Za-z0-9\-_.=+/]+`), "Bearer " + Redacted},
+	{regexp.MustCompile(`\beyJ[A-Za-z0-9_-]*\.[A-Za-z0-9_-]+\.[A-Za-z0-9_-]*`), Redacted},
+	{regexp.MustCompile(`(?i)\b(password|passwd|psw|contrase(?:ñ|n)a|token|secret)(\s*[:=]\s*)\S+`), "${1}${2}" + Redacted},
+	// Hashes de contraseña (md5 y mas largos).
+	{regexp.MustCompile(`\b[a-fA-F0-9]{32,}\b`), Redacted},
+}
+
+type redactHook struct{}
+
+func (redactHook) Levels() []log.Level {
+	return log.AllLevels
+}
+
+// Fire recibe una copia de la entrada (logrus duplica Data antes de los
+// hooks), asi que se puede modificar sin afectar al logger del pedido.
+func (redactHook) Fire(entry *log.Entry) error {
+	for key, value := range entry.Data {
+		switch {
+		case isSensitive(key):
+			entry.Data[key] = Redacted
+		case trustedKeys[key]:
+		default:
+			entry.Data[key] = Redact(value)
+		}
+	}
+	entry.Message = RedactText(entry.Message)
+	return nil
+}
+
+// RedactText tapa tokens, JWT, hashes y pares password=valor dentro de un
+// texto libre.
+func RedactText(text string) string {
+	for _, p := range textPatterns {
+		text = p.pattern.ReplaceAllString(text, p.replacement)
+	}
+	return text
+}
+
+// Redact devuelve una version de value apta para loguear: los structs y
+// mapas se convierten en mapas con los campos sensibles tapados, los errores
+// y textos pasan por RedactText.
+func Redact(value interface{}) interface{} {
+	return redactValue(reflect.ValueOf(value), 0)
+}
+
+const maxRedactDepth = 6
+
+var timeType = reflect.TypeOf(time.Time{})
+
+func redactValue(value reflect.Value, depth int) interface{} {
+	if !value.IsValid() {
+		return nil
+	}
+	if depth > maxRedactDepth {
+		return Redacted
+	}
+
+	if value.CanInterface() {
+		if err, ok := value.Interface().(error); ok {
+			if value.Kind() == reflect.Pointer && value.IsNil() {
+				return nil
+			}
+			return RedactText(err.Error())
+		}
+	}
+
+	switch value.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if value.IsNil() {
+			return nil
+		}
+		return redactValue(value.Elem(), depth+1)
+	case reflect.String:
+		return RedactText(value.String())
+	case reflect.Struct:
+		if value.Type() == timeType {
+			return value.Interface()
+		}
+		fields := make(map[string]interface{}, value.NumField())
+		for i := 0; i < value.NumField(); i++ {
+			field := value.Type().Field(i)
+			if !field.IsExported() {
+				continue
+			}
+			name := fieldName(field)
+			if name == "-" {
+				continue
+			}
+			if isSensitive(field.Name) || isSensitive(name) {
+				fields[name] = Redacted
+				continue
+			}
+			fields[name] = redactValue(value.Field(i), depth+1)
+		}
+		return fields
+	case reflect.Map:
+		if value.Type().Key().Kind() != reflect.String {
+			return Redacted
+		}
+		entries := make(map[string]interface{}, value.Len())
+		iter := value.MapRange()
+		for iter.Next() {
+			key := iter.Key().String()
+			if isSensitive(key) {
+				entries[key] = Redacted
+				continue
+			}
+			entries[key] = redactValue(iter.Value(), depth+1)
+		}
+		return entries
+	case reflect.Slice, reflect.Array:
+		if value.Type().Elem().Kind() == reflect.Uint8 {
+			// []byte puede ser un cuerpo o un hash: no se loguea.
+			return Redacted
+		}
+		items := make([]interface{}, value.Len())
+		for i := range items {
+			items[i] = redactValue(value.Index(i), depth+1)
+		}
+		return items
+	default:
+		if value.CanInterface() {
+			return value.Interface()
+		}
+		return Redacted
+	}
+}
+
+func fieldName(field reflect.StructField) string {
+	tag := field.Tag.Get("json")
+	if name, _, _ := strings.Cut(tag, ","); name != "" {
+		return name
+	}
+	return field.Name
+}
+
+func isSensitive(key string) bool {
+	key = strings.ToLower(key)
+	if sensitiveKeys[key] {
+		return true
+	}
+	return strings.Contains(key, "password") || strings.Contains(key, "token") || strings.Contains(key, "secret")
+}
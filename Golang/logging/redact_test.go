@@ -0,0 +1,87 @@
+package logging
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	Domain "Golang/domain"
+	Model "Golang/model"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedact_Structs(t *testing.T) {
+	user := Model.User{
+		Id:           4,
+		Nombre:       "ana",
+		Password:     "5f4dcc3b5aa765d61d8327deb882cf99",
+		Atributos:    "alergia",
+		Diabetico:    true,
+		Lentes:       true,
+		Enfermedades: "asma",
+	}
+
+	redacted := Redact(user).(map[string]interface{})
+	assert.Equal(t, 4, redacted["Id"])
+	assert.Equal(t, "ana", redacted["Nombre"])
+	for _, field := range []string{"Password", "Atributos", "Diabetico", "Lentes", "Enfermedades"} {
+		assert.Equal(t, Redacted, redacted[field], field)
+	}
+
+	// Los tags json tambien cuentan: LoginData expone el token como "Token".
+	login := Redact(&Domain.LoginData{Token: "eyJhbGciOi.e30.sig", IdU: 4}).(map[string]interface{})
+	assert.Equal(t, Redacted, login["Token"])
+	assert.Equal(t, 4, login["IdU"])
+}
+
+func TestRedact_MapsSlicesAndErrors(t *testing.T) {
+	value := map[string]interface{}{
+		"nombre":       "ana",
+		"enfermedades": "asma",
+		"usuarios":     []Model.User{{Password: "x"}},
+	}
+	redacted := Redact(value).(map[string]interface{})
+	assert.Equal(t, "ana", redacted["nombre"])
+	assert.Equal(t, Redacted, redacted["enfermedades"])
+	users := redacted["usuarios"].([]interface{})
+	assert.Equal(t, Redacted, users[0].(map[string]interface{})["Password"])
+
+	assert.Equal(t, "query failed: password="+Redacted, Redact(errors.New("query failed: password=hunter2")))
+	assert.Equal(t, Redacted, Redact([]byte("cuerpo")))
+	assert.Nil(t, Redact(nil))
+}
+
+func TestRedactText(t *testing.T) {
+	cases := map[string]string{
+		"Authorization: Bearer abc.def-ghi":            "Authorization: Bearer " + Redacted,
+		"token eyJhbGciOiJIUzI1NiJ9.eyJpZFUiOjF9.c2ln": "token " + Redacted,
+		"hash 5f4dcc3b5aa765d61d8327deb882cf99 listo":  "hash " + Redacted + " listo",
+		"contraseña: hunter2":                          "contraseña: " + Redacted,
+		"usuario 42 actualizado":                       "usuario 42 actualizado",
+	}
+	for in, want := range cases {
+		assert.Equal(t, want, RedactText(in), in)
+	}
+}
+
+func TestHook_RedactsEveryLine(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := New(Config{Level: "debug", Output: &buf})
+	require.NoError(t, err)
+
+	logger.WithFields(map[string]interface{}{
+		"user":       Model.User{Nombre: "ana", Password: "5f4dcc3b5aa765d61d8327deb882cf99", Enfermedades: "asma"},
+		"token":      "eyJhbGciOi.e30.sig",
+		"request_id": "0af7651916cd43dd8448eb211c80319c",
+	}).WithError(errors.New("bad secret=xyz")).Info("login con Bearer abc123")
+
+	out := buf.String()
+	for _, leaked := range []string{"5f4dcc3b5aa765d61d8327deb882cf99", "asma", "eyJhbGciOi", "xyz", "abc123"} {
+		assert.NotContains(t, out, leaked)
+	}
+	assert.Contains(t, out, `"Nombre":"ana"`)
+	// request_id es de confianza aunque parezca un hash.
+	assert.Contains(t, out, "0af7651916cd43dd8448eb211c80319c")
+}
@@ -1,17 +1,36 @@
 package main
 
 import (
+	"Golang/auth"
 	repo "Golang/clients"
+	"Golang/config"
 	controller "Golang/controller"
+	Domain "Golang/domain"
+	"Golang/health"
+	"Golang/logging"
+	"Golang/metrics"
 	"Golang/middleware"
+	"Golang/problem"
+	"Golang/server"
 	service "Golang/service"
-	"log"
+	"Golang/tracing"
+	"context"
+	"crypto/tls"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
 	"net/http"
 	os "os"
+	"os/signal"
+	"strconv"
 	"strings"
+	"syscall"
 
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
+	log "github.com/sirupsen/logrus"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
 )
 
 type Controller interface {
@@ -21,72 +40,228 @@ type Controller interface {
 	Extrac(c *gin.Context)
 	GetUserById(c *gin.Context)
 	Login(c *gin.Context)
+	PatchUser(c *gin.Context)
 }
 
 func main() {
+	configFile := flag.String("config", "", "archivo de configuracion YAML o TOML (tambien CONFIG_FILE)")
+	printConfig := flag.Bool("print-config", false, "imprime la configuracion efectiva, sin secretos, y termina")
+	flag.Parse()
 
-	if err := godotenv.Load(); err != nil {
-		log.Println("No .env file found")
+	// El .env tambien se carga en el entorno del proceso para las librerias
+	// que leen sus propias variables, como OTEL_EXPORTER_OTLP_ENDPOINT.
+	godotenv.Load()
+	cfg, err := config.Load(config.Options{File: *configFile, EnvFile: ".env"})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	if *printConfig {
+		if err := cfg.Print(os.Stdout); err != nil {
+			log.Fatal("Configuration Failed to Print: ", err)
+		}
+		return
 	}
 
-	sqlconfig := repo.MySQLConfig{
-		Name: os.Getenv("DB_NAME"),
-		User: os.Getenv("DB_USER"),
-		Pass: os.Getenv("DB_PASS"),
-		Host: os.Getenv("DB_HOST"),
+	if err := logging.Setup(logging.Config{
+		Level:  cfg.Log.Level,
+		Format: cfg.Log.Format,
+	}); err != nil {
+		log.Fatal("Logging Failed to Start: ", err)
 	}
 
-	mainRepo := repo.NewSql(sqlconfig)
-	Service := service.NewService(mainRepo)
-	Controller := controller.NewController(Service)
-	router := gin.Default()
-
-	router.Use(func(c *gin.Context) {
-		origin := c.Request.Header.Get("Origin")
-
-		allowedOrigins := []string{
-			"https://localhost:3000",
-			"http://localhost:3000",
-			"http://127.0.0.1:3000",
-			"https://webapp-qa-2025.azurewebsites.net",
-			"https://webapp-produ-2025.azurewebsites.net",
-			"http://frontend-instance-qa.brazilsouth.azurecontainer.io",
-    		"http://frontend-instance-prod.brazilsouth.azurecontainer.io",
-			"https://frontend-qa-production.up.railway.app",
-			"https://frontend-prod-production-5d36.up.railway.app",
-		}
-		for _, o := range allowedOrigins {
-			if strings.HasPrefix(origin, o) {
-				c.Writer.Header().Set("Access-Control-Allow-Origin", origin)
-				c.Writer.Header().Set("Vary", "Origin")
-				break
-			}
+	shutdownTracing, err := tracing.Setup(context.Background(), tracing.Config{
+		Exporter:    cfg.Tracing.Exporter,
+		ServiceName: cfg.Tracing.ServiceName,
+		SampleRatio: cfg.Tracing.SampleRatio,
+	})
+	if err != nil {
+		log.Fatal("Tracing Failed to Start: ", err)
+	}
 
+	if !auth.LoadSigningKey(cfg.Auth.JWTSecret) {
+		log.Warn("JWT_SECRET not set, signing tokens with the legacy built-in secret")
+	}
+	auth.RequireConfiguredKey(!strings.EqualFold(cfg.Server.Environment, "development"))
+	checker := health.New(cfg.Health.CheckTimeout)
+	checker.Add(health.Check{Name: "signing_key", Run: auth.Check})
+
+	dbConfig := repo.Config{
+		Driver: cfg.Database.Driver,
+		Name:   cfg.Database.Name,
+		User:   cfg.Database.User,
+		Pass:   cfg.Database.Password,
+		Host:   cfg.Database.Host,
+		Port:   cfg.Database.Port,
+		TLS:    cfg.Database.TLS,
+		Path:   cfg.Database.Path,
+	}
+
+	mainRepo, err := repo.NewRepository(dbConfig)
+	if err != nil {
+		log.Fatal("Connection Failed to Open: ", err)
+	}
+	// Los consentimientos van directo al backend: no pasan por el cache ni
+	// por el cifrado de los usuarios.
+	consents, _ := mainRepo.(repo.ConsentStore)
+	// Lo mismo el historial de logins y el lock del job de retencion.
+	logins, _ := mainRepo.(repo.LoginHistory)
+	locker, _ := mainRepo.(repo.Locker)
+	sqlRepo, isSQL := mainRepo.(repo.SQL)
+	if isSQL {
+		if err := metrics.RegisterDB(sqlRepo.DB(), sqlRepo.Database); err != nil {
+			log.WithError(err).Warn("Could not register database metrics")
 		}
-		c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, GET, OPTIONS, PUT, DELETE, UPDATE")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Origin, Authorization, Content-Type, X-Auth-Token")
-		c.Writer.Header().Set("Access-Control-Expose-Headers", "Content-Length")
-		c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
-
-		if c.Request.Method == "OPTIONS" {
-			c.AbortWithStatus(http.StatusOK)
-			return
-		}
-		c.Next()
+		checker.Add(health.Check{Name: "database", Run: sqlRepo.Ping})
+		checker.Add(health.Check{Name: "migrations", Run: sqlRepo.CheckSchema})
+	}
+	mainRepo = repo.NewInstrumented(mainRepo)
+
+	cacheConfig := repo.CacheConfig{
+		Driver:   cfg.Cache.Driver,
+		TTL:      cfg.Cache.TTL,
+		Size:     cfg.Cache.Size,
+		Addr:     cfg.Cache.RedisAddr,
+		Password: cfg.Cache.RedisPassword,
+		DB:       cfg.Cache.RedisDB,
+	}
+	if cfg.Cache.RedisTLS {
+		cacheConfig.TLS = &tls.Config{MinVersion: tls.VersionTLS12}
+	}
+	cache, err := repo.NewCache(cacheConfig)
+	if err != nil {
+		log.Fatal("Cache Failed to Open: ", err)
+	}
+	if redis, ok := cache.(*repo.Redis); ok {
+		checker.Add(health.Check{Name: "cache", Run: redis.Ping, Optional: true})
+	}
+	if cache != nil {
+		cached := repo.NewCached(mainRepo, cache, cacheConfig.TTL)
+		metrics.RegisterCache(func() metrics.CacheCounts {
+			stats := cached.Stats()
+			return metrics.CacheCounts{Hits: stats.Hits, Misses: stats.Misses, Errors: stats.Errors}
+		})
+		mainRepo = cached
+	}
+	encrypted, err := repo.NewEncryption(mainRepo, repo.EncryptionConfig{
+		MasterKeys: cfg.Encryption.MasterKeys,
+		ActiveKey:  cfg.Encryption.ActiveKey,
+		IndexKey:   cfg.Encryption.IndexKey,
 	})
-	router.POST("/users", Controller.UsuarioInsert)
+	if err != nil {
+		log.Fatal("Encryption Failed to Start: ", err)
+	}
+	if encrypted != nil {
+		mainRepo = encrypted
+	} else {
+		log.Warn("ENCRYPTION_MASTER_KEYS not set, storing medical data in plaintext")
+	}
+	cors, err := middleware.NewCORS(middleware.CORSConfig{
+		AllowedOrigins:   cfg.CORS.AllowedOrigins,
+		AllowedHeaders:   cfg.CORS.AllowedHeaders,
+		ExposedHeaders:   cfg.CORS.ExposedHeaders,
+		AllowCredentials: cfg.CORS.AllowCredentials,
+		MaxAge:           cfg.CORS.MaxAge,
+	})
+	if err != nil {
+		log.Fatal("CORS Failed to Configure: ", err)
+	}
+
+	Service := service.NewService(mainRepo).WithConsents(consents).WithLoginHistory(logins)
+	if _, err := Service.CurrentConsent(context.Background()); errors.Is(err, Domain.ErrNoConsent) {
+		log.Warn("No consent text published, registration does not require consent (see usersctl publish-consent)")
+	}
+	Controller := controller.NewController(Service)
+	importer := service.NewImporter(Service, cfg.Import.JobRetention)
+	importController := controller.NewImportController(importer, controller.ImportConfig{
+		MaxBytes:     int64(cfg.Import.MaxBytes),
+		SyncMaxBytes: int64(cfg.Import.SyncMaxBytes),
+	})
+	exportController := controller.NewExportController(Service)
+	fhirController := controller.NewFHIRController(Service)
+	consentController := controller.NewConsentController(Service)
+	router := gin.New()
+	router.Use(otelgin.Middleware(cfg.Tracing.ServiceName))
+	router.Use(middleware.RequestID())
+	router.Use(middleware.Metrics())
+	router.Use(middleware.AccessLog())
+	router.Use(gin.Recovery())
+	router.HandleMethodNotAllowed = true
+	router.NoRoute(func(c *gin.Context) {
+		problem.Write(c, http.StatusNotFound, problem.TypeNotFound, problem.Text{ES: "La ruta no existe.", EN: "Route not found."})
+	})
+	router.NoMethod(func(c *gin.Context) {
+		problem.Write(c, http.StatusMethodNotAllowed, problem.TypeBlank, problem.Text{ES: "Método no soportado por esta ruta.", EN: "Method not supported for this route."})
+	})
+
+	router.Use(cors.Middleware())
+	router.GET("/metrics", gin.WrapH(metrics.Handler()))
+	router.GET("/healthz", checker.Liveness)
+	router.GET("/readyz", checker.Readiness)
+	router.GET("/status", middleware.AuthMiddleware(), middleware.RequireAdmin(), checker.Status)
+
+	router.POST("/users", middleware.OptionalAuth(), Controller.UsuarioInsert)
 	router.POST("/users/login", Controller.Login)
 	router.GET("/users/token", Controller.Extrac)
+	router.GET("/consent", consentController.CurrentConsent)
 
 	router.GET("/users/all", middleware.AuthMiddleware(), Controller.GetAllUsers)
 	router.GET("/users", middleware.AuthMiddleware(), Controller.GetUserByName)
 	router.GET("/users/:id", middleware.AuthMiddleware(), Controller.GetUserById)
 	router.PUT("/users", middleware.AuthMiddleware(), Controller.UpdateUser)
+	router.PATCH("/users/:id", middleware.AuthMiddleware(), Controller.PatchUser)
+	router.GET("/users/:id/consent", middleware.AuthMiddleware(), consentController.GetConsent)
+	router.PUT("/users/:id/consent", middleware.AuthMiddleware(), consentController.GrantConsent)
+	router.DELETE("/users/:id/consent", middleware.AuthMiddleware(), consentController.WithdrawConsent)
+	router.POST("/users/import", middleware.AuthMiddleware(), middleware.RequireAdmin(), importController.ImportUsers)
+	router.GET("/users/import/:id", middleware.AuthMiddleware(), middleware.RequireAdmin(), importController.GetImportJob)
+	router.GET("/users/export", middleware.AuthMiddleware(), middleware.RequireAdmin(), exportController.ExportUsers)
+	router.GET("/fhir/Patient/:id", middleware.AuthMiddleware(), middleware.RequireAdmin(), fhirController.GetPatient)
+	router.GET("/fhir/export", middleware.AuthMiddleware(), middleware.RequireAdmin(), fhirController.ExportBundle)
+	cors.RegisterPreflight(router)
+
+	httpServer, err := server.New(router, server.Config{
+		Addr:              ":" + strconv.Itoa(cfg.Server.Port),
+		ReadHeaderTimeout: cfg.Server.ReadHeaderTimeout,
+		ReadTimeout:       cfg.Server.ReadTimeout,
+		WriteTimeout:      cfg.Server.WriteTimeout,
+		IdleTimeout:       cfg.Server.IdleTimeout,
+		ShutdownTimeout:   cfg.Server.ShutdownTimeout,
+		MaxHeaderBytes:    cfg.Server.MaxHeaderBytes,
+		TLSCertFile:       cfg.Server.TLSCertFile,
+		TLSKeyFile:        cfg.Server.TLSKeyFile,
+		TLSMinVersion:     cfg.Server.TLSMinVersion,
+	})
+	if err != nil {
+		log.Fatal("Server Failed to Start: ", err)
+	}
+	// Se cierran en orden inverso: retencion, re-cifrado, importaciones en
+	// curso, cache, base y por ultimo las trazas, para exportar tambien los
+	// spans del apagado.
+	httpServer.OnShutdown("tracing", shutdownTracing)
+	if isSQL {
+		httpServer.OnShutdown("database", func(context.Context) error { return sqlRepo.Close() })
+	}
+	if closer, ok := cache.(io.Closer); ok {
+		httpServer.OnShutdown("cache", func(context.Context) error { return closer.Close() })
+	}
+	httpServer.OnShutdown("imports", importer.Close)
+	if encrypted != nil && cfg.Encryption.ReencryptInterval > 0 {
+		httpServer.OnShutdown("reencryption", encrypted.StartReencryption(cfg.Encryption.ReencryptInterval))
+	}
+	if locker != nil && cfg.Retention.Interval > 0 && (cfg.Retention.InactiveAccounts > 0 || cfg.Retention.LoginHistory > 0 || cfg.Retention.ConsentRecords > 0) {
+		retention := service.NewRetention(Service, Domain.RetentionPolicy{
+			InactiveAccounts: cfg.Retention.InactiveAccounts,
+			LoginHistory:     cfg.Retention.LoginHistory,
+			ConsentRecords:   cfg.Retention.ConsentRecords,
+		}, locker)
+		httpServer.OnShutdown("retention", retention.Start(cfg.Retention.Interval, cfg.Retention.DryRun))
+	}
 
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080"
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if err := httpServer.Run(ctx); err != nil {
+		log.Fatal("Server Stopped With Errors: ", err)
 	}
-	router.Run(":" + port)
 
 }
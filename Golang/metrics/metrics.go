@@ -0,0 +1,217 @@
+// Package metrics reune las metricas Prometheus del servicio y las expone
+// con Handler. Las capas las alimentan con las funciones Observe*; nada de
+// este paquete depende de gin, gorm ni de los repositorios.
+package metrics
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	dto "github.com/prometheus/client_model/go"
+)
+
+const namespace = "users"
+
+// Resultados de login para la etiqueta result.
+const (
+	LoginSuccess = "success"
+	LoginFailure = "failure"
+	LoginError   = "error"
+)
+
+// Resultados de una pasada de retencion para la etiqueta result.
+const (
+	RetentionSuccess = "success"
+	RetentionFailure = "failure"
+	// RetentionSkipped es una pasada que no corrio porque otra replica
+	// tenia el lock.
+	RetentionSkipped = "skipped"
+)
+
+// Registry es el registro que publica Handler. Es propio y no el global de
+// Prometheus para que solo aparezca lo que registra este paquete.
+var Registry = prometheus.NewRegistry()
+
+var (
+	httpRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests by method, route template and status code.",
+	}, []string{"method", "route", "status"})
+
+	httpDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latency by method, route template and status code.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	loginAttempts = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "login_attempts_total",
+		Help:      "Login attempts by result: success, failure (bad credentials) or error.",
+	}, []string{"result"})
+
+	repositoryDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "repository_call_duration_seconds",
+		Help:      "Repository call latency by method and result.",
+		Buckets:   []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+	}, []string{"method", "result"})
+
+	retentionRecords = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "retention_records_total",
+		Help:      "Records anonymized or purged by the retention job, by data category and action.",
+	}, []string{"category", "action"})
+
+	retentionRuns = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "retention_runs_total",
+		Help:      "Retention job runs by result: success, failure or skipped (another replica held the lock).",
+	}, []string{"result"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		httpRequests,
+		httpDuration,
+		loginAttempts,
+		repositoryDuration,
+		retentionRecords,
+		retentionRuns,
+	)
+}
+
+// Handler sirve las metricas en el formato de texto de Prometheus.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{Registry: Registry})
+}
+
+// ObserveRequest registra una peticion HTTP. route debe ser la plantilla de
+// la ruta (/users/:id) y no el path, para no crear una serie por usuario.
+func ObserveRequest(method string, route string, status int, elapsed time.Duration) {
+	code := strconv.Itoa(status)
+	httpRequests.WithLabelValues(method, route, code).Inc()
+	httpDuration.WithLabelValues(method, route, code).Observe(elapsed.Seconds())
+}
+
+// ObserveLogin cuenta un intento de login con uno de los resultados Login*.
+func ObserveLogin(result string) {
+	loginAttempts.WithLabelValues(result).Inc()
+}
+
+// ObserveRepository registra la duracion de una llamada al repositorio.
+// result es "ok" o la clase de error que devolvio.
+func ObserveRepository(method string, result string, elapsed time.Duration) {
+	repositoryDuration.WithLabelValues(method, result).Observe(elapsed.Seconds())
+}
+
+// ObserveRetention suma records registros de category a los que se aplico
+// action. Las pasadas de prueba no se cuentan.
+func ObserveRetention(category string, action string, records int) {
+	retentionRecords.WithLabelValues(category, action).Add(float64(records))
+}
+
+// ObserveRetentionRun cuenta una pasada de retencion con uno de los
+// resultados Retention*.
+func ObserveRetentionRun(result string) {
+	retentionRuns.WithLabelValues(result).Inc()
+}
+
+// RegisterDB publica las estadisticas del pool de conexiones de db
+// (conexiones abiertas, en uso, esperas...) con la etiqueta db_name.
+func RegisterDB(db *sql.DB, name string) error {
+	return Registry.Register(collectors.NewDBStatsCollector(db, name))
+}
+
+// CacheCounts son los contadores acumulados de una cache.
+type CacheCounts struct {
+	Hits   uint64
+	Misses uint64
+	Errors uint64
+}
+
+// RegisterCache publica los aciertos, fallos y errores que devuelve stats.
+// Se lee en cada scrape, asi la cache no necesita conocer Prometheus.
+func RegisterCache(stats func() CacheCounts) error {
+	return Registry.Register(cacheCollector{stats: stats})
+}
+
+var (
+	cacheRequestsDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "cache", "requests_total"),
+		"User cache lookups by result: hit or miss.",
+		[]string{"result"}, nil,
+	)
+	cacheErrorsDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "cache", "errors_total"),
+		"Cache operations that failed and fell back to the database.",
+		nil, nil,
+	)
+)
+
+type cacheCollector struct {
+	stats func() CacheCounts
+}
+
+func (collector cacheCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- cacheRequestsDesc
+	ch <- cacheErrorsDesc
+}
+
+func (collector cacheCollector) Collect(ch chan<- prometheus.Metric) {
+	counts := collector.stats()
+	ch <- prometheus.MustNewConstMetric(cacheRequestsDesc, prometheus.CounterValue, float64(counts.Hits), "hit")
+	ch <- prometheus.MustNewConstMetric(cacheRequestsDesc, prometheus.CounterValue, float64(counts.Misses), "miss")
+	ch <- prometheus.MustNewConstMetric(cacheErrorsDesc, prometheus.CounterValue, float64(counts.Errors))
+}
+
+// Sample devuelve el valor actual de la serie name con exactamente esas
+// etiquetas: el valor de un contador o gauge, o la cantidad de observaciones
+// de un histograma. Devuelve 0 si la serie no existe. Sirve para tests y
+// diagnostico sin parsear la salida de Handler.
+func Sample(name string, labels prometheus.Labels) float64 {
+	families, err := Registry.Gather()
+	if err != nil {
+		return 0
+	}
+	for _, family := range families {
+		if family.GetName() != name {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			if !hasLabels(metric.GetLabel(), labels) {
+				continue
+			}
+			switch {
+			case metric.Counter != nil:
+				return metric.GetCounter().GetValue()
+			case metric.Gauge != nil:
+				return metric.GetGauge().GetValue()
+			case metric.Histogram != nil:
+				return float64(metric.GetHistogram().GetSampleCount())
+			}
+		}
+	}
+	return 0
+}
+
+func hasLabels(pairs []*dto.LabelPair, labels prometheus.Labels) bool {
+	if len(pairs) != len(labels) {
+		return false
+	}
+	for _, pair := range pairs {
+		if value, ok := labels[pair.GetName()]; !ok || value != pair.GetValue() {
+			return false
+		}
+	}
+	return true
+}
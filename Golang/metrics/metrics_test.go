@@ -0,0 +1,58 @@
+package metrics
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestObserveRequest(t *testing.T) {
+	labels := prometheus.Labels{"method": "GET", "route": "/test/:id", "status": "200"}
+	before := Sample("users_http_requests_total", labels)
+
+	ObserveRequest("GET", "/test/:id", http.StatusOK, 30*time.Millisecond)
+	ObserveRequest("GET", "/test/:id", http.StatusOK, 10*time.Millisecond)
+
+	assert.Equal(t, before+2, Sample("users_http_requests_total", labels))
+	assert.Equal(t, before+2, Sample("users_http_request_duration_seconds", labels))
+}
+
+func TestObserveLogin(t *testing.T) {
+	labels := prometheus.Labels{"result": LoginFailure}
+	before := Sample("users_login_attempts_total", labels)
+
+	ObserveLogin(LoginFailure)
+
+	assert.Equal(t, before+1, Sample("users_login_attempts_total", labels))
+}
+
+func TestHandler_ExposesRuntimeAndServiceMetrics(t *testing.T) {
+	ObserveRepository("GetUserById", "ok", time.Millisecond)
+
+	w := httptest.NewRecorder()
+	Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body, _ := io.ReadAll(w.Body)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, string(body), "go_goroutines")
+	assert.Contains(t, string(body), `users_repository_call_duration_seconds_count{method="GetUserById",result="ok"}`)
+}
+
+func TestRegisterCache(t *testing.T) {
+	counts := CacheCounts{Hits: 7, Misses: 3, Errors: 1}
+	require.NoError(t, RegisterCache(func() CacheCounts { return counts }))
+	defer Registry.Unregister(cacheCollector{})
+
+	assert.Equal(t, float64(7), Sample("users_cache_requests_total", prometheus.Labels{"result": "hit"}))
+	assert.Equal(t, float64(3), Sample("users_cache_requests_total", prometheus.Labels{"result": "miss"}))
+
+	counts.Hits = 8
+	assert.Equal(t, float64(8), Sample("users_cache_requests_total", prometheus.Labels{"result": "hit"}))
+	assert.Equal(t, float64(1), Sample("users_cache_errors_total", nil))
+}
@@ -0,0 +1,233 @@
+package middleware
+
+import (
+	"Golang/problem"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	detailOriginNotAllowed = problem.Text{ES: "El origen no está habilitado para esta API.", EN: "Origin is not allowed for this API."}
+	detailCORSMethod       = problem.Text{ES: "El método pedido no está soportado por esta ruta.", EN: "Requested method is not supported for this route."}
+	detailCORSHeader       = problem.Text{ES: "Uno de los headers pedidos no está permitido.", EN: "One of the requested headers is not allowed."}
+)
+
+// CORSConfig es la politica de CORS de un entorno.
+type CORSConfig struct {
+	// AllowedOrigins son origenes exactos ("https://app.example.com") o con
+	// comodin de subdominio ("https://*.example.com", que no incluye
+	// example.com). El esquema y el puerto tienen que coincidir.
+	AllowedOrigins []string
+	// AllowedHeaders son los headers que el navegador puede enviar.
+	AllowedHeaders []string
+	// ExposedHeaders son los headers de la respuesta que puede leer el
+	// frontend.
+	ExposedHeaders []string
+	// AllowCredentials permite enviar cookies y el header Authorization.
+	AllowCredentials bool
+	// MaxAge es cuanto cachea el navegador un preflight.
+	MaxAge time.Duration
+}
+
+// CORS aplica una politica de CORS. Los metodos de cada ruta salen de las
+// rutas registradas en gin (ver RegisterPreflight).
+type CORS struct {
+	exact       map[string]bool
+	wildcards   []wildcardOrigin
+	headers     map[string]bool
+	exposed     string
+	credentials bool
+	maxAge      string
+}
+
+// wildcardOrigin es "scheme://*.suffix:port".
+type wildcardOrigin struct {
+	scheme string
+	suffix string
+	port   string
+}
+
+// NewCORS valida config. Un origen mal escrito se rechaza al arrancar en
+// lugar de dejar al frontend sin acceso.
+func NewCORS(config CORSConfig) (*CORS, error) {
+	cors := &CORS{
+		exact:       map[string]bool{},
+		headers:     map[string]bool{},
+		exposed:     strings.Join(config.ExposedHeaders, ", "),
+		credentials: config.AllowCredentials,
+		maxAge:      strconv.Itoa(int(config.MaxAge.Seconds())),
+	}
+
+	for _, origin := range config.AllowedOrigins {
+		scheme, host, port, err := splitOrigin(origin)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CORS origin %q: %w", origin, err)
+		}
+		if suffix, ok := strings.CutPrefix(host, "*."); ok {
+			if strings.Contains(suffix, "*") || !strings.Contains(suffix, ".") {
+				return nil, fmt.Errorf("invalid CORS origin %q: wildcard must be the first label of a domain like *.example.com", origin)
+			}
+			cors.wildcards = append(cors.wildcards, wildcardOrigin{scheme: scheme, suffix: "." + suffix, port: port})
+			continue
+		}
+		if strings.Contains(host, "*") {
+			return nil, fmt.Errorf("invalid CORS origin %q: wildcard must be the first label of a domain like *.example.com", origin)
+		}
+		cors.exact[joinOrigin(scheme, host, port)] = true
+	}
+
+	for _, header := range config.AllowedHeaders {
+		cors.headers[strings.ToLower(strings.TrimSpace(header))] = true
+	}
+	return cors, nil
+}
+
+// splitOrigin separa un origen en esquema, host y puerto, en minusculas.
+// Un origen no tiene path, query ni credenciales.
+func splitOrigin(origin string) (scheme string, host string, port string, err error) {
+	parsed, err := url.Parse(origin)
+	if err != nil {
+		return "", "", "", err
+	}
+	if parsed.Scheme != "http" && parsed.Scheme != "https" {
+		return "", "", "", fmt.Errorf("scheme must be http or https")
+	}
+	if parsed.User != nil || parsed.RawQuery != "" || parsed.Fragment != "" || (parsed.Path != "" && parsed.Path != "/") {
+		return "", "", "", fmt.Errorf("must be only scheme://host[:port]")
+	}
+	host = strings.ToLower(parsed.Hostname())
+	port = parsed.Port()
+	if host == "" {
+		return "", "", "", fmt.Errorf("host is empty")
+	}
+	if _, err := strconv.Atoi(port); port != "" && err != nil {
+		return "", "", "", fmt.Errorf("invalid port")
+	}
+	return parsed.Scheme, host, port, nil
+}
+
+func joinOrigin(scheme string, host string, port string) string {
+	if port == "" {
+		return scheme + "://" + host
+	}
+	return scheme + "://" + host + ":" + port
+}
+
+// Allowed informa si origin coincide exactamente con un origen habilitado
+// o con un comodin. "https://localhost:3000.evil.com" no coincide con
+// "https://localhost:3000".
+func (cors *CORS) Allowed(origin string) bool {
+	if origin == "" {
+		return false
+	}
+	scheme, host, port, err := splitOrigin(origin)
+	if err != nil || strings.HasSuffix(origin, "/") {
+		return false
+	}
+	if cors.exact[joinOrigin(scheme, host, port)] {
+		return true
+	}
+	for _, wildcard := range cors.wildcards {
+		if scheme == wildcard.scheme && port == wildcard.port &&
+			strings.HasSuffix(host, wildcard.suffix) && len(host) > len(wildcard.suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Middleware agrega los headers de CORS a cualquier respuesta para un
+// origen habilitado. No bloquea pedidos: eso lo hace el navegador.
+func (cors *CORS) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		header := c.Writer.Header()
+		header.Add("Vary", "Origin")
+		if origin := c.GetHeader("Origin"); cors.Allowed(origin) {
+			header.Set("Access-Control-Allow-Origin", origin)
+			if cors.credentials {
+				header.Set("Access-Control-Allow-Credentials", "true")
+			}
+			if c.Request.Method != http.MethodOptions && cors.exposed != "" {
+				header.Set("Access-Control-Expose-Headers", cors.exposed)
+			}
+		}
+		c.Next()
+	}
+}
+
+// RegisterPreflight agrega un OPTIONS a cada path registrado en router que
+// responde el preflight con los metodos de ese path. Debe llamarse despues
+// de registrar todas las rutas.
+func (cors *CORS) RegisterPreflight(router *gin.Engine) {
+	methods := map[string][]string{}
+	for _, route := range router.Routes() {
+		if route.Method != http.MethodOptions {
+			methods[route.Path] = append(methods[route.Path], route.Method)
+		}
+	}
+	for path, list := range methods {
+		sort.Strings(list)
+		router.OPTIONS(path, cors.preflight(list))
+	}
+}
+
+func (cors *CORS) preflight(methods []string) gin.HandlerFunc {
+	allow := strings.Join(append(append([]string{}, methods...), http.MethodOptions), ", ")
+	return func(c *gin.Context) {
+		requested := c.GetHeader("Access-Control-Request-Method")
+		if requested == "" {
+			// OPTIONS comun, no un preflight.
+			c.Header("Allow", allow)
+			c.AbortWithStatus(http.StatusNoContent)
+			return
+		}
+
+		header := c.Writer.Header()
+		header.Add("Vary", "Access-Control-Request-Method")
+		header.Add("Vary", "Access-Control-Request-Headers")
+
+		if !cors.Allowed(c.GetHeader("Origin")) {
+			problem.Write(c, http.StatusForbidden, problem.TypeForbidden, detailOriginNotAllowed)
+			return
+		}
+		if !contains(methods, requested) {
+			problem.Write(c, http.StatusForbidden, problem.TypeForbidden, detailCORSMethod)
+			return
+		}
+		var headers []string
+		for _, name := range strings.Split(c.GetHeader("Access-Control-Request-Headers"), ",") {
+			name = strings.ToLower(strings.TrimSpace(name))
+			if name == "" {
+				continue
+			}
+			if !cors.headers[name] {
+				problem.Write(c, http.StatusForbidden, problem.TypeForbidden, detailCORSHeader)
+				return
+			}
+			headers = append(headers, name)
+		}
+
+		header.Set("Access-Control-Allow-Methods", allow)
+		if len(headers) > 0 {
+			header.Set("Access-Control-Allow-Headers", strings.Join(headers, ", "))
+		}
+		header.Set("Access-Control-Max-Age", cors.maxAge)
+		c.AbortWithStatus(http.StatusNoContent)
+	}
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
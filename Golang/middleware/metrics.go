@@ -0,0 +1,28 @@
+package middleware
+
+import (
+	"Golang/metrics"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// unmatchedRoute agrupa las peticiones que no coinciden con ninguna ruta,
+// para que un escaneo de paths no cree una serie por cada uno.
+const unmatchedRoute = "unmatched"
+
+// Metrics registra cada peticion con la plantilla de la ruta (c.FullPath),
+// el metodo y el status final. Debe ir antes que el resto de los middlewares
+// para medir tambien lo que ellos cortan.
+func Metrics() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = unmatchedRoute
+		}
+		metrics.ObserveRequest(c.Request.Method, route, c.Writer.Status(), time.Since(start))
+	}
+}
@@ -0,0 +1,47 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"Golang/metrics"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetrics_UsesRouteTemplate(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(Metrics())
+	router.GET("/widgets/:id", func(c *gin.Context) { c.Status(http.StatusNoContent) })
+
+	found := prometheus.Labels{"method": "GET", "route": "/widgets/:id", "status": "204"}
+	unmatched := prometheus.Labels{"method": "GET", "route": "unmatched", "status": "404"}
+	beforeFound := metrics.Sample("users_http_requests_total", found)
+	beforeUnmatched := metrics.Sample("users_http_requests_total", unmatched)
+
+	for _, path := range []string{"/widgets/1", "/widgets/2", "/nope/3"} {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	assert.Equal(t, beforeFound+2, metrics.Sample("users_http_requests_total", found))
+	assert.Equal(t, beforeUnmatched+1, metrics.Sample("users_http_requests_total", unmatched))
+	assert.Zero(t, metrics.Sample("users_http_requests_total", prometheus.Labels{"method": "GET", "route": "/widgets/1", "status": "204"}))
+}
+
+func TestMetrics_CountsAbortedRequests(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(Metrics())
+	router.GET("/private", AuthMiddleware(), func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	labels := prometheus.Labels{"method": "GET", "route": "/private", "status": "401"}
+	before := metrics.Sample("users_http_requests_total", labels)
+
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/private", nil))
+
+	assert.Equal(t, before+1, metrics.Sample("users_http_requests_total", labels))
+}
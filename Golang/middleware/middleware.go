@@ -1,6 +1,8 @@
 package middleware
 
 import (
+	"Golang/auth"
+	"Golang/problem"
 	"fmt"
 	"net/http"
 	"strings"
@@ -9,8 +11,14 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
+var (
+	detailMissingToken = problem.Text{ES: "Se requiere el header Authorization.", EN: "Authorization header is required."}
+	detailInvalidToken = problem.Text{ES: "Token inválido.", EN: "Invalid token."}
+	detailAdminOnly    = problem.Text{ES: "Solo un administrador puede acceder a este recurso.", EN: "Only an administrator can access this resource."}
+)
+
 func ExtractClaims(tokenStr string) (jwt.MapClaims, error) {
-	hmacSecret := []byte("bitsion")
+	hmacSecret := auth.SigningKey()
 
 	token, err := jwt.Parse(tokenStr, func(token *jwt.Token) (interface{}, error) {
 		return hmacSecret, nil
@@ -31,31 +39,66 @@ func AuthMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization header is required"})
-			c.Abort()
+			problem.Write(c, http.StatusUnauthorized, problem.TypeUnauthorized, detailMissingToken)
 			return
 		}
 
 		tokenString := strings.TrimPrefix(authHeader, "Bearer ")
 		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-			return []byte("bitsion"), nil
+			return auth.SigningKey(), nil
 		})
 
 		if err != nil || !token.Valid {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
-			c.Abort()
+			problem.Write(c, http.StatusUnauthorized, problem.TypeUnauthorized, detailInvalidToken)
 			return
 		}
 
 		claims, ok := token.Claims.(jwt.MapClaims)
 		if !ok || !token.Valid {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
-			c.Abort()
+			problem.Write(c, http.StatusUnauthorized, problem.TypeUnauthorized, detailInvalidToken)
 			return
 		}
 
-		c.Set("userID", claims["user_id"])
-		c.Set("admin", claims["admin"])
+		c.Set("userID", claim(claims, "idU", "user_id"))
+		c.Set("admin", claim(claims, "Adminu", "admin"))
+		c.Set("role", claim(claims, "role"))
+		c.Next()
+	}
+}
+
+// OptionalAuth deja pasar los pedidos sin Authorization y valida los que lo
+// traen igual que AuthMiddleware: un token invalido no se trata como anonimo.
+func OptionalAuth() gin.HandlerFunc {
+	auth := AuthMiddleware()
+	return func(c *gin.Context) {
+		if c.GetHeader("Authorization") == "" {
+			c.Next()
+			return
+		}
+		auth(c)
+	}
+}
+
+// claim devuelve el primer claim presente. El login firma "idU" y "Adminu";
+// los nombres en minuscula se aceptan por los tokens emitidos por otras
+// herramientas.
+func claim(claims jwt.MapClaims, names ...string) interface{} {
+	for _, name := range names {
+		if value, ok := claims[name]; ok {
+			return value
+		}
+	}
+	return nil
+}
+
+// RequireAdmin corta con 403 si el token no es de un administrador. Debe ir
+// despues de AuthMiddleware.
+func RequireAdmin() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if admin, _ := c.Get("admin"); admin != true {
+			problem.Write(c, http.StatusForbidden, problem.TypeForbidden, detailAdminOnly)
+			return
+		}
 		c.Next()
 	}
 }
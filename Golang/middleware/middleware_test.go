@@ -1,6 +1,7 @@
 package middleware
 
 import (
+	"Golang/auth"
 	"net/http/httptest"
 	"testing"
 	"time"
@@ -41,6 +42,26 @@ func TestAuthMiddleware_NoHeader(t *testing.T) {
     assert.Equal(t, 401, w.Code)
 }
 
+func TestOptionalAuth(t *testing.T) {
+    gin.SetMode(gin.TestMode)
+    w := httptest.NewRecorder()
+    c, _ := gin.CreateTestContext(w)
+    c.Request = httptest.NewRequest("POST", "/", nil)
+
+    OptionalAuth()(c)
+    assert.False(t, c.IsAborted())
+    _, ok := c.Get("admin")
+    assert.False(t, ok)
+
+    w = httptest.NewRecorder()
+    c, _ = gin.CreateTestContext(w)
+    c.Request = httptest.NewRequest("POST", "/", nil)
+    c.Request.Header.Set("Authorization", "Bearer invalido")
+
+    OptionalAuth()(c)
+    assert.Equal(t, 401, w.Code)
+}
+
 func TestAuthMiddleware_ValidToken(t *testing.T) {
     gin.SetMode(gin.TestMode)
     w := httptest.NewRecorder()
@@ -62,3 +83,41 @@ func TestAuthMiddleware_ValidToken(t *testing.T) {
     assert.True(t, ok)
     assert.Equal(t, float64(12), v)
 }
+
+func TestAuthMiddleware_ReadsLoginClaims(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	// los mismos claims que firma el login del servicio
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"idU": 7, "Adminu": true, "exp": time.Now().Add(time.Hour).Unix()})
+	tok, _ := token.SignedString(auth.SigningKey())
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("Authorization", "Bearer "+tok)
+	c.Request = req
+
+	AuthMiddleware()(c)
+
+	assert.False(t, c.IsAborted())
+	assert.Equal(t, float64(7), c.Value("userID"))
+	assert.Equal(t, true, c.Value("admin"))
+}
+
+func TestRequireAdmin(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	for name, admin := range map[string]interface{}{"admin": true, "no admin": false, "sin claim": nil} {
+		t.Run(name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest("GET", "/status", nil)
+			c.Set("admin", admin)
+
+			RequireAdmin()(c)
+
+			assert.Equal(t, admin != true, c.IsAborted())
+			if admin != true {
+				assert.Equal(t, 403, w.Code)
+			}
+		})
+	}
+}
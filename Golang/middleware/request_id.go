@@ -0,0 +1,78 @@
+package middleware
+
+import (
+	"Golang/logging"
+	"Golang/problem"
+	"crypto/rand"
+	"encoding/hex"
+	"regexp"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+)
+
+// RequestIDKey es la clave de gin.Context con el id del pedido.
+const RequestIDKey = "requestID"
+
+// validRequestID limita lo que se acepta de un cliente o proxy: un id
+// arbitrario terminaria tal cual en los logs y en las respuestas.
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._:\-]{1,128}$`)
+
+// RequestID reutiliza el X-Request-ID entrante si es valido o genera uno, lo
+// devuelve en la respuesta y deja en el contexto del pedido un logger con
+// request_id, metodo y ruta para que lo usen las capas de abajo.
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(problem.RequestIDHeader)
+		if !validRequestID.MatchString(id) {
+			id = newRequestID()
+		}
+		c.Set(RequestIDKey, id)
+		c.Header(problem.RequestIDHeader, id)
+
+		ctx := c.Request.Context()
+		entry := logging.FromContext(ctx).WithFields(log.Fields{
+			"request_id": id,
+			"method":     c.Request.Method,
+			"route":      c.FullPath(),
+		})
+		c.Request = c.Request.WithContext(logging.WithContext(ctx, entry))
+		c.Next()
+	}
+}
+
+// AccessLog escribe una linea por pedido con el status y la duracion. Usa
+// la ruta y el path sin query string, que puede traer datos del usuario.
+// Debe ir despues de RequestID.
+func AccessLog() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		status := c.Writer.Status()
+		entry := logging.FromContext(c.Request.Context()).WithFields(log.Fields{
+			"path":       c.Request.URL.Path,
+			"status":     status,
+			"latency_ms": time.Since(start).Milliseconds(),
+			"client_ip":  c.ClientIP(),
+			"bytes":      c.Writer.Size(),
+		})
+		switch {
+		case status >= 500:
+			entry.Error("request completed")
+		case status >= 400:
+			entry.Warn("request completed")
+		default:
+			entry.Info("request completed")
+		}
+	}
+}
+
+func newRequestID() string {
+	var buf [16]byte
+	if _, err := rand.Read(buf[:]); err != nil {
+		return "unknown"
+	}
+	return hex.EncodeToString(buf[:])
+}
@@ -0,0 +1,91 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"Golang/logging"
+
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func requestIDRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(RequestID(), AccessLog())
+	router.GET("/users/:id", func(c *gin.Context) {
+		logging.FromContext(c.Request.Context()).Info("handler")
+		c.String(http.StatusOK, c.GetString(RequestIDKey))
+	})
+	return router
+}
+
+// captureLogs redirige el logger estandar a un buffer durante el test.
+func captureLogs(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	original := log.StandardLogger().Out
+	var buf bytes.Buffer
+	require.NoError(t, logging.Setup(logging.Config{Output: &buf}))
+	t.Cleanup(func() { logging.Setup(logging.Config{Output: original}) })
+	return &buf
+}
+
+func TestRequestID_GeneratesWhenMissing(t *testing.T) {
+	captureLogs(t)
+	w := httptest.NewRecorder()
+	requestIDRouter().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/users/1", nil))
+
+	id := w.Header().Get("X-Request-ID")
+	assert.Len(t, id, 32)
+	assert.Equal(t, id, w.Body.String())
+}
+
+func TestRequestID_PropagatesValidIncomingId(t *testing.T) {
+	captureLogs(t)
+	req := httptest.NewRequest(http.MethodGet, "/users/1", nil)
+	req.Header.Set("X-Request-ID", "front-1234.abc")
+	w := httptest.NewRecorder()
+	requestIDRouter().ServeHTTP(w, req)
+
+	assert.Equal(t, "front-1234.abc", w.Header().Get("X-Request-ID"))
+}
+
+func TestRequestID_ReplacesInvalidIncomingId(t *testing.T) {
+	captureLogs(t)
+	for _, bad := range []string{"con espacios", "<script>", strings.Repeat("a", 129)} {
+		req := httptest.NewRequest(http.MethodGet, "/users/1", nil)
+		req.Header.Set("X-Request-ID", bad)
+		w := httptest.NewRecorder()
+		requestIDRouter().ServeHTTP(w, req)
+
+		assert.NotEqual(t, bad, w.Header().Get("X-Request-ID"))
+		assert.Len(t, w.Header().Get("X-Request-ID"), 32)
+	}
+}
+
+func TestRequestID_LogsCarryRequestFields(t *testing.T) {
+	buf := captureLogs(t)
+	req := httptest.NewRequest(http.MethodGet, "/users/7?nombre=ana", nil)
+	req.Header.Set("X-Request-ID", "req-1")
+	requestIDRouter().ServeHTTP(httptest.NewRecorder(), req)
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 2)
+	var handler, access map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &handler))
+	require.NoError(t, json.Unmarshal([]byte(lines[1]), &access))
+
+	assert.Equal(t, "req-1", handler["request_id"])
+	assert.Equal(t, "/users/:id", handler["route"])
+	assert.Equal(t, "req-1", access["request_id"])
+	assert.Equal(t, float64(http.StatusOK), access["status"])
+	assert.Equal(t, "/users/7", access["path"])
+	assert.NotContains(t, buf.String(), "nombre=ana")
+}
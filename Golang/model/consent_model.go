@@ -0,0 +1,28 @@
+package model
+
+import "time"
+
+// ConsentVersion es un texto de consentimiento publicado para el tratamiento
+// de datos de salud. Los textos no se editan: un cambio es una version nueva
+// y la vigente es la de mayor Version.
+type ConsentVersion struct {
+	Id          int    `gorm:"primaryKey;autoIncrement"`
+	Version     int    `gorm:"not null;unique_index"`
+	Text        string `gorm:"type:text;not null"`
+	PublishedAt time.Time
+}
+
+// Consent es un registro de otorgamiento o retiro del consentimiento. La
+// tabla solo crece: el estado de un usuario es su ultimo registro.
+type Consent struct {
+	Id      int `gorm:"primaryKey;autoIncrement"`
+	UserId  int `gorm:"not null;index"`
+	Version int `gorm:"not null"`
+	// Granted es true al otorgar y false al retirar.
+	Granted bool `gorm:"not null"`
+	// IP es la direccion desde la que se hizo el pedido, como prueba del
+	// consentimiento. La politica de retencion de consent_records la borra
+	// pasado el plazo.
+	IP        string `gorm:"type:varchar(64)"`
+	CreatedAt time.Time
+}
@@ -0,0 +1,23 @@
+package model
+
+import "time"
+
+// Login es un intento de inicio de sesion de un usuario registrado. Se
+// conserva lo que indique la politica de retencion de login_history.
+type Login struct {
+	Id      int    `gorm:"primaryKey;autoIncrement"`
+	UserId  int    `gorm:"not null;index"`
+	Success bool   `gorm:"not null"`
+	IP      string `gorm:"type:varchar(64)"`
+	// CreatedAt lleva indice porque la purga borra por fecha.
+	CreatedAt time.Time `gorm:"index"`
+}
+
+// JobLock es un lock con vencimiento para que una tarea periodica corra en
+// una sola replica. Quien lo toma lo tiene hasta ExpiresAt aunque muera sin
+// liberarlo.
+type JobLock struct {
+	Name      string    `gorm:"primary_key;type:varchar(64)"`
+	Owner     string    `gorm:"type:varchar(128);not null"`
+	ExpiresAt time.Time `gorm:"not null"`
+}
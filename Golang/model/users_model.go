@@ -1,5 +1,7 @@
 package model
 
+import "time"
+
 type User struct {
 	Id           int    `gorm:"primaryKey;autoIncrement"`
 	Nombre       string `gorm:"type:varchar(600);not null"`
@@ -12,4 +14,52 @@ type User struct {
 	Enfermedades string `gorm:"type:varchar(600);not null"`
 	Admin        bool   `gorm:"not null"`
 	Estado       bool   `gorm:"not null"`
+	// Version se incrementa en cada escritura y se expone como ETag para
+	// control de concurrencia optimista.
+	Version int `gorm:"not null;default:1"`
+	// UpdatedAt lo mantiene gorm en cada escritura; se expone como
+	// Last-Modified.
+	UpdatedAt time.Time `gorm:"index"`
+	// Las columnas *Enc guardan Atributos, Enfermedades y Diabetico cifrados
+	// con la clave de datos de la fila, que va envuelta en DataKey. Con
+	// DataKey vacio la fila esta en texto plano (ver clientUsers.Encrypted).
+	AtributosEnc    string `gorm:"type:text"`
+	EnfermedadesEnc string `gorm:"type:text"`
+	DiabeticoEnc    string `gorm:"type:text"`
+	DataKey         string `gorm:"type:text"`
+	// DiabeticoIndex es el indice ciego de Diabetico: permite filtrar por
+	// igualdad sin descifrar.
+	DiabeticoIndex string `gorm:"type:varchar(64);index"`
+	// AnonymizedAt es cuando la politica de retencion anonimizo la cuenta;
+	// nil si no se anonimizo.
+	AnonymizedAt *time.Time
+	// DeactivatedAt es cuando la cuenta paso a Estado false; nil si esta
+	// activa. A diferencia de UpdatedAt no cambia con otras escrituras, asi
+	// que de ella se cuenta el plazo de retencion de las cuentas inactivas.
+	DeactivatedAt *time.Time `gorm:"index"`
+}
+
+// UsersStamp resume el estado de la tabla de usuarios para validar caches del
+// listado sin leerlo: cambia con cada alta, baja o modificacion.
+type UsersStamp struct {
+	Count        int
+	VersionSum   int
+	MaxId        int
+	LastModified time.Time
+}
+
+// UserFilter restringe los usuarios que recorre ScanUsers. Los punteros nil
+// no filtran; Nombre busca una subcadena sin distinguir mayusculas.
+// DiabeticoIndex, si no esta vacio, reemplaza a Diabetico en las filas
+// cifradas; las filas en texto plano se siguen filtrando por Diabetico.
+// DeactivatedBefore, si no es cero, deja las filas desactivadas antes de
+// esa fecha.
+type UserFilter struct {
+	Admin             *bool
+	Estado            *bool
+	Diabetico         *bool
+	DiabeticoIndex    string
+	Nombre            string
+	Anonymized        *bool
+	DeactivatedBefore time.Time
 }
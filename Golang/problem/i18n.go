@@ -0,0 +1,55 @@
+package problem
+
+import (
+	"net/http"
+
+	"golang.org/x/text/language"
+)
+
+const (
+	Spanish = "es"
+	English = "en"
+)
+
+var matcher = language.NewMatcher([]language.Tag{language.Spanish, language.English})
+
+// Language elige el idioma de la respuesta a partir de Accept-Language.
+// Sin header, o con idiomas que no soportamos, se responde en español.
+func Language(r *http.Request) string {
+	header := r.Header.Get("Accept-Language")
+	if header == "" {
+		return Spanish
+	}
+	tags, _, err := language.ParseAcceptLanguage(header)
+	if err != nil || len(tags) == 0 {
+		return Spanish
+	}
+	_, index, confidence := matcher.Match(tags...)
+	if confidence == language.No || index != 1 {
+		return Spanish
+	}
+	return English
+}
+
+var titles = map[int]Text{
+	http.StatusBadRequest:           {ES: "Solicitud inválida", EN: "Bad Request"},
+	http.StatusUnauthorized:         {ES: "No autenticado", EN: "Unauthorized"},
+	http.StatusForbidden:            {ES: "Acceso denegado", EN: "Forbidden"},
+	http.StatusNotFound:             {ES: "No encontrado", EN: "Not Found"},
+	http.StatusMethodNotAllowed:     {ES: "Método no permitido", EN: "Method Not Allowed"},
+	http.StatusConflict:             {ES: "Conflicto", EN: "Conflict"},
+	http.StatusPreconditionFailed:   {ES: "Precondición fallida", EN: "Precondition Failed"},
+	http.StatusUnsupportedMediaType: {ES: "Tipo de contenido no soportado", EN: "Unsupported Media Type"},
+	http.StatusUnprocessableEntity:  {ES: "Datos inválidos", EN: "Unprocessable Entity"},
+	http.StatusPreconditionRequired: {ES: "Precondición requerida", EN: "Precondition Required"},
+	http.StatusInternalServerError:  {ES: "Error interno", EN: "Internal Server Error"},
+	http.StatusServiceUnavailable:   {ES: "Servicio no disponible", EN: "Service Unavailable"},
+}
+
+// Title devuelve el titulo traducido para un status HTTP.
+func Title(status int, lang string) string {
+	if title, ok := titles[status]; ok {
+		return title.In(lang)
+	}
+	return http.StatusText(status)
+}
@@ -0,0 +1,104 @@
+// Package problem escribe las respuestas de error de la API en formato
+// RFC 7807 (application/problem+json), con titulos en español o ingles
+// segun el header Accept-Language.
+package problem
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	ContentType     = "application/problem+json"
+	RequestIDHeader = "X-Request-ID"
+)
+
+// Tipos de problema. Un cliente puede distinguir errores por este campo sin
+// depender del texto del titulo.
+const (
+	TypeBlank        = "about:blank"
+	TypeValidation   = "urn:problem:validation"
+	TypeNotFound     = "urn:problem:not-found"
+	TypeConflict     = "urn:problem:conflict"
+	TypeUnauthorized = "urn:problem:unauthorized"
+	TypeForbidden    = "urn:problem:forbidden"
+
+	TypePreconditionFailed = "urn:problem:precondition-failed"
+)
+
+// Problem es el cuerpo de una respuesta de error.
+type Problem struct {
+	Type      string       `json:"type"`
+	Title     string       `json:"title"`
+	Status    int          `json:"status"`
+	Detail    string       `json:"detail,omitempty"`
+	Instance  string       `json:"instance,omitempty"`
+	RequestID string       `json:"requestId,omitempty"`
+	Errors    []FieldError `json:"errors,omitempty"`
+}
+
+// FieldError describe por que fallo la validacion de un campo del body.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// Text es un mensaje con sus traducciones.
+type Text struct {
+	ES string
+	EN string
+}
+
+// In devuelve la traduccion para lang, o la española si no existe.
+func (t Text) In(lang string) string {
+	if lang == English && t.EN != "" {
+		return t.EN
+	}
+	return t.ES
+}
+
+// Write responde con un problem+json y aborta la cadena de handlers.
+// El titulo sale del status; detail se traduce segun Accept-Language.
+func Write(c *gin.Context, status int, problemType string, detail Text, fields ...FieldError) {
+	lang := Language(c.Request)
+	if problemType == "" {
+		problemType = TypeBlank
+	}
+
+	body := Problem{
+		Type:      problemType,
+		Title:     Title(status, lang),
+		Status:    status,
+		Detail:    detail.In(lang),
+		Instance:  c.Request.URL.RequestURI(),
+		RequestID: requestID(c),
+		Errors:    fields,
+	}
+
+	c.Header("Content-Language", lang)
+	c.Abort()
+	c.Render(status, render{body})
+}
+
+func requestID(c *gin.Context) string {
+	if id := c.Writer.Header().Get(RequestIDHeader); id != "" {
+		return id
+	}
+	return c.GetHeader(RequestIDHeader)
+}
+
+// render serializa el problema como JSON pero con el content type de RFC 7807.
+type render struct {
+	problem Problem
+}
+
+func (r render) Render(w http.ResponseWriter) error {
+	r.WriteContentType(w)
+	return json.NewEncoder(w).Encode(r.problem)
+}
+
+func (r render) WriteContentType(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", ContentType)
+}
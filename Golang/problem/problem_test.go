@@ -0,0 +1,73 @@
+package problem
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLanguage(t *testing.T) {
+	cases := map[string]string{
+		"":                        Spanish,
+		"en":                      English,
+		"en-US,en;q=0.9":          English,
+		"es-AR,es;q=0.9,en;q=0.8": Spanish,
+		"fr-FR":                   Spanish,
+		"fr-FR,en;q=0.5":          English,
+		"not a header;;":          Spanish,
+	}
+	for header, want := range cases {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Accept-Language", header)
+		assert.Equal(t, want, Language(req), header)
+	}
+}
+
+func TestWrite(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/users?x=1", nil)
+	c.Request.Header.Set("Accept-Language", "en")
+	c.Request.Header.Set(RequestIDHeader, "req-123")
+
+	Write(c, http.StatusBadRequest, TypeValidation,
+		Text{ES: "Datos inválidos.", EN: "Invalid data."},
+		FieldError{Field: "nombre", Message: "is required"})
+
+	assert.True(t, c.IsAborted())
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, ContentType, w.Header().Get("Content-Type"))
+
+	var got Problem
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &got))
+	assert.Equal(t, Problem{
+		Type:      TypeValidation,
+		Title:     "Bad Request",
+		Status:    http.StatusBadRequest,
+		Detail:    "Invalid data.",
+		Instance:  "/users?x=1",
+		RequestID: "req-123",
+		Errors:    []FieldError{{Field: "nombre", Message: "is required"}},
+	}, got)
+}
+
+func TestWrite_DefaultsToSpanish(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/users/9", nil)
+
+	Write(c, http.StatusNotFound, "", Text{ES: "El usuario no existe.", EN: "The user does not exist."})
+
+	var got Problem
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &got))
+	assert.Equal(t, TypeBlank, got.Type)
+	assert.Equal(t, "No encontrado", got.Title)
+	assert.Equal(t, "El usuario no existe.", got.Detail)
+	assert.Equal(t, "es", w.Header().Get("Content-Language"))
+}
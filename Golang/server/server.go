@@ -0,0 +1,193 @@
+// Package server reemplaza router.Run por un http.Server con timeouts y un
+// apagado ordenado: al recibir SIGINT/SIGTERM deja de aceptar conexiones,
+// espera a que terminen los pedidos en curso hasta un plazo y despues cierra
+// las dependencias (pool de la base, cache, exportador de trazas).
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	defaultReadHeaderTimeout = 5 * time.Second
+	defaultReadTimeout       = 15 * time.Second
+	defaultWriteTimeout      = 30 * time.Second
+	defaultIdleTimeout       = 60 * time.Second
+	defaultShutdownTimeout   = 20 * time.Second
+)
+
+// Config son los parametros del servidor HTTP. Los valores en cero toman el
+// default de cada campo.
+type Config struct {
+	// Addr es la direccion de escucha, por ejemplo ":8080".
+	Addr string
+	// ReadHeaderTimeout limita la lectura de los headers (5s).
+	ReadHeaderTimeout time.Duration
+	// ReadTimeout limita la lectura del pedido completo (15s).
+	ReadTimeout time.Duration
+	// WriteTimeout limita la escritura de la respuesta (30s).
+	WriteTimeout time.Duration
+	// IdleTimeout cierra conexiones keep-alive sin uso (60s).
+	IdleTimeout time.Duration
+	// ShutdownTimeout es cuanto se espera a los pedidos en curso y a los
+	// cierres al apagar (20s).
+	ShutdownTimeout time.Duration
+	// MaxHeaderBytes limita el tamaño de los headers (1 MB).
+	MaxHeaderBytes int
+	// TLSCertFile y TLSKeyFile activan HTTPS si se indican los dos.
+	TLSCertFile string
+	TLSKeyFile  string
+	// TLSMinVersion es "1.2" (por defecto) o "1.3".
+	TLSMinVersion string
+}
+
+func (config Config) withDefaults() Config {
+	if config.ReadHeaderTimeout <= 0 {
+		config.ReadHeaderTimeout = defaultReadHeaderTimeout
+	}
+	if config.ReadTimeout <= 0 {
+		config.ReadTimeout = defaultReadTimeout
+	}
+	if config.WriteTimeout <= 0 {
+		config.WriteTimeout = defaultWriteTimeout
+	}
+	if config.IdleTimeout <= 0 {
+		config.IdleTimeout = defaultIdleTimeout
+	}
+	if config.ShutdownTimeout <= 0 {
+		config.ShutdownTimeout = defaultShutdownTimeout
+	}
+	if config.MaxHeaderBytes <= 0 {
+		config.MaxHeaderBytes = http.DefaultMaxHeaderBytes
+	}
+	return config
+}
+
+type closer struct {
+	name  string
+	close func(context.Context) error
+}
+
+// Server es un http.Server con su ciclo de vida.
+type Server struct {
+	config  Config
+	http    *http.Server
+	closers []closer
+}
+
+// New arma el servidor para handler. Falla si la configuracion de TLS es
+// invalida.
+func New(handler http.Handler, config Config) (*Server, error) {
+	config = config.withDefaults()
+	if (config.TLSCertFile == "") != (config.TLSKeyFile == "") {
+		return nil, errors.New("both TLS certificate and key files are required")
+	}
+
+	httpServer := &http.Server{
+		Addr:              config.Addr,
+		Handler:           handler,
+		ReadHeaderTimeout: config.ReadHeaderTimeout,
+		ReadTimeout:       config.ReadTimeout,
+		WriteTimeout:      config.WriteTimeout,
+		IdleTimeout:       config.IdleTimeout,
+		MaxHeaderBytes:    config.MaxHeaderBytes,
+	}
+	if config.TLSCertFile != "" {
+		minVersion, err := tlsVersion(config.TLSMinVersion)
+		if err != nil {
+			return nil, err
+		}
+		httpServer.TLSConfig = &tls.Config{MinVersion: minVersion}
+	}
+
+	return &Server{config: config, http: httpServer}, nil
+}
+
+// OnShutdown registra una dependencia a cerrar despues de drenar los
+// pedidos. Se cierran en orden inverso al registro, como los defer.
+func (server *Server) OnShutdown(name string, close func(context.Context) error) {
+	server.closers = append(server.closers, closer{name: name, close: close})
+}
+
+// Run escucha en config.Addr hasta que ctx se cancela (main lo cancela con
+// SIGINT/SIGTERM) y entonces apaga el servidor ordenadamente.
+func (server *Server) Run(ctx context.Context) error {
+	listener, err := net.Listen("tcp", server.config.Addr)
+	if err != nil {
+		return fmt.Errorf("listening on %s: %w", server.config.Addr, err)
+	}
+	return server.Serve(ctx, listener)
+}
+
+// Serve atiende en listener hasta que ctx se cancela. Devuelve nil si el
+// apagado fue limpio, o el error del servidor, del drenado o de algun cierre.
+func (server *Server) Serve(ctx context.Context, listener net.Listener) error {
+	serveErr := make(chan error, 1)
+	go func() {
+		log.WithFields(log.Fields{
+			"addr": listener.Addr().String(),
+			"tls":  server.http.TLSConfig != nil,
+		}).Info("HTTP server listening")
+		if server.http.TLSConfig != nil {
+			serveErr <- server.http.ServeTLS(listener, server.config.TLSCertFile, server.config.TLSKeyFile)
+		} else {
+			serveErr <- server.http.Serve(listener)
+		}
+	}()
+
+	select {
+	case err := <-serveErr:
+		// El servidor no arranco (por ejemplo, certificado invalido): se
+		// cierran igual las dependencias.
+		return errors.Join(err, server.close(context.Background()))
+	case <-ctx.Done():
+	}
+
+	log.WithField("timeout", server.config.ShutdownTimeout.String()).Info("Shutting down, draining in-flight requests")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), server.config.ShutdownTimeout)
+	defer cancel()
+
+	var err error
+	if shutdownErr := server.http.Shutdown(shutdownCtx); shutdownErr != nil {
+		log.WithError(shutdownErr).Warn("Requests still in flight after the shutdown timeout, closing their connections")
+		server.http.Close()
+		err = fmt.Errorf("draining requests: %w", shutdownErr)
+	}
+	err = errors.Join(err, server.close(shutdownCtx))
+	if err == nil {
+		log.Info("Shutdown complete")
+	}
+	return err
+}
+
+func (server *Server) close(ctx context.Context) error {
+	var errs []error
+	for i := len(server.closers) - 1; i >= 0; i-- {
+		closer := server.closers[i]
+		if err := closer.close(ctx); err != nil {
+			log.WithError(err).WithField("dependency", closer.name).Error("Could not close dependency")
+			errs = append(errs, fmt.Errorf("closing %s: %w", closer.name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func tlsVersion(version string) (uint16, error) {
+	switch strings.TrimSpace(version) {
+	case "", "1.2":
+		return tls.VersionTLS12, nil
+	case "1.3":
+		return tls.VersionTLS13, nil
+	default:
+		return 0, fmt.Errorf("unsupported TLS minimum version %q", version)
+	}
+}
@@ -0,0 +1,189 @@
+package server
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// start sirve handler en un puerto libre y devuelve la URL base, la funcion
+// que simula la señal y el canal con el resultado de Serve.
+func start(t *testing.T, server *Server) (string, context.CancelFunc, <-chan error) {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	ctx, stop := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- server.Serve(ctx, listener) }()
+	t.Cleanup(stop)
+	return "http://" + listener.Addr().String(), stop, done
+}
+
+func TestServe_DrainsInFlightRequests(t *testing.T) {
+	entered := make(chan struct{})
+	release := make(chan struct{})
+	server, err := New(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(entered)
+		<-release
+		io.WriteString(w, "done")
+	}), Config{})
+	require.NoError(t, err)
+
+	var closed []string
+	server.OnShutdown("database", func(context.Context) error {
+		closed = append(closed, "database")
+		return nil
+	})
+	server.OnShutdown("cache", func(context.Context) error {
+		closed = append(closed, "cache")
+		return nil
+	})
+
+	url, stop, done := start(t, server)
+	response := make(chan string, 1)
+	go func() {
+		resp, err := http.Get(url)
+		if err != nil {
+			response <- err.Error()
+			return
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		response <- string(body)
+	}()
+
+	<-entered
+	stop()
+	select {
+	case <-done:
+		t.Fatal("Serve returned before the in-flight request finished")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(release)
+	assert.Equal(t, "done", <-response)
+	assert.NoError(t, <-done)
+	// Las dependencias se cierran despues de drenar y en orden inverso.
+	assert.Equal(t, []string{"cache", "database"}, closed)
+}
+
+func TestServe_ShutdownTimeoutStillClosesDependencies(t *testing.T) {
+	entered := make(chan struct{})
+	release := make(chan struct{})
+	defer close(release)
+	server, err := New(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(entered)
+		<-release
+	}), Config{ShutdownTimeout: 20 * time.Millisecond})
+	require.NoError(t, err)
+
+	closed := false
+	server.OnShutdown("database", func(context.Context) error {
+		closed = true
+		return nil
+	})
+
+	url, stop, done := start(t, server)
+	go http.Get(url)
+	<-entered
+	stop()
+
+	err = <-done
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.True(t, closed)
+}
+
+func TestServe_ReportsCloseErrors(t *testing.T) {
+	server, err := New(http.NotFoundHandler(), Config{})
+	require.NoError(t, err)
+	server.OnShutdown("cache", func(context.Context) error { return errors.New("boom") })
+
+	_, stop, done := start(t, server)
+	stop()
+
+	assert.ErrorContains(t, <-done, "closing cache: boom")
+}
+
+func TestNew_AppliesDefaultsAndTimeouts(t *testing.T) {
+	server, err := New(http.NotFoundHandler(), Config{WriteTimeout: time.Second, MaxHeaderBytes: 4096})
+	require.NoError(t, err)
+
+	assert.Equal(t, defaultReadHeaderTimeout, server.http.ReadHeaderTimeout)
+	assert.Equal(t, defaultIdleTimeout, server.http.IdleTimeout)
+	assert.Equal(t, time.Second, server.http.WriteTimeout)
+	assert.Equal(t, 4096, server.http.MaxHeaderBytes)
+	assert.Nil(t, server.http.TLSConfig)
+}
+
+func TestNew_RejectsInvalidTLS(t *testing.T) {
+	_, err := New(http.NotFoundHandler(), Config{TLSCertFile: "cert.pem"})
+	assert.Error(t, err)
+
+	_, err = New(http.NotFoundHandler(), Config{TLSCertFile: "cert.pem", TLSKeyFile: "key.pem", TLSMinVersion: "1.0"})
+	assert.ErrorContains(t, err, "unsupported TLS")
+}
+
+func TestServe_TLS(t *testing.T) {
+	certFile, keyFile := selfSignedCert(t)
+	server, err := New(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, r.TLS.ServerName)
+	}), Config{TLSCertFile: certFile, TLSKeyFile: keyFile, TLSMinVersion: "1.3"})
+	require.NoError(t, err)
+
+	url, stop, done := start(t, server)
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{
+		InsecureSkipVerify: true,
+		MaxVersion:         tls.VersionTLS12,
+	}}}
+	_, err = client.Get("https" + url[len("http"):])
+	assert.Error(t, err, "TLS 1.2 must be rejected when the minimum is 1.3")
+
+	client.Transport.(*http.Transport).TLSClientConfig.MaxVersion = 0
+	resp, err := client.Get("https" + url[len("http"):])
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, uint16(tls.VersionTLS13), resp.TLS.Version)
+
+	stop()
+	assert.NoError(t, <-done)
+}
+
+func selfSignedCert(t *testing.T) (string, string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+	require.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600))
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600))
+	return certFile, keyFile
+}
@@ -0,0 +1,86 @@
+package services
+
+import (
+	Domain "Golang/domain"
+	"Golang/logging"
+	Model "Golang/model"
+	"Golang/tracing"
+	"context"
+	"fmt"
+)
+
+// Operaciones de administracion. No estan expuestas por HTTP: las usa
+// cmd/usersctl, que corre con acceso directo a la base.
+
+// InsertAdmin registra al usuario con el rol de administrador, igual que
+// InsertUsuario. El rol se guarda con el alta: no queda un usuario comun si
+// algo falla despues.
+func (s Service) InsertAdmin(ctx context.Context, req Domain.CreateUserRequest, ip string) (_ Domain.UserResponse, err error) {
+	ctx, span := tracer.Start(ctx, "Service.InsertAdmin")
+	defer tracing.End(span, &err)
+
+	return s.insertUsuario(ctx, req, ip, true)
+}
+
+// SetAdmin otorga o quita el rol de administrador.
+func (s Service) SetAdmin(ctx context.Context, id int, admin bool) (_ Domain.UserResponse, err error) {
+	ctx, span := tracer.Start(ctx, "Service.SetAdmin")
+	defer tracing.End(span, &err)
+
+	return s.patchColumns(ctx, id, map[string]interface{}{"admin": admin}, "Error al cambiar el rol del usuario")
+}
+
+// SetEstado activa o desactiva la cuenta.
+func (s Service) SetEstado(ctx context.Context, id int, estado bool) (_ Domain.UserResponse, err error) {
+	ctx, span := tracer.Start(ctx, "Service.SetEstado")
+	defer tracing.End(span, &err)
+
+	return s.patchColumns(ctx, id, map[string]interface{}{"estado": estado}, "Error al cambiar el estado del usuario")
+}
+
+// ResetPassword reemplaza la contraseña del usuario.
+func (s Service) ResetPassword(ctx context.Context, id int, req Domain.PasswordReset) (_ Domain.UserResponse, err error) {
+	ctx, span := tracer.Start(ctx, "Service.ResetPassword")
+	defer tracing.End(span, &err)
+
+	if err := req.Validate(); err != nil {
+		return Domain.UserResponse{}, err
+	}
+	return s.patchColumns(ctx, id, map[string]interface{}{"password": hashPassword(req.Password)}, "Error al cambiar la contraseña")
+}
+
+// ListUsers devuelve los usuarios que cumplen filter, ordenados por id.
+func (s Service) ListUsers(ctx context.Context, filter Domain.UserFilter) (_ []Domain.UserResponse, err error) {
+	ctx, span := tracer.Start(ctx, "Service.ListUsers")
+	defer tracing.End(span, &err)
+
+	result := []Domain.UserResponse{}
+	err = s.UserService.ScanUsers(ctx, toModelFilter(filter), func(user Model.User) error {
+		result = append(result, toUserResponse(user))
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Error al obtener la lista de usuarios: %w", err)
+	}
+	return result, nil
+}
+
+// patchColumns escribe columnas sobre la version actual del usuario.
+func (s Service) patchColumns(ctx context.Context, id int, fields map[string]interface{}, action string) (Domain.UserResponse, error) {
+	actual, err := s.UserService.GetUserById(ctx, id)
+	if err != nil {
+		return Domain.UserResponse{}, fmt.Errorf("%s: %w", action, err)
+	}
+
+	user, err := s.UserService.PatchUser(ctx, id, actual.Version, withDeactivation(actual, fields))
+	if err != nil {
+		return Domain.UserResponse{}, fmt.Errorf("%s: %w", action, err)
+	}
+
+	columns := make([]string, 0, len(fields))
+	for column := range fields {
+		columns = append(columns, column)
+	}
+	logging.FromContext(ctx).WithField("user_id", id).WithField("columns", columns).Info("user changed by administrator")
+	return toUserResponse(user), nil
+}
@@ -0,0 +1,100 @@
+package services
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"testing"
+
+	clientUsers "Golang/clients"
+	Domain "Golang/domain"
+	Model "Golang/model"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSetAdmin_EscribeSobreLaVersionActual(t *testing.T) {
+	mockClients := new(MockUserClients)
+	guardado := usuarioGuardado()
+	guardado.Admin = false
+	mockClients.On("GetUserById", 3).Return(guardado, nil)
+
+	esperado := guardado
+	esperado.Admin = true
+	esperado.Version = 3
+	mockClients.On("PatchUser", 3, 2, map[string]interface{}{"admin": true}).Return(esperado, nil)
+
+	out, err := NewService(mockClients).SetAdmin(context.Background(), 3, true)
+
+	assert.NoError(t, err)
+	assert.True(t, out.Admin)
+	assert.Equal(t, 3, out.Version)
+	mockClients.AssertExpectations(t)
+}
+
+func TestSetEstado_UsuarioNoExiste(t *testing.T) {
+	mockClients := new(MockUserClients)
+	mockClients.On("GetUserById", 9).Return(Model.User{}, fmt.Errorf("error finding user: %w", Domain.ErrNotFound))
+
+	_, err := NewService(mockClients).SetEstado(context.Background(), 9, false)
+
+	assert.ErrorIs(t, err, Domain.ErrNotFound)
+	mockClients.AssertNotCalled(t, "PatchUser", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestResetPassword_GuardaElHash(t *testing.T) {
+	mockClients := new(MockUserClients)
+	guardado := usuarioGuardado()
+	mockClients.On("GetUserById", 3).Return(guardado, nil)
+
+	sum := md5.Sum([]byte("nueva-clave"))
+	mockClients.On("PatchUser", 3, 2, map[string]interface{}{"password": hex.EncodeToString(sum[:])}).Return(guardado, nil)
+
+	_, err := NewService(mockClients).ResetPassword(context.Background(), 3, Domain.PasswordReset{Password: "nueva-clave"})
+
+	assert.NoError(t, err)
+	mockClients.AssertExpectations(t)
+}
+
+func TestResetPassword_ValidaLaContrasenia(t *testing.T) {
+	mockClients := new(MockUserClients)
+
+	_, err := NewService(mockClients).ResetPassword(context.Background(), 3, Domain.PasswordReset{Password: "corta"})
+
+	assert.ErrorIs(t, err, Domain.ErrValidation)
+	mockClients.AssertNotCalled(t, "GetUserById", mock.Anything)
+}
+
+func TestListUsers_Filtros(t *testing.T) {
+	// El filtro lo aplica el repositorio, asi que se prueba contra Memory.
+	repo := clientUsers.NewMemory()
+	for _, user := range []Model.User{
+		{Nombre: "Ana", Genero: "F", Admin: true, Estado: true},
+		{Nombre: "Bruno", Genero: "M", Admin: false, Estado: true},
+		{Nombre: "Mariana", Genero: "F", Admin: false, Estado: false, Diabetico: true},
+	} {
+		_, err := repo.InsertUser(context.Background(), user)
+		require.NoError(t, err)
+	}
+	svc := NewService(repo)
+	yes, no := true, false
+
+	ids := func(filter Domain.UserFilter) []int {
+		users, err := svc.ListUsers(context.Background(), filter)
+		assert.NoError(t, err)
+		result := []int{}
+		for _, user := range users {
+			result = append(result, user.Id)
+		}
+		return result
+	}
+
+	assert.Equal(t, []int{1, 2, 3}, ids(Domain.UserFilter{}))
+	assert.Equal(t, []int{2, 3}, ids(Domain.UserFilter{Admin: &no}))
+	assert.Equal(t, []int{3}, ids(Domain.UserFilter{Estado: &no}))
+	assert.Equal(t, []int{1, 3}, ids(Domain.UserFilter{Nombre: "ANA"}))
+	assert.Equal(t, []int{3}, ids(Domain.UserFilter{Diabetico: &yes}))
+}
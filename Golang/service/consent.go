@@ -0,0 +1,242 @@
+package services
+
+import (
+	Domain "Golang/domain"
+	"Golang/logging"
+	Model "Golang/model"
+	"Golang/tracing"
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// El consentimiento para tratar datos de salud se pide al registrarse y se
+// vuelve a pedir cada vez que se publica un texto nuevo. Mientras no haya
+// ningun texto publicado no se exige, para que una instalacion existente
+// siga aceptando altas hasta que se publique el primero.
+
+type consentStore interface {
+	PublishConsentVersion(ctx context.Context, text string) (Model.ConsentVersion, error)
+	GetConsentVersion(ctx context.Context) (Model.ConsentVersion, error)
+	InsertConsent(ctx context.Context, consent Model.Consent) (Model.Consent, error)
+	GetConsent(ctx context.Context, userId int) (Model.Consent, error)
+	ConsentedUsers(ctx context.Context, version int) ([]int, error)
+	AnonymizeConsents(ctx context.Context, before time.Time, dryRun bool) (int, error)
+}
+
+// errNoConsentStore es el error de las operaciones de consentimiento de un
+// Service armado sin WithConsents.
+var errNoConsentStore = errors.New("consent store not configured")
+
+// WithConsents devuelve una copia de s que exige y registra los
+// consentimientos en consents.
+func (s Service) WithConsents(consents consentStore) Service {
+	s.Consents = consents
+	return s
+}
+
+// CurrentConsent devuelve el texto vigente, o ErrNoConsent si no se
+// publico ninguno.
+func (s Service) CurrentConsent(ctx context.Context) (_ Domain.ConsentText, err error) {
+	ctx, span := tracer.Start(ctx, "Service.CurrentConsent")
+	defer tracing.End(span, &err)
+
+	version, found, err := s.currentConsent(ctx)
+	if err != nil {
+		return Domain.ConsentText{}, err
+	}
+	if !found {
+		return Domain.ConsentText{}, Domain.ErrNoConsent
+	}
+	return toConsentText(version), nil
+}
+
+// PublishConsent publica text como version nueva. Desde ese momento las
+// altas tienen que aceptarla y GetConsent marca como pendientes a los
+// usuarios que aceptaron una anterior.
+func (s Service) PublishConsent(ctx context.Context, text string) (_ Domain.ConsentText, err error) {
+	ctx, span := tracer.Start(ctx, "Service.PublishConsent")
+	defer tracing.End(span, &err)
+
+	if s.Consents == nil {
+		return Domain.ConsentText{}, errNoConsentStore
+	}
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return Domain.ConsentText{}, &Domain.ValidationError{Violations: []Domain.FieldViolation{{Field: "text", Rule: "required"}}}
+	}
+	version, err := s.Consents.PublishConsentVersion(ctx, text)
+	if err != nil {
+		return Domain.ConsentText{}, fmt.Errorf("Error al publicar el consentimiento: %w", err)
+	}
+	return toConsentText(version), nil
+}
+
+// GetConsent devuelve el estado del consentimiento del usuario. Solo lo
+// pueden ver el propio usuario y los administradores.
+func (s Service) GetConsent(ctx context.Context, userId int, viewer Domain.Viewer) (_ Domain.ConsentStatus, err error) {
+	ctx, span := tracer.Start(ctx, "Service.GetConsent")
+	defer tracing.End(span, &err)
+
+	if !viewer.Admin && viewer.UserId != userId {
+		return Domain.ConsentStatus{}, fmt.Errorf("consent of user %d: %w", userId, Domain.ErrForbidden)
+	}
+	if err := s.checkConsentUser(ctx, userId); err != nil {
+		return Domain.ConsentStatus{}, err
+	}
+	return s.consentStatus(ctx, userId)
+}
+
+// GrantConsent registra que el usuario acepto req.Version, que tiene que
+// ser la vigente. Solo el propio usuario puede otorgarlo.
+func (s Service) GrantConsent(ctx context.Context, userId int, req Domain.ConsentGrant, ip string, viewer Domain.Viewer) (_ Domain.ConsentStatus, err error) {
+	ctx, span := tracer.Start(ctx, "Service.GrantConsent")
+	defer tracing.End(span, &err)
+
+	if viewer.UserId != userId {
+		return Domain.ConsentStatus{}, fmt.Errorf("consent of user %d: %w", userId, Domain.ErrForbidden)
+	}
+	if err := s.checkConsentUser(ctx, userId); err != nil {
+		return Domain.ConsentStatus{}, err
+	}
+	current, found, err := s.currentConsent(ctx)
+	if err != nil {
+		return Domain.ConsentStatus{}, err
+	}
+	if !found {
+		return Domain.ConsentStatus{}, Domain.ErrNoConsent
+	}
+	if req.Version != current.Version {
+		return Domain.ConsentStatus{}, staleConsent("version", current.Version)
+	}
+
+	if err := s.recordConsent(ctx, userId, current.Version, true, ip); err != nil {
+		return Domain.ConsentStatus{}, err
+	}
+	return s.consentStatus(ctx, userId)
+}
+
+// WithdrawConsent registra el retiro del consentimiento. Lo puede pedir el
+// propio usuario o un administrador en su nombre; si no habia
+// consentimiento otorgado no registra nada.
+func (s Service) WithdrawConsent(ctx context.Context, userId int, ip string, viewer Domain.Viewer) (_ Domain.ConsentStatus, err error) {
+	ctx, span := tracer.Start(ctx, "Service.WithdrawConsent")
+	defer tracing.End(span, &err)
+
+	if !viewer.Admin && viewer.UserId != userId {
+		return Domain.ConsentStatus{}, fmt.Errorf("consent of user %d: %w", userId, Domain.ErrForbidden)
+	}
+	if err := s.checkConsentUser(ctx, userId); err != nil {
+		return Domain.ConsentStatus{}, err
+	}
+	latest, err := s.Consents.GetConsent(ctx, userId)
+	if errors.Is(err, Domain.ErrNotFound) {
+		return s.consentStatus(ctx, userId)
+	}
+	if err != nil {
+		return Domain.ConsentStatus{}, fmt.Errorf("Error al buscar el consentimiento: %w", err)
+	}
+	if latest.Granted {
+		if err := s.recordConsent(ctx, userId, latest.Version, false, ip); err != nil {
+			return Domain.ConsentStatus{}, err
+		}
+	}
+	return s.consentStatus(ctx, userId)
+}
+
+// checkConsentUser controla que haya donde registrar y que el usuario
+// exista.
+func (s Service) checkConsentUser(ctx context.Context, userId int) error {
+	if s.Consents == nil {
+		return errNoConsentStore
+	}
+	if _, err := s.UserService.GetUserById(ctx, userId); err != nil {
+		return fmt.Errorf("Error al buscar el usuario: %w", err)
+	}
+	return nil
+}
+
+// currentConsent devuelve la version vigente; found es false si no se
+// publico ninguna o el Service no tiene consentimientos.
+func (s Service) currentConsent(ctx context.Context) (_ Model.ConsentVersion, found bool, _ error) {
+	if s.Consents == nil {
+		return Model.ConsentVersion{}, false, nil
+	}
+	version, err := s.Consents.GetConsentVersion(ctx)
+	if errors.Is(err, Domain.ErrNotFound) {
+		return Model.ConsentVersion{}, false, nil
+	}
+	if err != nil {
+		return Model.ConsentVersion{}, false, fmt.Errorf("Error al buscar el consentimiento vigente: %w", err)
+	}
+	return version, true, nil
+}
+
+func (s Service) recordConsent(ctx context.Context, userId int, version int, granted bool, ip string) error {
+	_, err := s.Consents.InsertConsent(ctx, Model.Consent{UserId: userId, Version: version, Granted: granted, IP: ip})
+	if err != nil {
+		return fmt.Errorf("Error al registrar el consentimiento: %w", err)
+	}
+	logging.FromContext(ctx).
+		WithField("user_id", userId).
+		WithField("version", version).
+		WithField("granted", granted).
+		Info("consent recorded")
+	return nil
+}
+
+func (s Service) consentStatus(ctx context.Context, userId int) (Domain.ConsentStatus, error) {
+	current, found, err := s.currentConsent(ctx)
+	if err != nil {
+		return Domain.ConsentStatus{}, err
+	}
+	status := Domain.ConsentStatus{UserId: userId}
+	latest, err := s.Consents.GetConsent(ctx, userId)
+	switch {
+	case err == nil:
+		status.Version = latest.Version
+		status.Granted = latest.Granted
+		status.RecordedAt = latest.CreatedAt
+	case !errors.Is(err, Domain.ErrNotFound):
+		return Domain.ConsentStatus{}, fmt.Errorf("Error al buscar el consentimiento: %w", err)
+	}
+	if found && !(status.Granted && status.Version == current.Version) {
+		text := toConsentText(current)
+		status.Pending = true
+		status.Current = &text
+	}
+	return status, nil
+}
+
+// consentedUsers devuelve a quien se le pueden compartir datos de salud: los
+// usuarios que otorgaron el consentimiento vigente. Un texto nuevo puede
+// cambiar lo que se acepta, asi que uno anterior no alcanza, igual que en
+// consentStatus. Devuelve nil si no se exige consentimiento.
+func (s Service) consentedUsers(ctx context.Context) (map[int]bool, error) {
+	current, found, err := s.currentConsent(ctx)
+	if err != nil || !found {
+		return nil, err
+	}
+	users, err := s.Consents.ConsentedUsers(ctx, current.Version)
+	if err != nil {
+		return nil, fmt.Errorf("Error al buscar los consentimientos: %w", err)
+	}
+	consented := make(map[int]bool, len(users))
+	for _, userId := range users {
+		consented[userId] = true
+	}
+	return consented, nil
+}
+
+// staleConsent es la violacion de aceptar una version que no es la vigente;
+// el parametro le indica al cliente cual es.
+func staleConsent(field string, current int) error {
+	return &Domain.ValidationError{Violations: []Domain.FieldViolation{{Field: field, Rule: "eq", Param: strconv.Itoa(current)}}}
+}
+
+func toConsentText(version Model.ConsentVersion) Domain.ConsentText {
+	return Domain.ConsentText{Version: version.Version, Text: version.Text, PublishedAt: version.PublishedAt}
+}
@@ -0,0 +1,258 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	clientUsers "Golang/clients"
+	Domain "Golang/domain"
+	Model "Golang/model"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func servicioConConsentimiento(t *testing.T, textos ...string) (Service, *clientUsers.Memory) {
+	t.Helper()
+	repo := clientUsers.NewMemory()
+	for _, texto := range textos {
+		_, err := repo.PublishConsentVersion(context.Background(), texto)
+		require.NoError(t, err)
+	}
+	return NewService(repo).WithConsents(repo), repo
+}
+
+func altaConConsentimiento(version int) Domain.CreateUserRequest {
+	return Domain.CreateUserRequest{Nombre: "ana", Password: "secreto", Genero: "F", Diabetico: true, Consentimiento: version}
+}
+
+func TestInsertUsuario_SinTextoPublicadoNoExigeConsentimiento(t *testing.T) {
+	svc, repo := servicioConConsentimiento(t)
+
+	user, err := svc.InsertUsuario(context.Background(), altaConConsentimiento(0), "10.0.0.1")
+
+	require.NoError(t, err)
+	_, err = repo.GetConsent(context.Background(), user.Id)
+	assert.ErrorIs(t, err, Domain.ErrNotFound)
+}
+
+func TestInsertUsuario_ExigeLaVersionVigente(t *testing.T) {
+	svc, repo := servicioConConsentimiento(t, "v1", "v2")
+
+	for _, version := range []int{0, 1, 3} {
+		_, err := svc.InsertUsuario(context.Background(), altaConConsentimiento(version), "10.0.0.1")
+		var validation *Domain.ValidationError
+		require.ErrorAs(t, err, &validation, "version %d", version)
+		assert.Equal(t, []Domain.FieldViolation{{Field: "consentimiento", Rule: "eq", Param: "2"}}, validation.Violations)
+	}
+	users, err := repo.GetAllUsers(context.Background())
+	require.NoError(t, err)
+	assert.Empty(t, users)
+
+	user, err := svc.InsertUsuario(context.Background(), altaConConsentimiento(2), "10.0.0.1")
+	require.NoError(t, err)
+	consent, err := repo.GetConsent(context.Background(), user.Id)
+	require.NoError(t, err)
+	assert.Equal(t, Model.Consent{Id: 1, UserId: user.Id, Version: 2, Granted: true, IP: "10.0.0.1", CreatedAt: consent.CreatedAt}, consent)
+}
+
+// consentimientoQueFalla no puede registrar consentimientos.
+type consentimientoQueFalla struct {
+	*clientUsers.Memory
+}
+
+func (consentimientoQueFalla) InsertConsent(ctx context.Context, consent Model.Consent) (Model.Consent, error) {
+	return Model.Consent{}, errors.New("db down")
+}
+
+func TestInsertUsuario_SinRegistroDeConsentimientoNoQuedaElUsuario(t *testing.T) {
+	_, repo := servicioConConsentimiento(t, "v1")
+	svc := NewService(repo).WithConsents(consentimientoQueFalla{repo})
+
+	_, err := svc.InsertUsuario(context.Background(), altaConConsentimiento(1), "10.0.0.1")
+
+	require.EqualError(t, err, "Error Inserting User: Error al registrar el consentimiento: db down")
+	users, err := repo.GetAllUsers(context.Background())
+	require.NoError(t, err)
+	assert.Empty(t, users)
+}
+
+func TestInsertUsuarioByAdmin_NoRegistraConsentimiento(t *testing.T) {
+	svc, repo := servicioConConsentimiento(t, "v1")
+
+	// Un administrador no puede aceptar el consentimiento por el usuario.
+	_, err := svc.InsertUsuarioByAdmin(context.Background(), altaConConsentimiento(1))
+	var validation *Domain.ValidationError
+	require.ErrorAs(t, err, &validation)
+	assert.Equal(t, []Domain.FieldViolation{{Field: "consentimiento", Rule: "eq", Param: "0"}}, validation.Violations)
+
+	user, err := svc.InsertUsuarioByAdmin(context.Background(), altaConConsentimiento(0))
+	require.NoError(t, err)
+	_, err = repo.GetConsent(context.Background(), user.Id)
+	assert.ErrorIs(t, err, Domain.ErrNotFound)
+}
+
+func TestGetConsent_PendienteConVersionNueva(t *testing.T) {
+	svc, repo := servicioConConsentimiento(t, "v1")
+	user, err := svc.InsertUsuario(context.Background(), altaConConsentimiento(1), "")
+	require.NoError(t, err)
+	propio := Domain.Viewer{UserId: user.Id}
+
+	status, err := svc.GetConsent(context.Background(), user.Id, propio)
+	require.NoError(t, err)
+	assert.True(t, status.Granted)
+	assert.False(t, status.Pending)
+	assert.Nil(t, status.Current)
+
+	_, err = repo.PublishConsentVersion(context.Background(), "v2")
+	require.NoError(t, err)
+	status, err = svc.GetConsent(context.Background(), user.Id, propio)
+	require.NoError(t, err)
+	assert.True(t, status.Granted)
+	assert.Equal(t, 1, status.Version)
+	assert.True(t, status.Pending)
+	require.NotNil(t, status.Current)
+	assert.Equal(t, "v2", status.Current.Text)
+
+	// Aceptar la vigente deja de pedirlo; una anterior no sirve.
+	_, err = svc.GrantConsent(context.Background(), user.Id, Domain.ConsentGrant{Version: 1}, "", propio)
+	assert.ErrorIs(t, err, Domain.ErrValidation)
+	status, err = svc.GrantConsent(context.Background(), user.Id, Domain.ConsentGrant{Version: 2}, "10.0.0.9", propio)
+	require.NoError(t, err)
+	assert.Equal(t, 2, status.Version)
+	assert.False(t, status.Pending)
+}
+
+func TestConsent_Permisos(t *testing.T) {
+	svc, _ := servicioConConsentimiento(t, "v1")
+	user, err := svc.InsertUsuario(context.Background(), altaConConsentimiento(1), "")
+	require.NoError(t, err)
+	otro := Domain.Viewer{UserId: user.Id + 1, Role: Domain.RoleClinician}
+	admin := Domain.Viewer{UserId: user.Id + 1, Admin: true}
+
+	_, err = svc.GetConsent(context.Background(), user.Id, otro)
+	assert.ErrorIs(t, err, Domain.ErrForbidden)
+	_, err = svc.WithdrawConsent(context.Background(), user.Id, "", otro)
+	assert.ErrorIs(t, err, Domain.ErrForbidden)
+	// Nadie puede consentir en nombre de otro, ni un administrador.
+	_, err = svc.GrantConsent(context.Background(), user.Id, Domain.ConsentGrant{Version: 1}, "", admin)
+	assert.ErrorIs(t, err, Domain.ErrForbidden)
+
+	_, err = svc.GetConsent(context.Background(), 99, Domain.Viewer{Admin: true})
+	assert.ErrorIs(t, err, Domain.ErrNotFound)
+	status, err := svc.GetConsent(context.Background(), user.Id, admin)
+	require.NoError(t, err)
+	assert.True(t, status.Granted)
+}
+
+func TestWithdrawConsent(t *testing.T) {
+	svc, repo := servicioConConsentimiento(t, "v1")
+	user, err := svc.InsertUsuario(context.Background(), altaConConsentimiento(1), "")
+	require.NoError(t, err)
+	propio := Domain.Viewer{UserId: user.Id}
+
+	status, err := svc.WithdrawConsent(context.Background(), user.Id, "10.0.0.5", propio)
+	require.NoError(t, err)
+	assert.False(t, status.Granted)
+	assert.True(t, status.Pending)
+	consent, err := repo.GetConsent(context.Background(), user.Id)
+	require.NoError(t, err)
+	assert.Equal(t, "10.0.0.5", consent.IP)
+
+	// Retirarlo de nuevo no agrega registros.
+	_, err = svc.WithdrawConsent(context.Background(), user.Id, "10.0.0.6", propio)
+	require.NoError(t, err)
+	again, err := repo.GetConsent(context.Background(), user.Id)
+	require.NoError(t, err)
+	assert.Equal(t, consent.Id, again.Id)
+}
+
+func TestCurrentConsentYPublish(t *testing.T) {
+	svc, _ := servicioConConsentimiento(t)
+
+	_, err := svc.CurrentConsent(context.Background())
+	assert.ErrorIs(t, err, Domain.ErrNoConsent)
+	_, err = svc.GrantConsent(context.Background(), 1, Domain.ConsentGrant{Version: 1}, "", Domain.Viewer{UserId: 1})
+	assert.ErrorIs(t, err, Domain.ErrNotFound)
+
+	_, err = svc.PublishConsent(context.Background(), "  \n")
+	assert.ErrorIs(t, err, Domain.ErrValidation)
+	published, err := svc.PublishConsent(context.Background(), "Acepto el tratamiento de mis datos de salud.\n")
+	require.NoError(t, err)
+	current, err := svc.CurrentConsent(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, published, current)
+	assert.Equal(t, "Acepto el tratamiento de mis datos de salud.", current.Text)
+
+	_, err = NewService(clientUsers.NewMemory()).PublishConsent(context.Background(), "texto")
+	assert.Error(t, err)
+}
+
+// servicioConUnConsentimiento tiene a ana con consentimiento y a bruno sin el.
+func servicioConUnConsentimiento(t *testing.T) Service {
+	t.Helper()
+	svc, repo := servicioConConsentimiento(t, "v1")
+	_, err := svc.InsertUsuario(context.Background(), altaConConsentimiento(1), "")
+	require.NoError(t, err)
+	_, err = repo.InsertUser(context.Background(), Model.User{Nombre: "bruno", Genero: "M", Diabetico: true, Enfermedades: "asma", Estado: true})
+	require.NoError(t, err)
+	return svc
+}
+
+func TestExportUsers_EnmascaraSinConsentimiento(t *testing.T) {
+	var out bytes.Buffer
+
+	rows, err := servicioConUnConsentimiento(t).ExportUsers(context.Background(), &out, Domain.ExportOptions{
+		Format:  Domain.ExportJSONL,
+		Columns: []string{"nombre", "diabetico", "enfermedades"},
+	})
+
+	require.NoError(t, err)
+	assert.Equal(t, 2, rows)
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	assert.JSONEq(t, `{"nombre":"ana","diabetico":true,"enfermedades":""}`, lines[0])
+	assert.JSONEq(t, `{"nombre":"bruno","diabetico":"***","enfermedades":"***"}`, lines[1])
+}
+
+func TestExportUsers_ConsentimientoAnteriorNoAlcanza(t *testing.T) {
+	svc := servicioConUnConsentimiento(t)
+	_, err := svc.PublishConsent(context.Background(), "v2")
+	require.NoError(t, err)
+	var out bytes.Buffer
+
+	_, err = svc.ExportUsers(context.Background(), &out, Domain.ExportOptions{
+		Format:  Domain.ExportJSONL,
+		Columns: []string{"nombre", "diabetico"},
+	})
+
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	assert.JSONEq(t, `{"nombre":"ana","diabetico":"***"}`, lines[0])
+}
+
+func TestExportFHIR_SinConsentimientoSoloPatient(t *testing.T) {
+	var out bytes.Buffer
+
+	patients, err := servicioConUnConsentimiento(t).ExportFHIR(context.Background(), &out, "", Domain.UserFilter{})
+
+	require.NoError(t, err)
+	assert.Equal(t, 2, patients)
+	var bundle struct {
+		Entry []struct {
+			Resource struct {
+				ResourceType string `json:"resourceType"`
+				Id           string `json:"id"`
+			} `json:"resource"`
+		} `json:"entry"`
+	}
+	require.NoError(t, json.Unmarshal(out.Bytes(), &bundle))
+	var resources []string
+	for _, entry := range bundle.Entry {
+		resources = append(resources, entry.Resource.ResourceType+"/"+entry.Resource.Id)
+	}
+	assert.Equal(t, []string{"Patient/1", "Condition/1-diabetes", "Observation/1-lentes", "Patient/2"}, resources)
+}
@@ -0,0 +1,301 @@
+package services
+
+import (
+	Domain "Golang/domain"
+	"Golang/logging"
+	Model "Golang/model"
+	"Golang/tracing"
+	"Golang/xlsx"
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// exportBufferSize es lo que se acumula antes de escribir en w. La
+	// primera pagina del repositorio entra entera, asi que si la consulta
+	// inicial falla w no recibio nada.
+	exportBufferSize = 64 << 10
+	// exportFlushRows es cada cuantas filas se manda lo acumulado, para que
+	// el cliente vaya recibiendo el archivo.
+	exportFlushRows = 1000
+	// exportSheetName es el nombre de la hoja del .xlsx.
+	exportSheetName = "Usuarios"
+)
+
+// exportEncoder escribe las filas en un formato.
+type exportEncoder interface {
+	header(columns []string) error
+	row(columns []string, values []interface{}) error
+	// flush manda a w lo que el encoder tenga pendiente.
+	flush() error
+	close() error
+}
+
+// ExportUsers escribe en w los usuarios que cumplen opts.Filter, en el
+// formato y con las columnas de opts. Los usuarios se recorren con
+// ScanUsers, asi que la memoria no crece con el tamaño de la tabla.
+// Devuelve la cantidad de filas exportadas.
+//
+// Si el error llega antes de la primera tanda de filas, w no recibio nada y
+// el llamador todavia puede responder con un error. Despues, lo escrito en
+// w queda truncado.
+func (s Service) ExportUsers(ctx context.Context, w io.Writer, opts Domain.ExportOptions) (rows int, err error) {
+	ctx, span := tracer.Start(ctx, "Service.ExportUsers")
+	defer tracing.End(span, &err)
+
+	if err := opts.Validate(); err != nil {
+		return 0, err
+	}
+	columns := opts.Columns
+	if len(columns) == 0 {
+		columns = Domain.ExportColumns
+	}
+	medical := make(map[string]bool, len(Domain.MedicalColumns))
+	for _, column := range Domain.MedicalColumns {
+		medical[column] = true
+	}
+	// Sin consentimiento los datos de salud del usuario salen enmascarados
+	// aunque no se pida MaskMedical.
+	consented, err := s.consentedUsers(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("Error al exportar los usuarios: %w", err)
+	}
+
+	buffered := bufio.NewWriterSize(w, exportBufferSize)
+	encoder, err := newExportEncoder(opts.Format, buffered)
+	if err != nil {
+		return 0, err
+	}
+	if err := encoder.header(columns); err != nil {
+		return 0, fmt.Errorf("Error al exportar los usuarios: %w", err)
+	}
+
+	values := make([]interface{}, len(columns))
+	withheld := 0
+	err = s.UserService.ScanUsers(ctx, toModelFilter(opts.Filter), func(user Model.User) error {
+		mask := opts.MaskMedical
+		if consented != nil && !consented[user.Id] {
+			mask = true
+			withheld++
+		}
+		for i, column := range columns {
+			if mask && medical[column] {
+				values[i] = Domain.MaskedValue
+				continue
+			}
+			values[i] = exportValue(user, column)
+		}
+		if err := encoder.row(columns, values); err != nil {
+			return err
+		}
+		rows++
+		if rows%exportFlushRows == 0 {
+			if err := encoder.flush(); err != nil {
+				return err
+			}
+			return buffered.Flush()
+		}
+		return nil
+	})
+	if err != nil {
+		return rows, fmt.Errorf("Error al exportar los usuarios: %w", err)
+	}
+	if err := encoder.close(); err != nil {
+		return rows, fmt.Errorf("Error al exportar los usuarios: %w", err)
+	}
+	if err := buffered.Flush(); err != nil {
+		return rows, fmt.Errorf("Error al exportar los usuarios: %w", err)
+	}
+
+	logging.FromContext(ctx).
+		WithField("format", opts.Format).
+		WithField("columns", columns).
+		WithField("mask_medical", opts.MaskMedical).
+		WithField("rows", rows).
+		WithField("without_consent", withheld).
+		Info("users exported")
+	return rows, nil
+}
+
+func toModelFilter(filter Domain.UserFilter) Model.UserFilter {
+	return Model.UserFilter{
+		Admin:     filter.Admin,
+		Estado:    filter.Estado,
+		Diabetico: filter.Diabetico,
+		Nombre:    filter.Nombre,
+	}
+}
+
+// exportValue devuelve el valor de una columna de Domain.ExportColumns.
+func exportValue(user Model.User, column string) interface{} {
+	switch column {
+	case "id":
+		return user.Id
+	case "nombre":
+		return user.Nombre
+	case "genero":
+		return user.Genero
+	case "atributos":
+		return user.Atributos
+	case "maneja":
+		return user.Maneja
+	case "lentes":
+		return user.Lentes
+	case "diabetico":
+		return user.Diabetico
+	case "enfermedades":
+		return user.Enfermedades
+	case "admin":
+		return user.Admin
+	case "estado":
+		return user.Estado
+	case "version":
+		return user.Version
+	case "updatedAt":
+		return user.UpdatedAt.UTC()
+	}
+	return nil
+}
+
+func newExportEncoder(format string, w io.Writer) (exportEncoder, error) {
+	switch format {
+	case Domain.ExportCSV:
+		// El BOM hace que Excel abra el CSV como UTF-8 y muestre bien los
+		// acentos. La importacion lo ignora.
+		if _, err := io.WriteString(w, "\ufeff"); err != nil {
+			return nil, err
+		}
+		return &csvEncoder{writer: csv.NewWriter(w)}, nil
+	case Domain.ExportJSONL:
+		return &jsonlEncoder{w: w}, nil
+	case Domain.ExportXLSX:
+		writer, err := xlsx.NewWriter(w, exportSheetName)
+		if err != nil {
+			return nil, err
+		}
+		return &xlsxEncoder{writer: writer}, nil
+	}
+	return nil, fmt.Errorf("unsupported export format %q: %w", format, Domain.ErrValidation)
+}
+
+type csvEncoder struct {
+	writer *csv.Writer
+	record []string
+}
+
+func (e *csvEncoder) header(columns []string) error {
+	e.record = make([]string, len(columns))
+	return e.writer.Write(columns)
+}
+
+func (e *csvEncoder) row(columns []string, values []interface{}) error {
+	for i, value := range values {
+		e.record[i] = csvCell(value)
+	}
+	return e.writer.Write(e.record)
+}
+
+func (e *csvEncoder) flush() error {
+	e.writer.Flush()
+	return e.writer.Error()
+}
+
+func (e *csvEncoder) close() error {
+	return e.flush()
+}
+
+// csvCell escribe el valor como texto. Un texto que empieza como una formula
+// lleva un apostrofo adelante para que la planilla no lo ejecute al abrir el
+// archivo.
+func csvCell(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		if v != "" && strings.ContainsRune("=+-@\t\r", rune(v[0])) {
+			return "'" + v
+		}
+		return v
+	case bool:
+		return strconv.FormatBool(v)
+	case int:
+		return strconv.Itoa(v)
+	case time.Time:
+		return v.Format(time.RFC3339)
+	}
+	return fmt.Sprint(value)
+}
+
+// jsonlEncoder escribe un objeto por linea con las claves en el orden de las
+// columnas.
+type jsonlEncoder struct {
+	w    io.Writer
+	line []byte
+}
+
+func (e *jsonlEncoder) header(columns []string) error {
+	return nil
+}
+
+func (e *jsonlEncoder) row(columns []string, values []interface{}) error {
+	e.line = append(e.line[:0], '{')
+	for i, value := range values {
+		if i > 0 {
+			e.line = append(e.line, ',')
+		}
+		e.line = strconv.AppendQuote(e.line, columns[i])
+		e.line = append(e.line, ':')
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			return err
+		}
+		e.line = append(e.line, encoded...)
+	}
+	e.line = append(e.line, '}', '\n')
+	_, err := e.w.Write(e.line)
+	return err
+}
+
+func (e *jsonlEncoder) flush() error {
+	return nil
+}
+
+func (e *jsonlEncoder) close() error {
+	return nil
+}
+
+type xlsxEncoder struct {
+	writer *xlsx.Writer
+}
+
+func (e *xlsxEncoder) header(columns []string) error {
+	cells := make([]interface{}, len(columns))
+	for i, column := range columns {
+		cells[i] = column
+	}
+	return e.writer.WriteRow(cells...)
+}
+
+// row escribe las fechas como texto: la planilla no tiene estilos para darles
+// formato de fecha.
+func (e *xlsxEncoder) row(columns []string, values []interface{}) error {
+	for i, value := range values {
+		if t, ok := value.(time.Time); ok {
+			values[i] = t.Format(time.RFC3339)
+		}
+	}
+	return e.writer.WriteRow(values...)
+}
+
+func (e *xlsxEncoder) flush() error {
+	return e.writer.Flush()
+}
+
+func (e *xlsxEncoder) close() error {
+	return e.writer.Close()
+}
@@ -0,0 +1,139 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	clientUsers "Golang/clients"
+	Domain "Golang/domain"
+	Model "Golang/model"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func repoParaExportar(t *testing.T) *clientUsers.Memory {
+	t.Helper()
+	repo := clientUsers.NewMemory()
+	usuarios := []Model.User{
+		{Nombre: "ana", Password: "hash-ana", Genero: "F", Atributos: "alta", Diabetico: true, Enfermedades: "asma", Estado: true},
+		{Nombre: "bruno", Password: "hash-bruno", Genero: "M", Admin: true, Estado: true},
+		{Nombre: "=carla", Password: "hash-carla", Genero: "F", Lentes: true, Estado: false},
+	}
+	for _, usuario := range usuarios {
+		_, err := repo.InsertUser(context.Background(), usuario)
+		require.NoError(t, err)
+	}
+	return repo
+}
+
+func TestExportUsers_CSV(t *testing.T) {
+	var out bytes.Buffer
+
+	rows, err := NewService(repoParaExportar(t)).ExportUsers(context.Background(), &out, Domain.ExportOptions{Format: Domain.ExportCSV})
+
+	require.NoError(t, err)
+	assert.Equal(t, 3, rows)
+	assert.True(t, strings.HasPrefix(out.String(), "\ufeff"))
+	assert.NotContains(t, out.String(), "hash-")
+
+	records, err := csv.NewReader(strings.NewReader(strings.TrimPrefix(out.String(), "\ufeff"))).ReadAll()
+	require.NoError(t, err)
+	require.Len(t, records, 4)
+	assert.Equal(t, Domain.ExportColumns, records[0])
+	assert.Equal(t, []string{"1", "ana", "F", "alta", "false", "false", "true", "asma", "false", "true", "1"}, records[1][:11])
+	// Un nombre con forma de formula no se ejecuta al abrirlo en una planilla.
+	assert.Equal(t, "'=carla", records[3][1])
+}
+
+func TestExportUsers_JSONLConColumnasFiltroYMascara(t *testing.T) {
+	var out bytes.Buffer
+	activo := true
+	opts := Domain.ExportOptions{
+		Format:      Domain.ExportJSONL,
+		Columns:     []string{"nombre", "diabetico", "enfermedades", "admin"},
+		MaskMedical: true,
+		Filter:      Domain.UserFilter{Estado: &activo},
+	}
+
+	rows, err := NewService(repoParaExportar(t)).ExportUsers(context.Background(), &out, opts)
+
+	require.NoError(t, err)
+	assert.Equal(t, 2, rows)
+	assert.Equal(t, `{"nombre":"ana","diabetico":"***","enfermedades":"***","admin":false}
+{"nombre":"bruno","diabetico":"***","enfermedades":"***","admin":true}
+`, out.String())
+
+	var linea map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(strings.SplitN(out.String(), "\n", 2)[0]), &linea))
+	assert.Equal(t, Domain.MaskedValue, linea["diabetico"])
+}
+
+func TestExportUsers_XLSX(t *testing.T) {
+	var out bytes.Buffer
+
+	rows, err := NewService(repoParaExportar(t)).ExportUsers(context.Background(), &out, Domain.ExportOptions{Format: Domain.ExportXLSX, Columns: []string{"nombre", "lentes"}})
+
+	require.NoError(t, err)
+	assert.Equal(t, 3, rows)
+	archive, err := zip.NewReader(bytes.NewReader(out.Bytes()), int64(out.Len()))
+	require.NoError(t, err)
+	sheet, err := archive.Open("xl/worksheets/sheet1.xml")
+	require.NoError(t, err)
+	content, err := io.ReadAll(sheet)
+	require.NoError(t, err)
+	assert.Contains(t, string(content), `<c r="A4" t="inlineStr"><is><t xml:space="preserve">=carla</t></is></c><c r="B4" t="b"><v>1</v></c>`)
+}
+
+func TestExportUsers_OpcionesInvalidas(t *testing.T) {
+	var out bytes.Buffer
+
+	_, err := NewService(repoParaExportar(t)).ExportUsers(context.Background(), &out, Domain.ExportOptions{Format: "pdf", Columns: []string{"nombre", "password", "nombre"}})
+
+	require.ErrorIs(t, err, Domain.ErrValidation)
+	var validationErr *Domain.ValidationError
+	require.True(t, errors.As(err, &validationErr))
+	assert.Equal(t, []Domain.FieldViolation{
+		{Field: "format", Rule: "oneof", Param: "csv jsonl xlsx"},
+		{Field: "columns", Rule: "oneof", Param: strings.Join(Domain.ExportColumns, " ")},
+		{Field: "columns", Rule: "unique", Param: "nombre"},
+	}, validationErr.Violations)
+	assert.Zero(t, out.Len())
+}
+
+func TestExportUsers_ErrorDelRepositorioNoEscribeNada(t *testing.T) {
+	repo := new(MockUserClients)
+	repo.On("ScanUsers", mock.Anything).Return([]Model.User{}, errors.New("connection refused"))
+	var out bytes.Buffer
+
+	_, err := NewService(repo).ExportUsers(context.Background(), &out, Domain.ExportOptions{Format: Domain.ExportXLSX})
+
+	assert.Error(t, err)
+	assert.Zero(t, out.Len())
+}
+
+func TestExportUsers_PasaElFiltroAlRepositorio(t *testing.T) {
+	repo := new(MockUserClients)
+	admin := true
+	repo.On("ScanUsers", Model.UserFilter{Admin: &admin, Nombre: "an"}).Return([]Model.User{{Id: 7, Nombre: "ana"}}, nil)
+	var out bytes.Buffer
+
+	rows, err := NewService(repo).ExportUsers(context.Background(), &out, Domain.ExportOptions{
+		Format:  Domain.ExportCSV,
+		Columns: []string{"id"},
+		Filter:  Domain.UserFilter{Admin: &admin, Nombre: "an"},
+	})
+
+	require.NoError(t, err)
+	assert.Equal(t, 1, rows)
+	assert.Equal(t, "\ufeffid\n7\n", out.String())
+	repo.AssertExpectations(t)
+}
@@ -0,0 +1,224 @@
+package services
+
+import (
+	Domain "Golang/domain"
+	"Golang/fhir"
+	"Golang/logging"
+	Model "Golang/model"
+	"Golang/tracing"
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Los recursos FHIR salen de model.User igual que los DTOs de mapping.go:
+// Genero va a Patient.gender, Diabetico y Enfermedades a Condition y Lentes
+// a una Observation. Todos los datos los declara el propio usuario, por eso
+// las Condition quedan como no confirmadas.
+
+// snomedDiabetes es "Diabetes mellitus (disorder)" en SNOMED CT.
+const snomedDiabetes = "73211009"
+
+// GetPatient devuelve el usuario como Patient de FHIR R4.
+func (s Service) GetPatient(ctx context.Context, id int) (_ fhir.Patient, err error) {
+	ctx, span := tracer.Start(ctx, "Service.GetPatient")
+	defer tracing.End(span, &err)
+
+	user, err := s.UserService.GetUserById(ctx, id)
+	if err != nil {
+		return fhir.Patient{}, fmt.Errorf("Error al buscar el paciente: %w", err)
+	}
+	return toPatient(user), nil
+}
+
+// ExportFHIR escribe en w un Bundle collection con el Patient, las Condition
+// y la Observation de cada usuario que cumple filter. base es la URL de la
+// API FHIR, sin barra final, para los fullUrl de cada entrada; vacia los
+// omite. Devuelve la cantidad de pacientes. Como en ExportUsers, si el error
+// llega antes de la primera tanda w no recibio nada.
+func (s Service) ExportFHIR(ctx context.Context, w io.Writer, base string, filter Domain.UserFilter) (patients int, err error) {
+	ctx, span := tracer.Start(ctx, "Service.ExportFHIR")
+	defer tracing.End(span, &err)
+
+	consented, err := s.consentedUsers(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("Error al exportar los pacientes: %w", err)
+	}
+
+	buffered := bufio.NewWriterSize(w, exportBufferSize)
+	timestamp, _ := json.Marshal(time.Now().UTC().Format(time.RFC3339))
+	fmt.Fprintf(buffered, `{"resourceType":%q,"type":%q,"timestamp":%s,"entry":[`, fhir.TypeBundle, fhir.BundleCollection, timestamp)
+
+	entries := 0
+	err = s.UserService.ScanUsers(ctx, toModelFilter(filter), func(user Model.User) error {
+		shared := consented == nil || consented[user.Id]
+		for _, entry := range bundleEntries(user, base, shared) {
+			encoded, err := json.Marshal(entry)
+			if err != nil {
+				return err
+			}
+			if entries > 0 {
+				buffered.WriteByte(',')
+			}
+			if _, err := buffered.Write(encoded); err != nil {
+				return err
+			}
+			entries++
+		}
+		patients++
+		if patients%exportFlushRows == 0 {
+			return buffered.Flush()
+		}
+		return nil
+	})
+	if err != nil {
+		return patients, fmt.Errorf("Error al exportar los pacientes: %w", err)
+	}
+	buffered.WriteString("]}")
+	if err := buffered.Flush(); err != nil {
+		return patients, fmt.Errorf("Error al exportar los pacientes: %w", err)
+	}
+
+	logging.FromContext(ctx).WithField("patients", patients).WithField("entries", entries).Info("fhir bundle exported")
+	return patients, nil
+}
+
+// bundleEntries son los recursos de un usuario, con el Patient primero. Sin
+// medical solo va el Patient: las Condition y la Observation son datos de
+// salud y requieren el consentimiento del usuario.
+func bundleEntries(user Model.User, base string, medical bool) []fhir.BundleEntry {
+	entry := func(resourceType string, id string, resource interface{}) fhir.BundleEntry {
+		result := fhir.BundleEntry{Resource: resource}
+		if base != "" {
+			result.FullUrl = base + "/" + resourceType + "/" + id
+		}
+		return result
+	}
+
+	patient := toPatient(user)
+	entries := []fhir.BundleEntry{entry(fhir.TypePatient, patient.Id, patient)}
+	if !medical {
+		return entries
+	}
+	for _, condition := range toConditions(user) {
+		entries = append(entries, entry(fhir.TypeCondition, condition.Id, condition))
+	}
+	for _, observation := range toObservations(user) {
+		entries = append(entries, entry(fhir.TypeObservation, observation.Id, observation))
+	}
+	return entries
+}
+
+func toPatient(user Model.User) fhir.Patient {
+	id := strconv.Itoa(user.Id)
+	active := user.Estado
+	return fhir.Patient{
+		ResourceType: fhir.TypePatient,
+		Id:           id,
+		Meta:         resourceMeta(user),
+		Identifier:   []fhir.Identifier{{System: fhir.IdentifierSystem, Value: id}},
+		Active:       &active,
+		Name:         []fhir.HumanName{{Text: user.Nombre}},
+		Gender:       fhirGender(user.Genero),
+	}
+}
+
+// toConditions devuelve una Condition codificada si el usuario es diabetico
+// y una por cada enfermedad de la lista libre, solo con texto.
+func toConditions(user Model.User) []fhir.Condition {
+	var conditions []fhir.Condition
+	if user.Diabetico {
+		conditions = append(conditions, newCondition(user, "diabetes", fhir.CodeableConcept{
+			Coding: []fhir.Coding{{System: fhir.SystemSNOMED, Code: snomedDiabetes, Display: "Diabetes mellitus"}},
+			Text:   "Diabetes",
+		}))
+	}
+	for i, enfermedad := range splitEnfermedades(user.Enfermedades) {
+		conditions = append(conditions, newCondition(user, "enfermedad-"+strconv.Itoa(i+1), fhir.CodeableConcept{Text: enfermedad}))
+	}
+	return conditions
+}
+
+func newCondition(user Model.User, suffix string, code fhir.CodeableConcept) fhir.Condition {
+	return fhir.Condition{
+		ResourceType: fhir.TypeCondition,
+		Id:           strconv.Itoa(user.Id) + "-" + suffix,
+		Meta:         resourceMeta(user),
+		ClinicalStatus: &fhir.CodeableConcept{
+			Coding: []fhir.Coding{{System: fhir.SystemConditionClinical, Code: "active", Display: "Active"}},
+		},
+		VerificationStatus: &fhir.CodeableConcept{
+			Coding: []fhir.Coding{{System: fhir.SystemConditionVerStatus, Code: "unconfirmed", Display: "Unconfirmed"}},
+		},
+		Category: []fhir.CodeableConcept{{
+			Coding: []fhir.Coding{{System: fhir.SystemConditionCategory, Code: "problem-list-item", Display: "Problem List Item"}},
+		}},
+		Code:    &code,
+		Subject: patientReference(user),
+	}
+}
+
+// toObservations informa si el usuario usa lentes, tanto si como no: que no
+// los use tambien es un dato.
+func toObservations(user Model.User) []fhir.Observation {
+	subject := patientReference(user)
+	lentes := user.Lentes
+	return []fhir.Observation{{
+		ResourceType: fhir.TypeObservation,
+		Id:           strconv.Itoa(user.Id) + "-lentes",
+		Meta:         resourceMeta(user),
+		Status:       "final",
+		Category: []fhir.CodeableConcept{{
+			Coding: []fhir.Coding{{System: fhir.SystemObservationCategory, Code: "survey", Display: "Survey"}},
+		}},
+		Code:              fhir.CodeableConcept{Text: "Usa lentes correctivos"},
+		Subject:           &subject,
+		EffectiveDateTime: fhirInstant(user.UpdatedAt),
+		ValueBoolean:      &lentes,
+	}}
+}
+
+// splitEnfermedades separa la lista libre de enfermedades por comas, punto y
+// coma o saltos de linea.
+func splitEnfermedades(text string) []string {
+	var enfermedades []string
+	for _, enfermedad := range strings.FieldsFunc(text, func(r rune) bool { return r == ',' || r == ';' || r == '\n' }) {
+		if enfermedad = strings.TrimSpace(enfermedad); enfermedad != "" {
+			enfermedades = append(enfermedades, enfermedad)
+		}
+	}
+	return enfermedades
+}
+
+func fhirGender(genero string) string {
+	switch genero {
+	case Domain.GeneroMasculino:
+		return fhir.GenderMale
+	case Domain.GeneroFemenino:
+		return fhir.GenderFemale
+	case Domain.GeneroOtro:
+		return fhir.GenderOther
+	}
+	return fhir.GenderUnknown
+}
+
+func patientReference(user Model.User) fhir.Reference {
+	return fhir.Reference{Reference: fhir.TypePatient + "/" + strconv.Itoa(user.Id)}
+}
+
+func resourceMeta(user Model.User) *fhir.Meta {
+	return &fhir.Meta{VersionId: strconv.Itoa(user.Version), LastUpdated: fhirInstant(user.UpdatedAt)}
+}
+
+// fhirInstant es el formato instant de FHIR. Sin fecha se omite.
+func fhirInstant(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
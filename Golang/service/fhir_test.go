@@ -0,0 +1,242 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"testing"
+	"time"
+
+	clientUsers "Golang/clients"
+	Domain "Golang/domain"
+	"Golang/fhir"
+	Model "Golang/model"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// Reglas de estructura de FHIR R4 para los recursos que genera la API:
+// elementos permitidos, obligatorios y value sets requeridos.
+var (
+	fhirIdPattern        = regexp.MustCompile(`^[A-Za-z0-9\-.]{1,64}$`)
+	fhirInstantPattern   = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}(\.\d+)?(Z|[+-]\d{2}:\d{2})$`)
+	fhirReferencePattern = regexp.MustCompile(`^Patient/[A-Za-z0-9\-.]{1,64}$`)
+
+	fhirElements = map[string][]string{
+		fhir.TypePatient:     {"resourceType", "id", "meta", "identifier", "active", "name", "gender"},
+		fhir.TypeCondition:   {"resourceType", "id", "meta", "clinicalStatus", "verificationStatus", "category", "code", "subject"},
+		fhir.TypeObservation: {"resourceType", "id", "meta", "status", "category", "code", "subject", "effectiveDateTime", "valueBoolean"},
+		fhir.TypeBundle:      {"resourceType", "type", "timestamp", "entry"},
+	}
+	fhirRequired = map[string][]string{
+		fhir.TypeCondition:   {"subject"},
+		fhir.TypeObservation: {"status", "code"},
+		fhir.TypeBundle:      {"type"},
+	}
+	fhirGenders           = []interface{}{"male", "female", "other", "unknown"}
+	fhirClinicalStatus    = []interface{}{"active", "recurrence", "relapse", "inactive", "remission", "resolved"}
+	fhirVerification      = []interface{}{"unconfirmed", "provisional", "differential", "confirmed", "refuted", "entered-in-error"}
+	fhirObservationStatus = []interface{}{"registered", "preliminary", "final", "amended", "corrected", "cancelled", "entered-in-error", "unknown"}
+	fhirBundleTypes       = []interface{}{"document", "message", "transaction", "transaction-response", "batch", "batch-response", "history", "searchset", "collection"}
+)
+
+// validateFHIR controla un recurso ya serializado a JSON contra las reglas de
+// arriba. Los Bundle se validan con todas sus entradas.
+func validateFHIR(t *testing.T, resource map[string]interface{}) {
+	t.Helper()
+	resourceType, _ := resource["resourceType"].(string)
+	allowed, ok := fhirElements[resourceType]
+	require.True(t, ok, "resourceType %q", resourceType)
+	for key := range resource {
+		assert.Contains(t, allowed, key, "%s.%s", resourceType, key)
+	}
+	for _, key := range fhirRequired[resourceType] {
+		assert.Contains(t, resource, key, "%s.%s es obligatorio", resourceType, key)
+	}
+	if resourceType != fhir.TypeBundle {
+		assert.Regexp(t, fhirIdPattern, resource["id"], "%s.id", resourceType)
+	}
+	if meta, ok := resource["meta"].(map[string]interface{}); ok {
+		assert.Regexp(t, fhirInstantPattern, meta["lastUpdated"])
+	}
+
+	switch resourceType {
+	case fhir.TypePatient:
+		assert.Contains(t, fhirGenders, resource["gender"])
+		assert.IsType(t, true, resource["active"])
+	case fhir.TypeCondition:
+		assert.Regexp(t, fhirReferencePattern, resource["subject"].(map[string]interface{})["reference"])
+		assert.Contains(t, fhirClinicalStatus, firstCode(t, resource["clinicalStatus"]))
+		assert.Contains(t, fhirVerification, firstCode(t, resource["verificationStatus"]))
+		assertConcept(t, resource["code"])
+	case fhir.TypeObservation:
+		assert.Contains(t, fhirObservationStatus, resource["status"])
+		assertConcept(t, resource["code"])
+		assert.Regexp(t, fhirReferencePattern, resource["subject"].(map[string]interface{})["reference"])
+		assert.Regexp(t, fhirInstantPattern, resource["effectiveDateTime"])
+	case fhir.TypeBundle:
+		assert.Contains(t, fhirBundleTypes, resource["type"])
+		assert.Regexp(t, fhirInstantPattern, resource["timestamp"])
+		seen := make(map[string]bool)
+		entries, _ := resource["entry"].([]interface{})
+		for _, raw := range entries {
+			entry := raw.(map[string]interface{})
+			child := entry["resource"].(map[string]interface{})
+			validateFHIR(t, child)
+			key := fmt.Sprintf("%s/%s", child["resourceType"], child["id"])
+			assert.False(t, seen[key], "%s repetido en el Bundle", key)
+			seen[key] = true
+			if fullUrl, ok := entry["fullUrl"]; ok {
+				assert.Regexp(t, `^https?://.+/`+regexp.QuoteMeta(key)+`$`, fullUrl)
+			}
+		}
+	}
+}
+
+// assertConcept controla que el CodeableConcept tenga texto o codigos, y que
+// cada codigo tenga sistema.
+func assertConcept(t *testing.T, value interface{}) {
+	t.Helper()
+	concept, ok := value.(map[string]interface{})
+	require.True(t, ok, "CodeableConcept")
+	codings, _ := concept["coding"].([]interface{})
+	assert.True(t, concept["text"] != nil || len(codings) > 0, "CodeableConcept vacio")
+	for _, raw := range codings {
+		coding := raw.(map[string]interface{})
+		assert.NotEmpty(t, coding["system"])
+		assert.NotEmpty(t, coding["code"])
+	}
+}
+
+func firstCode(t *testing.T, value interface{}) interface{} {
+	t.Helper()
+	assertConcept(t, value)
+	codings, _ := value.(map[string]interface{})["coding"].([]interface{})
+	require.NotEmpty(t, codings)
+	return codings[0].(map[string]interface{})["code"]
+}
+
+func asJSON(t *testing.T, value interface{}) map[string]interface{} {
+	t.Helper()
+	encoded, err := json.Marshal(value)
+	require.NoError(t, err)
+	var resource map[string]interface{}
+	require.NoError(t, json.Unmarshal(encoded, &resource))
+	return resource
+}
+
+var pacienteCompleto = Model.User{
+	Id:           7,
+	Nombre:       "ana",
+	Genero:       Domain.GeneroFemenino,
+	Lentes:       true,
+	Diabetico:    true,
+	Enfermedades: "asma; hipertension,\n ",
+	Estado:       true,
+	Version:      3,
+	UpdatedAt:    time.Date(2026, 10, 1, 12, 30, 0, 0, time.UTC),
+}
+
+func TestGetPatient(t *testing.T) {
+	repo := new(MockUserClients)
+	repo.On("GetUserById", 7).Return(pacienteCompleto, nil)
+
+	patient, err := NewService(repo).GetPatient(context.Background(), 7)
+
+	require.NoError(t, err)
+	validateFHIR(t, asJSON(t, patient))
+	assert.Equal(t, "7", patient.Id)
+	assert.Equal(t, fhir.GenderFemale, patient.Gender)
+	assert.Equal(t, []fhir.HumanName{{Text: "ana"}}, patient.Name)
+	assert.Equal(t, &fhir.Meta{VersionId: "3", LastUpdated: "2026-10-01T12:30:00Z"}, patient.Meta)
+	assert.Equal(t, []fhir.Identifier{{System: fhir.IdentifierSystem, Value: "7"}}, patient.Identifier)
+}
+
+func TestGetPatient_NoExiste(t *testing.T) {
+	_, err := NewService(clientUsers.NewMemory()).GetPatient(context.Background(), 99)
+
+	assert.ErrorIs(t, err, Domain.ErrNotFound)
+}
+
+func TestFHIRGender(t *testing.T) {
+	for genero, want := range map[string]string{"M": "male", "F": "female", "X": "other", "": "unknown", "Q": "unknown"} {
+		assert.Equal(t, want, fhirGender(genero), genero)
+	}
+}
+
+func TestBundleEntries_CondicionesYObservaciones(t *testing.T) {
+	entries := bundleEntries(pacienteCompleto, "https://api.example.com/fhir", true)
+
+	require.Len(t, entries, 5)
+	assert.Equal(t, "https://api.example.com/fhir/Patient/7", entries[0].FullUrl)
+
+	diabetes := entries[1].Resource.(fhir.Condition)
+	assert.Equal(t, "7-diabetes", diabetes.Id)
+	assert.Equal(t, "73211009", diabetes.Code.Coding[0].Code)
+	assert.Equal(t, fhir.Reference{Reference: "Patient/7"}, diabetes.Subject)
+	assert.Equal(t, "asma", entries[2].Resource.(fhir.Condition).Code.Text)
+	assert.Equal(t, "hipertension", entries[3].Resource.(fhir.Condition).Code.Text)
+
+	lentes := entries[4].Resource.(fhir.Observation)
+	assert.Equal(t, "7-lentes", lentes.Id)
+	assert.True(t, *lentes.ValueBoolean)
+
+	sinDatos := bundleEntries(Model.User{Id: 8, Nombre: "bruno", Genero: "M"}, "", true)
+	require.Len(t, sinDatos, 2)
+	assert.Empty(t, sinDatos[0].FullUrl)
+	assert.False(t, *sinDatos[1].Resource.(fhir.Observation).ValueBoolean)
+}
+
+func TestExportFHIR_BundleValido(t *testing.T) {
+	repo := clientUsers.NewMemory()
+	for _, user := range []Model.User{
+		{Nombre: "ana", Genero: "F", Diabetico: true, Enfermedades: "asma", Lentes: true, Estado: true},
+		{Nombre: "bruno", Genero: "M", Estado: true},
+		{Nombre: "carla", Genero: "X", Estado: false},
+	} {
+		_, err := repo.InsertUser(context.Background(), user)
+		require.NoError(t, err)
+	}
+	activo := true
+	var out bytes.Buffer
+
+	patients, err := NewService(repo).ExportFHIR(context.Background(), &out, "http://localhost/fhir", Domain.UserFilter{Estado: &activo})
+
+	require.NoError(t, err)
+	assert.Equal(t, 2, patients)
+	var bundle map[string]interface{}
+	require.NoError(t, json.Unmarshal(out.Bytes(), &bundle), out.String())
+	validateFHIR(t, bundle)
+	assert.Equal(t, fhir.BundleCollection, bundle["type"])
+	// ana: Patient, 2 Condition y la Observation; bruno: Patient y Observation.
+	assert.Len(t, bundle["entry"], 6)
+}
+
+func TestExportFHIR_SinPacientes(t *testing.T) {
+	var out bytes.Buffer
+
+	patients, err := NewService(clientUsers.NewMemory()).ExportFHIR(context.Background(), &out, "", Domain.UserFilter{})
+
+	require.NoError(t, err)
+	assert.Zero(t, patients)
+	var bundle map[string]interface{}
+	require.NoError(t, json.Unmarshal(out.Bytes(), &bundle))
+	validateFHIR(t, bundle)
+	assert.Empty(t, bundle["entry"])
+}
+
+func TestExportFHIR_ErrorDelRepositorioNoEscribeNada(t *testing.T) {
+	repo := new(MockUserClients)
+	repo.On("ScanUsers", mock.Anything).Return([]Model.User{}, errors.New("connection refused"))
+	var out bytes.Buffer
+
+	_, err := NewService(repo).ExportFHIR(context.Background(), &out, "", Domain.UserFilter{})
+
+	assert.Error(t, err)
+	assert.Zero(t, out.Len())
+}
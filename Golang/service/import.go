@@ -0,0 +1,410 @@
+package services
+
+import (
+	Domain "Golang/domain"
+	"Golang/logging"
+	Model "Golang/model"
+	"Golang/tracing"
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+const (
+	// maxImportRows acota un archivo; los mas grandes se parten.
+	maxImportRows = 100000
+	// maxImportErrors acota la lista de errores del reporte.
+	maxImportErrors = 1000
+	// importBatchSize es la cantidad de filas por transaccion.
+	importBatchSize = 200
+)
+
+// importRow es una fila leida del archivo, con su numero de linea.
+type importRow struct {
+	line   int
+	req    Domain.CreateUserRequest
+	errors []Domain.ImportRowError
+}
+
+// ImportUsers da de alta los usuarios de un archivo CSV o JSONL. Primero
+// valida todo el archivo: reglas de domain, nombres repetidos en el archivo
+// y nombres ya registrados. Con errores y sin SkipInvalid no escribe nada.
+//
+// Las filas se insertan en lotes de importBatchSize, cada uno en su propia
+// transaccion. Si un lote choca con un alta concurrente se reintenta fila por
+// fila para importar el resto. Si la base falla, el error se devuelve junto
+// con el reporte de lo importado hasta ese momento.
+func (s Service) ImportUsers(ctx context.Context, data io.Reader, opts Domain.ImportOptions) (_ Domain.ImportReport, err error) {
+	ctx, span := tracer.Start(ctx, "Service.ImportUsers")
+	defer tracing.End(span, &err)
+
+	report := Domain.ImportReport{Format: opts.Format, DryRun: opts.DryRun, Errors: []Domain.ImportRowError{}}
+
+	rows, err := decodeImport(data, opts.Format)
+	if err != nil {
+		return report, err
+	}
+	report.Rows = len(rows)
+
+	for i := range rows {
+		rows[i].errors = append(rows[i].errors, violations(rows[i])...)
+	}
+	if err := s.checkNames(ctx, rows); err != nil {
+		return report, fmt.Errorf("Error al buscar nombres registrados: %w", err)
+	}
+
+	valid := make([]importRow, 0, len(rows))
+	for _, row := range rows {
+		if len(row.errors) == 0 {
+			valid = append(valid, row)
+			continue
+		}
+		report.Invalid++
+		addErrors(&report, row.errors...)
+	}
+	report.Valid = len(valid)
+
+	logger := logging.FromContext(ctx).WithField("format", opts.Format).WithField("rows", report.Rows).WithField("invalid", report.Invalid)
+	if opts.DryRun {
+		logger.Info("import dry run finished")
+		return report, nil
+	}
+	if report.Invalid > 0 && !opts.SkipInvalid {
+		report.Aborted = true
+		logger.Info("import aborted: file has invalid rows")
+		return report, nil
+	}
+
+	for start := 0; start < len(valid); start += importBatchSize {
+		end := start + importBatchSize
+		if end > len(valid) {
+			end = len(valid)
+		}
+		// gorm no corta una sentencia al cancelar ctx; se controla entre lotes.
+		if err := ctx.Err(); err != nil {
+			logger.WithField("imported", report.Imported).Warn("import cancelled")
+			return report, fmt.Errorf("Error al importar los usuarios: %w", err)
+		}
+		if err := s.insertBatch(ctx, valid[start:end], &report); err != nil {
+			logger.WithField("imported", report.Imported).WithError(err).Error("import stopped")
+			return report, fmt.Errorf("Error al importar los usuarios: %w", err)
+		}
+	}
+
+	logger.WithField("imported", report.Imported).Info("import finished")
+	return report, nil
+}
+
+// checkNames marca los nombres repetidos dentro del archivo y los que ya
+// estan registrados.
+func (s Service) checkNames(ctx context.Context, rows []importRow) error {
+	first := make(map[string]int, len(rows))
+	nombres := make([]string, 0, len(rows))
+	for i, row := range rows {
+		nombre := row.req.Nombre
+		if strings.TrimSpace(nombre) == "" {
+			continue
+		}
+		if line, seen := first[nombre]; seen {
+			rows[i].errors = append(rows[i].errors, rowError(row, "nombre", Domain.ImportRuleDuplicate, strconv.Itoa(line)))
+			continue
+		}
+		first[nombre] = row.line
+		nombres = append(nombres, nombre)
+	}
+
+	existing, err := s.UserService.ExistingNames(ctx, nombres)
+	if err != nil {
+		return err
+	}
+	registered := make(map[string]bool, len(existing))
+	for _, nombre := range existing {
+		registered[nombre] = true
+	}
+	for i, row := range rows {
+		if registered[row.req.Nombre] {
+			rows[i].errors = append(rows[i].errors, rowError(row, "nombre", Domain.ImportRuleExists, ""))
+		}
+	}
+	return nil
+}
+
+func (s Service) insertBatch(ctx context.Context, batch []importRow, report *Domain.ImportReport) error {
+	users := make([]Model.User, 0, len(batch))
+	for _, row := range batch {
+		users = append(users, userFromCreate(row.req, hashPassword(row.req.Password)))
+	}
+
+	created, err := s.UserService.InsertUsers(ctx, users)
+	if err == nil {
+		report.Imported += len(created)
+		return nil
+	}
+	if !errors.Is(err, Domain.ErrConflict) {
+		return err
+	}
+
+	// Alguien registro uno de los nombres despues de la validacion. Fila por
+	// fila solo fallan las que chocan.
+	for i, row := range batch {
+		if _, err := s.UserService.InsertUser(ctx, users[i]); err != nil {
+			if !errors.Is(err, Domain.ErrConflict) {
+				return err
+			}
+			report.Invalid++
+			report.Valid--
+			addErrors(report, rowError(row, "nombre", Domain.ImportRuleConflict, ""))
+			continue
+		}
+		report.Imported++
+	}
+	return nil
+}
+
+func addErrors(report *Domain.ImportReport, rowErrors ...Domain.ImportRowError) {
+	for _, rowErr := range rowErrors {
+		if len(report.Errors) == maxImportErrors {
+			report.ErrorsTruncated = true
+			return
+		}
+		report.Errors = append(report.Errors, rowErr)
+	}
+}
+
+func rowError(row importRow, field string, rule string, param string) Domain.ImportRowError {
+	return Domain.ImportRowError{Row: row.line, Nombre: row.req.Nombre, Field: field, Rule: rule, Param: param}
+}
+
+// violations traduce las reglas de domain incumplidas por la fila. Las filas
+// que ya tienen un error de formato no se validan: sus valores no son
+// confiables.
+func violations(row importRow) []Domain.ImportRowError {
+	if len(row.errors) > 0 {
+		return nil
+	}
+	err := row.req.Validate()
+	if err == nil {
+		return nil
+	}
+	var validationErr *Domain.ValidationError
+	if !errors.As(err, &validationErr) {
+		return []Domain.ImportRowError{rowError(row, "", Domain.ImportRuleFormat, "")}
+	}
+	result := make([]Domain.ImportRowError, 0, len(validationErr.Violations))
+	for _, v := range validationErr.Violations {
+		result = append(result, rowError(row, v.Field, v.Rule, v.Param))
+	}
+	return result
+}
+
+// decodeImport lee todas las filas del archivo. Un error de formato en una
+// fila queda en esa fila; solo un archivo ilegible devuelve error.
+func decodeImport(data io.Reader, format string) ([]importRow, error) {
+	switch format {
+	case Domain.ImportCSV:
+		return decodeCSV(data)
+	case Domain.ImportJSONL:
+		return decodeJSONL(data)
+	default:
+		return nil, &Domain.ImportFileError{Reason: Domain.ImportFileUnsupported, Param: format}
+	}
+}
+
+// importColumns son las columnas del CSV, las mismas claves que el JSON de
+// POST /users salvo el consentimiento, que solo lo puede dar el propio
+// usuario.
+var importColumns = importFields()
+
+func importFields() map[string]int {
+	fields := jsonFields(reflect.TypeOf(Domain.CreateUserRequest{}))
+	delete(fields, "consentimiento")
+	return fields
+}
+
+func jsonFields(t reflect.Type) map[string]int {
+	fields := make(map[string]int, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		name := strings.SplitN(t.Field(i).Tag.Get("json"), ",", 2)[0]
+		fields[name] = i
+	}
+	return fields
+}
+
+// decodeCSV acepta coma o punto y coma como separador, segun el que use el
+// encabezado: las planillas en español suelen exportar con punto y coma.
+func decodeCSV(data io.Reader) ([]importRow, error) {
+	buffered := bufio.NewReader(data)
+	reader := csv.NewReader(buffered)
+	reader.Comma = detectComma(buffered)
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, &Domain.ImportFileError{Reason: Domain.ImportFileEmpty}
+	}
+	if err != nil {
+		return nil, malformed(err)
+	}
+
+	columns := make([]int, len(header))
+	seen := make(map[string]bool, len(header))
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		field, ok := importColumns[name]
+		if !ok {
+			return nil, &Domain.ImportFileError{Reason: Domain.ImportFileUnknownColumn, Param: name, Line: 1}
+		}
+		if seen[name] {
+			return nil, &Domain.ImportFileError{Reason: Domain.ImportFileRepeatedColumn, Param: name, Line: 1}
+		}
+		seen[name] = true
+		columns[i] = field
+	}
+	for _, required := range []string{"nombre", "password", "genero"} {
+		if !seen[required] {
+			return nil, &Domain.ImportFileError{Reason: Domain.ImportFileMissingColumn, Param: required, Line: 1}
+		}
+	}
+
+	rows := make([]importRow, 0)
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, malformed(err)
+		}
+		if len(rows) == maxImportRows {
+			return nil, tooManyRows()
+		}
+
+		line, _ := reader.FieldPos(0)
+		row := importRow{line: line}
+		if len(record) != len(header) {
+			row.errors = append(row.errors, rowError(row, "", Domain.ImportRuleFormat, fmt.Sprintf("%d columns", len(header))))
+			rows = append(rows, row)
+			continue
+		}
+		value := reflect.ValueOf(&row.req).Elem()
+		for i, text := range record {
+			field := value.Field(columns[i])
+			if field.Kind() == reflect.Bool {
+				parsed, ok := parseBool(text)
+				if !ok {
+					row.errors = append(row.errors, rowError(row, strings.ToLower(strings.TrimSpace(header[i])), Domain.ImportRuleFormat, "bool"))
+					continue
+				}
+				field.SetBool(parsed)
+				continue
+			}
+			field.SetString(text)
+		}
+		row.req.Normalize()
+		for i := range row.errors {
+			row.errors[i].Nombre = row.req.Nombre
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// malformed describe un CSV que no se puede interpretar, con la linea si el
+// lector la informa.
+func malformed(err error) error {
+	fileErr := &Domain.ImportFileError{Reason: Domain.ImportFileMalformed}
+	var parseErr *csv.ParseError
+	if errors.As(err, &parseErr) {
+		fileErr.Line = parseErr.Line
+	}
+	return fileErr
+}
+
+func tooManyRows() error {
+	return &Domain.ImportFileError{Reason: Domain.ImportFileTooManyRows, Param: strconv.Itoa(maxImportRows)}
+}
+
+// detectComma mira la primera linea sin consumirla.
+func detectComma(data *bufio.Reader) rune {
+	peek, _ := data.Peek(4096)
+	if end := bytes.IndexByte(peek, '\n'); end >= 0 {
+		peek = peek[:end]
+	}
+	if bytes.Count(peek, []byte(";")) > bytes.Count(peek, []byte(",")) {
+		return ';'
+	}
+	return ','
+}
+
+// parseBool acepta los valores de strconv.ParseBool y si/no. Vacio es false.
+func parseBool(text string) (bool, bool) {
+	switch strings.ToLower(strings.TrimSpace(text)) {
+	case "":
+		return false, true
+	case "si", "sí":
+		return true, true
+	case "no":
+		return false, true
+	}
+	value, err := strconv.ParseBool(strings.TrimSpace(text))
+	return value, err == nil
+}
+
+func decodeJSONL(data io.Reader) ([]importRow, error) {
+	scanner := bufio.NewScanner(data)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1<<20)
+
+	rows := make([]importRow, 0)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := bytes.TrimSpace(scanner.Bytes())
+		if line == 1 {
+			text = bytes.TrimPrefix(text, []byte("\ufeff"))
+		}
+		if len(text) == 0 {
+			continue
+		}
+		if len(rows) == maxImportRows {
+			return nil, tooManyRows()
+		}
+
+		row := importRow{line: line}
+		decoder := json.NewDecoder(bytes.NewReader(text))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&row.req); err != nil {
+			row.errors = append(row.errors, rowError(row, jsonErrorField(err), Domain.ImportRuleFormat, ""))
+		} else if row.req.Consentimiento != 0 {
+			row.errors = append(row.errors, rowError(row, "consentimiento", Domain.ImportRuleFormat, ""))
+		}
+		row.req.Normalize()
+		rows = append(rows, row)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, &Domain.ImportFileError{Reason: Domain.ImportFileMalformed, Line: line + 1}
+	}
+	if line == 0 {
+		return nil, &Domain.ImportFileError{Reason: Domain.ImportFileEmpty}
+	}
+	return rows, nil
+}
+
+// jsonErrorField indica el campo culpable cuando el decoder lo sabe.
+func jsonErrorField(err error) string {
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) {
+		return typeErr.Field
+	}
+	if field, ok := strings.CutPrefix(err.Error(), "json: unknown field "); ok {
+		return strings.Trim(field, `"`)
+	}
+	return ""
+}
@@ -0,0 +1,208 @@
+package services
+
+import (
+	Domain "Golang/domain"
+	"Golang/logging"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// defaultImportRetention es cuanto se conserva el estado de una importacion
+// terminada.
+const defaultImportRetention = time.Hour
+
+// errImportsClosed se devuelve al pedir una importacion durante el apagado.
+var errImportsClosed = errors.New("imports are shutting down")
+
+// Importer corre las importaciones grandes en segundo plano, de a una por
+// vez para no competir con el trafico normal por la base. El estado de los
+// trabajos vive en memoria: con varias replicas, GET /users/import/:id tiene
+// que llegar a la misma que recibio el archivo.
+type Importer struct {
+	service   Service
+	retention time.Duration
+	// slot permite un solo trabajo corriendo; los demas quedan en cola.
+	slot chan struct{}
+
+	mu     sync.Mutex
+	jobs   map[string]*Domain.ImportJob
+	closed bool
+
+	running sync.WaitGroup
+	base    context.Context
+	cancel  context.CancelFunc
+}
+
+// NewImporter crea el Importer. Si retention es cero se usa una hora.
+func NewImporter(service Service, retention time.Duration) *Importer {
+	if retention <= 0 {
+		retention = defaultImportRetention
+	}
+	base, cancel := context.WithCancel(context.Background())
+	return &Importer{
+		service:   service,
+		retention: retention,
+		slot:      make(chan struct{}, 1),
+		jobs:      make(map[string]*Domain.ImportJob),
+		base:      base,
+		cancel:    cancel,
+	}
+}
+
+// ImportUsers importa en el momento, dentro del pedido.
+func (importer *Importer) ImportUsers(ctx context.Context, data io.Reader, opts Domain.ImportOptions) (Domain.ImportReport, error) {
+	return importer.service.ImportUsers(ctx, data, opts)
+}
+
+// StartImport encola la importacion y devuelve el trabajo sin esperarla. El
+// trabajo conserva los datos de log del pedido pero no se corta cuando el
+// pedido termina.
+func (importer *Importer) StartImport(ctx context.Context, data []byte, opts Domain.ImportOptions) (Domain.ImportJob, error) {
+	importer.mu.Lock()
+	defer importer.mu.Unlock()
+	if importer.closed {
+		return Domain.ImportJob{}, errImportsClosed
+	}
+	importer.prune()
+
+	id, err := newJobId()
+	if err != nil {
+		return Domain.ImportJob{}, err
+	}
+	job := &Domain.ImportJob{Id: id, Status: Domain.ImportQueued, CreatedAt: time.Now().UTC()}
+	importer.jobs[id] = job
+
+	jobCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	stop := context.AfterFunc(importer.base, cancel)
+	importer.running.Add(1)
+	go func() {
+		defer importer.running.Done()
+		defer stop()
+		defer cancel()
+		importer.run(jobCtx, job, data, opts)
+	}()
+
+	logging.FromContext(ctx).WithField("job_id", id).WithField("bytes", len(data)).Info("import queued")
+	return *job, nil
+}
+
+// GetImportJob devuelve el estado de un trabajo. Los terminados hace mas de
+// la retencion ya no existen.
+func (importer *Importer) GetImportJob(ctx context.Context, id string) (Domain.ImportJob, error) {
+	importer.mu.Lock()
+	defer importer.mu.Unlock()
+	importer.prune()
+
+	job, ok := importer.jobs[id]
+	if !ok {
+		return Domain.ImportJob{}, fmt.Errorf("import job %q: %w", id, Domain.ErrNotFound)
+	}
+	return copyJob(job), nil
+}
+
+// Close deja de aceptar importaciones y espera las que estan en curso hasta
+// que venza ctx. Despues las cancela; los lotes ya confirmados quedan.
+func (importer *Importer) Close(ctx context.Context) error {
+	importer.mu.Lock()
+	importer.closed = true
+	importer.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		importer.running.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		importer.cancel()
+		return nil
+	case <-ctx.Done():
+		importer.cancel()
+		<-done
+		return fmt.Errorf("imports cancelled before finishing: %w", ctx.Err())
+	}
+}
+
+func (importer *Importer) run(ctx context.Context, job *Domain.ImportJob, data []byte, opts Domain.ImportOptions) {
+	logger := logging.FromContext(ctx).WithField("job_id", job.Id)
+
+	select {
+	case importer.slot <- struct{}{}:
+		defer func() { <-importer.slot }()
+	case <-ctx.Done():
+		importer.finish(job, nil, ctx.Err())
+		logger.Warn("import cancelled before starting")
+		return
+	}
+	importer.update(job, func(job *Domain.ImportJob) { job.Status = Domain.ImportRunning })
+
+	report, err := importer.service.ImportUsers(ctx, bytes.NewReader(data), opts)
+	if err != nil {
+		logger.WithError(err).Error("import job failed")
+	}
+	importer.finish(job, &report, err)
+}
+
+// finish registra el resultado. El detalle de una falla interna va solo al
+// log; al cliente se le muestran los errores del archivo.
+func (importer *Importer) finish(job *Domain.ImportJob, report *Domain.ImportReport, err error) {
+	importer.update(job, func(job *Domain.ImportJob) {
+		finished := time.Now().UTC()
+		job.FinishedAt = &finished
+		job.Report = report
+		job.Status = Domain.ImportSucceeded
+		switch {
+		case err == nil:
+		case errors.Is(err, Domain.ErrValidation):
+			job.Status = Domain.ImportFailed
+			job.Error = err.Error()
+		case errors.Is(err, context.Canceled):
+			job.Status = Domain.ImportFailed
+			job.Error = "import cancelled by server shutdown"
+		default:
+			job.Status = Domain.ImportFailed
+			job.Error = "import failed, see the server logs"
+		}
+	})
+}
+
+func (importer *Importer) update(job *Domain.ImportJob, change func(*Domain.ImportJob)) {
+	importer.mu.Lock()
+	defer importer.mu.Unlock()
+	change(job)
+}
+
+// prune borra los trabajos vencidos. Debe llamarse con el lock tomado.
+func (importer *Importer) prune() {
+	limit := time.Now().Add(-importer.retention)
+	for id, job := range importer.jobs {
+		if job.FinishedAt != nil && job.FinishedAt.Before(limit) {
+			delete(importer.jobs, id)
+		}
+	}
+}
+
+func copyJob(job *Domain.ImportJob) Domain.ImportJob {
+	result := *job
+	if job.Report != nil {
+		report := *job.Report
+		report.Errors = append(make([]Domain.ImportRowError, 0, len(job.Report.Errors)), job.Report.Errors...)
+		result.Report = &report
+	}
+	return result
+}
+
+func newJobId() (string, error) {
+	var id [16]byte
+	if _, err := rand.Read(id[:]); err != nil {
+		return "", fmt.Errorf("generating job id: %w", err)
+	}
+	return hex.EncodeToString(id[:]), nil
+}
@@ -0,0 +1,327 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	clientUsers "Golang/clients"
+	Domain "Golang/domain"
+	Model "Golang/model"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+const csvValido = `nombre,password,genero,atributos,maneja,lentes,diabetico,enfermedades
+ana,secreto1,F,alta,si,no,false,
+bruno,secreto2,M,,true,1,no,asma
+`
+
+func repoConUsuarios(t *testing.T, nombres ...string) *clientUsers.Memory {
+	t.Helper()
+	repo := clientUsers.NewMemory()
+	for _, nombre := range nombres {
+		_, err := repo.InsertUser(context.Background(), Model.User{Nombre: nombre, Genero: "F", Estado: true})
+		require.NoError(t, err)
+	}
+	return repo
+}
+
+func TestImportUsers_CSV(t *testing.T) {
+	repo := repoConUsuarios(t)
+
+	report, err := NewService(repo).ImportUsers(context.Background(), strings.NewReader(csvValido), Domain.ImportOptions{Format: Domain.ImportCSV})
+
+	require.NoError(t, err)
+	assert.Equal(t, 2, report.Rows)
+	assert.Equal(t, 2, report.Imported)
+	assert.Empty(t, report.Errors)
+
+	ana, err := repo.GetUserByName(context.Background(), Model.User{Nombre: "ana"})
+	require.NoError(t, err)
+	assert.True(t, ana.Maneja)
+	assert.False(t, ana.Lentes)
+	assert.True(t, ana.Estado)
+	assert.False(t, ana.Admin)
+	assert.Equal(t, hashPassword("secreto1"), ana.Password)
+
+	bruno, err := repo.GetUserByName(context.Background(), Model.User{Nombre: "bruno"})
+	require.NoError(t, err)
+	assert.True(t, bruno.Lentes)
+	assert.Equal(t, "asma", bruno.Enfermedades)
+}
+
+func TestImportUsers_CSVConPuntoYComaYBOM(t *testing.T) {
+	repo := repoConUsuarios(t)
+	data := "\ufeffNombre;Password;Genero\r\nana;secreto1;F\r\n"
+
+	report, err := NewService(repo).ImportUsers(context.Background(), strings.NewReader(data), Domain.ImportOptions{Format: Domain.ImportCSV})
+
+	require.NoError(t, err)
+	assert.Equal(t, 1, report.Imported)
+}
+
+func TestImportUsers_NormalizaGeneroHeredado(t *testing.T) {
+	repo := repoConUsuarios(t)
+	data := "nombre,password,genero\nana,secreto1,Femenino\n"
+
+	report, err := NewService(repo).ImportUsers(context.Background(), strings.NewReader(data), Domain.ImportOptions{Format: Domain.ImportCSV})
+
+	require.NoError(t, err)
+	require.Equal(t, 1, report.Imported)
+	user, err := repo.GetUserByName(context.Background(), Model.User{Nombre: "ana"})
+	require.NoError(t, err)
+	assert.Equal(t, "F", user.Genero)
+}
+
+func TestImportUsers_JSONL(t *testing.T) {
+	repo := repoConUsuarios(t)
+	data := `{"nombre":"ana","password":"secreto1","genero":"F","lentes":true}
+
+{"nombre":"bruno","password":"secreto2","genero":"M"}
+`
+
+	report, err := NewService(repo).ImportUsers(context.Background(), strings.NewReader(data), Domain.ImportOptions{Format: Domain.ImportJSONL})
+
+	require.NoError(t, err)
+	assert.Equal(t, 2, report.Rows)
+	assert.Equal(t, 2, report.Imported)
+}
+
+func TestImportUsers_DryRunReportaErroresPorFila(t *testing.T) {
+	repo := repoConUsuarios(t, "carla")
+	data := `nombre,password,genero,maneja
+ana,secreto1,F,si
+,corta,Z,no
+carla,secreto3,F,no
+ana,secreto4,F,no
+diego,secreto5,M,quizas
+`
+
+	report, err := NewService(repo).ImportUsers(context.Background(), strings.NewReader(data), Domain.ImportOptions{Format: Domain.ImportCSV, DryRun: true})
+
+	require.NoError(t, err)
+	assert.True(t, report.DryRun)
+	assert.Equal(t, 5, report.Rows)
+	assert.Equal(t, 1, report.Valid)
+	assert.Equal(t, 4, report.Invalid)
+	assert.Equal(t, 0, report.Imported)
+	assert.Equal(t, []Domain.ImportRowError{
+		{Row: 3, Field: "nombre", Rule: "notblank"},
+		{Row: 3, Field: "password", Rule: "min", Param: "6"},
+		{Row: 3, Field: "genero", Rule: "oneof", Param: "M F X"},
+		{Row: 4, Nombre: "carla", Field: "nombre", Rule: Domain.ImportRuleExists},
+		{Row: 5, Nombre: "ana", Field: "nombre", Rule: Domain.ImportRuleDuplicate, Param: "2"},
+		{Row: 6, Nombre: "diego", Field: "maneja", Rule: Domain.ImportRuleFormat, Param: "bool"},
+	}, report.Errors)
+
+	all, _ := repo.GetAllUsers(context.Background())
+	assert.Len(t, all, 1)
+}
+
+func TestImportUsers_ConErroresNoImportaNada(t *testing.T) {
+	repo := repoConUsuarios(t)
+	data := "nombre,password,genero\nana,secreto1,F\nbruno,corta,M\n"
+
+	report, err := NewService(repo).ImportUsers(context.Background(), strings.NewReader(data), Domain.ImportOptions{Format: Domain.ImportCSV})
+
+	require.NoError(t, err)
+	assert.True(t, report.Aborted)
+	assert.Equal(t, 0, report.Imported)
+	all, _ := repo.GetAllUsers(context.Background())
+	assert.Empty(t, all)
+}
+
+func TestImportUsers_SkipInvalidImportaLasValidas(t *testing.T) {
+	repo := repoConUsuarios(t)
+	data := "nombre,password,genero\nana,secreto1,F\nbruno,corta,M\n"
+
+	report, err := NewService(repo).ImportUsers(context.Background(), strings.NewReader(data), Domain.ImportOptions{Format: Domain.ImportCSV, SkipInvalid: true})
+
+	require.NoError(t, err)
+	assert.False(t, report.Aborted)
+	assert.Equal(t, 1, report.Imported)
+	assert.Equal(t, 1, report.Invalid)
+}
+
+func TestImportUsers_JSONLConLineasInvalidas(t *testing.T) {
+	repo := repoConUsuarios(t)
+	data := `{"nombre":"ana","password":"secreto1","genero":"F","admin":true}
+{"nombre":"bruno","password":"secreto2","genero":"M","lentes":"si"}
+{"nombre":
+`
+
+	report, err := NewService(repo).ImportUsers(context.Background(), strings.NewReader(data), Domain.ImportOptions{Format: Domain.ImportJSONL, DryRun: true})
+
+	require.NoError(t, err)
+	assert.Equal(t, []Domain.ImportRowError{
+		{Row: 1, Nombre: "ana", Field: "admin", Rule: Domain.ImportRuleFormat},
+		{Row: 2, Nombre: "bruno", Field: "lentes", Rule: Domain.ImportRuleFormat},
+		{Row: 3, Rule: Domain.ImportRuleFormat},
+	}, report.Errors)
+}
+
+func TestImportUsers_ErroresDeArchivo(t *testing.T) {
+	casos := []struct {
+		nombre string
+		format string
+		data   string
+		reason string
+		param  string
+	}{
+		{"vacio", Domain.ImportCSV, "", Domain.ImportFileEmpty, ""},
+		{"columna desconocida", Domain.ImportCSV, "nombre,password,genero,edad\n", Domain.ImportFileUnknownColumn, "edad"},
+		{"consentimiento", Domain.ImportCSV, "nombre,password,genero,consentimiento\n", Domain.ImportFileUnknownColumn, "consentimiento"},
+		{"columna repetida", Domain.ImportCSV, "nombre,nombre,password,genero\n", Domain.ImportFileRepeatedColumn, "nombre"},
+		{"falta columna", Domain.ImportCSV, "nombre,genero\n", Domain.ImportFileMissingColumn, "password"},
+		{"comillas sin cerrar", Domain.ImportCSV, "nombre,password,genero\n\"ana,secreto1,F\n", Domain.ImportFileMalformed, ""},
+		{"jsonl vacio", Domain.ImportJSONL, "", Domain.ImportFileEmpty, ""},
+		{"formato desconocido", "xlsx", "", Domain.ImportFileUnsupported, "xlsx"},
+	}
+
+	for _, caso := range casos {
+		t.Run(caso.nombre, func(t *testing.T) {
+			_, err := NewService(repoConUsuarios(t)).ImportUsers(context.Background(), strings.NewReader(caso.data), Domain.ImportOptions{Format: caso.format})
+
+			var fileErr *Domain.ImportFileError
+			require.ErrorAs(t, err, &fileErr)
+			assert.ErrorIs(t, err, Domain.ErrValidation)
+			assert.Equal(t, caso.reason, fileErr.Reason)
+			assert.Equal(t, caso.param, fileErr.Param)
+		})
+	}
+}
+
+func TestImportUsers_VariosLotes(t *testing.T) {
+	repo := repoConUsuarios(t)
+	var data strings.Builder
+	data.WriteString("nombre,password,genero\n")
+	for i := 0; i < importBatchSize*2+5; i++ {
+		fmt.Fprintf(&data, "usuario-%d,secreto,X\n", i)
+	}
+
+	report, err := NewService(repo).ImportUsers(context.Background(), strings.NewReader(data.String()), Domain.ImportOptions{Format: Domain.ImportCSV})
+
+	require.NoError(t, err)
+	assert.Equal(t, importBatchSize*2+5, report.Imported)
+}
+
+func TestImportUsers_ConflictoConcurrenteSeReintentaPorFila(t *testing.T) {
+	mockClients := new(MockUserClients)
+	mockClients.On("ExistingNames", []string{"ana", "bruno"}).Return([]string{}, nil)
+	mockClients.On("InsertUsers", mock.Anything).Return([]Model.User(nil), fmt.Errorf("error importing users: %w", Domain.ErrConflict))
+	mockClients.On("InsertUser", mock.MatchedBy(func(u Model.User) bool { return u.Nombre == "ana" })).Return(Model.User{Id: 1}, nil)
+	mockClients.On("InsertUser", mock.MatchedBy(func(u Model.User) bool { return u.Nombre == "bruno" })).Return(Model.User{}, fmt.Errorf("error creating user: %w", Domain.ErrConflict))
+
+	data := "nombre,password,genero\nana,secreto1,F\nbruno,secreto2,M\n"
+	report, err := NewService(mockClients).ImportUsers(context.Background(), strings.NewReader(data), Domain.ImportOptions{Format: Domain.ImportCSV})
+
+	require.NoError(t, err)
+	assert.Equal(t, 1, report.Imported)
+	assert.Equal(t, 1, report.Valid)
+	assert.Equal(t, 1, report.Invalid)
+	assert.Equal(t, []Domain.ImportRowError{{Row: 3, Nombre: "bruno", Field: "nombre", Rule: Domain.ImportRuleConflict}}, report.Errors)
+	mockClients.AssertExpectations(t)
+}
+
+func TestImportUsers_FallaDeLaBase(t *testing.T) {
+	mockClients := new(MockUserClients)
+	mockClients.On("ExistingNames", mock.Anything).Return([]string{}, nil)
+	mockClients.On("InsertUsers", mock.Anything).Return([]Model.User(nil), errors.New("error importing users: connection reset"))
+
+	data := "nombre,password,genero\nana,secreto1,F\n"
+	report, err := NewService(mockClients).ImportUsers(context.Background(), strings.NewReader(data), Domain.ImportOptions{Format: Domain.ImportCSV})
+
+	assert.Error(t, err)
+	assert.NotErrorIs(t, err, Domain.ErrValidation)
+	assert.Equal(t, 0, report.Imported)
+}
+
+func esperarJob(t *testing.T, importer *Importer, id string) Domain.ImportJob {
+	t.Helper()
+	var job Domain.ImportJob
+	require.Eventually(t, func() bool {
+		var err error
+		job, err = importer.GetImportJob(context.Background(), id)
+		require.NoError(t, err)
+		return job.FinishedAt != nil
+	}, 5*time.Second, 10*time.Millisecond)
+	return job
+}
+
+func TestImporter_TrabajoEnSegundoPlano(t *testing.T) {
+	repo := repoConUsuarios(t)
+	importer := NewImporter(NewService(repo), time.Hour)
+	defer importer.Close(context.Background())
+
+	job, err := importer.StartImport(context.Background(), []byte(csvValido), Domain.ImportOptions{Format: Domain.ImportCSV})
+	require.NoError(t, err)
+	assert.Equal(t, Domain.ImportQueued, job.Status)
+	assert.Len(t, job.Id, 32)
+
+	job = esperarJob(t, importer, job.Id)
+	assert.Equal(t, Domain.ImportSucceeded, job.Status)
+	require.NotNil(t, job.Report)
+	assert.Equal(t, 2, job.Report.Imported)
+}
+
+func TestImporter_ArchivoInvalido(t *testing.T) {
+	importer := NewImporter(NewService(repoConUsuarios(t)), time.Hour)
+	defer importer.Close(context.Background())
+
+	job, err := importer.StartImport(context.Background(), []byte("nombre,edad\n"), Domain.ImportOptions{Format: Domain.ImportCSV})
+	require.NoError(t, err)
+
+	job = esperarJob(t, importer, job.Id)
+	assert.Equal(t, Domain.ImportFailed, job.Status)
+	assert.Contains(t, job.Error, "unknown_column")
+}
+
+func TestImporter_FallaInternaNoExponeDetalle(t *testing.T) {
+	mockClients := new(MockUserClients)
+	mockClients.On("ExistingNames", mock.Anything).Return([]string(nil), errors.New("dial tcp 10.0.0.5:3306: connection refused"))
+	importer := NewImporter(NewService(mockClients), time.Hour)
+	defer importer.Close(context.Background())
+
+	job, err := importer.StartImport(context.Background(), []byte(csvValido), Domain.ImportOptions{Format: Domain.ImportCSV})
+	require.NoError(t, err)
+
+	job = esperarJob(t, importer, job.Id)
+	assert.Equal(t, Domain.ImportFailed, job.Status)
+	assert.NotContains(t, job.Error, "10.0.0.5")
+}
+
+func TestImporter_TrabajoInexistente(t *testing.T) {
+	importer := NewImporter(NewService(repoConUsuarios(t)), time.Hour)
+
+	_, err := importer.GetImportJob(context.Background(), "nope")
+
+	assert.ErrorIs(t, err, Domain.ErrNotFound)
+}
+
+func TestImporter_CloseRechazaNuevosTrabajos(t *testing.T) {
+	importer := NewImporter(NewService(repoConUsuarios(t)), time.Hour)
+	require.NoError(t, importer.Close(context.Background()))
+
+	_, err := importer.StartImport(context.Background(), []byte(csvValido), Domain.ImportOptions{Format: Domain.ImportCSV})
+
+	assert.Error(t, err)
+}
+
+func TestImporter_VencenLosTrabajosTerminados(t *testing.T) {
+	importer := NewImporter(NewService(repoConUsuarios(t)), time.Millisecond)
+	defer importer.Close(context.Background())
+
+	job, err := importer.StartImport(context.Background(), []byte(csvValido), Domain.ImportOptions{Format: Domain.ImportCSV})
+	require.NoError(t, err)
+
+	require.Eventually(t, func() bool {
+		_, err := importer.GetImportJob(context.Background(), job.Id)
+		return errors.Is(err, Domain.ErrNotFound)
+	}, 5*time.Second, 10*time.Millisecond)
+}
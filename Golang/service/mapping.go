@@ -0,0 +1,131 @@
+package services
+
+import (
+	Domain "Golang/domain"
+	Model "Golang/model"
+	"fmt"
+	"time"
+)
+
+// Conversiones entre el modelo de base de datos y los DTOs de domain. Son el
+// unico lugar donde se decide que campos de model.User salen del servidor.
+
+func userFromCreate(req Domain.CreateUserRequest, passwordHash string) Model.User {
+	return Model.User{
+		Nombre:       req.Nombre,
+		Password:     passwordHash,
+		Genero:       req.Genero,
+		Atributos:    req.Atributos,
+		Maneja:       req.Maneja,
+		Lentes:       req.Lentes,
+		Diabetico:    req.Diabetico,
+		Enfermedades: req.Enfermedades,
+		Admin:        false,
+		Estado:       true,
+	}
+}
+
+// applyUpdate copia los campos editables sobre el usuario guardado. Id,
+// Password y Admin se conservan.
+func applyUpdate(user Model.User, req Domain.UpdateUserRequest) Model.User {
+	if user.Estado != req.Estado {
+		user.DeactivatedAt = deactivatedAt(req.Estado)
+	}
+	user.Nombre = req.Nombre
+	user.Genero = req.Genero
+	user.Atributos = req.Atributos
+	user.Maneja = req.Maneja
+	user.Lentes = req.Lentes
+	user.Diabetico = req.Diabetico
+	user.Enfermedades = req.Enfermedades
+	user.Estado = req.Estado
+	return user
+}
+
+// withDeactivation agrega a fields la columna deactivated_at si cambian el
+// estado de actual.
+func withDeactivation(actual Model.User, fields map[string]interface{}) map[string]interface{} {
+	if estado, ok := fields["estado"].(bool); ok && estado != actual.Estado {
+		fields["deactivated_at"] = deactivatedAt(estado)
+	}
+	return fields
+}
+
+// deactivatedAt es el DeactivatedAt de una cuenta que pasa a estado: ahora
+// si se desactiva, nil si se reactiva.
+func deactivatedAt(estado bool) *time.Time {
+	if estado {
+		return nil
+	}
+	at := time.Now().UTC().Truncate(time.Second)
+	return &at
+}
+
+// toUpdateRequest es el documento sobre el que se aplican los PATCH: solo los
+// campos que un cliente puede editar.
+func toUpdateRequest(user Model.User) Domain.UpdateUserRequest {
+	return Domain.UpdateUserRequest{
+		Id:           user.Id,
+		Nombre:       user.Nombre,
+		Genero:       user.Genero,
+		Atributos:    user.Atributos,
+		Maneja:       user.Maneja,
+		Lentes:       user.Lentes,
+		Diabetico:    user.Diabetico,
+		Enfermedades: user.Enfermedades,
+		Estado:       user.Estado,
+	}
+}
+
+func toUserResponse(user Model.User) Domain.UserResponse {
+	return Domain.UserResponse{
+		Id:           user.Id,
+		Nombre:       user.Nombre,
+		Genero:       user.Genero,
+		Atributos:    user.Atributos,
+		Maneja:       user.Maneja,
+		Lentes:       user.Lentes,
+		Diabetico:    user.Diabetico,
+		Enfermedades: user.Enfermedades,
+		Admin:        user.Admin,
+		Estado:       user.Estado,
+		Version:      user.Version,
+		UpdatedAt:    user.UpdatedAt,
+	}
+}
+
+// toUserView aplica la politica de visibilidad: deja solo los campos que el
+// viewer de vis puede ver de user, recortados a los que pidio.
+func toUserView(user Model.User, vis Domain.Visibility) Domain.UserResponse {
+	return toUserResponse(user).Redact(vis.VisibleFields(user.Id))
+}
+
+// checkWritable valida vis y exige que el viewer pueda modificar al usuario
+// id (ver Viewer.CanWrite). Se controla antes de leer el usuario: la
+// operacion test de JSON Patch le dejaria a cualquier otro deducir sus datos.
+func checkWritable(id int, vis Domain.Visibility) error {
+	if err := vis.Validate(); err != nil {
+		return err
+	}
+	if !vis.Viewer.CanWrite(id) {
+		return fmt.Errorf("profile %s cannot modify user %d: %w", vis.Viewer.Profile(id), id, Domain.ErrForbidden)
+	}
+	return nil
+}
+
+// checkEstado exige el perfil admin para cambiar el estado del usuario id;
+// si no, un usuario desactivado podria reactivarse a si mismo.
+func checkEstado(id int, actual bool, requested bool, vis Domain.Visibility) error {
+	if actual != requested && vis.Viewer.Profile(id) != Domain.ProfileAdmin {
+		return fmt.Errorf("profile %s cannot change estado of user %d: %w", vis.Viewer.Profile(id), id, Domain.ErrForbidden)
+	}
+	return nil
+}
+
+func toPublicProfile(user Model.User) Domain.PublicProfile {
+	return Domain.PublicProfile{
+		Id:     user.Id,
+		Nombre: user.Nombre,
+		Estado: user.Estado,
+	}
+}
@@ -0,0 +1,116 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+
+	Domain "Golang/domain"
+	"Golang/tracing"
+
+	jsonpatch "github.com/evanphx/json-patch/v5"
+)
+
+// PatchUser aplica un JSON Merge Patch (RFC 7396) o un JSON Patch (RFC 6902)
+// sobre los campos editables del usuario. El documento resultante se valida
+// igual que en PUT y solo se escriben las columnas que cambiaron. Como en
+// UpdateUser, version 0 aplica el patch sobre la version actual.
+func (s Service) PatchUser(ctx context.Context, id int, version int, contentType string, patch []byte, vis Domain.Visibility) (_ Domain.UserResponse, err error) {
+	ctx, span := tracer.Start(ctx, "Service.PatchUser")
+	defer tracing.End(span, &err)
+
+	if err := checkWritable(id, vis); err != nil {
+		return Domain.UserResponse{}, err
+	}
+	actual, err := s.UserService.GetUserById(ctx, id)
+	if err != nil {
+		return Domain.UserResponse{}, fmt.Errorf("Error al buscar el usuario: %w", err)
+	}
+	if err := checkVersion(actual, version); err != nil {
+		return Domain.UserResponse{}, err
+	}
+
+	original := toUpdateRequest(actual)
+	document, err := json.Marshal(original)
+	if err != nil {
+		return Domain.UserResponse{}, fmt.Errorf("encoding user %d: %w", id, err)
+	}
+
+	patched, err := applyPatch(contentType, document, patch)
+	if err != nil {
+		return Domain.UserResponse{}, fmt.Errorf("applying patch: %v: %w", err, Domain.ErrValidation)
+	}
+
+	var req Domain.UpdateUserRequest
+	decoder := json.NewDecoder(bytes.NewReader(patched))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&req); err != nil {
+		return Domain.UserResponse{}, fmt.Errorf("decoding patched user: %v: %w", err, Domain.ErrValidation)
+	}
+	if req.Id != id {
+		return Domain.UserResponse{}, fmt.Errorf("patch cannot change id: %w", Domain.ErrValidation)
+	}
+	req.Normalize()
+	if err := req.Validate(); err != nil {
+		return Domain.UserResponse{}, err
+	}
+	if err := checkEstado(id, original.Estado, req.Estado, vis); err != nil {
+		return Domain.UserResponse{}, err
+	}
+
+	user, err := s.UserService.PatchUser(ctx, id, actual.Version, withDeactivation(actual, changedColumns(original, req)))
+	if err != nil {
+		return Domain.UserResponse{}, fmt.Errorf("Error al actualizar el usuario: %w", err)
+	}
+
+	return toUserView(user, vis), nil
+}
+
+func applyPatch(contentType string, document []byte, patch []byte) ([]byte, error) {
+	switch contentType {
+	case Domain.JSONPatchContentType:
+		operations, err := jsonpatch.DecodePatch(patch)
+		if err != nil {
+			return nil, err
+		}
+		return operations.Apply(document)
+	case Domain.MergePatchContentType, "application/json":
+		if !json.Valid(patch) || bytes.HasPrefix(bytes.TrimSpace(patch), []byte("[")) {
+			return nil, fmt.Errorf("merge patch must be a JSON object")
+		}
+		return jsonpatch.MergePatch(document, patch)
+	default:
+		return nil, fmt.Errorf("unsupported patch format %q", contentType)
+	}
+}
+
+// changedColumns devuelve, por nombre de columna, los campos que difieren.
+func changedColumns(before Domain.UpdateUserRequest, after Domain.UpdateUserRequest) map[string]interface{} {
+	fields := map[string]interface{}{}
+	if before.Nombre != after.Nombre {
+		fields["nombre"] = after.Nombre
+	}
+	if before.Genero != after.Genero {
+		fields["genero"] = after.Genero
+	}
+	if before.Atributos != after.Atributos {
+		fields["atributos"] = after.Atributos
+	}
+	if before.Maneja != after.Maneja {
+		fields["maneja"] = after.Maneja
+	}
+	if before.Lentes != after.Lentes {
+		fields["lentes"] = after.Lentes
+	}
+	if before.Diabetico != after.Diabetico {
+		fields["diabetico"] = after.Diabetico
+	}
+	if before.Enfermedades != after.Enfermedades {
+		fields["enfermedades"] = after.Enfermedades
+	}
+	if before.Estado != after.Estado {
+		fields["estado"] = after.Estado
+	}
+	return fields
+}
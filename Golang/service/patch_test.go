@@ -0,0 +1,183 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	Domain "Golang/domain"
+	Model "Golang/model"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func usuarioGuardado() Model.User {
+	return Model.User{
+		Id:           3,
+		Nombre:       "ana",
+		Password:     "hash",
+		Genero:       "F",
+		Atributos:    "alta",
+		Maneja:       true,
+		Lentes:       false,
+		Diabetico:    true,
+		Enfermedades: "asma",
+		Admin:        true,
+		Estado:       true,
+		Version:      2,
+	}
+}
+
+func TestPatchUser_MergePatchSoloCambiaLoEnviado(t *testing.T) {
+	mockClients := new(MockUserClients)
+	guardado := usuarioGuardado()
+	mockClients.On("GetUserById", 3).Return(guardado, nil)
+
+	esperado := guardado
+	esperado.Lentes = true
+	mockClients.On("PatchUser", 3, 2, map[string]interface{}{"lentes": true}).Return(esperado, nil)
+
+	service := NewService(mockClients)
+	out, err := service.PatchUser(context.Background(), 3, 2, Domain.MergePatchContentType, []byte(`{"lentes": true}`), vistaAdmin)
+
+	assert.NoError(t, err)
+	assert.True(t, out.Lentes)
+	assert.True(t, out.Maneja)
+	assert.True(t, out.Admin)
+	mockClients.AssertExpectations(t)
+}
+
+func TestPatchUser_NormalizaGeneroHeredado(t *testing.T) {
+	mockClients := new(MockUserClients)
+	guardado := usuarioGuardado()
+	guardado.Genero = "Femenino"
+	mockClients.On("GetUserById", 3).Return(guardado, nil)
+	mockClients.On("PatchUser", 3, 2, map[string]interface{}{"lentes": true, "genero": "F"}).Return(guardado, nil)
+
+	service := NewService(mockClients)
+	_, err := service.PatchUser(context.Background(), 3, 2, Domain.MergePatchContentType, []byte(`{"lentes": true}`), vistaAdmin)
+
+	assert.NoError(t, err)
+	mockClients.AssertExpectations(t)
+}
+
+func TestPatchUser_JSONPatch(t *testing.T) {
+	mockClients := new(MockUserClients)
+	mockClients.On("GetUserById", 3).Return(usuarioGuardado(), nil)
+	mockClients.On("PatchUser", 3, 2, map[string]interface{}{"enfermedades": "", "diabetico": false}).Return(Model.User{Id: 3}, nil)
+
+	service := NewService(mockClients)
+	_, err := service.PatchUser(context.Background(), 3, 2, Domain.JSONPatchContentType, []byte(`[
+		{"op": "test", "path": "/diabetico", "value": true},
+		{"op": "replace", "path": "/diabetico", "value": false},
+		{"op": "replace", "path": "/enfermedades", "value": ""}
+	]`), vistaAdmin)
+
+	assert.NoError(t, err)
+	mockClients.AssertExpectations(t)
+}
+
+func TestPatchUser_SinCambiosNoEscribeColumnas(t *testing.T) {
+	mockClients := new(MockUserClients)
+	mockClients.On("GetUserById", 3).Return(usuarioGuardado(), nil)
+	mockClients.On("PatchUser", 3, 2, map[string]interface{}{}).Return(usuarioGuardado(), nil)
+
+	service := NewService(mockClients)
+	_, err := service.PatchUser(context.Background(), 3, 2, Domain.MergePatchContentType, []byte(`{"nombre": "ana"}`), vistaAdmin)
+
+	assert.NoError(t, err)
+	mockClients.AssertExpectations(t)
+}
+
+func TestPatchUser_ErroresDeValidacion(t *testing.T) {
+	cases := map[string]struct {
+		contentType string
+		patch       string
+	}{
+		"campo no editable":      {Domain.MergePatchContentType, `{"admin": false}`},
+		"contraseña":             {Domain.MergePatchContentType, `{"password": "nueva"}`},
+		"borrar campo requerido": {Domain.MergePatchContentType, `{"nombre": null}`},
+		"genero invalido":        {Domain.MergePatchContentType, `{"genero": "Z"}`},
+		"cambiar id":             {Domain.MergePatchContentType, `{"id": 4}`},
+		"merge patch no objeto":  {Domain.MergePatchContentType, `[{"op": "remove", "path": "/nombre"}]`},
+		"json invalido":          {Domain.MergePatchContentType, `{`},
+		"test fallido":           {Domain.JSONPatchContentType, `[{"op": "test", "path": "/nombre", "value": "otra"}]`},
+		"formato desconocido":    {"text/plain", `{}`},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			mockClients := new(MockUserClients)
+			mockClients.On("GetUserById", 3).Return(usuarioGuardado(), nil)
+
+			service := NewService(mockClients)
+			_, err := service.PatchUser(context.Background(), 3, 0, tc.contentType, []byte(tc.patch), vistaAdmin)
+
+			assert.ErrorIs(t, err, Domain.ErrValidation)
+			mockClients.AssertNotCalled(t, "PatchUser", mock.Anything, mock.Anything, mock.Anything)
+		})
+	}
+}
+
+func TestPatchUser_UsuarioNoExiste(t *testing.T) {
+	mockClients := new(MockUserClients)
+	mockClients.On("GetUserById", 9).Return(Model.User{}, fmt.Errorf("error finding user: %w", Domain.ErrNotFound))
+
+	service := NewService(mockClients)
+	_, err := service.PatchUser(context.Background(), 9, 0, Domain.MergePatchContentType, []byte(`{}`), vistaAdmin)
+
+	assert.ErrorIs(t, err, Domain.ErrNotFound)
+}
+
+func TestPatchUser_VersionVieja(t *testing.T) {
+	mockClients := new(MockUserClients)
+	mockClients.On("GetUserById", 3).Return(usuarioGuardado(), nil)
+
+	service := NewService(mockClients)
+	_, err := service.PatchUser(context.Background(), 3, 1, Domain.MergePatchContentType, []byte(`{"lentes": true}`), vistaAdmin)
+
+	assert.ErrorIs(t, err, Domain.ErrPreconditionFailed)
+	mockClients.AssertNotCalled(t, "PatchUser", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestPatchUser_SoloAdminCambiaElEstado(t *testing.T) {
+	mockClients := new(MockUserClients)
+	desactivado := pacienteConDatos(3)
+	desactivado.Estado = false
+	mockClients.On("GetUserById", 3).Return(desactivado, nil)
+	service := NewService(mockClients)
+
+	for _, viewer := range []Domain.Viewer{{UserId: 3}, {UserId: 8, Role: Domain.RoleClinician}} {
+		_, err := service.PatchUser(context.Background(), 3, 2, Domain.MergePatchContentType, []byte(`{"estado": true}`), Domain.Visibility{Viewer: viewer})
+		assert.ErrorIs(t, err, Domain.ErrForbidden, "viewer %+v", viewer)
+
+		_, err = service.UpdateUser(context.Background(), toUpdateRequest(activado(desactivado)), 2, Domain.Visibility{Viewer: viewer})
+		assert.ErrorIs(t, err, Domain.ErrForbidden, "viewer %+v", viewer)
+	}
+	mockClients.AssertNotCalled(t, "PatchUser", mock.Anything, mock.Anything, mock.Anything)
+	mockClients.AssertNotCalled(t, "UpdateUser", mock.Anything)
+
+	mockClients.On("PatchUser", 3, 2, map[string]interface{}{"estado": true, "deactivated_at": (*time.Time)(nil)}).Return(activado(desactivado), nil)
+	out, err := service.PatchUser(context.Background(), 3, 2, Domain.MergePatchContentType, []byte(`{"estado": true}`), vistaAdmin)
+	assert.NoError(t, err)
+	assert.True(t, out.Estado)
+}
+
+func TestPatchUser_SinCambioDeEstadoNoRequiereAdmin(t *testing.T) {
+	mockClients := new(MockUserClients)
+	mockClients.On("GetUserById", 3).Return(pacienteConDatos(3), nil)
+	mockClients.On("PatchUser", 3, 2, map[string]interface{}{"lentes": false}).Return(pacienteConDatos(3), nil)
+	service := NewService(mockClients)
+
+	_, err := service.PatchUser(context.Background(), 3, 2, Domain.MergePatchContentType, []byte(`{"lentes": false, "estado": true}`), Domain.Visibility{Viewer: Domain.Viewer{UserId: 3}})
+
+	assert.NoError(t, err)
+	mockClients.AssertExpectations(t)
+}
+
+func activado(user Model.User) Model.User {
+	user.Estado = true
+	return user
+}
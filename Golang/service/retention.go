@@ -0,0 +1,322 @@
+package services
+
+import (
+	Domain "Golang/domain"
+	"Golang/logging"
+	"Golang/metrics"
+	Model "Golang/model"
+	"Golang/tracing"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+type loginHistory interface {
+	InsertLogin(ctx context.Context, login Model.Login) error
+	PurgeLogins(ctx context.Context, before time.Time, dryRun bool) (int, error)
+}
+
+type locker interface {
+	AcquireLock(ctx context.Context, name string, owner string, ttl time.Duration) (bool, error)
+	RenewLock(ctx context.Context, name string, owner string, ttl time.Duration) (bool, error)
+	ReleaseLock(ctx context.Context, name string, owner string) error
+}
+
+// retentionLock es el nombre del lock que comparten las replicas.
+const retentionLock = "retention"
+
+// retentionLockTTL es cuanto retiene el lock una pasada sin renovarlo. Si
+// la replica muere sin liberarlo, otra lo toma cuando vence.
+const retentionLockTTL = 30 * time.Minute
+
+// retentionBatch es cada cuantas cuentas se renueva el lock mientras se
+// anonimiza.
+const retentionBatch = 100
+
+// errRetentionLockLost corta una pasada cuyo lock vencio: otra replica
+// puede haberlo tomado y estar trabajando sobre los mismos datos.
+var errRetentionLockLost = errors.New("retention lock lost")
+
+// WithLoginHistory devuelve una copia de s que guarda los intentos de login
+// en logins.
+func (s Service) WithLoginHistory(logins loginHistory) Service {
+	s.Logins = logins
+	return s
+}
+
+// recordLogin guarda un intento de login. Un error no cambia el resultado
+// del login, solo se registra.
+func (s Service) recordLogin(ctx context.Context, userId int, success bool, ip string) {
+	if s.Logins == nil {
+		return
+	}
+	if err := s.Logins.InsertLogin(ctx, Model.Login{UserId: userId, Success: success, IP: ip}); err != nil {
+		logging.FromContext(ctx).WithError(err).WithField("user_id", userId).Warn("could not record login")
+	}
+}
+
+// Retention aplica una RetentionPolicy: anonimiza las cuentas inactivas y
+// las IP de los consentimientos y borra el historial de logins vencido. Varias replicas pueden correrla a la
+// vez; el lock hace que solo una trabaje en cada pasada.
+type Retention struct {
+	service Service
+	policy  Domain.RetentionPolicy
+	locks   locker
+	owner   string
+	clock   func() time.Time
+}
+
+// NewRetention arma el job de retencion sobre service. locks es el lock
+// compartido entre replicas.
+func NewRetention(service Service, policy Domain.RetentionPolicy, locks locker) *Retention {
+	return &Retention{
+		service: service,
+		policy:  policy,
+		locks:   locks,
+		owner:   lockOwner(),
+		clock:   time.Now,
+	}
+}
+
+// lockOwner identifica a esta replica en el lock.
+func lockOwner() string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+	return host + ":" + strconv.Itoa(os.Getpid())
+}
+
+// Run hace una pasada. Con dryRun solo cuenta los registros vencidos. Si
+// otra replica tiene el lock devuelve un reporte con Skipped.
+func (r *Retention) Run(ctx context.Context, dryRun bool) (report Domain.RetentionReport, err error) {
+	ctx, span := tracer.Start(ctx, "Retention.Run")
+	defer tracing.End(span, &err)
+
+	report = Domain.RetentionReport{DryRun: dryRun, StartedAt: r.clock().UTC()}
+	acquired, err := r.locks.AcquireLock(ctx, retentionLock, r.owner, retentionLockTTL)
+	if err != nil {
+		metrics.ObserveRetentionRun(metrics.RetentionFailure)
+		return report, fmt.Errorf("acquiring retention lock: %w", err)
+	}
+	if !acquired {
+		report.Skipped = true
+		metrics.ObserveRetentionRun(metrics.RetentionSkipped)
+		return report, nil
+	}
+	defer func() {
+		// Se libera aunque ctx este cancelado para no esperar el TTL.
+		if err := r.locks.ReleaseLock(context.WithoutCancel(ctx), retentionLock, r.owner); err != nil {
+			logging.FromContext(ctx).WithError(err).Warn("could not release retention lock")
+		}
+	}()
+
+	if r.policy.InactiveAccounts > 0 {
+		if err := r.renewLock(ctx); err != nil {
+			metrics.ObserveRetentionRun(metrics.RetentionFailure)
+			return report, err
+		}
+		category, err := r.anonymizeInactive(ctx, report.StartedAt.Add(-r.policy.InactiveAccounts), dryRun)
+		report.Categories = append(report.Categories, category)
+		if err != nil {
+			metrics.ObserveRetentionRun(metrics.RetentionFailure)
+			return report, err
+		}
+	}
+	if r.policy.LoginHistory > 0 && r.service.Logins != nil {
+		if err := r.renewLock(ctx); err != nil {
+			metrics.ObserveRetentionRun(metrics.RetentionFailure)
+			return report, err
+		}
+		category, err := r.purgeLogins(ctx, report.StartedAt.Add(-r.policy.LoginHistory), dryRun)
+		report.Categories = append(report.Categories, category)
+		if err != nil {
+			metrics.ObserveRetentionRun(metrics.RetentionFailure)
+			return report, err
+		}
+	}
+	if r.policy.ConsentRecords > 0 && r.service.Consents != nil {
+		if err := r.renewLock(ctx); err != nil {
+			metrics.ObserveRetentionRun(metrics.RetentionFailure)
+			return report, err
+		}
+		category, err := r.anonymizeConsents(ctx, report.StartedAt.Add(-r.policy.ConsentRecords), dryRun)
+		report.Categories = append(report.Categories, category)
+		if err != nil {
+			metrics.ObserveRetentionRun(metrics.RetentionFailure)
+			return report, err
+		}
+	}
+
+	metrics.ObserveRetentionRun(metrics.RetentionSuccess)
+	return report, nil
+}
+
+// renewLock extiende el lock antes de cada tanda de trabajo, asi una pasada
+// larga no lo deja vencer mientras sigue escribiendo.
+func (r *Retention) renewLock(ctx context.Context) error {
+	held, err := r.locks.RenewLock(ctx, retentionLock, r.owner, retentionLockTTL)
+	if err != nil {
+		return fmt.Errorf("renewing retention lock: %w", err)
+	}
+	if !held {
+		return errRetentionLockLost
+	}
+	return nil
+}
+
+// anonymizeInactive anonimiza las cuentas desactivadas antes de cutoff. Se
+// cuenta desde DeactivatedAt y no desde UpdatedAt, que cambia con cualquier
+// escritura aunque la cuenta siga desactivada. Las que cambian en el medio
+// se saltean: ya no estan vencidas o las toma la pasada siguiente.
+func (r *Retention) anonymizeInactive(ctx context.Context, cutoff time.Time, dryRun bool) (Domain.RetentionCategoryReport, error) {
+	report := Domain.RetentionCategoryReport{
+		Category: Domain.RetentionInactiveAccounts,
+		Action:   Domain.RetentionAnonymize,
+		Cutoff:   cutoff,
+	}
+	inactive, anonymized := false, false
+	filter := Model.UserFilter{Estado: &inactive, Anonymized: &anonymized, DeactivatedBefore: cutoff}
+	scanned := 0
+	err := r.service.UserService.ScanUsers(ctx, filter, func(user Model.User) error {
+		if scanned++; scanned%retentionBatch == 0 {
+			if err := r.renewLock(ctx); err != nil {
+				return err
+			}
+		}
+		if dryRun {
+			report.Records++
+			return nil
+		}
+		_, err := r.service.UserService.PatchUser(ctx, user.Id, user.Version, anonymizedFields(user.Id, r.clock().UTC()))
+		switch {
+		case errors.Is(err, Domain.ErrPreconditionFailed), errors.Is(err, Domain.ErrNotFound):
+		case err != nil:
+			report.Failed++
+			logging.FromContext(ctx).WithError(err).WithField("user_id", user.Id).Warn("could not anonymize user")
+		default:
+			report.Records++
+		}
+		return ctx.Err()
+	})
+	if !dryRun {
+		metrics.ObserveRetention(report.Category, report.Action, report.Records)
+	}
+	if err != nil {
+		return report, fmt.Errorf("anonymizing inactive accounts: %w", err)
+	}
+	return report, nil
+}
+
+// anonymizedFields borra los datos personales de una cuenta. El nombre se
+// reemplaza porque es unico y no puede quedar vacio; la contraseña vacia no
+// coincide con ningun hash, asi que la cuenta ya no puede iniciar sesion.
+func anonymizedFields(id int, at time.Time) map[string]interface{} {
+	return map[string]interface{}{
+		"nombre":        "anonimo-" + strconv.Itoa(id),
+		"password":      "",
+		"atributos":     "",
+		"enfermedades":  "",
+		"maneja":        false,
+		"lentes":        false,
+		"diabetico":     false,
+		"anonymized_at": at,
+	}
+}
+
+func (r *Retention) purgeLogins(ctx context.Context, cutoff time.Time, dryRun bool) (Domain.RetentionCategoryReport, error) {
+	report := Domain.RetentionCategoryReport{
+		Category: Domain.RetentionLoginHistory,
+		Action:   Domain.RetentionPurge,
+		Cutoff:   cutoff,
+	}
+	purged, err := r.service.Logins.PurgeLogins(ctx, cutoff, dryRun)
+	if err != nil {
+		return report, fmt.Errorf("purging login history: %w", err)
+	}
+	report.Records = purged
+	if !dryRun {
+		metrics.ObserveRetention(report.Category, report.Action, purged)
+	}
+	return report, nil
+}
+
+func (r *Retention) anonymizeConsents(ctx context.Context, cutoff time.Time, dryRun bool) (Domain.RetentionCategoryReport, error) {
+	report := Domain.RetentionCategoryReport{
+		Category: Domain.RetentionConsentRecords,
+		Action:   Domain.RetentionAnonymize,
+		Cutoff:   cutoff,
+	}
+	anonymized, err := r.service.Consents.AnonymizeConsents(ctx, cutoff, dryRun)
+	if err != nil {
+		return report, fmt.Errorf("anonymizing consent records: %w", err)
+	}
+	report.Records = anonymized
+	if !dryRun {
+		metrics.ObserveRetention(report.Category, report.Action, anonymized)
+	}
+	return report, nil
+}
+
+// Start corre Run al arrancar y despues cada interval, en segundo plano. La
+// funcion devuelta la detiene y espera la pasada en curso hasta que venza su
+// ctx; sirve para server.OnShutdown.
+func (r *Retention) Start(interval time.Duration, dryRun bool) func(context.Context) error {
+	base, cancel := context.WithCancel(context.Background())
+	var running sync.WaitGroup
+	running.Add(1)
+	go func() {
+		defer running.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			r.pass(base, dryRun)
+			select {
+			case <-base.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	return func(ctx context.Context) error {
+		cancel()
+		done := make(chan struct{})
+		go func() {
+			running.Wait()
+			close(done)
+		}()
+		select {
+		case <-done:
+			return nil
+		case <-ctx.Done():
+			return fmt.Errorf("retention job did not stop: %w", ctx.Err())
+		}
+	}
+}
+
+func (r *Retention) pass(ctx context.Context, dryRun bool) {
+	report, err := r.Run(ctx, dryRun)
+	logger := logging.FromContext(ctx).WithField("dry_run", dryRun)
+	if err != nil && ctx.Err() == nil {
+		logger.WithError(err).Error("retention run failed")
+	}
+	if report.Skipped {
+		logger.Debug("retention run skipped: another replica holds the lock")
+		return
+	}
+	for _, category := range report.Categories {
+		entry := logger.
+			WithField("category", category.Category).
+			WithField("action", category.Action).
+			WithField("records", category.Records).
+			WithField("failed", category.Failed)
+		if category.Records > 0 || category.Failed > 0 {
+			entry.Info("retention applied")
+		}
+	}
+}
@@ -0,0 +1,268 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	clientUsers "Golang/clients"
+	Domain "Golang/domain"
+	"Golang/metrics"
+	Model "Golang/model"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func retentionRecords(category string, action string) float64 {
+	return metrics.Sample("users_retention_records_total", prometheus.Labels{"category": category, "action": action})
+}
+
+func retentionRuns(result string) float64 {
+	return metrics.Sample("users_retention_runs_total", prometheus.Labels{"result": result})
+}
+
+// servicioConRetencion da de alta a ana (activa) y a bruno (desactivado),
+// con un login de cada uno, y arma una Retention cuyo reloj va dos dias
+// adelantado.
+func servicioConRetencion(t *testing.T) (Service, *clientUsers.Memory, *Retention) {
+	t.Helper()
+	ctx := context.Background()
+	repo := clientUsers.NewMemory()
+	svc := NewService(repo).WithLoginHistory(repo)
+	for _, nombre := range []string{"ana", "bruno"} {
+		_, err := svc.InsertUsuario(ctx, Domain.CreateUserRequest{Nombre: nombre, Password: "secreto", Genero: "F", Diabetico: true, Enfermedades: "asma"}, "")
+		require.NoError(t, err)
+		_, err = svc.Login(ctx, Domain.LoginRequest{Nombre: nombre, Password: "secreto"}, "10.0.0.1")
+		require.NoError(t, err)
+	}
+	_, err := svc.SetEstado(ctx, 2, false)
+	require.NoError(t, err)
+
+	retention := NewRetention(svc, Domain.RetentionPolicy{InactiveAccounts: 24 * time.Hour, LoginHistory: 24 * time.Hour}, repo)
+	retention.clock = func() time.Time { return time.Now().Add(48 * time.Hour) }
+	return svc, repo, retention
+}
+
+func TestLogin_RecordsHistoryOfKnownUsers(t *testing.T) {
+	repo := clientUsers.NewMemory()
+	svc := NewService(repo).WithLoginHistory(repo)
+	_, err := svc.InsertUsuario(context.Background(), Domain.CreateUserRequest{Nombre: "ana", Password: "secreto", Genero: "F"}, "")
+	require.NoError(t, err)
+
+	svc.Login(context.Background(), Domain.LoginRequest{Nombre: "ana", Password: "secreto"}, "10.0.0.1")
+	svc.Login(context.Background(), Domain.LoginRequest{Nombre: "ana", Password: "otra"}, "10.0.0.1")
+	svc.Login(context.Background(), Domain.LoginRequest{Nombre: "nadie", Password: "secreto"}, "10.0.0.1")
+
+	recorded, err := repo.PurgeLogins(context.Background(), time.Now().Add(time.Hour), true)
+	require.NoError(t, err)
+	assert.Equal(t, 2, recorded)
+}
+
+func TestLogin_CuentaDesactivadaEsIgualQueContraseniaIncorrecta(t *testing.T) {
+	repo := clientUsers.NewMemory()
+	svc := NewService(repo).WithLoginHistory(repo)
+	_, err := repo.InsertUser(context.Background(), Model.User{Nombre: "ana", Password: hashPassword("secreto"), Genero: "F", Estado: false})
+	require.NoError(t, err)
+
+	data, inactive := svc.Login(context.Background(), Domain.LoginRequest{Nombre: "ana", Password: "secreto"}, "10.0.0.1")
+	assert.ErrorIs(t, inactive, Domain.ErrUnauthorized)
+	assert.Empty(t, data.Token)
+
+	// La respuesta no revela si la contraseña era la correcta.
+	_, wrong := svc.Login(context.Background(), Domain.LoginRequest{Nombre: "ana", Password: "otra"}, "10.0.0.1")
+	assert.Equal(t, wrong.Error(), inactive.Error())
+
+	recorded, err := repo.PurgeLogins(context.Background(), time.Now().Add(time.Hour), true)
+	require.NoError(t, err)
+	assert.Equal(t, 2, recorded)
+}
+
+func TestRetention_DryRunOnlyCounts(t *testing.T) {
+	_, repo, retention := servicioConRetencion(t)
+	anonymized := retentionRecords(Domain.RetentionInactiveAccounts, Domain.RetentionAnonymize)
+
+	report, err := retention.Run(context.Background(), true)
+
+	require.NoError(t, err)
+	assert.True(t, report.DryRun)
+	require.Len(t, report.Categories, 2)
+	assert.Equal(t, Domain.RetentionInactiveAccounts, report.Categories[0].Category)
+	assert.Equal(t, 1, report.Categories[0].Records)
+	assert.Equal(t, Domain.RetentionLoginHistory, report.Categories[1].Category)
+	assert.Equal(t, 2, report.Categories[1].Records)
+
+	bruno, err := repo.GetUserById(context.Background(), 2)
+	require.NoError(t, err)
+	assert.Equal(t, "bruno", bruno.Nombre)
+	assert.Nil(t, bruno.AnonymizedAt)
+	assert.Equal(t, anonymized, retentionRecords(Domain.RetentionInactiveAccounts, Domain.RetentionAnonymize))
+}
+
+func TestRetention_AnonymizesInactiveAccountsAndPurgesLogins(t *testing.T) {
+	svc, repo, retention := servicioConRetencion(t)
+	anonymized := retentionRecords(Domain.RetentionInactiveAccounts, Domain.RetentionAnonymize)
+	purged := retentionRecords(Domain.RetentionLoginHistory, Domain.RetentionPurge)
+	runs := retentionRuns(metrics.RetentionSuccess)
+
+	report, err := retention.Run(context.Background(), false)
+
+	require.NoError(t, err)
+	require.Len(t, report.Categories, 2)
+	assert.Equal(t, 1, report.Categories[0].Records)
+	assert.Equal(t, 2, report.Categories[1].Records)
+	assert.Equal(t, anonymized+1, retentionRecords(Domain.RetentionInactiveAccounts, Domain.RetentionAnonymize))
+	assert.Equal(t, purged+2, retentionRecords(Domain.RetentionLoginHistory, Domain.RetentionPurge))
+	assert.Equal(t, runs+1, retentionRuns(metrics.RetentionSuccess))
+
+	bruno, err := repo.GetUserById(context.Background(), 2)
+	require.NoError(t, err)
+	assert.Equal(t, "anonimo-2", bruno.Nombre)
+	assert.Empty(t, bruno.Password)
+	assert.Empty(t, bruno.Enfermedades)
+	assert.False(t, bruno.Diabetico)
+	assert.NotNil(t, bruno.AnonymizedAt)
+	ana, err := repo.GetUserById(context.Background(), 1)
+	require.NoError(t, err)
+	assert.Equal(t, "asma", ana.Enfermedades)
+	_, err = svc.Login(context.Background(), Domain.LoginRequest{Nombre: "bruno", Password: "secreto"}, "")
+	assert.ErrorIs(t, err, Domain.ErrUnauthorized)
+
+	// Una cuenta anonimizada no se vuelve a tratar.
+	report, err = retention.Run(context.Background(), false)
+	require.NoError(t, err)
+	assert.Zero(t, report.Categories[0].Records)
+}
+
+func TestRetention_AnonymizesConsentIPs(t *testing.T) {
+	svc, repo := servicioConConsentimiento(t, "v1")
+	user, err := svc.InsertUsuario(context.Background(), altaConConsentimiento(1), "10.0.0.1")
+	require.NoError(t, err)
+	retention := NewRetention(svc, Domain.RetentionPolicy{ConsentRecords: 24 * time.Hour}, repo)
+	retention.clock = func() time.Time { return time.Now().Add(48 * time.Hour) }
+	anonymized := retentionRecords(Domain.RetentionConsentRecords, Domain.RetentionAnonymize)
+
+	report, err := retention.Run(context.Background(), false)
+
+	require.NoError(t, err)
+	require.Len(t, report.Categories, 1)
+	assert.Equal(t, Domain.RetentionConsentRecords, report.Categories[0].Category)
+	assert.Equal(t, 1, report.Categories[0].Records)
+	assert.Equal(t, anonymized+1, retentionRecords(Domain.RetentionConsentRecords, Domain.RetentionAnonymize))
+	consent, err := repo.GetConsent(context.Background(), user.Id)
+	require.NoError(t, err)
+	assert.Empty(t, consent.IP)
+	assert.True(t, consent.Granted)
+}
+
+func TestRetention_CountsFromDeactivationNotLastUpdate(t *testing.T) {
+	_, repo, retention := servicioConRetencion(t)
+	retention.clock = time.Now
+	ctx := context.Background()
+	bruno, err := repo.GetUserById(ctx, 2)
+	require.NoError(t, err)
+	hace := time.Now().Add(-48 * time.Hour)
+	require.NoError(t, repo.RewriteColumns(ctx, bruno.Id, bruno.Version, map[string]interface{}{"deactivated_at": &hace}))
+	// Una escritura posterior cambia UpdatedAt pero la cuenta sigue desactivada.
+	_, err = repo.PatchUser(ctx, bruno.Id, bruno.Version, map[string]interface{}{"genero": "M"})
+	require.NoError(t, err)
+
+	report, err := retention.Run(ctx, false)
+
+	require.NoError(t, err)
+	assert.Equal(t, 1, report.Categories[0].Records)
+	bruno, err = repo.GetUserById(ctx, 2)
+	require.NoError(t, err)
+	assert.NotNil(t, bruno.AnonymizedAt)
+}
+
+func TestRetention_SkipsWhenAnotherReplicaHoldsTheLock(t *testing.T) {
+	_, repo, retention := servicioConRetencion(t)
+	acquired, err := repo.AcquireLock(context.Background(), retentionLock, "otra-replica", time.Hour)
+	require.NoError(t, err)
+	require.True(t, acquired)
+	skipped := retentionRuns(metrics.RetentionSkipped)
+
+	report, err := retention.Run(context.Background(), false)
+
+	require.NoError(t, err)
+	assert.True(t, report.Skipped)
+	assert.Empty(t, report.Categories)
+	assert.Equal(t, skipped+1, retentionRuns(metrics.RetentionSkipped))
+	bruno, err := repo.GetUserById(context.Background(), 2)
+	require.NoError(t, err)
+	assert.Equal(t, "bruno", bruno.Nombre)
+}
+
+// lockQueVence deja renovar el lock renewals veces y despues lo da por
+// perdido, como si hubiera vencido y lo tuviera otra replica.
+type lockQueVence struct {
+	*clientUsers.Memory
+	renewals int
+}
+
+func (l *lockQueVence) RenewLock(ctx context.Context, name string, owner string, ttl time.Duration) (bool, error) {
+	if l.renewals == 0 {
+		return false, nil
+	}
+	l.renewals--
+	return l.Memory.RenewLock(ctx, name, owner, ttl)
+}
+
+func TestRetention_StopsWhenTheLockIsLost(t *testing.T) {
+	svc, repo, _ := servicioConRetencion(t)
+	retention := NewRetention(svc, Domain.RetentionPolicy{InactiveAccounts: 24 * time.Hour, LoginHistory: 24 * time.Hour}, &lockQueVence{Memory: repo})
+	retention.clock = func() time.Time { return time.Now().Add(48 * time.Hour) }
+	failures := retentionRuns(metrics.RetentionFailure)
+
+	_, err := retention.Run(context.Background(), false)
+
+	require.ErrorIs(t, err, errRetentionLockLost)
+	assert.Equal(t, failures+1, retentionRuns(metrics.RetentionFailure))
+	bruno, err := repo.GetUserById(context.Background(), 2)
+	require.NoError(t, err)
+	assert.Nil(t, bruno.AnonymizedAt)
+}
+
+func TestRetention_RenewsTheLockEveryBatch(t *testing.T) {
+	repo := clientUsers.NewMemory()
+	hace := time.Now().Add(-48 * time.Hour)
+	users := make([]Model.User, retentionBatch+20)
+	for i := range users {
+		users[i] = Model.User{Nombre: fmt.Sprintf("usuario-%d", i), Genero: "F", DeactivatedAt: &hace}
+	}
+	_, err := repo.InsertUsers(context.Background(), users)
+	require.NoError(t, err)
+	// Alcanza para empezar la categoria pero no para la segunda tanda.
+	locks := &lockQueVence{Memory: repo, renewals: 1}
+	retention := NewRetention(NewService(repo), Domain.RetentionPolicy{InactiveAccounts: 24 * time.Hour}, locks)
+
+	report, err := retention.Run(context.Background(), false)
+
+	require.ErrorIs(t, err, errRetentionLockLost)
+	assert.Equal(t, retentionBatch-1, report.Categories[0].Records)
+}
+
+func TestRetention_ReleasesTheLock(t *testing.T) {
+	_, repo, retention := servicioConRetencion(t)
+
+	_, err := retention.Run(context.Background(), true)
+	require.NoError(t, err)
+
+	acquired, err := repo.AcquireLock(context.Background(), retentionLock, "otra-replica", time.Hour)
+	require.NoError(t, err)
+	assert.True(t, acquired)
+}
+
+func TestRetention_StartStops(t *testing.T) {
+	_, repo, retention := servicioConRetencion(t)
+
+	stop := retention.Start(time.Hour, false)
+	require.Eventually(t, func() bool {
+		bruno, err := repo.GetUserById(context.Background(), 2)
+		return err == nil && bruno.AnonymizedAt != nil
+	}, time.Second, 10*time.Millisecond)
+	require.NoError(t, stop(context.Background()))
+}
@@ -1,27 +1,47 @@
 package services
 
 import (
+	"Golang/auth"
 	Domain "Golang/domain"
+	"Golang/logging"
+	"Golang/metrics"
 	Model "Golang/model"
+	"Golang/tracing"
 	"context"
 	"crypto/md5"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"time"
 
 	"github.com/golang-jwt/jwt"
+	"go.opentelemetry.io/otel"
 )
 
+var tracer = otel.Tracer("Golang/service")
+
 type userClients interface {
-	GetUserById(Id int) (Model.User, error)
+	GetUserById(ctx context.Context, Id int) (Model.User, error)
 	UpdateUser(ctx context.Context, User Model.User) (Model.User, error)
-	InsertUser(user Model.User) (Model.User, error)
-	GetUserByName(Usuario Model.User) (Model.User, error)
-	GetAllUsers() ([]Model.User, error)
+	PatchUser(ctx context.Context, Id int, version int, fields map[string]interface{}) (Model.User, error)
+	InsertUser(ctx context.Context, user Model.User) (Model.User, error)
+	GetUserByName(ctx context.Context, Usuario Model.User) (Model.User, error)
+	GetAllUsers(ctx context.Context) ([]Model.User, error)
+	GetUsersStamp(ctx context.Context) (Model.UsersStamp, error)
+	InsertUsers(ctx context.Context, users []Model.User) ([]Model.User, error)
+	ExistingNames(ctx context.Context, nombres []string) ([]string, error)
+	ScanUsers(ctx context.Context, filter Model.UserFilter, fn func(Model.User) error) error
+	DeleteUser(ctx context.Context, Id int) error
 }
 
 type Service struct {
 	UserService userClients
+	// Consents registra los consentimientos; nil no los exige (ver
+	// WithConsents).
+	Consents consentStore
+	// Logins guarda el historial de logins; nil no lo guarda (ver
+	// WithLoginHistory).
+	Logins loginHistory
 }
 
 func NewService(UserService userClients) Service {
@@ -30,188 +50,235 @@ func NewService(UserService userClients) Service {
 	}
 }
 
-func (s Service) InsertUsuario(usuarioDomain Domain.UserData) (Domain.UserData, error) {
+// InsertUsuario registra al usuario. Si hay un texto de consentimiento
+// publicado, req.Consentimiento tiene que ser su version y el otorgamiento
+// se registra con ip.
+func (s Service) InsertUsuario(ctx context.Context, req Domain.CreateUserRequest, ip string) (_ Domain.UserResponse, err error) {
+	ctx, span := tracer.Start(ctx, "Service.InsertUsuario")
+	defer tracing.End(span, &err)
 
-	hash := md5.New()
-	hash.Write([]byte(usuarioDomain.Password))
-	usuarioDomain.Password = hex.EncodeToString(hash.Sum(nil))
+	return s.insertUsuario(ctx, req, ip, false)
+}
 
-	usuario := Model.User{
-		Nombre:       usuarioDomain.Nombre,
-		Password:     usuarioDomain.Password,
-		Genero:       usuarioDomain.Genero,
-		Atributos:    usuarioDomain.Atributos,
-		Maneja:       usuarioDomain.Maneja,
-		Lentes:       usuarioDomain.Lentes,
-		Diabetico:    usuarioDomain.Diabetico,
-		Enfermedades: usuarioDomain.Enfermedades,
-		Admin:        usuarioDomain.Admin,
-		Estado:       true,
+func (s Service) insertUsuario(ctx context.Context, req Domain.CreateUserRequest, ip string, admin bool) (Domain.UserResponse, error) {
+	consent, required, err := s.currentConsent(ctx)
+	if err != nil {
+		return Domain.UserResponse{}, fmt.Errorf("Error Inserting User: %w", err)
+	}
+	if required && req.Consentimiento != consent.Version {
+		return Domain.UserResponse{}, staleConsent("consentimiento", consent.Version)
 	}
 
-	//var result, er = s.UserService.GetUserByName(usuario)
-	usuario2, err := s.UserService.InsertUser(usuario)
+	usuario := userFromCreate(req, hashPassword(req.Password))
+	usuario.Admin = admin
+
+	usuario, err = s.UserService.InsertUser(ctx, usuario)
 
 	if err != nil {
-		return usuarioDomain, fmt.Errorf("Error Inserting User.")
+		return Domain.UserResponse{}, fmt.Errorf("Error Inserting User: %w", err)
 	}
 
-	usuarioDomain.Id = usuario2.Id
-
-	return usuarioDomain, nil
+	if required {
+		// Sin el registro del consentimiento no se guardan datos de salud: se
+		// borra el usuario recien creado y el alta falla.
+		if err := s.recordConsent(ctx, usuario.Id, consent.Version, true, ip); err != nil {
+			if delErr := s.UserService.DeleteUser(ctx, usuario.Id); delErr != nil {
+				logging.FromContext(ctx).WithError(delErr).WithField("user_id", usuario.Id).Error("user without consent not deleted")
+			}
+			return Domain.UserResponse{}, fmt.Errorf("Error Inserting User: %w", err)
+		}
+	}
 
+	return toUserResponse(usuario), nil
 }
 
-func (s Service) GetUserByName(usuarioDomain Domain.UserData) (Domain.UserData, error) {
+// InsertUsuarioByAdmin registra un usuario cargado por un administrador.
+// Como en la importacion no se registra consentimiento: solo lo puede dar el
+// propio usuario (ver GrantConsent) y hasta entonces sus datos medicos no se
+// exportan.
+func (s Service) InsertUsuarioByAdmin(ctx context.Context, req Domain.CreateUserRequest) (_ Domain.UserResponse, err error) {
+	ctx, span := tracer.Start(ctx, "Service.InsertUsuarioByAdmin")
+	defer tracing.End(span, &err)
 
-	usuario := Model.User{
-		Nombre: usuarioDomain.Nombre,
+	if req.Consentimiento != 0 {
+		return Domain.UserResponse{}, &Domain.ValidationError{Violations: []Domain.FieldViolation{{Field: "consentimiento", Rule: "eq", Param: "0"}}}
 	}
-
-	user, err := s.UserService.GetUserByName(usuario)
-
+	usuario, err := s.UserService.InsertUser(ctx, userFromCreate(req, hashPassword(req.Password)))
 	if err != nil {
-		return Domain.UserData{}, fmt.Errorf("Error al buscar el usuario")
+		return Domain.UserResponse{}, fmt.Errorf("Error Inserting User: %w", err)
 	}
+	return toUserResponse(usuario), nil
+}
 
-	var userDomain Domain.UserData
+func (s Service) GetUserByName(ctx context.Context, nombre string) (_ Domain.PublicProfile, err error) {
+	ctx, span := tracer.Start(ctx, "Service.GetUserByName")
+	defer tracing.End(span, &err)
 
-	userDomain.Id = user.Id
-	userDomain.Nombre = user.Nombre
-	userDomain.Genero = user.Genero
-	userDomain.Atributos = user.Atributos
-	userDomain.Maneja = user.Maneja
-	userDomain.Lentes = user.Lentes
-	userDomain.Diabetico = user.Diabetico
-	userDomain.Enfermedades = user.Enfermedades
-	userDomain.Admin = user.Admin
+	user, err := s.UserService.GetUserByName(ctx, Model.User{Nombre: nombre})
 
-	userDomain.Estado = user.Estado
+	if err != nil {
+		return Domain.PublicProfile{}, fmt.Errorf("Error al buscar el usuario: %w", err)
+	}
 
-	return userDomain, nil
+	return toPublicProfile(user), nil
 
 }
 
-func (s Service) GetUserById(userId int) (Domain.UserData, error) {
-	user, err := s.UserService.GetUserById(userId)
-	if err != nil {
-		return Domain.UserData{}, fmt.Errorf("Error al obtener el usuario: %v", err)
-	}
+// GetUserById devuelve el usuario con los campos que vis permite ver.
+func (s Service) GetUserById(ctx context.Context, userId int, vis Domain.Visibility) (_ Domain.UserResponse, err error) {
+	ctx, span := tracer.Start(ctx, "Service.GetUserById")
+	defer tracing.End(span, &err)
 
-	userDomain := Domain.UserData{
-		Id:           user.Id,
-		Nombre:       user.Nombre,
-		Genero:       user.Genero,
-		Atributos:    user.Atributos,
-		Maneja:       user.Maneja,
-		Lentes:       user.Lentes,
-		Diabetico:    user.Diabetico,
-		Enfermedades: user.Enfermedades,
-		Estado:       user.Estado,
+	if err := vis.Validate(); err != nil {
+		return Domain.UserResponse{}, err
+	}
+	user, err := s.UserService.GetUserById(ctx, userId)
+	if err != nil {
+		return Domain.UserResponse{}, fmt.Errorf("Error al obtener el usuario: %w", err)
 	}
 
-	return userDomain, nil
+	return toUserView(user, vis), nil
 }
 
-func (s Service) UpdateUser(usuarioDomain Domain.UserData) (Domain.UserData, error) {
+// UpdateUser reemplaza los campos editables del usuario si su version sigue
+// siendo version. Con version 0 se actualiza sobre la version actual.
+func (s Service) UpdateUser(ctx context.Context, req Domain.UpdateUserRequest, version int, vis Domain.Visibility) (_ Domain.UserResponse, err error) {
+	ctx, span := tracer.Start(ctx, "Service.UpdateUser")
+	defer tracing.End(span, &err)
 
-	usuario := Model.User{
-		Id:           usuarioDomain.Id,
-		Nombre:       usuarioDomain.Nombre,
-		Genero:       usuarioDomain.Genero,
-		Atributos:    usuarioDomain.Atributos,
-		Maneja:       usuarioDomain.Maneja,
-		Lentes:       usuarioDomain.Lentes,
-		Diabetico:    usuarioDomain.Diabetico,
-		Enfermedades: usuarioDomain.Enfermedades,
-		Admin:        usuarioDomain.Admin,
-		Estado:       usuarioDomain.Estado,
+	if err := checkWritable(req.Id, vis); err != nil {
+		return Domain.UserResponse{}, err
 	}
-	ctx := context.Background()
-
-	user, err := s.UserService.UpdateUser(ctx, usuario)
-
+	actual, err := s.UserService.GetUserById(ctx, req.Id)
 	if err != nil {
-		return Domain.UserData{}, fmt.Errorf("Error al buscar el usuario")
+		return Domain.UserResponse{}, fmt.Errorf("Error al buscar el usuario: %w", err)
+	}
+	if err := checkVersion(actual, version); err != nil {
+		return Domain.UserResponse{}, err
+	}
+	if err := checkEstado(req.Id, actual.Estado, req.Estado, vis); err != nil {
+		return Domain.UserResponse{}, err
 	}
 
-	var userDomain Domain.UserData
+	user, err := s.UserService.UpdateUser(ctx, applyUpdate(actual, req))
 
-	userDomain.Id = user.Id
-	userDomain.Nombre = user.Nombre
-	userDomain.Genero = user.Genero
-	userDomain.Atributos = user.Atributos
-	userDomain.Maneja = user.Maneja
-	userDomain.Lentes = user.Lentes
-	userDomain.Diabetico = user.Diabetico
-	userDomain.Enfermedades = user.Enfermedades
-	userDomain.Admin = user.Admin
-	userDomain.Estado = user.Estado
+	if err != nil {
+		return Domain.UserResponse{}, fmt.Errorf("Error al actualizar el usuario: %w", err)
+	}
 
-	return userDomain, nil
+	return toUserView(user, vis), nil
 
 }
 
-func (s Service) Login(User Domain.UserData) (Domain.LoginData, error) {
+// Login valida la contraseña y devuelve un token. Los intentos sobre
+// usuarios registrados quedan en el historial con ip.
+func (s Service) Login(ctx context.Context, User Domain.LoginRequest, ip string) (_ Domain.LoginData, err error) {
+	ctx, span := tracer.Start(ctx, "Service.Login")
+	defer tracing.End(span, &err)
+
 	usuario := Model.User{
 		Nombre: User.Nombre,
-		Admin:  User.Admin,
 	}
 
-	user, err := s.UserService.GetUserByName(usuario)
-	fmt.Println("user ", user)
+	user, err := s.UserService.GetUserByName(ctx, usuario)
+	logger := logging.FromContext(ctx)
 
 	var tokenDomain Domain.LoginData
 
 	if err != nil {
-		return tokenDomain, fmt.Errorf("error")
+		// Un usuario inexistente se informa igual que una contraseña
+		// incorrecta para no revelar que nombres estan registrados.
+		if errors.Is(err, Domain.ErrNotFound) {
+			logger.Info("login rejected: unknown user")
+			metrics.ObserveLogin(metrics.LoginFailure)
+			return tokenDomain, fmt.Errorf("login: %w", Domain.ErrUnauthorized)
+		}
+		metrics.ObserveLogin(metrics.LoginError)
+		return tokenDomain, fmt.Errorf("login: %w", err)
 	}
 
-	var Logpsw = md5.Sum([]byte(User.Password))
-	psw := hex.EncodeToString(Logpsw[:])
-	fmt.Println("contra ", psw)
-	fmt.Println("contra ", user.Password)
+	psw := hashPassword(User.Password)
+	logger = logger.WithField("user_id", user.Id)
 
-	if psw == user.Password {
+	// Una cuenta desactivada se rechaza con el mismo error que una contraseña
+	// incorrecta: una respuesta distinta confirmaria que la contraseña es la
+	// correcta.
+	if psw == user.Password && user.Estado {
 		token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
 			"idU":    user.Id,
 			"Adminu": user.Admin,
 			"exp":    time.Now().Add(time.Hour * 72).Unix(),
 		})
-		t, _ := token.SignedString([]byte("bitsion"))
+		t, _ := token.SignedString(auth.SigningKey())
 		tokenDomain.Token = t
 		tokenDomain.IdU = user.Id
 		tokenDomain.AdminU = user.Admin
+		logger.Info("login succeeded")
+		metrics.ObserveLogin(metrics.LoginSuccess)
+		s.recordLogin(ctx, user.Id, true, ip)
 		return tokenDomain, nil
 	} else {
-		fmt.Println("eeror contra")
-		return tokenDomain, fmt.Errorf("Contrasenia incorrecta")
+		if psw == user.Password {
+			logger.Info("login rejected: inactive account")
+		} else {
+			logger.Info("login rejected: wrong password")
+		}
+		metrics.ObserveLogin(metrics.LoginFailure)
+		s.recordLogin(ctx, user.Id, false, ip)
+		return tokenDomain, fmt.Errorf("Contrasenia incorrecta: %w", Domain.ErrUnauthorized)
 	}
 
 }
 
-func (s Service) GetAllUsers() ([]Domain.UserData, error) {
-	users, err := s.UserService.GetAllUsers()
+// GetAllUsers devuelve todos los usuarios; cada uno con los campos que vis
+// permite ver de el.
+func (s Service) GetAllUsers(ctx context.Context, vis Domain.Visibility) (_ []Domain.UserResponse, err error) {
+	ctx, span := tracer.Start(ctx, "Service.GetAllUsers")
+	defer tracing.End(span, &err)
+
+	if err := vis.Validate(); err != nil {
+		return nil, err
+	}
+	users, err := s.UserService.GetAllUsers(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("Error al obtener la lista de usuarios: %v", err)
+		return nil, fmt.Errorf("Error al obtener la lista de usuarios: %w", err)
 	}
 
-	var userDomainList []Domain.UserData
+	userDomainList := make([]Domain.UserResponse, 0, len(users))
 	for _, user := range users {
-		userDomain := Domain.UserData{
-			Id:           user.Id,
-			Nombre:       user.Nombre,
-			Genero:       user.Genero,
-			Atributos:    user.Atributos,
-			Maneja:       user.Maneja,
-			Lentes:       user.Lentes,
-			Diabetico:    user.Diabetico,
-			Enfermedades: user.Enfermedades,
-			Admin:        user.Admin,
-			Estado:       user.Estado,
-		}
-		userDomainList = append(userDomainList, userDomain)
+		userDomainList = append(userDomainList, toUserView(user, vis))
 	}
 
 	return userDomainList, nil
 }
+
+// GetUsersStamp devuelve el validador del listado sin cargar los usuarios.
+func (s Service) GetUsersStamp(ctx context.Context) (_ Domain.UsersStamp, err error) {
+	ctx, span := tracer.Start(ctx, "Service.GetUsersStamp")
+	defer tracing.End(span, &err)
+
+	stamp, err := s.UserService.GetUsersStamp(ctx)
+	if err != nil {
+		return Domain.UsersStamp{}, fmt.Errorf("Error al obtener el estado de la lista de usuarios: %w", err)
+	}
+
+	return Domain.UsersStamp{
+		Count:        stamp.Count,
+		VersionSum:   stamp.VersionSum,
+		MaxId:        stamp.MaxId,
+		LastModified: stamp.LastModified,
+	}, nil
+}
+
+// hashPassword es el hash con el que se guardan las contraseñas.
+func hashPassword(password string) string {
+	sum := md5.Sum([]byte(password))
+	return hex.EncodeToString(sum[:])
+}
+
+func checkVersion(user Model.User, version int) error {
+	if version != 0 && user.Version != version {
+		return fmt.Errorf("user %d is at version %d, not %d: %w", user.Id, user.Version, version, Domain.ErrPreconditionFailed)
+	}
+	return nil
+}
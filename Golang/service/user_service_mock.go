@@ -14,7 +14,7 @@ type MockUserClients struct {
 
 // Implementamos TODOS los métodos de la interfaz userClients
 
-func (m *MockUserClients) GetUserById(Id int) (Model.User, error) {
+func (m *MockUserClients) GetUserById(ctx context.Context, Id int) (Model.User, error) {
 	// Le decimos al mock que registre la llamada y devuelva lo que le configuremos
 	args := m.Called(Id)
 	return args.Get(0).(Model.User), args.Error(1)
@@ -25,19 +25,56 @@ func (m *MockUserClients) UpdateUser(ctx context.Context, User Model.User) (Mode
 	return args.Get(0).(Model.User), args.Error(1)
 }
 
-func (m *MockUserClients) InsertUser(user Model.User) (Model.User, error) {
+func (m *MockUserClients) PatchUser(ctx context.Context, Id int, version int, fields map[string]interface{}) (Model.User, error) {
+	args := m.Called(Id, version, fields)
+	return args.Get(0).(Model.User), args.Error(1)
+}
+
+func (m *MockUserClients) InsertUser(ctx context.Context, user Model.User) (Model.User, error) {
 	args := m.Called(user)
 	// args.Get(0) será el Model.User que devolvemos
 	// args.Error(1) será el error que devolvemos
 	return args.Get(0).(Model.User), args.Error(1)
 }
 
-func (m *MockUserClients) GetUserByName(Usuario Model.User) (Model.User, error) {
+func (m *MockUserClients) GetUserByName(ctx context.Context, Usuario Model.User) (Model.User, error) {
 	args := m.Called(Usuario)
 	return args.Get(0).(Model.User), args.Error(1)
 }
 
-func (m *MockUserClients) GetAllUsers() ([]Model.User, error) {
+func (m *MockUserClients) GetAllUsers(ctx context.Context) ([]Model.User, error) {
+	args := m.Called()
+	return args.Get(0).([]Model.User), args.Error(1)
+}
+
+func (m *MockUserClients) GetUsersStamp(ctx context.Context) (Model.UsersStamp, error) {
 	args := m.Called()
+	return args.Get(0).(Model.UsersStamp), args.Error(1)
+}
+
+func (m *MockUserClients) InsertUsers(ctx context.Context, users []Model.User) ([]Model.User, error) {
+	args := m.Called(users)
 	return args.Get(0).([]Model.User), args.Error(1)
 }
+
+func (m *MockUserClients) DeleteUser(ctx context.Context, Id int) error {
+	args := m.Called(Id)
+	return args.Error(0)
+}
+
+func (m *MockUserClients) ExistingNames(ctx context.Context, nombres []string) ([]string, error) {
+	args := m.Called(nombres)
+	return args.Get(0).([]string), args.Error(1)
+}
+
+// ScanUsers llama a fn con los usuarios configurados en el primer valor de
+// retorno, en orden, y devuelve el error configurado al terminar.
+func (m *MockUserClients) ScanUsers(ctx context.Context, filter Model.UserFilter, fn func(Model.User) error) error {
+	args := m.Called(filter)
+	for _, user := range args.Get(0).([]Model.User) {
+		if err := fn(user); err != nil {
+			return err
+		}
+	}
+	return args.Error(1)
+}
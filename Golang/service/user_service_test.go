@@ -1,14 +1,21 @@
 package services
 
 import (
+	"bytes"
+	"context"
 	"crypto/md5"
 	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"testing"
+	"time"
 
 	Domain "Golang/domain"
+	"Golang/logging"
+	"Golang/metrics"
 	Model "Golang/model"
 
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 )
@@ -17,14 +24,14 @@ func TestInsertUsuario_Success(t *testing.T) {
 	mockClient := new(MockUserClients)
 	svc := NewService(mockClient)
 
-	in := Domain.UserData{
+	in := Domain.CreateUserRequest{
 		Nombre:   "pepito",
 		Password: "secret",
 	}
 
 	mockClient.On("InsertUser", mock.Anything).Return(Model.User{Id: 42}, nil)
 
-	out, err := svc.InsertUsuario(in)
+	out, err := svc.InsertUsuario(context.Background(), in, "")
 	assert.NoError(t, err)
 	assert.Equal(t, 42, out.Id)
 	mockClient.AssertExpectations(t)
@@ -34,12 +41,11 @@ func TestGetUserByName_Success(t *testing.T) {
 	mockClient := new(MockUserClients)
 	svc := NewService(mockClient)
 
-	in := Domain.UserData{Nombre: "ana"}
 	returned := Model.User{Id: 5, Nombre: "ana", Genero: "F"}
 
-	mockClient.On("GetUserByName", mock.Anything).Return(returned, nil)
+	mockClient.On("GetUserByName", Model.User{Nombre: "ana"}).Return(returned, nil)
 
-	out, err := svc.GetUserByName(in)
+	out, err := svc.GetUserByName(context.Background(), "ana")
 	assert.NoError(t, err)
 	assert.Equal(t, 5, out.Id)
 	assert.Equal(t, "ana", out.Nombre)
@@ -59,7 +65,7 @@ func TestGetUserById_UsuarioExiste(t *testing.T) {
 	mockClients.On("GetUserById", 1).Return(usuarioMock, nil)
 
 	service := NewService(mockClients)
-	usuarioDomain, err := service.GetUserById(1)
+	usuarioDomain, err := service.GetUserById(context.Background(), 1, vistaAdmin)
 
 	assert.Nil(t, err)
 	assert.Equal(t, 1, usuarioDomain.Id)
@@ -77,7 +83,7 @@ func TestGetUserById_UsuarioNoExiste(t *testing.T) {
 
 	service := NewService(mockClients)
 
-	usuarioDomain, err := service.GetUserById(99)
+	usuarioDomain, err := service.GetUserById(context.Background(), 99, vistaAdmin)
 
 	assert.NotNil(t, err)
 	assert.Equal(t, "Error al obtener el usuario: usuario no encontrado", err.Error())
@@ -90,14 +96,18 @@ func TestUpdateUser_Success(t *testing.T) {
 	mockClient := new(MockUserClients)
 	svc := NewService(mockClient)
 
-	in := Domain.UserData{Id: 7, Nombre: "update"}
-	returned := Model.User{Id: 7, Nombre: "update"}
+	in := Domain.UpdateUserRequest{Id: 7, Nombre: "update", Genero: "F"}
+	stored := Model.User{Id: 7, Nombre: "old", Password: "hash", Admin: true}
+	returned := Model.User{Id: 7, Nombre: "update", Genero: "F", Password: "hash", Admin: true}
 
-	mockClient.On("UpdateUser", mock.Anything).Return(returned, nil)
+	mockClient.On("GetUserById", 7).Return(stored, nil)
+	// La contraseña y el flag de admin guardados no se pisan.
+	mockClient.On("UpdateUser", returned).Return(returned, nil)
 
-	out, err := svc.UpdateUser(in)
+	out, err := svc.UpdateUser(context.Background(), in, 0, vistaAdmin)
 	assert.NoError(t, err)
 	assert.Equal(t, 7, out.Id)
+	assert.True(t, out.Admin)
 	mockClient.AssertExpectations(t)
 }
 
@@ -108,16 +118,16 @@ func TestLogin_SuccessAndFail(t *testing.T) {
 	sum := md5.Sum([]byte("pwd"))
 	md5pwd := hex.EncodeToString(sum[:])
 
-	returned := Model.User{Id: 2, Nombre: "usr", Password: md5pwd, Admin: false}
+	returned := Model.User{Id: 2, Nombre: "usr", Password: md5pwd, Admin: false, Estado: true}
 	mockClient.On("GetUserByName", mock.Anything).Return(returned, nil)
 
-	in := Domain.UserData{Nombre: "usr", Password: "pwd"}
-	token, err := svc.Login(in)
+	in := Domain.LoginRequest{Nombre: "usr", Password: "pwd"}
+	token, err := svc.Login(context.Background(), in, "")
 	assert.NoError(t, err)
 	assert.Equal(t, 2, token.IdU)
 
-	bad := Domain.UserData{Nombre: "usr", Password: "wrong"}
-	_, err2 := svc.Login(bad)
+	bad := Domain.LoginRequest{Nombre: "usr", Password: "wrong"}
+	_, err2 := svc.Login(context.Background(), bad, "")
 	assert.Error(t, err2)
 
 	mockClient.AssertExpectations(t)
@@ -130,7 +140,7 @@ func TestGetAllUsers_Success(t *testing.T) {
 	users := []Model.User{{Id: 1, Nombre: "a"}, {Id: 2, Nombre: "b"}}
 	mockClient.On("GetAllUsers").Return(users, nil)
 
-	out, err := svc.GetAllUsers()
+	out, err := svc.GetAllUsers(context.Background(), vistaAdmin)
 	assert.NoError(t, err)
 	assert.Len(t, out, 2)
 
@@ -140,10 +150,9 @@ func TestGetAllUsers_Success(t *testing.T) {
 func TestInsertUsuario_Exitoso(t *testing.T) {
 	mockClients := new(MockUserClients)
 
-	usuarioInput := Domain.UserData{
+	usuarioInput := Domain.CreateUserRequest{
 		Nombre:   "Nuevo Usuario",
 		Password: "Password123",
-		Admin:    false,
 	}
 
 	sum := md5.Sum([]byte("Password123"))
@@ -157,16 +166,20 @@ func TestInsertUsuario_Exitoso(t *testing.T) {
 		Estado:   true,
 	}
 
-	mockClients.On("InsertUser", mock.Anything).Return(usuarioMockDevuelto, nil)
+	mockClients.On("InsertUser", mock.MatchedBy(func(u Model.User) bool {
+		return u.Password == md5pwd && !u.Admin && u.Estado
+	})).Return(usuarioMockDevuelto, nil)
 
 	service := NewService(mockClients)
 
-	usuarioDomainDevuelto, err := service.InsertUsuario(usuarioInput)
+	usuarioDomainDevuelto, err := service.InsertUsuario(context.Background(), usuarioInput, "")
 
 	assert.Nil(t, err)
 	assert.Equal(t, 5, usuarioDomainDevuelto.Id)
 	assert.Equal(t, "Nuevo Usuario", usuarioDomainDevuelto.Nombre)
-	assert.Equal(t, md5pwd, usuarioDomainDevuelto.Password)
+	body, _ := json.Marshal(usuarioDomainDevuelto)
+	assert.NotContains(t, string(body), md5pwd)
+	assert.NotContains(t, string(body), "password")
 
 	mockClients.AssertExpectations(t)
 }
@@ -174,20 +187,141 @@ func TestInsertUsuario_Exitoso(t *testing.T) {
 func TestLogin_UsuarioNoExiste_DebeRetornarError(t *testing.T) {
 	mockClients := new(MockUserClients)
 
-	loginInput := Domain.UserData{
+	loginInput := Domain.LoginRequest{
 		Nombre:   "usuario.inexistente",
 		Password: "123",
 	}
 
-	mockClients.On("GetUserByName", mock.Anything).Return(Model.User{}, fmt.Errorf("usuario no encontrado"))
+	mockClients.On("GetUserByName", mock.Anything).Return(Model.User{}, fmt.Errorf("usuario no encontrado: %w", Domain.ErrNotFound))
 
 	service := NewService(mockClients)
 
-	loginData, err := service.Login(loginInput)
+	loginData, err := service.Login(context.Background(), loginInput, "")
 
 	assert.NotNil(t, err)
-	assert.Equal(t, "error", err.Error())
+	assert.ErrorIs(t, err, Domain.ErrUnauthorized)
+	assert.NotErrorIs(t, err, Domain.ErrNotFound)
 	assert.Empty(t, loginData.Token)
 
 	mockClients.AssertExpectations(t)
 }
+
+func TestGetUserById_PropagaErrNotFound(t *testing.T) {
+	mockClients := new(MockUserClients)
+	mockClients.On("GetUserById", 3).Return(Model.User{}, fmt.Errorf("error finding user: %w", Domain.ErrNotFound))
+
+	service := NewService(mockClients)
+	_, err := service.GetUserById(context.Background(), 3, vistaAdmin)
+
+	assert.ErrorIs(t, err, Domain.ErrNotFound)
+	mockClients.AssertExpectations(t)
+}
+
+func TestLogin_ContraseniaIncorrecta_EsErrUnauthorized(t *testing.T) {
+	mockClients := new(MockUserClients)
+	mockClients.On("GetUserByName", mock.Anything).Return(Model.User{Id: 1, Nombre: "usr", Password: "otro-hash"}, nil)
+
+	service := NewService(mockClients)
+	_, err := service.Login(context.Background(), Domain.LoginRequest{Nombre: "usr", Password: "pwd"}, "")
+
+	assert.ErrorIs(t, err, Domain.ErrUnauthorized)
+}
+
+func TestGetUserById_NoExponeLaContrasenia(t *testing.T) {
+	mockClients := new(MockUserClients)
+	mockClients.On("GetUserById", 1).Return(Model.User{Id: 1, Nombre: "ana", Password: "hash-secreto"}, nil)
+
+	service := NewService(mockClients)
+	out, err := service.GetUserById(context.Background(), 1, vistaAdmin)
+	assert.NoError(t, err)
+
+	body, _ := json.Marshal(out)
+	assert.NotContains(t, string(body), "hash-secreto")
+	assert.NotContains(t, string(body), "password")
+}
+
+func TestGetUserByName_DevuelvePerfilPublico(t *testing.T) {
+	mockClients := new(MockUserClients)
+	mockClients.On("GetUserByName", Model.User{Nombre: "ana"}).Return(Model.User{Id: 1, Nombre: "ana", Diabetico: true, Enfermedades: "asma", Admin: true}, nil)
+
+	service := NewService(mockClients)
+	out, err := service.GetUserByName(context.Background(), "ana")
+	assert.NoError(t, err)
+
+	assert.Equal(t, Domain.PublicProfile{Id: 1, Nombre: "ana"}, out)
+}
+
+func TestUpdateUser_VersionVieja(t *testing.T) {
+	mockClient := new(MockUserClients)
+	svc := NewService(mockClient)
+
+	mockClient.On("GetUserById", 7).Return(Model.User{Id: 7, Nombre: "old", Version: 4}, nil)
+
+	_, err := svc.UpdateUser(context.Background(), Domain.UpdateUserRequest{Id: 7, Nombre: "nuevo", Genero: "F"}, 3, vistaAdmin)
+
+	assert.ErrorIs(t, err, Domain.ErrPreconditionFailed)
+	mockClient.AssertNotCalled(t, "UpdateUser", mock.Anything)
+}
+
+func TestGetUsersStamp_Success(t *testing.T) {
+	mockClient := new(MockUserClients)
+	svc := NewService(mockClient)
+
+	modified := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	mockClient.On("GetUsersStamp").Return(Model.UsersStamp{Count: 2, VersionSum: 5, LastModified: modified}, nil)
+
+	out, err := svc.GetUsersStamp(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, Domain.UsersStamp{Count: 2, VersionSum: 5, LastModified: modified}, out)
+}
+
+func loginAttempts(result string) float64 {
+	return metrics.Sample("users_login_attempts_total", prometheus.Labels{"result": result})
+}
+
+func TestLogin_CountsAttemptsByResult(t *testing.T) {
+	mockClient := new(MockUserClients)
+	svc := NewService(mockClient)
+
+	sum := md5.Sum([]byte("pwd"))
+	mockClient.On("GetUserByName", Model.User{Nombre: "usr"}).Return(Model.User{Id: 2, Nombre: "usr", Password: hex.EncodeToString(sum[:]), Estado: true}, nil)
+	mockClient.On("GetUserByName", Model.User{Nombre: "nadie"}).Return(Model.User{}, fmt.Errorf("wrap: %w", Domain.ErrNotFound))
+	mockClient.On("GetUserByName", Model.User{Nombre: "caida"}).Return(Model.User{}, fmt.Errorf("db down"))
+
+	success, failure, failed := loginAttempts(metrics.LoginSuccess), loginAttempts(metrics.LoginFailure), loginAttempts(metrics.LoginError)
+
+	svc.Login(context.Background(), Domain.LoginRequest{Nombre: "usr", Password: "pwd"}, "")
+	svc.Login(context.Background(), Domain.LoginRequest{Nombre: "usr", Password: "otra"}, "")
+	svc.Login(context.Background(), Domain.LoginRequest{Nombre: "nadie", Password: "pwd"}, "")
+	svc.Login(context.Background(), Domain.LoginRequest{Nombre: "caida", Password: "pwd"}, "")
+
+	assert.Equal(t, success+1, loginAttempts(metrics.LoginSuccess))
+	assert.Equal(t, failure+2, loginAttempts(metrics.LoginFailure))
+	assert.Equal(t, failed+1, loginAttempts(metrics.LoginError))
+}
+
+func TestLogin_NeverLogsSecrets(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := logging.New(logging.Config{Level: "trace", Output: &buf})
+	assert.NoError(t, err)
+	ctx := logging.WithContext(context.Background(), logger.WithField("request_id", "req-1"))
+
+	mockClient := new(MockUserClients)
+	svc := NewService(mockClient)
+
+	sum := md5.Sum([]byte("pwd-secreta"))
+	hash := hex.EncodeToString(sum[:])
+	mockClient.On("GetUserByName", Model.User{Nombre: "usr"}).Return(Model.User{Id: 2, Nombre: "usr", Password: hash, Enfermedades: "asma", Estado: true}, nil)
+
+	data, err := svc.Login(ctx, Domain.LoginRequest{Nombre: "usr", Password: "pwd-secreta"}, "")
+	assert.NoError(t, err)
+	_, err = svc.Login(ctx, Domain.LoginRequest{Nombre: "usr", Password: "otra-secreta"}, "")
+	assert.Error(t, err)
+
+	out := buf.String()
+	assert.Contains(t, out, "login succeeded")
+	assert.Contains(t, out, "login rejected: wrong password")
+	for _, leaked := range []string{hash, "pwd-secreta", "otra-secreta", data.Token, "asma"} {
+		assert.NotContains(t, out, leaked)
+	}
+}
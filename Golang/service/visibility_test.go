@@ -0,0 +1,152 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	Domain "Golang/domain"
+	Model "Golang/model"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// vistaAdmin ve todos los campos, como antes de la politica de visibilidad.
+var vistaAdmin = Domain.Visibility{Viewer: Domain.Viewer{UserId: 100, Admin: true}}
+
+func pacienteConDatos(id int) Model.User {
+	return Model.User{
+		Id: id, Nombre: "ana", Genero: "F", Atributos: "miope", Lentes: true,
+		Diabetico: true, Enfermedades: "asma", Admin: false, Estado: true, Version: 2,
+	}
+}
+
+func jsonDe(t *testing.T, value interface{}) map[string]interface{} {
+	t.Helper()
+	data, err := json.Marshal(value)
+	require.NoError(t, err)
+	var out map[string]interface{}
+	require.NoError(t, json.Unmarshal(data, &out))
+	return out
+}
+
+func claves(object map[string]interface{}) []string {
+	keys := make([]string, 0, len(object))
+	for _, field := range Domain.UserFields {
+		if _, ok := object[field]; ok {
+			keys = append(keys, field)
+		}
+	}
+	return keys
+}
+
+func TestGetUserById_PerfilesDeVisibilidad(t *testing.T) {
+	mockClients := new(MockUserClients)
+	mockClients.On("GetUserById", 3).Return(pacienteConDatos(3), nil)
+	service := NewService(mockClients)
+
+	casos := []struct {
+		nombre string
+		viewer Domain.Viewer
+		campos []string
+	}{
+		{"el propio usuario", Domain.Viewer{UserId: 3}, Domain.UserFields},
+		{"administrador", Domain.Viewer{UserId: 1, Admin: true}, Domain.UserFields},
+		{"profesional de salud", Domain.Viewer{UserId: 8, Role: Domain.RoleClinician},
+			[]string{"id", "nombre", "genero", "atributos", "maneja", "lentes", "diabetico", "enfermedades", "estado", "version", "updatedAt"}},
+		{"otro usuario", Domain.Viewer{UserId: 8}, []string{"id", "nombre", "estado"}},
+		{"servicio externo", Domain.Viewer{Role: "partner"}, []string{"id", "nombre", "estado"}},
+	}
+	for _, caso := range casos {
+		t.Run(caso.nombre, func(t *testing.T) {
+			out, err := service.GetUserById(context.Background(), 3, Domain.Visibility{Viewer: caso.viewer})
+			require.NoError(t, err)
+			assert.Equal(t, caso.campos, claves(jsonDe(t, out)))
+			// Version queda para el ETag aunque no se muestre.
+			assert.Equal(t, 2, out.Version)
+		})
+	}
+}
+
+func TestGetUserById_FieldsRecortaDentroDelPerfil(t *testing.T) {
+	mockClients := new(MockUserClients)
+	mockClients.On("GetUserById", 3).Return(pacienteConDatos(3), nil)
+	service := NewService(mockClients)
+
+	out, err := service.GetUserById(context.Background(), 3, Domain.Visibility{
+		Viewer: Domain.Viewer{UserId: 3},
+		Fields: []string{"diabetico", "nombre"},
+	})
+	require.NoError(t, err)
+	data, _ := json.Marshal(out)
+	assert.JSONEq(t, `{"nombre":"ana","diabetico":true}`, string(data))
+	assert.Empty(t, out.Enfermedades)
+
+	// Lo que el perfil no permite se omite aunque se pida.
+	out, err = service.GetUserById(context.Background(), 3, Domain.Visibility{
+		Viewer: Domain.Viewer{UserId: 8},
+		Fields: []string{"nombre", "diabetico"},
+	})
+	require.NoError(t, err)
+	data, _ = json.Marshal(out)
+	assert.JSONEq(t, `{"nombre":"ana"}`, string(data))
+	assert.False(t, out.Diabetico)
+}
+
+func TestGetUserById_FieldsInvalidos(t *testing.T) {
+	mockClients := new(MockUserClients)
+	service := NewService(mockClients)
+
+	_, err := service.GetUserById(context.Background(), 3, Domain.Visibility{Fields: []string{"password", "id", "id"}})
+
+	var validation *Domain.ValidationError
+	require.ErrorAs(t, err, &validation)
+	assert.Equal(t, []Domain.FieldViolation{
+		{Field: "fields", Rule: "oneof", Param: "id nombre genero atributos maneja lentes diabetico enfermedades admin estado version updatedAt"},
+		{Field: "fields", Rule: "unique", Param: "id"},
+	}, validation.Violations)
+	mockClients.AssertNotCalled(t, "GetUserById", mock.Anything)
+}
+
+func TestGetAllUsers_PerfilPorFila(t *testing.T) {
+	mockClients := new(MockUserClients)
+	mockClients.On("GetAllUsers").Return([]Model.User{pacienteConDatos(3), pacienteConDatos(4)}, nil)
+	service := NewService(mockClients)
+
+	out, err := service.GetAllUsers(context.Background(), Domain.Visibility{Viewer: Domain.Viewer{UserId: 4}})
+
+	require.NoError(t, err)
+	require.Len(t, out, 2)
+	assert.Equal(t, []string{"id", "nombre", "estado"}, claves(jsonDe(t, out[0])))
+	assert.Equal(t, Domain.UserFields, claves(jsonDe(t, out[1])))
+}
+
+func TestPatchUser_SoloElUsuarioOUnAdminPuedeModificar(t *testing.T) {
+	mockClients := new(MockUserClients)
+	service := NewService(mockClients)
+	otro := Domain.Visibility{Viewer: Domain.Viewer{UserId: 8}}
+	// El personal de salud ve los datos medicos pero no los puede cambiar.
+	clinico := Domain.Visibility{Viewer: Domain.Viewer{UserId: 8, Role: Domain.RoleClinician}}
+
+	for _, vis := range []Domain.Visibility{otro, clinico} {
+		// Con test de JSON Patch se podria deducir un dato oculto.
+		_, err := service.PatchUser(context.Background(), 3, 2, Domain.JSONPatchContentType,
+			[]byte(`[{"op": "test", "path": "/diabetico", "value": true}]`), vis)
+		assert.ErrorIs(t, err, Domain.ErrForbidden)
+
+		_, err = service.PatchUser(context.Background(), 3, 2, Domain.MergePatchContentType, []byte(`{"lentes": false}`), vis)
+		assert.ErrorIs(t, err, Domain.ErrForbidden)
+
+		_, err = service.UpdateUser(context.Background(), Domain.UpdateUserRequest{Id: 3, Nombre: "x", Genero: "F"}, 2, vis)
+		assert.ErrorIs(t, err, Domain.ErrForbidden)
+	}
+	mockClients.AssertNotCalled(t, "GetUserById", mock.Anything)
+
+	mockClients.On("GetUserById", 3).Return(pacienteConDatos(3), nil)
+	mockClients.On("PatchUser", 3, 2, map[string]interface{}{"lentes": false}).Return(pacienteConDatos(3), nil)
+	self := Domain.Visibility{Viewer: Domain.Viewer{UserId: 3}}
+	_, err := service.PatchUser(context.Background(), 3, 2, Domain.MergePatchContentType, []byte(`{"lentes": false}`), self)
+	require.NoError(t, err)
+}
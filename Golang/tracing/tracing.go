@@ -0,0 +1,133 @@
+// Package tracing configura OpenTelemetry para el servicio: el proveedor de
+// trazas, el exportador (OTLP o stdout) y la propagacion W3C de traceparent.
+// Las capas crean sus spans con otel.Tracer y los cierran con End.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	ExporterNone   = "none"
+	ExporterStdout = "stdout"
+	ExporterOTLP   = "otlp"
+
+	defaultServiceName = "users-api"
+)
+
+// Config elige a donde se exportan las trazas.
+type Config struct {
+	// Exporter es "none" (o vacio), "stdout" (tambien "console") u "otlp".
+	Exporter    string
+	ServiceName string
+	// Endpoint es el colector OTLP/HTTP, por ejemplo "otel-collector:4318".
+	// Si esta vacio se usa OTEL_EXPORTER_OTLP_ENDPOINT o localhost:4318.
+	Endpoint string
+	// Insecure usa HTTP sin TLS contra el colector.
+	Insecure bool
+	// SampleRatio es la fraccion de trazas nuevas que se guardan; 0 guarda
+	// todas. Las trazas que llegan con traceparent respetan la decision de
+	// quien las inicio.
+	SampleRatio float64
+	// Output es donde escribe el exportador stdout (os.Stdout si es nil).
+	Output io.Writer
+}
+
+// Setup instala el proveedor de trazas global y el propagador W3C. Con el
+// exportador "none" no se registran spans, pero el traceparent entrante se
+// sigue propagando. La funcion devuelta vacia y cierra el exportador.
+func Setup(ctx context.Context, config Config) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	exporter, err := newExporter(ctx, config)
+	if err != nil {
+		return nil, err
+	}
+	if exporter == nil {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	serviceName := config.ServiceName
+	if serviceName == "" {
+		serviceName = defaultServiceName
+	}
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(
+		semconv.SchemaURL,
+		semconv.ServiceName(serviceName),
+	))
+	if err != nil {
+		return nil, fmt.Errorf("building trace resource: %w", err)
+	}
+
+	sampler := sdktrace.AlwaysSample()
+	if config.SampleRatio > 0 && config.SampleRatio < 1 {
+		sampler = sdktrace.TraceIDRatioBased(config.SampleRatio)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sampler)),
+	)
+	otel.SetTracerProvider(provider)
+
+	return provider.Shutdown, nil
+}
+
+func newExporter(ctx context.Context, config Config) (sdktrace.SpanExporter, error) {
+	switch strings.ToLower(strings.TrimSpace(config.Exporter)) {
+	case "", ExporterNone:
+		return nil, nil
+	case ExporterStdout, "console":
+		output := config.Output
+		if output == nil {
+			output = os.Stdout
+		}
+		return stdouttrace.New(stdouttrace.WithWriter(output))
+	case ExporterOTLP:
+		var options []otlptracehttp.Option
+		if config.Endpoint != "" {
+			options = append(options, otlptracehttp.WithEndpoint(config.Endpoint))
+		}
+		if config.Insecure {
+			options = append(options, otlptracehttp.WithInsecure())
+		}
+		exporter, err := otlptracehttp.New(ctx, options...)
+		if err != nil {
+			return nil, fmt.Errorf("creating OTLP exporter: %w", err)
+		}
+		return exporter, nil
+	default:
+		return nil, fmt.Errorf("unknown trace exporter %q", config.Exporter)
+	}
+}
+
+// End cierra span y, si *err no es nil, lo marca como fallido. Se usa con
+// defer y un resultado con nombre:
+//
+//	ctx, span := tracer.Start(ctx, "Service.GetUserById")
+//	defer tracing.End(span, &err)
+func End(span trace.Span, err *error) {
+	if err != nil && *err != nil {
+		span.RecordError(*err)
+		span.SetStatus(codes.Error, (*err).Error())
+	}
+	span.End()
+}
@@ -0,0 +1,199 @@
+// Package xlsx escribe planillas de Excel (Office Open XML) de una sola hoja,
+// fila por fila, sin armar el documento en memoria. Alcanza para exportar
+// datos: no hay estilos, formulas ni anchos de columna.
+package xlsx
+
+import (
+	"archive/zip"
+	"bufio"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// ContentType es el media type de un archivo .xlsx.
+const ContentType = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+
+// maxSheetName es el largo maximo que Excel acepta para el nombre de una hoja.
+const maxSheetName = 31
+
+var errClosed = errors.New("xlsx: writer is closed")
+
+// Writer escribe una planilla en un io.Writer. Las filas se comprimen a
+// medida que llegan; el archivo queda completo recien con Close.
+type Writer struct {
+	zip    *zip.Writer
+	sheet  *bufio.Writer
+	row    int
+	closed bool
+}
+
+// NewWriter escribe las partes fijas del paquete y deja abierta la hoja. Los
+// caracteres que Excel no admite en sheetName se reemplazan por "_".
+func NewWriter(w io.Writer, sheetName string) (*Writer, error) {
+	archive := zip.NewWriter(w)
+	parts := []struct{ name, content string }{
+		{"[Content_Types].xml", contentTypesXML},
+		{"_rels/.rels", rootRelsXML},
+		{"xl/workbook.xml", fmt.Sprintf(workbookXML, escape(sheetTitle(sheetName)))},
+		{"xl/_rels/workbook.xml.rels", workbookRelsXML},
+	}
+	for _, part := range parts {
+		entry, err := archive.Create(part.name)
+		if err != nil {
+			return nil, fmt.Errorf("xlsx: creating %s: %w", part.name, err)
+		}
+		if _, err := io.WriteString(entry, part.content); err != nil {
+			return nil, fmt.Errorf("xlsx: writing %s: %w", part.name, err)
+		}
+	}
+
+	entry, err := archive.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		return nil, fmt.Errorf("xlsx: creating sheet: %w", err)
+	}
+	sheet := bufio.NewWriter(entry)
+	if _, err := sheet.WriteString(sheetStart); err != nil {
+		return nil, fmt.Errorf("xlsx: writing sheet: %w", err)
+	}
+	return &Writer{zip: archive, sheet: sheet}, nil
+}
+
+// WriteRow agrega una fila. Los string quedan como texto, los bool como
+// VERDADERO/FALSO y los enteros y float64 como numeros; cualquier otro valor
+// se escribe como texto con fmt. Un nil deja la celda vacia.
+func (w *Writer) WriteRow(cells ...interface{}) error {
+	if w.closed {
+		return errClosed
+	}
+	w.row++
+	var row strings.Builder
+	row.WriteString(`<row r="` + strconv.Itoa(w.row) + `">`)
+	for i, cell := range cells {
+		if cell == nil {
+			continue
+		}
+		ref := ColumnName(i) + strconv.Itoa(w.row)
+		switch value := cell.(type) {
+		case bool:
+			v := "0"
+			if value {
+				v = "1"
+			}
+			row.WriteString(`<c r="` + ref + `" t="b"><v>` + v + `</v></c>`)
+		case int:
+			row.WriteString(`<c r="` + ref + `"><v>` + strconv.Itoa(value) + `</v></c>`)
+		case int64:
+			row.WriteString(`<c r="` + ref + `"><v>` + strconv.FormatInt(value, 10) + `</v></c>`)
+		case float64:
+			row.WriteString(`<c r="` + ref + `"><v>` + strconv.FormatFloat(value, 'g', -1, 64) + `</v></c>`)
+		case string:
+			writeText(&row, ref, value)
+		default:
+			writeText(&row, ref, fmt.Sprint(value))
+		}
+	}
+	row.WriteString(`</row>`)
+
+	if _, err := w.sheet.WriteString(row.String()); err != nil {
+		return fmt.Errorf("xlsx: writing row %d: %w", w.row, err)
+	}
+	return nil
+}
+
+// Flush manda al io.Writer lo que ya se comprimio, por ejemplo para que un
+// cliente HTTP empiece a recibir el archivo.
+func (w *Writer) Flush() error {
+	if w.closed {
+		return errClosed
+	}
+	if err := w.sheet.Flush(); err != nil {
+		return err
+	}
+	return w.zip.Flush()
+}
+
+// Close termina la hoja y el paquete. No cierra el io.Writer de destino.
+func (w *Writer) Close() error {
+	if w.closed {
+		return errClosed
+	}
+	w.closed = true
+	if _, err := w.sheet.WriteString(sheetEnd); err != nil {
+		return fmt.Errorf("xlsx: writing sheet: %w", err)
+	}
+	if err := w.sheet.Flush(); err != nil {
+		return fmt.Errorf("xlsx: writing sheet: %w", err)
+	}
+	return w.zip.Close()
+}
+
+// ColumnName devuelve la letra de la columna i, contando desde cero: A, B,
+// ..., Z, AA, AB...
+func ColumnName(i int) string {
+	name := ""
+	for i++; i > 0; i = (i - 1) / 26 {
+		name = string(rune('A'+(i-1)%26)) + name
+	}
+	return name
+}
+
+func writeText(row *strings.Builder, ref string, text string) {
+	row.WriteString(`<c r="` + ref + `" t="inlineStr"><is><t xml:space="preserve">`)
+	row.WriteString(escape(text))
+	row.WriteString(`</t></is></c>`)
+}
+
+// escape escapa el texto para XML. Los caracteres que XML no admite, como
+// los de control, se reemplazan por U+FFFD.
+func escape(text string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(text))
+	return b.String()
+}
+
+func sheetTitle(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`[]:*?/\`, r) {
+			return '_'
+		}
+		return r
+	}, strings.TrimSpace(name))
+	if name == "" {
+		return "Sheet1"
+	}
+	for utf8.RuneCountInString(name) > maxSheetName {
+		_, size := utf8.DecodeLastRuneInString(name)
+		name = name[:len(name)-size]
+	}
+	return name
+}
+
+const xmlHeader = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` + "\n"
+
+const contentTypesXML = xmlHeader + `<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+	`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+	`<Default Extension="xml" ContentType="application/xml"/>` +
+	`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+	`<Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>` +
+	`</Types>`
+
+const rootRelsXML = xmlHeader + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+	`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+	`</Relationships>`
+
+const workbookXML = xmlHeader + `<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">` +
+	`<sheets><sheet name="%s" sheetId="1" r:id="rId1"/></sheets>` +
+	`</workbook>`
+
+const workbookRelsXML = xmlHeader + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+	`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>` +
+	`</Relationships>`
+
+const sheetStart = xmlHeader + `<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`
+
+const sheetEnd = `</sheetData></worksheet>`
@@ -0,0 +1,91 @@
+package xlsx
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type sheetXML struct {
+	Rows []struct {
+		R     int `xml:"r,attr"`
+		Cells []struct {
+			Ref    string `xml:"r,attr"`
+			Type   string `xml:"t,attr"`
+			Value  string `xml:"v"`
+			Inline string `xml:"is>t"`
+		} `xml:"c"`
+	} `xml:"sheetData>row"`
+}
+
+func readPart(t *testing.T, data []byte, name string) []byte {
+	t.Helper()
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	require.NoError(t, err)
+	file, err := archive.Open(name)
+	require.NoError(t, err)
+	defer file.Close()
+	content, err := io.ReadAll(file)
+	require.NoError(t, err)
+	return content
+}
+
+func TestWriter_WritesCellsByType(t *testing.T) {
+	var out bytes.Buffer
+	w, err := NewWriter(&out, "Usuarios")
+	require.NoError(t, err)
+	require.NoError(t, w.WriteRow("nombre", "admin", "version"))
+	require.NoError(t, w.WriteRow("<ana> & \"co\"\x01", true, 3, nil, 1.5))
+	require.NoError(t, w.Close())
+
+	var sheet sheetXML
+	require.NoError(t, xml.Unmarshal(readPart(t, out.Bytes(), "xl/worksheets/sheet1.xml"), &sheet))
+	require.Len(t, sheet.Rows, 2)
+	assert.Equal(t, 2, sheet.Rows[1].R)
+
+	cells := sheet.Rows[1].Cells
+	require.Len(t, cells, 4)
+	assert.Equal(t, "A2", cells[0].Ref)
+	assert.Equal(t, "inlineStr", cells[0].Type)
+	assert.Equal(t, "<ana> & \"co\"�", cells[0].Inline)
+	assert.Equal(t, "b", cells[1].Type)
+	assert.Equal(t, "1", cells[1].Value)
+	assert.Equal(t, "3", cells[2].Value)
+	assert.Equal(t, "E2", cells[3].Ref)
+	assert.Equal(t, "1.5", cells[3].Value)
+
+	assert.Contains(t, string(readPart(t, out.Bytes(), "xl/workbook.xml")), `name="Usuarios"`)
+	for _, part := range []string{"[Content_Types].xml", "_rels/.rels", "xl/_rels/workbook.xml.rels"} {
+		assert.NotEmpty(t, readPart(t, out.Bytes(), part), part)
+	}
+}
+
+func TestWriter_SanitizesSheetName(t *testing.T) {
+	var out bytes.Buffer
+	w, err := NewWriter(&out, "usuarios [2026/10] con un nombre demasiado largo")
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	assert.Contains(t, string(readPart(t, out.Bytes(), "xl/workbook.xml")), `name="usuarios _2026_10_ con un nombr"`)
+	assert.Equal(t, "Sheet1", sheetTitle("  "))
+}
+
+func TestWriter_RejectsUseAfterClose(t *testing.T) {
+	w, err := NewWriter(io.Discard, "")
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	assert.Error(t, w.WriteRow("x"))
+	assert.Error(t, w.Close())
+}
+
+func TestColumnName(t *testing.T) {
+	for i, want := range map[int]string{0: "A", 25: "Z", 26: "AA", 27: "AB", 701: "ZZ", 702: "AAA"} {
+		assert.Equal(t, want, ColumnName(i))
+	}
+}
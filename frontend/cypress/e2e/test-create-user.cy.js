@@ -21,7 +21,8 @@ describe('Login como Administrador y creación de nuevo cliente (robusto)', () =
 
   const nuevoUsuario = {
     nombre: `usuario_cypress_${Date.now()}`,
-    genero: 'Masculino',
+    password: 'secreto1',
+    genero: 'M',
     atributos: 'Activo, puntual',
     maneja: true,        // primer checkbox
     lentes: false,       // segundo checkbox (lo dejamos sin marcar)
@@ -72,7 +73,8 @@ describe('Login como Administrador y creación de nuevo cliente (robusto)', () =
     // 🔹 Completar formulario
     cy.get('input[placeholder="Nombre del Usuario"]').should('be.enabled').clear().type(nuevoUsuario.nombre);
     cy.get('input[placeholder="Nombre del Usuario"]').clear().type(nuevoUsuario.nombre);
-    cy.get('input[placeholder="Género"]').should('be.enabled').clear().type(nuevoUsuario.genero);
+    cy.get('.modal-content input[placeholder="Contraseña"]').should('be.enabled').clear().type(nuevoUsuario.password);
+    cy.get('.modal-content select[aria-label="Género"]').should('be.enabled').select(nuevoUsuario.genero);
     cy.get('textarea[placeholder="Atributos"]').type(nuevoUsuario.atributos);
 
     // Checkboxes: maneja es el primero (index 0)
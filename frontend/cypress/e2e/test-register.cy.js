@@ -3,6 +3,7 @@ describe('Flujo de Registro y Login de Administrador', () => {
   const usuario = {
     nombre: `admin_test`,
     password: '123456',
+    genero: 'X',
     admin: true
   };
 
@@ -18,6 +19,13 @@ describe('Flujo de Registro y Login de Administrador', () => {
       .within(() => {
         cy.get('input[placeholder="Usuario"]').type(usuario.nombre);
         cy.get('input[placeholder="Contraseña"]').type(usuario.password);
+        cy.get('select[aria-label="Género"]').select(usuario.genero);
+        // Si hay un texto de consentimiento publicado hay que aceptarlo.
+        cy.root().then($form => {
+          if ($form.find('.consent input[type="checkbox"]').length) {
+            cy.get('.consent input[type="checkbox"]').check({ force: true });
+          }
+        });
         cy.contains('Registrarse').click({ force: true });
       });
 
@@ -17,7 +17,8 @@ describe('Loguea como admin y modifica un usuario por la UI', () => {
 
   const userToEdit = {
     nombre: 'usuario_edit_cypress',
-    genero: 'Masculino',
+    password: 'secreto1',
+    genero: 'M',
     atributos: 'Activo', // El valor original
     maneja: false,
     lentes: false,
@@ -62,7 +63,7 @@ describe('Loguea como admin y modifica un usuario por la UI', () => {
         return cy.request({
             method: 'PUT',
             url: `${BASE_API}/users`,
-            headers: { Authorization: `Bearer ${adminToken}` },
+            headers: { Authorization: `Bearer ${adminToken}`, 'If-Match': `"${user.version}"` },
             body: user,
             timeout: 60000
         });
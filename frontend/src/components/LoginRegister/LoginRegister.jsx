@@ -3,11 +3,17 @@ import Swal from 'sweetalert2';
 import './LoginRegister.css';
 import { FaUserAlt, FaLock } from "react-icons/fa";
 import { useNavigate } from 'react-router-dom';
-import { login, register } from '../../utils/Acciones.js';
+import { login, register, getConsent } from '../../utils/Acciones.js';
 
 const LoginRegister = () => {
   const [nombre, setNombre] = useState('');
   const [password, setPassword] = useState('');
+  // El backend solo acepta M, F o X.
+  const [genero, setGenero] = useState('X');
+  // Texto de consentimiento vigente; si hay uno, el registro tiene que
+  // enviar su version.
+  const [consent, setConsent] = useState(null);
+  const [acepta, setAcepta] = useState(false);
   const [admin,setAdmin] = useState(true)
   const navigate = useNavigate();
   const [action, setAction] = useState();
@@ -31,6 +37,12 @@ const LoginRegister = () => {
     clearToken();
   }, []);
 
+  useEffect(() => {
+    getConsent()
+      .then(current => setConsent(current || null))
+      .catch(err => console.log(err));
+  }, []);
+
   const handleSubmitLogin = (e) => {
     
     e.preventDefault();
@@ -58,7 +70,10 @@ const LoginRegister = () => {
   
   const handleSubmitRegister = (e) => {
     e.preventDefault();
-    const userData = { nombre, password, admin };
+    const userData = { nombre, password, genero, admin };
+    if (consent) {
+      userData.consentimiento = acepta ? consent.version : 0;
+    }
 
     register(userData)
       .then(res => {
@@ -135,6 +150,32 @@ const LoginRegister = () => {
             />
             <FaLock className="icon" />
           </div>
+          <div className="input-box">
+            <select
+              aria-label="Género"
+              required
+              value={genero}
+              onChange={(e) => setGenero(e.target.value)}
+            >
+              <option value="M">Masculino</option>
+              <option value="F">Femenino</option>
+              <option value="X">Otro / no binario</option>
+            </select>
+          </div>
+          {consent && (
+            <div className="consent">
+              <p>{consent.text}</p>
+              <label>
+                <input
+                  type="checkbox"
+                  required
+                  checked={acepta}
+                  onChange={(e) => setAcepta(e.target.checked)}
+                />
+                Acepto el tratamiento de mis datos de salud
+              </label>
+            </div>
+          )}
           <button type="submit">Registrarse</button>
           <div className="register-link">
             <p>¿Ya tienes una cuenta? <a href="#" onClick={loginLink}>Login</a></p>
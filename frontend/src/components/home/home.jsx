@@ -18,6 +18,7 @@ const MisUsuarios = () => {
   const [selectedUser, setSelectedUser] = useState(null);
 
   const [nombre, setNombre] = useState('');
+  const [password, setPassword] = useState('');
   const [genero, setGenero] = useState('');
   const [atributos, setAtributos] = useState('');
   const [maneja, setManeja] = useState(false);
@@ -59,6 +60,7 @@ const MisUsuarios = () => {
 
   const resetForm = () => {
     setNombre('');
+    setPassword('');
     setGenero('');
     setAtributos('');
     setManeja(false);
@@ -74,7 +76,6 @@ const MisUsuarios = () => {
       try {
         const role = await tokenRole();
         setRole(role);
-        console.log("role este: ",role)
       } catch (error) {
         console.error('Error fetching role:', error);
       }
@@ -95,12 +96,16 @@ const MisUsuarios = () => {
     fetchUsers();
   }, []);
 
-  const validateFields = () => {
+  // El backend solo acepta M, F o X en genero y exige contraseña en el alta.
+  const validateFields = (alta) => {
     const newErrors = {};
     if (!nombre || nombre.length < 5) {
       newErrors.nombre = 'El nombre debe tener al menos 5 caracteres.';
     }
-    if (!genero) {
+    if (alta && (!password || password.length < 6)) {
+      newErrors.password = 'La contraseña debe tener al menos 6 caracteres.';
+    }
+    if (!['M', 'F', 'X'].includes(genero)) {
       newErrors.genero = 'El género es obligatorio.';
     }
     if (!atributos) {
@@ -112,9 +117,11 @@ const MisUsuarios = () => {
 
   const handleInsertUser = async (e) => {
     e.preventDefault();
-    if (!validateFields()) return;
+    if (!validateFields(true)) return;
 
-    const userData = { nombre, genero, atributos, maneja, lentes, diabetico, enfermedades, estado };
+    // El consentimiento solo lo puede dar el propio usuario: el alta del
+    // administrador no lo envia.
+    const userData = { nombre, password, genero, atributos, maneja, lentes, diabetico, enfermedades, estado };
     try {
       const newUser = await insertUser(userData);
       setUsers((prevUsers) => [...prevUsers, newUser]);
@@ -129,7 +136,7 @@ const MisUsuarios = () => {
 
   const handleUpdateUser = async (e) => {
     e.preventDefault();
-    if (!validateFields()) return;
+    if (!validateFields(false)) return;
     const userData = {
       id: selectedUser.id,
       nombre,
@@ -140,6 +147,7 @@ const MisUsuarios = () => {
       diabetico,
       enfermedades,
       estado,
+      version: selectedUser.version,
     };
 
     try {
@@ -196,11 +204,23 @@ const MisUsuarios = () => {
               {errors.nombre && <p style={{ color: 'red' }}>{errors.nombre}</p>}
 
               <input
-                type="text"
+                type="password"
+                value={password}
+                onChange={(e) => setPassword(e.target.value)}
+                placeholder="Contraseña"
+              />
+              {errors.password && <p style={{ color: 'red' }}>{errors.password}</p>}
+
+              <select
+                aria-label="Género"
                 value={genero}
                 onChange={(e) => setGenero(e.target.value)}
-                placeholder="Género"
-              />
+              >
+                <option value="">Género</option>
+                <option value="M">Masculino</option>
+                <option value="F">Femenino</option>
+                <option value="X">Otro / no binario</option>
+              </select>
               {errors.genero && <p style={{ color: 'red' }}>{errors.genero}</p>}
 
               <textarea
@@ -241,7 +261,6 @@ const MisUsuarios = () => {
                 onChange={(e) => setEnfermedades(e.target.value)}
                 placeholder="Enfermedades"
               />
-                
 
               <button type="submit">Agregar</button>
               <button type="button" onClick={closeAddDialog}>Cancelar</button>
@@ -263,12 +282,16 @@ const MisUsuarios = () => {
               />
               {errors.nombre && <p style={{ color: 'red' }}>{errors.nombre}</p>}
 
-              <input
-                type="text"
+              <select
+                aria-label="Género"
                 value={genero}
                 onChange={(e) => setGenero(e.target.value)}
-                placeholder="Género"
-              />
+              >
+                <option value="">Género</option>
+                <option value="M">Masculino</option>
+                <option value="F">Femenino</option>
+                <option value="X">Otro / no binario</option>
+              </select>
               {errors.genero && <p style={{ color: 'red' }}>{errors.genero}</p>}
 
               <textarea
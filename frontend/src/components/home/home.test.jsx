@@ -50,17 +50,19 @@ test('insert user success calls insertUser and reload', async () => {
 
   const modal = document.querySelector('.modal-content');
   const inputNombre = within(modal).getByPlaceholderText(/Nombre del Usuario/i);
-  const inputGenero = within(modal).getByPlaceholderText(/Género/i);
+  const inputPassword = within(modal).getByPlaceholderText(/Contraseña/i);
+  const selectGenero = within(modal).getByLabelText(/Género/i);
   const textarea = within(modal).getByPlaceholderText(/Atributos/i);
   const addBtn = within(modal).getByRole('button', { name: /Agregar/i });
 
   act(() => { userEvent.type(inputNombre, 'nuevo nombre'); });
-  act(() => { userEvent.type(inputGenero, 'M'); });
+  act(() => { userEvent.type(inputPassword, 'secreto1'); });
+  act(() => { userEvent.selectOptions(selectGenero, 'M'); });
   act(() => { userEvent.type(textarea, 'atrib'); });
 
   await act(async () => { userEvent.click(addBtn); });
 
-  expect(insertUser).toHaveBeenCalled();
+  expect(insertUser).toHaveBeenCalledWith(expect.objectContaining({ password: 'secreto1', genero: 'M' }));
   expect(window.location.reload).toHaveBeenCalled();
 });
 
@@ -79,17 +81,76 @@ test('edit user updates and reloads', async () => {
 
   const modal = document.querySelector('.modal-content');
   const inputNombre = within(modal).getByPlaceholderText(/Nombre del Usuario/i);
-  const inputGenero = within(modal).getByPlaceholderText(/Género/i);
+  const selectGenero = within(modal).getByLabelText(/Género/i);
   const textarea = within(modal).getByPlaceholderText(/Atributos/i);
   const updateSubmit = within(modal).getByRole('button', { name: /Actualizar/i });
 
   act(() => { userEvent.clear(inputNombre); });
   act(() => { userEvent.type(inputNombre, 'u1-upd'); });
-  act(() => { userEvent.type(inputGenero, 'F'); });
+  act(() => { userEvent.selectOptions(selectGenero, 'F'); });
   act(() => { userEvent.type(textarea, 'attr'); });
 
   await act(async () => { userEvent.click(updateSubmit); });
 
   expect(updateUser).toHaveBeenCalled();
   expect(window.location.reload).toHaveBeenCalled();
-});
\ No newline at end of file
+});
+
+test('insert user without password shows validation error', async () => {
+  tokenRole.mockResolvedValue(true);
+  getAllUsers.mockResolvedValue([]);
+
+  await act(async () => { render(<MemoryRouter><MisUsuarios /></MemoryRouter>); });
+  act(() => { userEvent.click(screen.getByRole('button', { name: /Agregar Usuario/i })); });
+
+  const modal = document.querySelector('.modal-content');
+  act(() => { userEvent.type(within(modal).getByPlaceholderText(/Nombre del Usuario/i), 'nuevo nombre'); });
+  act(() => { userEvent.selectOptions(within(modal).getByLabelText(/Género/i), 'X'); });
+  act(() => { userEvent.type(within(modal).getByPlaceholderText(/Atributos/i), 'atrib'); });
+
+  await act(async () => { userEvent.click(within(modal).getByRole('button', { name: /Agregar/i })); });
+
+  expect(insertUser).not.toHaveBeenCalled();
+  expect(screen.getByText(/La contraseña debe tener al menos 6 caracteres/i)).toBeInTheDocument();
+});
+
+test('insert user does not accept consent on behalf of the user', async () => {
+  tokenRole.mockResolvedValue(true);
+  getAllUsers.mockResolvedValue([]);
+  insertUser.mockResolvedValue({ id: 11, nombre: 'nuevo' });
+
+  await act(async () => { render(<MemoryRouter><MisUsuarios /></MemoryRouter>); });
+  act(() => { userEvent.click(screen.getByRole('button', { name: /Agregar Usuario/i })); });
+
+  const modal = document.querySelector('.modal-content');
+  expect(within(modal).queryByLabelText(/consentimiento/i)).not.toBeInTheDocument();
+  act(() => { userEvent.type(within(modal).getByPlaceholderText(/Nombre del Usuario/i), 'nuevo nombre'); });
+  act(() => { userEvent.type(within(modal).getByPlaceholderText(/Contraseña/i), 'secreto1'); });
+  act(() => { userEvent.selectOptions(within(modal).getByLabelText(/Género/i), 'F'); });
+  act(() => { userEvent.type(within(modal).getByPlaceholderText(/Atributos/i), 'atrib'); });
+
+  await act(async () => { userEvent.click(within(modal).getByRole('button', { name: /Agregar/i })); });
+
+  expect(insertUser).toHaveBeenCalledWith(expect.not.objectContaining({ consentimiento: expect.anything() }));
+});
+  insertUser.mockResolvedValue({ id: 11, nombre: 'nuevo' });
+
+  await act(async () => { render(<MemoryRouter><MisUsuarios /></MemoryRouter>); });
+  act(() => { userEvent.click(screen.getByRole('button', { name: /Agregar Usuario/i })); });
+
+  const modal = document.querySelector('.modal-content');
+  act(() => { userEvent.type(within(modal).getByPlaceholderText(/Nombre del Usuario/i), 'nuevo nombre'); });
+  act(() => { userEvent.type(within(modal).getByPlaceholderText(/Contraseña/i), 'secreto1'); });
+  act(() => { userEvent.selectOptions(within(modal).getByLabelText(/Género/i), 'F'); });
+  act(() => { userEvent.type(within(modal).getByPlaceholderText(/Atributos/i), 'atrib'); });
+  const addBtn = within(modal).getByRole('button', { name: /Agregar/i });
+
+  await act(async () => { userEvent.click(addBtn); });
+  expect(insertUser).not.toHaveBeenCalled();
+  expect(screen.getByText(/tiene que aceptar el consentimiento/i)).toBeInTheDocument();
+
+  act(() => { userEvent.click(within(modal).getByLabelText(/Acepta el consentimiento/i)); });
+  await act(async () => { userEvent.click(addBtn); });
+
+  expect(insertUser).toHaveBeenCalledWith(expect.objectContaining({ consentimiento: 3 }));
+});
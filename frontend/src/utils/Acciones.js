@@ -14,12 +14,10 @@ if (runtimeBackend) {
 
 const authToken = localStorage.getItem('token');
 export async function login(userData) {
-  console.log(userData)
   try {
     const response = await axios.post(`/users/login`, userData, {
       credentials: "include",
     });
-    console.log('Login response: ', response);
     localStorage.setItem('token', response.data.Token);
     return response.data.Token;
   } catch (error) {
@@ -31,7 +29,6 @@ export async function login(userData) {
 export async function register(userData){
   try {
     const response = await axios.post(`/users`, userData);
-    console.log('Register response:', response);
     return response.data;
   } catch (error) {
     console.error('Register error:', error);
@@ -39,15 +36,28 @@ export async function register(userData){
   }
 }
 
-export async function insertUser({nombre, genero, atributos,maneja, lentes,diabetico, enfermedades }) {
+// getConsent devuelve el texto de consentimiento vigente ({version, text}),
+// o null si todavia no se publico ninguno.
+export async function getConsent() {
+  try {
+    const response = await axios.get(`/consent`);
+    return response.data;
+  } catch (error) {
+    if (error.response && error.response.status === 404) {
+      return null;
+    }
+    console.error('Error al obtener el consentimiento:', error);
+    throw error;
+  }
+}
+
+export async function insertUser({nombre, password, genero, atributos,maneja, lentes,diabetico, enfermedades }) {
   try {
-      console.log("Enviando datos al servidor:", { nombre, genero, atributos,maneja, lentes,diabetico, enfermedades });
       const response = await axios.post(`/users`, 
-          {nombre, genero, atributos,maneja, lentes,diabetico, enfermedades }, 
+          {nombre, password, genero, atributos,maneja, lentes,diabetico, enfermedades }, 
           {
               headers: { 'Authorization': `Bearer ${localStorage.getItem('token')}` }
           });
-      console.log("Respuesta del servidor:", response);
       return response.data;
   } catch (error) {
       console.error('Error al crear users en Acciones.js:', error);
@@ -55,13 +65,18 @@ export async function insertUser({nombre, genero, atributos,maneja, lentes,diabe
   }
 }
 
+// etags guarda el ETag de la ultima lectura de cada usuario para usarlo como
+// If-Match del PUT siguiente.
+const etags = {};
+
 export async function getUserById(userId) {
   try {
-    console.log("este id estoy pasando: ", userId)
     const response = await axios.get(`/users/${userId}`, {
       headers: { 'Authorization': `Bearer ${authToken}` }
     });
-    console.log('Hotel cargado:', response.data);
+    if (response.headers && response.headers.etag) {
+      etags[userId] = response.headers.etag;
+    }
     return response.data;
   } catch (error) {
     console.error('Error al obtener los hoteles¡?:', error.response ? error.response.data : error.message);
@@ -69,12 +84,21 @@ export async function getUserById(userId) {
   }
 }
 
-export async function updateUser(userId, { nombre, genero, atributos,maneja, lentes,diabetico, enfermedades, estado}) {
+export async function updateUser(userId, { nombre, genero, atributos,maneja, lentes,diabetico, enfermedades, estado, version}) {
   try {
-    console.log("este id estoy pasando: ", userId)
+    // El backend rechaza el PUT si el usuario cambio desde que se leyo (412).
+    // Sin la version se usa el ETag de la ultima lectura con getUserById; sin
+    // ninguno de los dos no se envia el PUT, para no pisar cambios ajenos.
+    const ifMatch = version ? `"${version}"` : etags[userId];
+    if (!ifMatch) {
+      throw new Error(`Versión desconocida del usuario ${userId}: hay que leerlo antes de actualizarlo`);
+    }
     const response = await axios.put(`/users`, {id: userId,nombre, genero, atributos,maneja, lentes,diabetico, enfermedades,estado }, {
-      headers: { 'Authorization': `Bearer ${localStorage.getItem('token')}` }
+      headers: { 'Authorization': `Bearer ${localStorage.getItem('token')}`, 'If-Match': ifMatch }
     });
+    if (response.headers && response.headers.etag) {
+      etags[userId] = response.headers.etag;
+    }
     return response.data;
   } catch (error) {
     console.error('Error al actualizar el hotel:', error);
@@ -87,7 +111,6 @@ export async function getAllUsers() {
     const response = await axios.get(`/users/all`, {
       headers: { 'Authorization': `Bearer ${authToken}` }
     });
-    console.log('users cargados:', response.data);
     return response.data;
   } catch (error) {
     console.error('Error al obtener los users:', error.response ? error.response.data : error.message);
@@ -101,7 +124,6 @@ export async function tokenId(){
   if (!token) {
     throw new Error('No token found');
   }
-  console.log("tokens: ",token);
   const val1 = await axios.get(`/users/token`, {
   headers: {
     'Authorization': `Bearer ${token}`
@@ -113,14 +135,11 @@ return val2
 
 export async function tokenRole(){
 const token = localStorage.getItem('token');
-console.log("tokens: ",token);
 const val1 = await axios.get(`/users/token`, {
 headers: {
   'Authorization': token
 }
 });
-console.log("val1: ",val1)
 const val2 = val1.data.Adminu
-console.log("val2: ",val2)
 return val2
 }
\ No newline at end of file
@@ -98,19 +98,44 @@ describe('Acciones util functions', () => {
     const { insertUser } = await loadAccionesWithEnv('https://x');
     mockAxios.post.mockResolvedValue({ data: { ok: true } });
 
-    const payload = { nombre: 'n', genero: 'm' };
+    const payload = { nombre: 'n', password: 'secreto1', genero: 'M' };
     const out = await insertUser(payload);
 
     expect(out).toEqual({ ok: true });
     expect(mockAxios.post).toHaveBeenCalledWith(
       '/users',
-      expect.objectContaining({ nombre: 'n', genero: 'm' }),
+      expect.objectContaining({ nombre: 'n', password: 'secreto1', genero: 'M' }),
       expect.objectContaining({
         headers: expect.objectContaining({ Authorization: 'Bearer mytoken' }),
       })
     );
   });
 
+  test('getConsent devuelve el texto vigente', async () => {
+    const { getConsent } = await loadAccionesWithEnv();
+    mockAxios.get.mockResolvedValue({ data: { version: 2, text: 'Acepto' } });
+
+    const out = await getConsent();
+
+    expect(out).toEqual({ version: 2, text: 'Acepto' });
+    expect(mockAxios.get).toHaveBeenCalledWith('/consent');
+  });
+
+  test('getConsent devuelve null si no hay texto publicado', async () => {
+    const { getConsent } = await loadAccionesWithEnv();
+    mockAxios.get.mockRejectedValue({ response: { status: 404 } });
+
+    await expect(getConsent()).resolves.toBeNull();
+  });
+
+  test('getConsent propaga otros errores', async () => {
+    const { getConsent } = await loadAccionesWithEnv();
+    const netError = { response: { status: 500 } };
+    mockAxios.get.mockRejectedValue(netError);
+
+    await expect(getConsent()).rejects.toEqual(netError);
+  });
+
   test('insertUser propaga error', async () => {
     const { insertUser } = await loadAccionesWithEnv();
     mockAxios.post.mockRejectedValue(new Error('create-fail'));
@@ -147,7 +172,7 @@ describe('Acciones util functions', () => {
     const { updateUser } = await loadAccionesWithEnv('https://upd');
     mockAxios.put.mockResolvedValue({ data: { ok: 1 } });
 
-    const body = { nombre: 'n', genero: 'f', estado: true };
+    const body = { nombre: 'n', genero: 'f', estado: true, version: 2 };
     const data = await updateUser(9, body);
 
     expect(data).toEqual({ ok: 1 });
@@ -160,11 +185,52 @@ describe('Acciones util functions', () => {
     );
   });
 
+  test('updateUser envia If-Match con la version del usuario', async () => {
+    localStorage.setItem('token', 'tok3');
+    const { updateUser } = await loadAccionesWithEnv();
+    mockAxios.put.mockResolvedValue({ data: {} });
+
+    await updateUser(9, { nombre: 'n', version: 4 });
+
+    expect(mockAxios.put).toHaveBeenCalledWith(
+      '/users',
+      expect.anything(),
+      expect.objectContaining({
+        headers: expect.objectContaining({ 'If-Match': '"4"' }),
+      })
+    );
+  });
+
   test('updateUser propaga error', async () => {
     const { updateUser } = await loadAccionesWithEnv();
     mockAxios.put.mockRejectedValue(new Error('put-fail'));
 
-    await expect(updateUser(1, {})).rejects.toThrow('put-fail');
+    await expect(updateUser(1, { version: 1 })).rejects.toThrow('put-fail');
+  });
+
+  test('updateUser sin version usa el ETag de getUserById', async () => {
+    localStorage.setItem('token', 'tok4');
+    const { getUserById, updateUser } = await loadAccionesWithEnv();
+    mockAxios.get.mockResolvedValue({ data: { id: 9 }, headers: { etag: '"7"' } });
+    mockAxios.put.mockResolvedValue({ data: {} });
+
+    await getUserById(9);
+    await updateUser(9, { nombre: 'n' });
+
+    expect(mockAxios.put).toHaveBeenCalledWith(
+      '/users',
+      expect.anything(),
+      expect.objectContaining({
+        headers: expect.objectContaining({ 'If-Match': '"7"' }),
+      })
+    );
+  });
+
+  test('updateUser sin version ni lectura previa no envia el PUT', async () => {
+    const { updateUser } = await loadAccionesWithEnv();
+
+    await expect(updateUser(9, { nombre: 'n' })).rejects.toThrow(/Versión desconocida/);
+    expect(mockAxios.put).not.toHaveBeenCalled();
   });
 
   test('tokenId lanza si no hay token en localStorage', async () => {